@@ -0,0 +1,87 @@
+package hnsw
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"golang.org/x/exp/maps"
+)
+
+// WriteGraphML writes the structure of every layer as a directed GraphML
+// document, for analysis in tools like NetworkX or Gephi. Each node
+// carries the index of its top layer as "level" and each edge the layer
+// it belongs to as "layer". Vectors are not included.
+func (g *Graph[K]) WriteGraphML(w io.Writer) error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, xml.Header)
+	fmt.Fprintln(bw, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	fmt.Fprintln(bw, `  <key id="level" for="node" attr.name="level" attr.type="int"/>`)
+	fmt.Fprintln(bw, `  <key id="layer" for="edge" attr.name="layer" attr.type="int"/>`)
+	fmt.Fprintln(bw, `  <graph edgedefault="directed">`)
+
+	levels := map[K]int{}
+	for i, l := range g.layers {
+		for key := range l.nodes {
+			levels[key] = i
+		}
+	}
+	keys := maps.Keys(levels)
+	slices.SortFunc(keys, keyOrder[K]())
+	for _, key := range keys {
+		fmt.Fprintf(bw, "    <node id=\"%s\"><data key=\"level\">%d</data></node>\n", graphMLEscape(key), levels[key])
+	}
+
+	for i, l := range g.layers {
+		keys := maps.Keys(l.nodes)
+		slices.SortFunc(keys, keyOrder[K]())
+		for _, key := range keys {
+			for _, n := range l.nodes[key].neighborKeys() {
+				fmt.Fprintf(bw, "    <edge source=\"%s\" target=\"%s\"><data key=\"layer\">%d</data></edge>\n",
+					graphMLEscape(key), graphMLEscape(n), i)
+			}
+		}
+	}
+
+	fmt.Fprintln(bw, "  </graph>")
+	fmt.Fprintln(bw, "</graphml>")
+	return bw.Flush()
+}
+
+// graphMLEscape formats key for use in an XML attribute.
+func graphMLEscape(key any) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(fmt.Sprint(key)))
+	return sb.String()
+}
+
+// WriteEdgeList writes the edges of a layer as CSV with a
+// "source,target" header, one directed edge per row. Layer 0 is the base
+// layer, which holds every node.
+func (g *Graph[K]) WriteEdgeList(w io.Writer, layer int) error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if layer < 0 || layer >= len(g.layers) {
+		return fmt.Errorf("layer %d out of range [0, %d)", layer, len(g.layers))
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"source", "target"})
+	keys := maps.Keys(g.layers[layer].nodes)
+	slices.SortFunc(keys, keyOrder[K]())
+	for _, key := range keys {
+		for _, n := range g.layers[layer].nodes[key].neighborKeys() {
+			cw.Write([]string{fmt.Sprint(key), fmt.Sprint(n)})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,49 @@
+package hnsw
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_WriteGraphML(t *testing.T) {
+	g := treeGraph()
+
+	var buf bytes.Buffer
+	require.NoError(t, g.WriteGraphML(&buf))
+
+	var doc struct {
+		Graph struct {
+			Nodes []struct {
+				ID string `xml:"id,attr"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+				Layer  int    `xml:"data"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	require.Len(t, doc.Graph.Nodes, 6)
+	require.Len(t, doc.Graph.Edges, 10)
+	require.Equal(t, "0", doc.Graph.Edges[0].Source)
+	require.Equal(t, "1", doc.Graph.Edges[0].Target)
+}
+
+func TestGraph_WriteEdgeList(t *testing.T) {
+	g := treeGraph()
+
+	var buf bytes.Buffer
+	require.NoError(t, g.WriteEdgeList(&buf, 0))
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 11)
+	require.Equal(t, []string{"source", "target"}, rows[0])
+	require.Equal(t, []string{"0", "1"}, rows[1])
+
+	require.Error(t, g.WriteEdgeList(&buf, 1))
+}
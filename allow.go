@@ -0,0 +1,25 @@
+package hnsw
+
+// Allowlist is a set of keys a search is restricted to. It is
+// implemented by AllowSet; bitmaps such as roaring bitmaps can be
+// adapted with a small wrapper.
+type Allowlist[K comparable] interface {
+	Contains(key K) bool
+}
+
+// AllowSet is an Allowlist backed by a map.
+type AllowSet[K comparable] map[K]struct{}
+
+// Contains reports whether key is in the set.
+func (s AllowSet[K]) Contains(key K) bool {
+	_, ok := s[key]
+	return ok
+}
+
+// allow returns the predicate of opts.Allow, or nil if it is not set.
+func (opts SearchOptions[K]) allow() func(K) bool {
+	if opts.Allow == nil {
+		return nil
+	}
+	return opts.Allow.Contains
+}
@@ -0,0 +1,41 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_SearchAllow(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 1000; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+
+	t.Run("Small", func(t *testing.T) {
+		// 2% of the nodes: scanned exhaustively.
+		allow := AllowSet[int]{}
+		for i := 0; i < 1000; i += 50 {
+			allow[i] = struct{}{}
+		}
+		results, err := g.SearchWithOptions(Vector{510}, 3, SearchOptions[int]{Allow: allow})
+		require.NoError(t, err)
+		require.Equal(t, []int{500, 550, 450}, keysOf(results))
+	})
+
+	t.Run("Large", func(t *testing.T) {
+		allow := AllowSet[int]{}
+		for i := 0; i < 1000; i += 4 {
+			allow[i] = struct{}{}
+		}
+		require.Greater(t, len(allow), g.EfSearch*g.M)
+
+		results, err := g.SearchWithOptions(Vector{510}, 3, SearchOptions[int]{Allow: allow})
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		for _, r := range results {
+			require.Contains(t, allow, r.Key)
+			require.Less(t, r.Distance, float32(20))
+		}
+	})
+}
@@ -1,12 +1,18 @@
 package hnsw
 
-import "cmp"
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"golang.org/x/exp/maps"
+)
 
 // Analyzer is a struct that holds a graph and provides
 // methods for analyzing it. It offers no compatibility guarantee
 // as the methods of measuring the graph's health with change
 // with the implementation.
-type Analyzer[K cmp.Ordered] struct {
+type Analyzer[K comparable] struct {
 	Graph *Graph[K]
 }
 
@@ -42,3 +48,272 @@ func (a *Analyzer[T]) Topography() []int {
 	}
 	return topography
 }
+
+// normBuckets is the number of buckets in VectorAudit.NormHistogram.
+const normBuckets = 10
+
+// VectorAudit summarizes the health of the vectors stored in a graph.
+type VectorAudit[K comparable] struct {
+	// Vectors is the number of vectors audited.
+	Vectors int
+
+	// Dims is the most common dimensionality among the vectors.
+	Dims int
+
+	// DimensionMismatches are the keys of vectors that don't have Dims
+	// dimensions.
+	DimensionMismatches []K
+
+	// ZeroVectors are the keys of vectors with a norm of zero. Their
+	// cosine distance to everything is NaN.
+	ZeroVectors []K
+
+	// NonFinite are the keys of vectors containing NaN or Inf.
+	NonFinite []K
+
+	// Unnormalized is the number of finite vectors whose norm differs from
+	// 1 by more than 1e-3.
+	Unnormalized int
+
+	// MinNorm, MaxNorm and MeanNorm describe the norms of the finite
+	// vectors.
+	MinNorm, MaxNorm, MeanNorm float64
+
+	// NormHistogram counts the norms of the finite vectors in equal-width
+	// buckets spanning [MinNorm, MaxNorm].
+	NormHistogram []int
+}
+
+// AuditVectors scans the vectors in the base layer and reports on their
+// norms and anomalies. Zero vectors, non-finite values and inconsistent
+// dimensions silently destroy the quality of cosine distance.
+func (a *Analyzer[K]) AuditVectors() VectorAudit[K] {
+	var (
+		audit     VectorAudit[K]
+		dimCounts = make(map[int]int)
+		norms     = make(map[K]float64)
+	)
+	if len(a.Graph.layers) == 0 {
+		return audit
+	}
+
+	for key, node := range a.Graph.layers[0].nodes {
+		audit.Vectors++
+		dimCounts[len(node.Value)]++
+
+		var sum float64
+		finite := true
+		for _, v := range node.Value {
+			f := float64(v)
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				finite = false
+				break
+			}
+			sum += f * f
+		}
+		if !finite {
+			audit.NonFinite = append(audit.NonFinite, key)
+			continue
+		}
+		if sum == 0 {
+			audit.ZeroVectors = append(audit.ZeroVectors, key)
+		}
+		norms[key] = math.Sqrt(sum)
+	}
+
+	for dims, count := range dimCounts {
+		if count > dimCounts[audit.Dims] || (count == dimCounts[audit.Dims] && dims > audit.Dims) {
+			audit.Dims = dims
+		}
+	}
+	for key, node := range a.Graph.layers[0].nodes {
+		if len(node.Value) != audit.Dims {
+			audit.DimensionMismatches = append(audit.DimensionMismatches, key)
+		}
+	}
+
+	if len(norms) > 0 {
+		audit.MinNorm = math.Inf(1)
+		audit.MaxNorm = math.Inf(-1)
+		for _, norm := range norms {
+			audit.MinNorm = min(audit.MinNorm, norm)
+			audit.MaxNorm = max(audit.MaxNorm, norm)
+			audit.MeanNorm += norm
+			if math.Abs(norm-1) > 1e-3 {
+				audit.Unnormalized++
+			}
+		}
+		audit.MeanNorm /= float64(len(norms))
+
+		audit.NormHistogram = make([]int, normBuckets)
+		width := (audit.MaxNorm - audit.MinNorm) / normBuckets
+		for _, norm := range norms {
+			bucket := 0
+			if width > 0 {
+				bucket = min(int((norm-audit.MinNorm)/width), normBuckets-1)
+			}
+			audit.NormHistogram[bucket]++
+		}
+	}
+
+	slices.SortFunc(audit.DimensionMismatches, keyOrder[K]())
+	slices.SortFunc(audit.ZeroVectors, keyOrder[K]())
+	slices.SortFunc(audit.NonFinite, keyOrder[K]())
+	return audit
+}
+
+// distanceBuckets is the number of buckets in
+// DistanceDistribution.Histogram.
+const distanceBuckets = 20
+
+// DistanceDistribution describes the distances between randomly sampled
+// pairs of stored vectors under the graph's distance function.
+type DistanceDistribution struct {
+	// Samples holds the sampled distances in ascending order.
+	Samples []float32
+
+	// NaN is the number of sampled pairs whose distance was NaN.
+	NaN int
+
+	Min, Max, Mean float32
+
+	// Histogram counts the samples in equal-width buckets spanning
+	// [Min, Max].
+	Histogram []int
+}
+
+// Percentile returns the distance below which the fraction p of the
+// samples fall, for p in [0, 1].
+func (d *DistanceDistribution) Percentile(p float64) float32 {
+	if len(d.Samples) == 0 {
+		return float32(math.NaN())
+	}
+	i := int(math.Round(p * float64(len(d.Samples)-1)))
+	i = max(0, min(i, len(d.Samples)-1))
+	return d.Samples[i]
+}
+
+// DistanceDistribution samples up to pairs random pairs of stored vectors
+// and reports the distribution of their distances. It helps with choosing
+// radius thresholds and score cutoffs for the configured metric.
+func (a *Analyzer[K]) DistanceDistribution(pairs int) (*DistanceDistribution, error) {
+	var dist DistanceDistribution
+	if len(a.Graph.layers) == 0 || a.Graph.layers[0].size() < 2 {
+		return &dist, nil
+	}
+
+	// Sort the keys so that a deterministic Rng yields a deterministic
+	// sample.
+	nodes := a.Graph.layers[0].nodes
+	keys := maps.Keys(nodes)
+	slices.SortFunc(keys, keyOrder[K]())
+
+	rng := a.Graph.Rng
+	if rng == nil {
+		rng = defaultRand()
+	}
+
+	for i := 0; i < pairs; i++ {
+		x := rng.Intn(len(keys))
+		y := rng.Intn(len(keys) - 1)
+		if y >= x {
+			y++
+		}
+		d, err := a.Graph.Distance(nodes[keys[x]].Value, nodes[keys[y]].Value)
+		if err != nil {
+			return nil, err
+		}
+		if math.IsNaN(float64(d)) {
+			dist.NaN++
+			continue
+		}
+		dist.Samples = append(dist.Samples, d)
+	}
+	if len(dist.Samples) == 0 {
+		return &dist, nil
+	}
+
+	slices.Sort(dist.Samples)
+	dist.Min = dist.Samples[0]
+	dist.Max = dist.Samples[len(dist.Samples)-1]
+
+	var sum float64
+	dist.Histogram = make([]int, distanceBuckets)
+	width := (dist.Max - dist.Min) / distanceBuckets
+	for _, d := range dist.Samples {
+		sum += float64(d)
+		bucket := 0
+		if width > 0 {
+			bucket = min(int((d-dist.Min)/width), distanceBuckets-1)
+		}
+		dist.Histogram[bucket]++
+	}
+	dist.Mean = float32(sum / float64(len(dist.Samples)))
+
+	return &dist, nil
+}
+
+// DriftReport compares stored vectors against freshly computed ones,
+// e.g. after upgrading the embedding model.
+type DriftReport[K comparable] struct {
+	// Drift is the distance between the stored and the fresh vector of
+	// each compared key.
+	Drift map[K]float32
+
+	// Missing are sampled keys that aren't in the graph.
+	Missing []K
+
+	// Incomparable are sampled keys whose fresh vector can't be compared
+	// to the stored one, e.g. because the dimensionality changed.
+	Incomparable []K
+
+	// Mean and Max summarize Drift.
+	Mean, Max float32
+
+	// Priority lists the compared keys from most to least drifted: the
+	// order in which they should be re-embedded.
+	Priority []K
+}
+
+// DriftReport measures how far the stored vectors have drifted from the
+// fresh vectors in sample, keyed by node key.
+func (a *Analyzer[K]) DriftReport(sample map[K]Vector) *DriftReport[K] {
+	report := &DriftReport[K]{Drift: make(map[K]float32, len(sample))}
+
+	var nodes map[K]*layerNode[K]
+	if len(a.Graph.layers) > 0 {
+		nodes = a.Graph.layers[0].nodes
+	}
+
+	var sum float64
+	for key, fresh := range sample {
+		node, ok := nodes[key]
+		if !ok {
+			report.Missing = append(report.Missing, key)
+			continue
+		}
+		d, err := a.Graph.Distance(node.Value, fresh)
+		if err != nil || math.IsNaN(float64(d)) {
+			report.Incomparable = append(report.Incomparable, key)
+			continue
+		}
+		report.Drift[key] = d
+		report.Priority = append(report.Priority, key)
+		sum += float64(d)
+		report.Max = max(report.Max, d)
+	}
+	if len(report.Drift) > 0 {
+		report.Mean = float32(sum / float64(len(report.Drift)))
+	}
+
+	slices.SortFunc(report.Missing, keyOrder[K]())
+	slices.SortFunc(report.Incomparable, keyOrder[K]())
+	compareKeys := keyOrder[K]()
+	slices.SortFunc(report.Priority, func(a, b K) int {
+		if c := cmp.Compare(report.Drift[b], report.Drift[a]); c != 0 {
+			return c
+		}
+		return compareKeys(a, b)
+	})
+	return report
+}
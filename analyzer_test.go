@@ -0,0 +1,83 @@
+package hnsw
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzer_AuditVectors(t *testing.T) {
+	g := newTestGraph[int]()
+	g.Add(
+		MakeNode(1, Vector{1, 0}),
+		MakeNode(2, Vector{0, 2}),
+		MakeNode(3, Vector{0, 0}),
+		MakeNode(4, Vector{float32(math.NaN()), 1}),
+		MakeNode(5, Vector{1, 1}),
+	)
+	// Simulate a vector of the wrong size slipping in.
+	g.layers[0].nodes[5].Value = Vector{1, 0, 0}
+
+	audit := (&Analyzer[int]{Graph: g}).AuditVectors()
+	require.Equal(t, 5, audit.Vectors)
+	require.Equal(t, 2, audit.Dims)
+	require.Equal(t, []int{5}, audit.DimensionMismatches)
+	require.Equal(t, []int{3}, audit.ZeroVectors)
+	require.Equal(t, []int{4}, audit.NonFinite)
+	require.Equal(t, 2, audit.Unnormalized)
+	require.Equal(t, 0.0, audit.MinNorm)
+	require.Equal(t, 2.0, audit.MaxNorm)
+	require.Equal(t, 1.0, audit.MeanNorm)
+	require.Equal(t, []int{1, 0, 0, 0, 0, 2, 0, 0, 0, 1}, audit.NormHistogram)
+}
+
+func TestAnalyzer_DistanceDistribution(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 100; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+
+	dist, err := (&Analyzer[int]{Graph: g}).DistanceDistribution(1000)
+	require.NoError(t, err)
+	require.Len(t, dist.Samples, 1000)
+	require.Zero(t, dist.NaN)
+	require.GreaterOrEqual(t, dist.Min, float32(1))
+	require.LessOrEqual(t, dist.Max, float32(99))
+	require.True(t, slices.IsSorted(dist.Samples))
+
+	var total int
+	for _, n := range dist.Histogram {
+		total += n
+	}
+	require.Equal(t, 1000, total)
+
+	// Distances between uniformly spread points on a line
+	// skew small: the median is about a third of the range.
+	require.InDelta(t, 29, dist.Percentile(0.5), 5)
+	require.Equal(t, dist.Min, dist.Percentile(0))
+	require.Equal(t, dist.Max, dist.Percentile(1))
+}
+
+func TestAnalyzer_DriftReport(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 10; i++ {
+		g.Add(MakeNode(i, Vector{float32(i), 0}))
+	}
+
+	report := (&Analyzer[int]{Graph: g}).DriftReport(map[int]Vector{
+		1:  {1, 0},
+		2:  {2, 3},
+		3:  {3, 1},
+		4:  {4, 0, 0},
+		42: {0, 0},
+	})
+
+	require.Equal(t, map[int]float32{1: 0, 2: 3, 3: 1}, report.Drift)
+	require.Equal(t, []int{42}, report.Missing)
+	require.Equal(t, []int{4}, report.Incomparable)
+	require.Equal(t, []int{2, 3, 1}, report.Priority)
+	require.Equal(t, float32(3), report.Max)
+	require.InDelta(t, 4.0/3, report.Mean, 1e-6)
+}
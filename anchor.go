@@ -0,0 +1,132 @@
+package hnsw
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// Neighborhood is the surroundings of an anchor node that
+// SearchNeighborhood is constrained to. At least one of Hops and Radius
+// must be set; if both are, a node must satisfy both.
+type Neighborhood[K comparable] struct {
+	// Anchor is the key of the node the neighborhood is centered on.
+	Anchor K
+
+	// Hops limits the neighborhood to nodes at most Hops edges away from
+	// the anchor in the base layer.
+	Hops int
+
+	// Radius limits the neighborhood to nodes within Radius of the
+	// anchor's vector.
+	Radius float32
+}
+
+// SearchNeighborhood finds the k nodes nearest to near among the nodes
+// in the neighborhood of an anchor, e.g. "more items like X, but related
+// to Y". Results are sorted closest first. The anchor itself is part of
+// its neighborhood.
+//
+// The neighborhood is collected first and then ranked exhaustively, so
+// the cost grows with its size rather than with the graph.
+func (g *Graph[K]) SearchNeighborhood(near Vector, k int, nb Neighborhood[K]) ([]SearchResultNode[K], error) {
+	if nb.Hops <= 0 && nb.Radius <= 0 {
+		return nil, fmt.Errorf("neighborhood needs Hops or Radius")
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	g.assertDims(near)
+
+	if len(g.layers) == 0 {
+		return nil, fmt.Errorf("graph is empty")
+	}
+	anchor, ok := g.layers[0].nodes[nb.Anchor]
+	if !ok || g.isTombstone(nb.Anchor) {
+		return nil, fmt.Errorf("anchor %v not found", nb.Anchor)
+	}
+
+	var members []*layerNode[K]
+	if nb.Hops > 0 {
+		members = anchor.withinHops(nb.Hops)
+	}
+	if nb.Radius > 0 {
+		score := distanceTo[K](anchor.Value, g.Distance)
+		if members == nil {
+			matches, err := anchor.searchRadius(nb.Radius, g.EfSearch, score)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range matches {
+				members = append(members, m.node)
+			}
+		} else {
+			var err error
+			members, err = filterNodes(members, func(n *layerNode[K]) (bool, error) {
+				d, err := score(n)
+				return d <= nb.Radius, err
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	score := distanceTo[K](near, g.Distance)
+	ranked := make([]SearchResultNode[K], 0, len(members))
+	for _, n := range members {
+		if g.isTombstone(n.Key) {
+			continue
+		}
+		d, err := score(n)
+		if err != nil {
+			return nil, err
+		}
+		ranked = append(ranked, SearchResultNode[K]{Node: n.Node, Distance: d})
+	}
+	slices.SortFunc(ranked, func(a, b SearchResultNode[K]) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+	if len(ranked) > k {
+		ranked = ranked[:k]
+	}
+	g.attachPayloads(ranked)
+	return ranked, nil
+}
+
+// withinHops returns n and every node reachable from it in at most hops
+// edges.
+func (n *layerNode[K]) withinHops(hops int) []*layerNode[K] {
+	visited := map[K]bool{n.Key: true}
+	frontier := []*layerNode[K]{n}
+	out := []*layerNode[K]{n}
+	for ; hops > 0 && len(frontier) > 0; hops-- {
+		var next []*layerNode[K]
+		for _, node := range frontier {
+			for _, neighbor := range liveNeighbors(node) {
+				if visited[neighbor.Key] {
+					continue
+				}
+				visited[neighbor.Key] = true
+				next = append(next, neighbor)
+			}
+		}
+		out = append(out, next...)
+		frontier = next
+	}
+	return out
+}
+
+func filterNodes[K comparable](nodes []*layerNode[K], keep func(*layerNode[K]) (bool, error)) ([]*layerNode[K], error) {
+	out := nodes[:0]
+	for _, n := range nodes {
+		ok, err := keep(n)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,49 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_SearchNeighborhood(t *testing.T) {
+	g := newTestGraph[int]()
+	g.M = 16
+	for i := 0; i < 16; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+
+	t.Run("Radius", func(t *testing.T) {
+		results, err := g.SearchNeighborhood(Vector{10}, 2, Neighborhood[int]{Anchor: 3, Radius: 2})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		require.Equal(t, 5, results[0].Key)
+		require.Equal(t, 4, results[1].Key)
+	})
+
+	t.Run("Hops", func(t *testing.T) {
+		results, err := g.SearchNeighborhood(Vector{10}, 16, Neighborhood[int]{Anchor: 3, Hops: 1})
+		require.NoError(t, err)
+		anchor := g.layers[0].nodes[3]
+		require.Len(t, results, len(anchor.neighbors)+1)
+		for _, r := range results {
+			if r.Key != 3 {
+				require.Contains(t, anchor.neighborKeys(), r.Key)
+			}
+		}
+	})
+
+	t.Run("HopsAndRadius", func(t *testing.T) {
+		results, err := g.SearchNeighborhood(Vector{0}, 16, Neighborhood[int]{Anchor: 3, Hops: 2, Radius: 1})
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		require.Equal(t, 2, results[0].Key)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := g.SearchNeighborhood(Vector{0}, 1, Neighborhood[int]{Anchor: 3})
+		require.Error(t, err)
+		_, err = g.SearchNeighborhood(Vector{0}, 1, Neighborhood[int]{Anchor: 99, Hops: 1})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,182 @@
+package hnsw
+
+import (
+	"math"
+
+	"github.com/viterin/vek/vek32"
+)
+
+const (
+	// anisotropicPasses bounds the coordinate descent passes over the
+	// subspaces when encoding with ProductQuantizer.AnisotropicWeight.
+	anisotropicPasses = 4
+
+	// anisotropicRounds is the number of rounds of anisotropic encoding
+	// and centroid refitting in ProductQuantizer.Train.
+	anisotropicRounds = 4
+)
+
+// encodeAnisotropic refines code, the nearest centroids of v, to minimize
+// the anisotropic loss |r|² + (w-1)(r·v)²/|v|² of the residual r = v -
+// Decode(code), where w is the AnisotropicWeight: the parallel error
+// weighs w times the orthogonal one. Each subspace in turn switches to the
+// centroid minimizing the loss of the whole vector, until none switches.
+func (p *ProductQuantizer) encodeAnisotropic(v Vector, code []byte) {
+	norm := vek32.Dot(v, v)
+	if norm == 0 {
+		// Every direction is parallel; code minimizes |r|² already.
+		return
+	}
+	eta := p.AnisotropicWeight - 1
+	r := vek32.Sub(v, p.Decode(code))
+	rr, rv := vek32.Dot(r, r), vek32.Dot(r, v)
+
+	for pass := 0; pass < anisotropicPasses; pass++ {
+		changed := false
+		for s := range code {
+			lo, hi := p.bounds(s)
+			n := hi - lo
+			vs, rs := v[lo:hi], r[lo:hi]
+			// The loss terms of the other subspaces.
+			restRR := rr - vek32.Dot(rs, rs)
+			restRV := rv - vek32.Dot(rs, vs)
+
+			best := int(code[s])
+			bestLoss := rr + eta*rv*rv/norm
+			centroids := p.centroids[s]
+			for c := 0; c*n < len(centroids); c++ {
+				centroid := centroids[c*n : (c+1)*n]
+				var sRR, sRV float32
+				for j, x := range vs {
+					d := x - centroid[j]
+					sRR += d * d
+					sRV += d * x
+				}
+				parallel := restRV + sRV
+				if loss := restRR + sRR + eta*parallel*parallel/norm; loss < bestLoss {
+					best, bestLoss = c, loss
+				}
+			}
+			if best == int(code[s]) {
+				continue
+			}
+			changed = true
+			code[s] = byte(best)
+			centroid := centroids[best*n : (best+1)*n]
+			for j, x := range vs {
+				rs[j] = x - centroid[j]
+			}
+			rr = restRR + vek32.Dot(rs, rs)
+			rv = restRV + vek32.Dot(rs, vs)
+		}
+		if !changed {
+			return
+		}
+	}
+}
+
+// refitAnisotropic encodes sample with the anisotropic loss, then moves
+// every centroid to where it minimizes the loss of the vectors encoded
+// with it, given their other subspaces.
+//
+// With x the part of a vector v in the subspace, and a the residual of v
+// with that part left unquantized, the loss of centroid c is
+// |x-c|² + (w-1)(a·v - c·x)²/|v|² plus a constant, minimized by solving
+//
+//	Σ (I + (w-1)xxᵀ/|v|²) c = Σ x + (w-1)(a·v)x/|v|²
+//
+// over the vectors encoded with c.
+func (p *ProductQuantizer) refitAnisotropic(sample []Vector) {
+	eta := float64(p.AnisotropicWeight - 1)
+	codes := make([][]byte, len(sample))
+	// parallel[i] is r·v for the residual r of sample[i].
+	parallel := make([]float32, len(sample))
+	for i, v := range sample {
+		codes[i] = p.encode(v)
+		parallel[i] = vek32.Dot(vek32.Sub(v, p.Decode(codes[i])), v)
+	}
+
+	refitted := make([][]float32, len(p.centroids))
+	for s, centroids := range p.centroids {
+		lo, hi := p.bounds(s)
+		n := hi - lo
+		k := len(centroids) / n
+		lhs := make([]float64, k*n*n)
+		rhs := make([]float64, k*n)
+		counts := make([]int, k)
+		for i, v := range sample {
+			c := int(codes[i][s])
+			counts[c]++
+			x := v[lo:hi]
+			a, b := lhs[c*n*n:(c+1)*n*n], rhs[c*n:(c+1)*n]
+			var weight, av float64
+			if norm := float64(vek32.Dot(v, v)); norm > 0 {
+				weight = eta / norm
+				// a·v = r·v with the subspace's term r_s·x replaced by
+				// x·x.
+				rs := vek32.Sub(x, centroids[c*n:(c+1)*n])
+				av = float64(parallel[i] - vek32.Dot(rs, x) + vek32.Dot(x, x))
+			}
+			for j := range x {
+				a[j*n+j]++
+				for l := range x {
+					a[j*n+l] += weight * float64(x[j]) * float64(x[l])
+				}
+				b[j] += float64(x[j]) + weight*av*float64(x[j])
+			}
+		}
+
+		refitted[s] = append([]float32(nil), centroids...)
+		for c := 0; c < k; c++ {
+			if counts[c] == 0 {
+				continue
+			}
+			solution, ok := solveSPD(lhs[c*n*n:(c+1)*n*n], rhs[c*n:(c+1)*n])
+			if !ok {
+				continue
+			}
+			for j, x := range solution {
+				refitted[s][c*n+j] = float32(x)
+			}
+		}
+	}
+	p.centroids = refitted
+}
+
+// solveSPD solves ax = b, where a is a symmetric positive definite n×n
+// matrix in row-major order, by Cholesky decomposition. It reports false
+// if a isn't positive definite. a is overwritten.
+func solveSPD(a, b []float64) ([]float64, bool) {
+	n := len(b)
+	// Decompose a = LLᵀ, storing L in the lower triangle of a.
+	for j := 0; j < n; j++ {
+		for k := 0; k < j; k++ {
+			a[j*n+j] -= a[j*n+k] * a[j*n+k]
+		}
+		if a[j*n+j] <= 0 {
+			return nil, false
+		}
+		a[j*n+j] = math.Sqrt(a[j*n+j])
+		for i := j + 1; i < n; i++ {
+			for k := 0; k < j; k++ {
+				a[i*n+j] -= a[i*n+k] * a[j*n+k]
+			}
+			a[i*n+j] /= a[j*n+j]
+		}
+	}
+	// Solve Ly = b, then Lᵀx = y.
+	x := append([]float64(nil), b...)
+	for i := 0; i < n; i++ {
+		for k := 0; k < i; k++ {
+			x[i] -= a[i*n+k] * x[k]
+		}
+		x[i] /= a[i*n+i]
+	}
+	for i := n - 1; i >= 0; i-- {
+		for k := i + 1; k < n; k++ {
+			x[i] -= a[k*n+i] * x[k]
+		}
+		x[i] /= a[i*n+i]
+	}
+	return x, true
+}
@@ -0,0 +1,96 @@
+package hnsw
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductQuantizer_Anisotropic(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	vec := func() Vector {
+		v := make(Vector, 16)
+		for i := range v {
+			v[i] = float32(rng.NormFloat64())
+		}
+		return v
+	}
+	data := make([]Vector, 2000)
+	for i := range data {
+		data[i] = vec()
+	}
+	queries := make([]Vector, 50)
+	for i := range queries {
+		queries[i] = vec()
+	}
+
+	// recall is the share of the 10 largest inner products found in the 10
+	// largest approximate ones.
+	recall := func(pq *ProductQuantizer) float64 {
+		exact := NewBruteForce[int]()
+		exact.Distance = DotProductDistance
+		idx := &QuantizedIndex[int]{Quantizer: pq}
+		for i, v := range data {
+			exact.Add(MakeNode(i, v))
+			require.NoError(t, idx.Add(MakeNode(i, v)))
+		}
+		var found int
+		for _, q := range queries {
+			want, err := exact.Search(q, 10)
+			require.NoError(t, err)
+			got, err := idx.Search(q, 10)
+			require.NoError(t, err)
+			for _, w := range want {
+				for _, g := range got {
+					if g.Key == w.Key {
+						found++
+					}
+				}
+			}
+		}
+		return float64(found) / float64(10*len(queries))
+	}
+
+	plain := &ProductQuantizer{Subspaces: 4, Iterations: 10, Rng: rand.New(rand.NewSource(1)), InnerProduct: true}
+	require.NoError(t, plain.Train(data))
+	anisotropic := &ProductQuantizer{Subspaces: 4, Iterations: 10, Rng: rand.New(rand.NewSource(1)), InnerProduct: true, AnisotropicWeight: 4}
+	require.NoError(t, anisotropic.Train(data))
+
+	// The approximate distance is the negative inner product with the
+	// decoded vector.
+	code, err := anisotropic.Encode(data[0])
+	require.NoError(t, err)
+	distances, err := anisotropic.Distances(queries[0])
+	require.NoError(t, err)
+	want, _ := DotProductDistance(anisotropic.Decode(code), queries[0])
+	require.InDelta(t, want, distances(code), 1e-4)
+
+	plainRecall, anisotropicRecall := recall(plain), recall(anisotropic)
+	require.Greater(t, anisotropicRecall, plainRecall)
+
+	// The options survive a round trip.
+	state, err := anisotropic.MarshalBinary()
+	require.NoError(t, err)
+	var restored ProductQuantizer
+	require.NoError(t, restored.UnmarshalBinary(state))
+	require.True(t, restored.InnerProduct)
+	require.Equal(t, float32(4), restored.AnisotropicWeight)
+	code2, err := restored.Encode(data[0])
+	require.NoError(t, err)
+	require.Equal(t, code, code2)
+
+	// States encoded before the options were added decode without them.
+	var old ProductQuantizer
+	require.NoError(t, old.UnmarshalBinary(state[:len(state)-5]))
+	require.False(t, old.InnerProduct)
+	require.Zero(t, old.AnisotropicWeight)
+}
+
+func TestSolveSPD(t *testing.T) {
+	x, ok := solveSPD([]float64{4, 2, 2, 3}, []float64{2, 1})
+	require.True(t, ok)
+	require.InDeltaSlice(t, []float64{0.5, 0}, x, 1e-9)
+	_, ok = solveSPD([]float64{0, 0, 0, 1}, []float64{1, 1})
+	require.False(t, ok)
+}
@@ -0,0 +1,314 @@
+package hnsw
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// batchInsert is a node being inserted by AddBatch.
+type batchInsert[K comparable] struct {
+	node Node[K]
+	// level is the level the node was inserted at, or -1 if it wasn't
+	// linked as part of its chunk.
+	level int
+	// candidates are the neighbor candidates found in the graph, by layer.
+	candidates [][]searchCandidate[K]
+	// peers are the distances to the nodes before it in the chunk, which
+	// were not in the graph during the search.
+	peers []float32
+}
+
+// AddBatch inserts nodes like Add, but spreads the work over workers
+// goroutines; workers <= 0 means GOMAXPROCS.
+//
+// Nodes are inserted in chunks. The neighbors of the nodes of a chunk
+// are searched for in parallel, in the graph as it was before the chunk
+// and amongst each other; the chunk is then linked into the graph in a
+// short sequential step. Chunks are kept small relative to the graph, so
+// the result is about as good as inserting the nodes one by one.
+//
+// The searches only hold the read lock, so searches of the graph are
+// blocked while chunks are linked, not for the whole batch.
+func (g *Graph[K]) AddBatch(nodes []Node[K], workers int) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	for len(nodes) > 0 {
+		n, err := g.addBatchStep(nodes, workers)
+		if err != nil {
+			return err
+		}
+		nodes = nodes[n:]
+	}
+	return nil
+}
+
+// addBatchStep inserts a chunk from the start of nodes and returns its
+// size.
+func (g *Graph[K]) addBatchStep(nodes []Node[K], workers int) (int, error) {
+	g.mu.RLock()
+	if g.Distance == nil {
+		g.mu.RUnlock()
+		return 0, fmt.Errorf("(*Graph).Distance must be set")
+	}
+	// Inserting into a small graph is cheap, and parallel chunks would
+	// make up a large part of it. Hardened insertions draw from one
+	// random source, and duplicates must be checked against the nodes
+	// before them, so both happen in order.
+	serial := g.len() < 8*workers || g.Hardening != nil || g.DuplicateDistance > 0
+	size := min(max(g.len()/8, workers), 16*workers, len(nodes))
+	g.mu.RUnlock()
+
+	if serial {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		_, err := g.addUnique(context.Background(), nodes[0])
+		return 1, err
+	}
+	return size, g.insertChunk(nodes[:size], workers)
+}
+
+// insertChunk inserts nodes with parallel neighbor searches under the
+// read lock, then links them under the write lock.
+func (g *Graph[K]) insertChunk(nodes []Node[K], workers int) error {
+	chunk, later, err := g.searchChunk(nodes, workers)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	added := g.clock().UnixNano()
+	for i, b := range chunk {
+		// The graph may have changed since the search. A node added
+		// meanwhile is replaced the regular way.
+		if g.level(b.node.Key) >= 0 {
+			b.level = -1
+			later = append(later, b.node)
+			continue
+		}
+		level, err := g.randomLevel(b.node.Key)
+		if err != nil {
+			return err
+		}
+		b.level = level
+		for b.level >= len(g.layers) {
+			g.layers = append(g.layers, &layer[K]{})
+		}
+		delete(g.stale, b.node.Key)
+
+		for l := 0; l <= b.level; l++ {
+			layer := g.layers[l]
+			newNode := &layerNode[K]{Node: b.node, added: added}
+
+			var candidates []searchCandidate[K]
+			if l < len(b.candidates) {
+				// Skip candidates deleted since the search.
+				for _, c := range b.candidates[l] {
+					if !c.node.removed && layer.nodes[c.node.Key] == c.node {
+						candidates = append(candidates, c)
+					}
+				}
+			}
+			// Earlier nodes of the chunk were not in the graph during the
+			// search; consider them too.
+			for j, peer := range chunk[:i] {
+				if peer.level >= l {
+					candidates = append(candidates, searchCandidate[K]{
+						node: layer.nodes[peer.node.Key],
+						dist: b.peers[j],
+					})
+				}
+			}
+			slices.SortFunc(candidates, func(a, b searchCandidate[K]) int {
+				return cmp.Compare(a.dist, b.dist)
+			})
+			m := g.maxNeighbors(l)
+			candidates, err := g.selectNeighbors(b.node.Key, b.node.Value, candidates, m)
+			if err != nil {
+				return err
+			}
+
+			layer.add(newNode)
+			if l == 0 {
+				g.sketch.insert(b.node.Value)
+			}
+			for _, c := range candidates {
+				g.link(c.node, newNode, m)
+				g.link(newNode, c.node, m)
+			}
+		}
+		g.notifyAdd(b.node)
+	}
+
+	for _, node := range later {
+		level, err := g.randomLevel(node.Key)
+		if err != nil {
+			return err
+		}
+		if err := g.insert(node, level); err != nil {
+			return err
+		}
+		g.notifyAdd(node)
+	}
+	return nil
+}
+
+// searchChunk searches the neighbor candidates of nodes in parallel under
+// the read lock. Nodes that replace a node are returned in later, to be
+// inserted the regular way after the chunk.
+func (g *Graph[K]) searchChunk(nodes []Node[K], workers int) (chunk []*batchInsert[K], later []Node[K], err error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	dims := g.Dims()
+	chunk = make([]*batchInsert[K], 0, len(nodes))
+	seen := make(map[K]bool, len(nodes))
+	for _, node := range nodes {
+		if dims != 0 && len(node.Value) != dims {
+			return nil, nil, fmt.Errorf("embedding dimension mismatch for %v: %d != %d", node.Key, len(node.Value), dims)
+		}
+		// Replacing a node isolates the old one, which doesn't mix with
+		// searching the graph in parallel.
+		if seen[node.Key] || g.level(node.Key) >= 0 {
+			later = append(later, node)
+			continue
+		}
+		seen[node.Key] = true
+		chunk = append(chunk, &batchInsert[K]{node: node})
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+		next     = make(chan int)
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				b := chunk[i]
+				var err error
+				// The levels are drawn when linking; search every layer.
+				b.candidates, err = g.neighborhoods(b.node.Value, len(g.layers)-1)
+				b.peers = make([]float32, i)
+				for j, peer := range chunk[:i] {
+					if err != nil {
+						break
+					}
+					b.peers[j], err = g.Distance(b.node.Value, peer.node.Value)
+				}
+				if err != nil {
+					errMu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					errMu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range chunk {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+	return chunk, later, nil
+}
+
+// neighborhoods searches the graph for the neighbor candidates of vec in
+// every layer up to level, like insert does, without modifying the
+// graph. Layers that are empty or don't exist yet get no candidates.
+func (g *Graph[K]) neighborhoods(vec Vector, level int) ([][]searchCandidate[K], error) {
+	out := make([][]searchCandidate[K], level+1)
+	score := distanceTo[K](vec, g.Distance)
+
+	var elevator *K
+	for i := len(g.layers) - 1; i >= 0; i-- {
+		layer := g.layers[i]
+		searchPoint := layer.entry()
+		if elevator != nil {
+			searchPoint = layer.nodes[*elevator]
+		}
+		if searchPoint == nil {
+			continue
+		}
+
+		neighborhood, err := searchPoint.search(layerSearch[K]{
+			k:        g.candidates(i),
+			efSearch: g.EfConstruction,
+			score:    score,
+		})
+		if err != nil {
+			return nil, err
+		}
+		best := slices.MinFunc(neighborhood, func(a, b searchCandidate[K]) int {
+			return cmp.Compare(a.dist, b.dist)
+		})
+		elevator = ptr(best.node.Key)
+		if i <= level {
+			out[i] = neighborhood
+		}
+	}
+	return out, nil
+}
+
+// BatchSearch runs Search for each of queries and returns the results in
+// the same order. The queries are spread over GOMAXPROCS goroutines that
+// share one read lock, which saves the per-call overhead of many small
+// searches. It fails with the first error of any query.
+func (g *Graph[K]) BatchSearch(queries []Vector, k int) ([][]SearchResultNode[K], error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	out := make([][]SearchResultNode[K], len(queries))
+	workers := min(runtime.GOMAXPROCS(0), len(queries))
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+		next     = make(chan int)
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				var results []SearchResultNode[K]
+				err := g.assertQueryDims(queries[i])
+				if err == nil {
+					results, err = g.searchScore(context.Background(), distanceTo[K](queries[i], g.Distance), k, SearchOptions[K]{})
+				}
+				if err != nil {
+					errMu.Lock()
+					if firstErr == nil {
+						firstErr = fmt.Errorf("query %d: %w", i, err)
+					}
+					errMu.Unlock()
+					continue
+				}
+				g.recordHits(results)
+				out[i] = results
+			}
+		}()
+	}
+	for i := range queries {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return out, nil
+}
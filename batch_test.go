@@ -0,0 +1,133 @@
+package hnsw
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_AddBatch(t *testing.T) {
+	nodes := make([]Node[int], 2000)
+	for i := range nodes {
+		nodes[i] = MakeNode(i, randFloats(4))
+	}
+
+	g := newTestGraph[int]()
+	require.NoError(t, g.AddBatch(nodes, 4))
+	require.NoError(t, g.Verify())
+	require.Equal(t, len(nodes), g.Len())
+
+	// Compare with inserting one by one.
+	serial := newTestGraph[int]()
+	require.NoError(t, serial.Add(nodes...))
+
+	found := func(g *Graph[int]) int {
+		var n int
+		for _, node := range nodes {
+			results, err := g.Search(node.Value, 1)
+			require.NoError(t, err)
+			if len(results) == 1 && results[0].Key == node.Key {
+				n++
+			}
+		}
+		return n
+	}
+	require.GreaterOrEqual(t, found(g), found(serial)*9/10)
+
+	// Replacing nodes in a batch.
+	replaced := []Node[int]{MakeNode(0, randFloats(4)), MakeNode(0, randFloats(4))}
+	require.NoError(t, g.AddBatch(replaced, 4))
+	require.Equal(t, len(nodes), g.Len())
+	vec, _ := g.Lookup(0)
+	require.Equal(t, replaced[1].Value, vec)
+	require.NoError(t, g.Verify())
+}
+
+func TestGraph_AddBatchConcurrent(t *testing.T) {
+	nodes := make([]Node[int], 4000)
+	for i := range nodes {
+		nodes[i] = MakeNode(i, randFloats(4))
+	}
+	g := newTestGraph[int]()
+	require.NoError(t, g.AddBatch(nodes[:1000], 4))
+
+	// Searches, deletions and replacements proceed while the batch runs.
+	var (
+		wg       sync.WaitGroup
+		done     atomic.Bool
+		searches atomic.Int64
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; !done.Load(); i++ {
+			if _, err := g.Search(randFloats(4), 4); err != nil {
+				t.Error(err)
+				return
+			}
+			searches.Add(1)
+			if i%10 == 0 {
+				g.Delete(i % 1000)
+				g.Add(nodes[(i+500)%4000])
+			}
+		}
+	}()
+	require.NoError(t, g.AddBatch(nodes[1000:], 4))
+	done.Store(true)
+	wg.Wait()
+
+	require.Positive(t, searches.Load())
+	require.NoError(t, g.Verify())
+}
+
+func TestGraph_BatchSearch(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 500; i++ {
+		g.Add(MakeNode(i, randFloats(4)))
+	}
+
+	queries := make([]Vector, 50)
+	for i := range queries {
+		queries[i] = randFloats(4)
+	}
+	results, err := g.BatchSearch(queries, 5)
+	require.NoError(t, err)
+	require.Len(t, results, len(queries))
+	for i, q := range queries {
+		want, err := g.Search(q, 5)
+		require.NoError(t, err)
+		require.Equal(t, want, results[i])
+	}
+
+	_, err = g.BatchSearch([]Vector{randFloats(4), randFloats(3)}, 5)
+	require.Error(t, err)
+}
+
+func BenchmarkGraph_BatchSearch(b *testing.B) {
+	g := newTestGraph[int]()
+	for i := 0; i < 10000; i++ {
+		g.Add(MakeNode(i, randFloats(32)))
+	}
+	queries := make([]Vector, 256)
+	for i := range queries {
+		queries[i] = randFloats(32)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.BatchSearch(queries, 10)
+	}
+}
+
+func BenchmarkGraph_AddBatch(b *testing.B) {
+	nodes := make([]Node[int], 10000)
+	for i := range nodes {
+		nodes[i] = MakeNode(i, randFloats(256))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g := newTestGraph[int]()
+		g.AddBatch(nodes, 0)
+	}
+}
@@ -0,0 +1,144 @@
+package hnsw
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	"github.com/chewxy/math32"
+)
+
+// BinaryQuantizer is a Quantizer that keeps one bit per dimension: whether
+// the value is above the dimension's mean in the training sample. A
+// vector of d float32s is compressed 32x, to d/8 bytes, and codes are
+// compared by Hamming distance, a few popcounts per vector.
+//
+// Binary codes only roughly order vectors, so use them as a first stage:
+// set QuantizedIndex.Rerank to a few times k, and rescore that many
+// candidates with their full-precision vectors from
+// QuantizedIndex.Originals. This works best for high-dimensional,
+// normalized embeddings.
+//
+// QuantizedIndex compares the query with every code, so Hamming distances
+// speed up a flat scan; they are not used to traverse a graph.
+type BinaryQuantizer struct {
+	dims int
+	// threshold is the mean of each dimension, and spread the mean
+	// absolute deviation from it, used by Decode.
+	threshold, spread []float32
+}
+
+var _ Quantizer = (*BinaryQuantizer)(nil)
+
+// Train learns the mean and spread of every dimension in sample.
+func (b *BinaryQuantizer) Train(sample []Vector) error {
+	if len(sample) == 0 {
+		return fmt.Errorf("empty sample")
+	}
+	dims := len(sample[0])
+	for _, v := range sample {
+		if len(v) != dims {
+			return fmt.Errorf("embedding dimension mismatch: %d != %d", dims, len(v))
+		}
+	}
+
+	n := float32(len(sample))
+	threshold := make([]float32, dims)
+	spread := make([]float32, dims)
+	for _, v := range sample {
+		for d, x := range v {
+			threshold[d] += x / n
+		}
+	}
+	for _, v := range sample {
+		for d, x := range v {
+			spread[d] += math32.Abs(x-threshold[d]) / n
+		}
+	}
+	b.dims, b.threshold, b.spread = dims, threshold, spread
+	return nil
+}
+
+// Encode sets bit d of the code if dimension d of v is above its mean.
+// The code is padded to whole 64-bit words.
+func (b *BinaryQuantizer) Encode(v Vector) ([]byte, error) {
+	if b.dims == 0 {
+		return nil, fmt.Errorf("binary quantizer is not trained")
+	}
+	if len(v) != b.dims {
+		return nil, fmt.Errorf("embedding dimension mismatch: %d != %d", b.dims, len(v))
+	}
+	code := make([]byte, (b.dims+63)/64*8)
+	for d, x := range v {
+		if x > b.threshold[d] {
+			code[d/8] |= 1 << (d % 8)
+		}
+	}
+	return code, nil
+}
+
+// Decode returns, for each dimension, its mean plus or minus its spread.
+func (b *BinaryQuantizer) Decode(code []byte) Vector {
+	v := make(Vector, b.dims)
+	for d := range v {
+		if code[d/8]&(1<<(d%8)) != 0 {
+			v[d] = b.threshold[d] + b.spread[d]
+		} else {
+			v[d] = b.threshold[d] - b.spread[d]
+		}
+	}
+	return v
+}
+
+// hamming returns the number of bits that differ between a and b, which
+// have the same length, a multiple of 8.
+func hamming(a, b []byte) int {
+	var n int
+	for i := 0; i < len(a); i += 8 {
+		n += bits.OnesCount64(binary.LittleEndian.Uint64(a[i:]) ^ binary.LittleEndian.Uint64(b[i:]))
+	}
+	return n
+}
+
+// Distances encodes q and returns the Hamming distance from it to codes.
+// The distances are bit counts, not approximations of the distance
+// between the vectors; they only serve to rank codes.
+func (b *BinaryQuantizer) Distances(q Vector) (func(code []byte) float32, error) {
+	qcode, err := b.Encode(q)
+	if err != nil {
+		return nil, err
+	}
+	return func(code []byte) float32 {
+		return float32(hamming(qcode, code))
+	}, nil
+}
+
+// MarshalBinary encodes the trained state of the quantizer.
+func (b *BinaryQuantizer) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := multiBinaryWrite(&buf, b.dims, b.threshold, b.spread)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary restores a state encoded by MarshalBinary.
+func (b *BinaryQuantizer) UnmarshalBinary(data []byte) error {
+	r := bufio.NewReader(bytes.NewReader(data))
+	var (
+		dims              int
+		threshold, spread []float32
+	)
+	_, err := multiBinaryRead(r, &dims, &threshold, &spread)
+	if err != nil {
+		return err
+	}
+	if len(threshold) != dims || len(spread) != dims {
+		return fmt.Errorf("invalid binary quantizer state")
+	}
+	b.dims, b.threshold, b.spread = dims, threshold, spread
+	return nil
+}
@@ -0,0 +1,72 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryQuantizer(t *testing.T) {
+	vecs := make(map[int]Vector)
+	sample := make([]Vector, 0, 2000)
+	for i := 0; i < 2000; i++ {
+		vecs[i] = unitFloats(256)
+		sample = append(sample, vecs[i])
+	}
+
+	bq := &BinaryQuantizer{}
+	_, err := bq.Encode(vecs[0])
+	require.Error(t, err)
+	require.NoError(t, bq.Train(sample))
+
+	code, err := bq.Encode(vecs[0])
+	require.NoError(t, err)
+	require.Len(t, code, 32)
+	_, err = bq.Encode(Vector{1})
+	require.Error(t, err)
+	require.Len(t, bq.Decode(code), 256)
+
+	distances, err := bq.Distances(vecs[0])
+	require.NoError(t, err)
+	require.Zero(t, distances(code))
+
+	state, err := bq.MarshalBinary()
+	require.NoError(t, err)
+	var bq2 BinaryQuantizer
+	require.NoError(t, bq2.UnmarshalBinary(state))
+	code2, err := bq2.Encode(vecs[0])
+	require.NoError(t, err)
+	require.Equal(t, code, code2)
+
+	// Rescoring the Hamming candidates recovers most of the exact
+	// neighbors.
+	idx := &QuantizedIndex[int]{
+		Quantizer: bq,
+		Rerank:    100,
+		Originals: func(key int) (Vector, bool) {
+			vec, ok := vecs[key]
+			return vec, ok
+		},
+	}
+	exact := NewBruteForce[int]()
+	exact.Distance = EuclideanDistance
+	for i := 0; i < 2000; i++ {
+		require.NoError(t, idx.Add(MakeNode(i, vecs[i])))
+		exact.Add(MakeNode(i, vecs[i]))
+	}
+	var found int
+	for i := 0; i < 50; i++ {
+		q := unitFloats(256)
+		want, _ := exact.Search(q, 10)
+		got, err := idx.Search(q, 10)
+		require.NoError(t, err)
+		for _, w := range want {
+			for _, g := range got {
+				if g.Key == w.Key {
+					found++
+				}
+			}
+		}
+	}
+	require.Greater(t, found, 350)
+}
@@ -0,0 +1,182 @@
+package hnsw
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// BruteForce is an exact index that compares queries with every stored
+// vector. Searches take time proportional to the number of vectors, but
+// there is no graph to build or keep in memory, so it is the better
+// choice for small collections, up to a few thousand vectors. It is also
+// the ground truth when measuring the recall of a Graph.
+//
+// The zero value is not usable; create one with NewBruteForce.
+type BruteForce[K comparable] struct {
+	// Distance is the distance function used to compare vectors.
+	Distance DistanceFunc
+
+	mu   sync.RWMutex
+	vecs map[K]Vector
+}
+
+var _ Index[int] = (*BruteForce[int])(nil)
+
+// NewBruteForce returns a new exact index with the cosine distance, like
+// NewGraph.
+func NewBruteForce[K comparable]() *BruteForce[K] {
+	return &BruteForce[K]{
+		Distance: CosineDistance,
+		vecs:     make(map[K]Vector),
+	}
+}
+
+// dims returns the dimensionality of the stored vectors, or 0 if there
+// are none. The caller must hold the read lock.
+func (b *BruteForce[K]) dims() int {
+	for _, vec := range b.vecs {
+		return len(vec)
+	}
+	return 0
+}
+
+// Add inserts nodes, replacing nodes with the same key.
+func (b *BruteForce[K]) Add(nodes ...Node[K]) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, n := range nodes {
+		if dims := b.dims(); dims != 0 && len(n.Value) != dims {
+			return fmt.Errorf("embedding dimension mismatch: %d != %d", dims, len(n.Value))
+		}
+		b.vecs[n.Key] = n.Value
+	}
+	return nil
+}
+
+// Search returns the k nodes nearest to near, closest first. Nodes at
+// the same distance are ordered by key.
+func (b *BruteForce[K]) Search(near Vector, k int) ([]SearchResultNode[K], error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.Distance == nil {
+		return nil, fmt.Errorf("(*BruteForce).Distance must be set")
+	}
+
+	out := make([]SearchResultNode[K], 0, len(b.vecs))
+	for key, vec := range b.vecs {
+		dist, err := b.Distance(vec, near)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, SearchResultNode[K]{Node: MakeNode(key, vec), Distance: dist})
+	}
+	compareKeys := keyOrder[K]()
+	slices.SortFunc(out, func(a, b SearchResultNode[K]) int {
+		if c := cmp.Compare(a.Distance, b.Distance); c != 0 {
+			return c
+		}
+		return compareKeys(a.Key, b.Key)
+	})
+	return out[:min(k, len(out))], nil
+}
+
+// Delete removes the node with the given key and reports whether it
+// existed.
+func (b *BruteForce[K]) Delete(key K) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.vecs[key]
+	delete(b.vecs, key)
+	return ok
+}
+
+// Lookup returns the vector with the given key.
+func (b *BruteForce[K]) Lookup(key K) (Vector, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	vec, ok := b.vecs[key]
+	return vec, ok
+}
+
+// Len returns the number of nodes.
+func (b *BruteForce[K]) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.vecs)
+}
+
+// bruteForceVersion is the version of the encoding of BruteForce.Export.
+const bruteForceVersion = 1
+
+// Export writes the index to w, ordered by key, to be read back with
+// Import. The distance function must be registered with
+// RegisterDistanceFunc.
+//
+// K must be encodable like the keys of Graph.Export.
+func (b *BruteForce[K]) Export(w io.Writer) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	name, ok := distanceFuncToName(b.Distance)
+	if !ok {
+		return fmt.Errorf("distance function %v must be registered with RegisterDistanceFunc", b.Distance)
+	}
+	_, err := multiBinaryWrite(w, bruteForceVersion, name, len(b.vecs))
+	if err != nil {
+		return fmt.Errorf("encode header: %w", err)
+	}
+	keys := make([]K, 0, len(b.vecs))
+	for key := range b.vecs {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, keyOrder[K]())
+	for _, key := range keys {
+		_, err = multiBinaryWrite(w, key, b.vecs[key])
+		if err != nil {
+			return fmt.Errorf("encode node %v: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Import replaces the contents of the index with those written by
+// Export. r must implement io.ByteReader, e.g. a *bufio.Reader.
+func (b *BruteForce[K]) Import(r io.Reader) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var (
+		version, n int
+		name       string
+	)
+	_, err := multiBinaryRead(r, &version, &name, &n)
+	if err != nil {
+		return err
+	}
+	if version != bruteForceVersion {
+		return fmt.Errorf("incompatible encoding version: %d", version)
+	}
+	distance, ok := distanceFuncs[name]
+	if !ok {
+		return fmt.Errorf("unknown distance function %q", name)
+	}
+
+	vecs := make(map[K]Vector, n)
+	for i := 0; i < n; i++ {
+		var (
+			key K
+			vec Vector
+		)
+		_, err = multiBinaryRead(r, &key, &vec)
+		if err != nil {
+			return fmt.Errorf("decoding node %d: %w", i, err)
+		}
+		vecs[key] = vec
+	}
+	b.Distance = distance
+	b.vecs = vecs
+	return nil
+}
@@ -0,0 +1,53 @@
+package hnsw
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBruteForce(t *testing.T) {
+	b := NewBruteForce[int]()
+	b.Distance = EuclideanDistance
+	for i := 0; i < 100; i++ {
+		require.NoError(t, b.Add(MakeNode(i, Vector{float32(i)})))
+	}
+	require.Error(t, b.Add(MakeNode(100, Vector{1, 2})))
+	require.Equal(t, 100, b.Len())
+
+	results, err := b.Search(Vector{41.5}, 3)
+	require.NoError(t, err)
+	require.Equal(t, []int{41, 42, 40}, keysOf(results))
+	require.Equal(t, float32(0.5), results[0].Distance)
+
+	require.True(t, b.Delete(41))
+	require.False(t, b.Delete(41))
+	_, ok := b.Lookup(41)
+	require.False(t, ok)
+
+	// It agrees with a graph on small data.
+	g := newTestGraph[int]()
+	for i := 0; i < 100; i++ {
+		if i != 41 {
+			g.Add(MakeNode(i, Vector{float32(i)}))
+		}
+	}
+	want, err := g.Search(Vector{70.2}, 5)
+	require.NoError(t, err)
+	got, err := b.Search(Vector{70.2}, 5)
+	require.NoError(t, err)
+	require.Equal(t, keysOf(want), keysOf(got))
+
+	t.Run("ExportImport", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, b.Export(&buf))
+		b2 := NewBruteForce[int]()
+		require.NoError(t, b2.Import(bufio.NewReader(&buf)))
+		require.Equal(t, 99, b2.Len())
+		got, err := b2.Search(Vector{70.2}, 5)
+		require.NoError(t, err)
+		require.Equal(t, keysOf(want), keysOf(got))
+	})
+}
@@ -0,0 +1,327 @@
+package hnsw
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// BuildOptions configures BuildFromFiles.
+type BuildOptions struct {
+	// Graph is the graph to insert the records into, with its parameters
+	// set. It defaults to NewGraph.
+	Graph *Graph[string]
+
+	// Workers is the number of goroutines parsing records and inserting
+	// nodes; <= 0 means GOMAXPROCS.
+	Workers int
+
+	// BatchSize is the number of nodes inserted with each AddBatch. It
+	// defaults to 1024.
+	BatchSize int
+}
+
+// BuildFromFiles builds a graph from the embeddings in the files matching
+// the glob pattern, in the syntax of filepath.Match, taking the key and
+// the vector of each record from the named fields.
+//
+// The format of a file is chosen by its extension:
+//
+//   - .jsonl, .ndjson and .json files hold one JSON object per line. The
+//     key may be a string or a number; the vector is an array of numbers.
+//   - .csv files have a header row naming the columns. The vector is
+//     written as numbers separated by commas, semicolons or spaces,
+//     optionally enclosed in brackets, like "[0.1, 0.2]".
+//
+// Files ending in .gz are decompressed first, e.g. "embeddings.jsonl.gz".
+// Files are read one by one while their records are parsed in parallel
+// and inserted in batches with AddBatch. A record with an existing key
+// replaces the node, as with Add.
+func BuildFromFiles(pattern, keyField, vectorField string, opts BuildOptions) (*Graph[string], error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files match %q", pattern)
+	}
+	slices.Sort(paths)
+
+	g := opts.Graph
+	if g == nil {
+		g = NewGraph[string]()
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1024
+	}
+
+	b := &builder{
+		keyField:    keyField,
+		vectorField: vectorField,
+		stop:        make(chan struct{}),
+	}
+	records := make(chan rawRecord, 4*workers)
+	parsed := make(chan parsedRecord, 4*workers)
+
+	go func() {
+		defer close(records)
+		for _, path := range paths {
+			if err := b.readFile(path, records); err != nil {
+				b.fail(err)
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rec := range records {
+				node, err := b.parse(rec)
+				if err != nil {
+					err = fmt.Errorf("%s:%d: %w", rec.path, rec.line, err)
+				}
+				parsed <- parsedRecord{node: node, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(parsed)
+	}()
+
+	batch := make([]Node[string], 0, batchSize)
+	for p := range parsed {
+		if b.failed() {
+			// Drain the pipeline.
+			continue
+		}
+		if p.err != nil {
+			b.fail(p.err)
+			continue
+		}
+		batch = append(batch, p.node)
+		if len(batch) == batchSize {
+			if err := g.AddBatch(batch, workers); err != nil {
+				b.fail(err)
+			}
+			batch = batch[:0]
+		}
+	}
+	if !b.failed() && len(batch) > 0 {
+		if err := g.AddBatch(batch, workers); err != nil {
+			b.fail(err)
+		}
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	return g, nil
+}
+
+// builder holds the state of BuildFromFiles shared by its goroutines.
+type builder struct {
+	keyField, vectorField string
+
+	once sync.Once
+	err  error
+	// stop is closed on the first error.
+	stop chan struct{}
+}
+
+// rawRecord is a record read from a file, yet to be parsed. JSON records
+// are a line, CSV records the key and vector fields.
+type rawRecord struct {
+	path       string
+	line       int
+	json       []byte
+	key, value string
+}
+
+type parsedRecord struct {
+	node Node[string]
+	err  error
+}
+
+func (b *builder) fail(err error) {
+	b.once.Do(func() {
+		b.err = err
+		close(b.stop)
+	})
+}
+
+func (b *builder) failed() bool {
+	select {
+	case <-b.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// send sends rec to records and reports whether the build goes on.
+func (b *builder) send(records chan<- rawRecord, rec rawRecord) bool {
+	select {
+	case records <- rec:
+		return true
+	case <-b.stop:
+		return false
+	}
+}
+
+// readFile sends the records of the file at path to records.
+func (b *builder) readFile(path string, records chan<- rawRecord) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	name := path
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+		name = strings.TrimSuffix(name, ".gz")
+	}
+
+	switch ext := filepath.Ext(name); ext {
+	case ".jsonl", ".ndjson", ".json":
+		return b.readJSONLines(path, r, records)
+	case ".csv":
+		return b.readCSV(path, r, records)
+	default:
+		return fmt.Errorf("%s: unsupported file type %q", path, ext)
+	}
+}
+
+func (b *builder) readJSONLines(path string, r io.Reader, records chan<- rawRecord) error {
+	// Lines of large embeddings exceed the limits of bufio.Scanner.
+	br := bufio.NewReaderSize(r, 1<<16)
+	for line := 1; ; line++ {
+		data, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			if !b.send(records, rawRecord{path: path, line: line, json: data}) {
+				return nil
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+	}
+}
+
+func (b *builder) readCSV(path string, r io.Reader, records chan<- rawRecord) error {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("%s: reading header: %w", path, err)
+	}
+	keyCol := slices.Index(header, b.keyField)
+	if keyCol < 0 {
+		return fmt.Errorf("%s: no column %q", path, b.keyField)
+	}
+	vectorCol := slices.Index(header, b.vectorField)
+	if vectorCol < 0 {
+		return fmt.Errorf("%s: no column %q", path, b.vectorField)
+	}
+
+	for {
+		fields, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		line, _ := cr.FieldPos(0)
+		rec := rawRecord{path: path, line: line, key: fields[keyCol], value: fields[vectorCol]}
+		if !b.send(records, rec) {
+			return nil
+		}
+	}
+}
+
+// parse parses rec into a node.
+func (b *builder) parse(rec rawRecord) (Node[string], error) {
+	if rec.json == nil {
+		vec, err := parseVectorText(rec.value)
+		if err != nil {
+			return Node[string]{}, err
+		}
+		return MakeNode(rec.key, vec), nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(rec.json, &fields); err != nil {
+		return Node[string]{}, err
+	}
+	rawKey, ok := fields[b.keyField]
+	if !ok {
+		return Node[string]{}, fmt.Errorf("no field %q", b.keyField)
+	}
+	var key string
+	if err := json.Unmarshal(rawKey, &key); err != nil {
+		// Numeric keys are taken as written.
+		var n json.Number
+		if json.Unmarshal(rawKey, &n) != nil {
+			return Node[string]{}, fmt.Errorf("field %q is neither a string nor a number", b.keyField)
+		}
+		key = n.String()
+	}
+	rawVec, ok := fields[b.vectorField]
+	if !ok {
+		return Node[string]{}, fmt.Errorf("no field %q", b.vectorField)
+	}
+	var vec Vector
+	if err := json.Unmarshal(rawVec, &vec); err != nil {
+		return Node[string]{}, fmt.Errorf("field %q: %w", b.vectorField, err)
+	}
+	return MakeNode(key, vec), nil
+}
+
+// parseVectorText parses numbers separated by commas, semicolons or
+// spaces, optionally enclosed in brackets.
+func parseVectorText(s string) (Vector, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';' || unicode.IsSpace(r)
+	})
+	vec := make(Vector, len(fields))
+	for i, field := range fields {
+		x, err := strconv.ParseFloat(field, 32)
+		if err != nil {
+			return nil, err
+		}
+		vec[i] = float32(x)
+	}
+	return vec, nil
+}
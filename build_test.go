@@ -0,0 +1,78 @@
+package hnsw
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildFromFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644))
+	}
+
+	var jsonl strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&jsonl, `{"id": "j%d", "embedding": [%d, 1, 0], "text": "ignored"}`+"\n", i, i)
+	}
+	write("a.jsonl", jsonl.String())
+
+	f, err := os.Create(filepath.Join(dir, "b.jsonl.gz"))
+	require.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(gz, `{"id": %d, "embedding": [%d, 2, 0]}`+"\n", i, i)
+	}
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+
+	var csv strings.Builder
+	csv.WriteString("embedding,id\n")
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&csv, "\"[%d, 3, 0]\",c%d\n", i, i)
+	}
+	write("c.csv", csv.String())
+
+	g, err := BuildFromFiles(filepath.Join(dir, "*"), "id", "embedding", BuildOptions{
+		Workers:   3,
+		BatchSize: 64,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 300, g.Len())
+	require.NoError(t, g.Verify())
+	for key, want := range map[string]Vector{
+		"j7": {7, 1, 0},
+		"7":  {7, 2, 0},
+		"c7": {7, 3, 0},
+	} {
+		vec, ok := g.Lookup(key)
+		require.True(t, ok, key)
+		require.Equal(t, want, vec)
+	}
+	results, err := g.Search(Vector{50, 3, 0}, 1)
+	require.NoError(t, err)
+	require.Equal(t, "c50", results[0].Key)
+
+	// Records are inserted into the given graph.
+	into := NewGraph[string]()
+	into.M = 8
+	g, err = BuildFromFiles(filepath.Join(dir, "*.csv"), "id", "embedding", BuildOptions{Graph: into})
+	require.NoError(t, err)
+	require.Same(t, into, g)
+	require.Equal(t, 100, g.Len())
+
+	_, err = BuildFromFiles(filepath.Join(dir, "*.parquet"), "id", "embedding", BuildOptions{})
+	require.Error(t, err)
+	_, err = BuildFromFiles(filepath.Join(dir, "a.jsonl"), "id", "vector", BuildOptions{})
+	require.ErrorContains(t, err, `no field "vector"`)
+
+	write("bad.jsonl", "{\"id\": \"x\", \"embedding\": [1, 2, 3]}\n{\"id\": \"y\", \"embedding\": \"oops\"}\n")
+	_, err = BuildFromFiles(filepath.Join(dir, "bad.jsonl"), "id", "embedding", BuildOptions{})
+	require.ErrorContains(t, err, "bad.jsonl:2")
+}
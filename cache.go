@@ -0,0 +1,145 @@
+package hnsw
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// semanticCacheCandidates is the number of stored queries considered on
+// a lookup, so that an expired entry doesn't hide a live one right
+// behind it.
+const semanticCacheCandidates = 4
+
+type cacheEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// SemanticCache caches values, e.g. LLM responses, by the embedding of
+// the query that produced them. A lookup hits when a stored query is
+// within Threshold of the new one, so paraphrased queries share an entry.
+type SemanticCache[K comparable, V any] struct {
+	// Threshold is the maximum distance between two queries for them to
+	// share a cached value.
+	Threshold float32
+
+	// TTL is how long an entry stays valid. Zero means entries don't
+	// expire.
+	TTL time.Duration
+
+	// MaxSize is the maximum number of entries. When exceeded, the least
+	// recently used entry is evicted. Zero means no limit.
+	MaxSize int
+
+	mu    sync.Mutex
+	graph *Graph[K]
+	// lru holds entries from most to least recently used.
+	lru     *list.List
+	entries map[K]*list.Element
+	now     func() time.Time
+}
+
+// NewSemanticCache returns a cache backed by a graph with default
+// parameters. The graph's distance function is cosine distance.
+func NewSemanticCache[K comparable, V any](threshold float32, ttl time.Duration, maxSize int) *SemanticCache[K, V] {
+	return &SemanticCache[K, V]{
+		Threshold: threshold,
+		TTL:       ttl,
+		MaxSize:   maxSize,
+		graph:     NewGraph[K](),
+		lru:       list.New(),
+		entries:   make(map[K]*list.Element),
+		now:       time.Now,
+	}
+}
+
+// Put stores value under key, for queries close to vec. An existing entry
+// with the same key is replaced.
+func (c *SemanticCache[K, V]) Put(key K, vec Vector, value V) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.graph.Add(MakeNode(key, vec))
+	if err != nil {
+		return err
+	}
+
+	entry := &cacheEntry[K, V]{key: key, value: value}
+	if c.TTL > 0 {
+		entry.expires = c.now().Add(c.TTL)
+	}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+	} else {
+		c.entries[key] = c.lru.PushFront(entry)
+	}
+
+	for c.MaxSize > 0 && c.lru.Len() > c.MaxSize {
+		c.remove(c.lru.Back().Value.(*cacheEntry[K, V]).key)
+	}
+	return nil
+}
+
+// Get returns the value cached for the stored query closest to vec, if
+// it is within Threshold and hasn't expired.
+func (c *SemanticCache[K, V]) Get(vec Vector) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	if c.lru.Len() == 0 {
+		return zero, false
+	}
+	results, err := c.graph.Search(vec, semanticCacheCandidates)
+	if err != nil {
+		return zero, false
+	}
+
+	var best *list.Element
+	bestDist := c.Threshold
+	for _, result := range results {
+		elem := c.entries[result.Key]
+		entry := elem.Value.(*cacheEntry[K, V])
+		if c.TTL > 0 && !c.now().Before(entry.expires) {
+			c.remove(entry.key)
+			continue
+		}
+		if result.Distance <= bestDist {
+			best, bestDist = elem, result.Distance
+		}
+	}
+	if best == nil {
+		return zero, false
+	}
+
+	c.lru.MoveToFront(best)
+	return best.Value.(*cacheEntry[K, V]).value, true
+}
+
+// Delete removes the entry with the given key.
+func (c *SemanticCache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(key)
+}
+
+// Len returns the number of entries, including expired entries that
+// haven't been evicted yet.
+func (c *SemanticCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *SemanticCache[K, V]) remove(key K) {
+	elem, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	c.lru.Remove(elem)
+	delete(c.entries, key)
+	c.graph.Delete(key)
+}
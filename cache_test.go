@@ -0,0 +1,50 @@
+package hnsw
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSemanticCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewSemanticCache[string, string](0.1, time.Minute, 2)
+	c.now = func() time.Time { return now }
+
+	_, ok := c.Get(Vector{1, 0})
+	require.False(t, ok)
+
+	require.NoError(t, c.Put("weather", Vector{1, 0}, "sunny"))
+	require.NoError(t, c.Put("stocks", Vector{0, 1}, "up"))
+
+	// A paraphrase is close enough.
+	v, ok := c.Get(Vector{1, 0.1})
+	require.True(t, ok)
+	require.Equal(t, "sunny", v)
+
+	// Something unrelated isn't.
+	_, ok = c.Get(Vector{1, 1})
+	require.False(t, ok)
+
+	// "weather" was used more recently than "stocks", so "stocks" is
+	// evicted.
+	require.NoError(t, c.Put("sports", Vector{-1, 0}, "won"))
+	require.Equal(t, 2, c.Len())
+	_, ok = c.Get(Vector{0, 1})
+	require.False(t, ok)
+
+	// Expired entries are dropped as lookups come across them.
+	now = now.Add(2 * time.Minute)
+	_, ok = c.Get(Vector{1, 0})
+	require.False(t, ok)
+	_, ok = c.Get(Vector{-1, 0})
+	require.False(t, ok)
+	require.Equal(t, 0, c.Len())
+
+	require.NoError(t, c.Put("news", Vector{1, 0}, "quiet"))
+	c.Delete("news")
+	require.Equal(t, 0, c.Len())
+	_, ok = c.Get(Vector{1, 0})
+	require.False(t, ok)
+}
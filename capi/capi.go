@@ -0,0 +1,164 @@
+// Command capi builds a C shared library around hnsw, so that programs
+// in other languages can embed the index through FFI:
+//
+//	go build -buildmode=c-shared -o libhnsw.so ./capi
+//
+// This also writes libhnsw.h. Graphs have int64 keys and are referred to
+// by opaque handles, which must be released with hnsw_free. Functions
+// that can fail return an error message, or NULL on success; the message
+// must be released with hnsw_free_string.
+//
+//	uintptr_t g;
+//	char *err = hnsw_new(16, 20, "cosine", &g);
+//	float vec[] = {1, 0, 0};
+//	err = hnsw_add(g, 42, vec, 3);
+//	int64_t keys[10]; float dists[10]; int n;
+//	err = hnsw_search(g, vec, 3, 10, keys, dists, &n);
+//	hnsw_free(g);
+package main
+
+// #include <stdint.h>
+// #include <stdlib.h>
+import "C"
+
+import (
+	"bufio"
+	"os"
+	"runtime/cgo"
+	"unsafe"
+
+	"github.com/hypermodeinc/hnsw"
+)
+
+func main() {}
+
+// cError converts err to a C string for the caller to free.
+func cError(err error) *C.char {
+	if err == nil {
+		return nil
+	}
+	return C.CString(err.Error())
+}
+
+func graphOf(h C.uintptr_t) *hnsw.Graph[int64] {
+	return cgo.Handle(h).Value().(*hnsw.Graph[int64])
+}
+
+func floats(vec *C.float, dim C.int) []float32 {
+	return unsafe.Slice((*float32)(unsafe.Pointer(vec)), int(dim))
+}
+
+// hnsw_new creates a graph and stores its handle in out. m and ef_search
+// of 0 keep the defaults; distance is "cosine", "euclidean" or "dot", or
+// NULL for cosine.
+//
+//export hnsw_new
+func hnsw_new(m, efSearch C.int, distance *C.char, out *C.uintptr_t) *C.char {
+	var name string
+	if distance != nil {
+		name = C.GoString(distance)
+	}
+	g, err := newGraph(int(m), int(efSearch), name)
+	if err != nil {
+		return cError(err)
+	}
+	*out = C.uintptr_t(cgo.NewHandle(g))
+	return nil
+}
+
+// hnsw_add inserts the vector of dim floats under key, replacing any
+// previous one. The vector is copied.
+//
+//export hnsw_add
+func hnsw_add(h C.uintptr_t, key C.int64_t, vec *C.float, dim C.int) *C.char {
+	v := append([]float32(nil), floats(vec, dim)...)
+	return cError(graphOf(h).Add(hnsw.MakeNode(int64(key), v)))
+}
+
+// hnsw_add_batch inserts n vectors of dim floats, stored consecutively
+// in vecs, under the n keys in keys. The vectors are copied.
+//
+//export hnsw_add_batch
+func hnsw_add_batch(h C.uintptr_t, keys *C.int64_t, vecs *C.float, n, dim C.int) *C.char {
+	nodes := make([]hnsw.Node[int64], int(n))
+	ks := unsafe.Slice((*int64)(unsafe.Pointer(keys)), int(n))
+	data := append([]float32(nil), floats(vecs, n*dim)...)
+	for i := range nodes {
+		nodes[i] = hnsw.MakeNode(ks[i], data[i*int(dim):(i+1)*int(dim):(i+1)*int(dim)])
+	}
+	return cError(graphOf(h).Add(nodes...))
+}
+
+// hnsw_search writes the keys and distances of the k nearest neighbors
+// of vec, closest first, to keys and dists, which must have room for k
+// values each, and their number to n.
+//
+//export hnsw_search
+func hnsw_search(h C.uintptr_t, vec *C.float, dim, k C.int, keys *C.int64_t, dists *C.float, n *C.int) *C.char {
+	found, err := search(
+		graphOf(h),
+		floats(vec, dim),
+		unsafe.Slice((*int64)(unsafe.Pointer(keys)), int(k)),
+		unsafe.Slice((*float32)(unsafe.Pointer(dists)), int(k)),
+	)
+	*n = C.int(found)
+	return cError(err)
+}
+
+// hnsw_delete removes the vector stored under key and returns 1 if it
+// existed, 0 otherwise.
+//
+//export hnsw_delete
+func hnsw_delete(h C.uintptr_t, key C.int64_t) C.int {
+	if graphOf(h).Delete(int64(key)) {
+		return 1
+	}
+	return 0
+}
+
+// hnsw_len returns the number of vectors in the graph.
+//
+//export hnsw_len
+func hnsw_len(h C.uintptr_t) C.int {
+	return C.int(graphOf(h).Len())
+}
+
+// hnsw_save writes the graph to the file at path.
+//
+//export hnsw_save
+func hnsw_save(h C.uintptr_t, path *C.char) *C.char {
+	g := &hnsw.SavedGraph[int64]{Graph: graphOf(h), Path: C.GoString(path)}
+	return cError(g.Save())
+}
+
+// hnsw_load reads the graph saved at path and stores its handle in out.
+//
+//export hnsw_load
+func hnsw_load(path *C.char, out *C.uintptr_t) *C.char {
+	f, err := os.Open(C.GoString(path))
+	if err != nil {
+		return cError(err)
+	}
+	defer f.Close()
+	g := hnsw.NewGraph[int64]()
+	if err := g.Import(bufio.NewReader(f)); err != nil {
+		return cError(err)
+	}
+	*out = C.uintptr_t(cgo.NewHandle(g))
+	return nil
+}
+
+// hnsw_free releases the graph of a handle. The handle must not be used
+// afterwards.
+//
+//export hnsw_free
+func hnsw_free(h C.uintptr_t) {
+	cgo.Handle(h).Delete()
+}
+
+// hnsw_free_string releases an error message.
+//
+//export hnsw_free_string
+func hnsw_free_string(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hypermodeinc/hnsw"
+)
+
+// distances are the distance functions selectable by name through the C
+// API.
+var distances = map[string]hnsw.DistanceFunc{
+	"cosine":    hnsw.CosineDistance,
+	"euclidean": hnsw.EuclideanDistance,
+	"dot":       hnsw.DotProductDistance,
+}
+
+// newGraph returns a graph with the given parameters. Zero values keep
+// the defaults of hnsw.NewGraph, and an empty distance means cosine.
+func newGraph(m, efSearch int, distance string) (*hnsw.Graph[int64], error) {
+	g := hnsw.NewGraph[int64]()
+	if m > 0 {
+		g.M = m
+	}
+	if efSearch > 0 {
+		g.EfSearch = efSearch
+	}
+	if distance != "" {
+		fn, ok := distances[distance]
+		if !ok {
+			return nil, fmt.Errorf("unknown distance %q", distance)
+		}
+		g.Distance = fn
+	}
+	return g, nil
+}
+
+// search finds the k nearest neighbors of vec and writes their keys and
+// distances to keys and dists, which must have room for k results. It
+// returns the number of results written.
+func search(g *hnsw.Graph[int64], vec []float32, keys []int64, dists []float32) (int, error) {
+	results, err := g.Search(vec, len(keys))
+	if err != nil {
+		return 0, err
+	}
+	for i, r := range results {
+		keys[i] = r.Key
+		dists[i] = r.Distance
+	}
+	return len(results), nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hypermodeinc/hnsw"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	g, err := newGraph(8, 0, "euclidean")
+	require.NoError(t, err)
+	require.Equal(t, 8, g.M)
+	require.Equal(t, 20, g.EfSearch)
+	for i := int64(0); i < 10; i++ {
+		require.NoError(t, g.Add(hnsw.MakeNode(i, hnsw.Vector{float32(i)})))
+	}
+
+	keys := make([]int64, 3)
+	dists := make([]float32, 3)
+	n, err := search(g, []float32{4.1}, keys, dists)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, []int64{4, 5, 3}, keys)
+
+	_, err = newGraph(0, 0, "manhattan")
+	require.Error(t, err)
+}
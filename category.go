@@ -0,0 +1,183 @@
+package hnsw
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Category is a coarse partition of a graph, e.g. the documents of one
+// language or one product type.
+type Category[K comparable] struct {
+	Name string
+
+	// Centroid represents the category for routing: queries go to the
+	// categories with the closest centroids.
+	Centroid Vector
+
+	// Members restricts the searches routed to the category to its
+	// keys.
+	Members Allowlist[K]
+}
+
+// CategoryRouter routes searches over a large, heterogeneous graph to
+// the categories closest to the query, and searches only within them.
+// Categories may overlap. The zero value with a Graph is ready to use.
+type CategoryRouter[K comparable] struct {
+	Graph *Graph[K]
+
+	// Probe is the number of closest categories searched. Zero means 1.
+	// Searching more categories helps queries near category boundaries.
+	Probe int
+
+	mu         sync.RWMutex
+	categories []Category[K]
+}
+
+// Register adds a category, replacing any category with the same name.
+func (r *CategoryRouter[K]) Register(c Category[K]) error {
+	if c.Name == "" {
+		return fmt.Errorf("category has no name")
+	}
+	if c.Members == nil {
+		return fmt.Errorf("category %q has no members", c.Name)
+	}
+	if dims := r.Graph.Dims(); dims != 0 && len(c.Centroid) != dims {
+		return fmt.Errorf("category %q: centroid has %d dimensions, want %d", c.Name, len(c.Centroid), dims)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := r.index(c.Name); i >= 0 {
+		r.categories[i] = c
+	} else {
+		r.categories = append(r.categories, c)
+	}
+	return nil
+}
+
+// Learn registers a category made of keys, with the mean of their
+// vectors as its centroid.
+func (r *CategoryRouter[K]) Learn(name string, keys []K) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("category %q has no members", name)
+	}
+	members := make(AllowSet[K], len(keys))
+	var centroid Vector
+	for _, key := range keys {
+		vec, ok := r.Graph.Lookup(key)
+		if !ok {
+			return fmt.Errorf("category %q: key %v not found", name, key)
+		}
+		if centroid == nil {
+			centroid = make(Vector, len(vec))
+		}
+		for i, v := range vec {
+			centroid[i] += v
+		}
+		members[key] = struct{}{}
+	}
+	for i := range centroid {
+		centroid[i] /= float32(len(keys))
+	}
+	return r.Register(Category[K]{Name: name, Centroid: centroid, Members: members})
+}
+
+// Unregister removes the named category, reporting whether it existed.
+func (r *CategoryRouter[K]) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(name)
+	if i < 0 {
+		return false
+	}
+	r.categories = slices.Delete(r.categories, i, i+1)
+	return true
+}
+
+func (r *CategoryRouter[K]) index(name string) int {
+	return slices.IndexFunc(r.categories, func(c Category[K]) bool { return c.Name == name })
+}
+
+// Route returns the names of the categories a search for near goes to,
+// closest first.
+func (r *CategoryRouter[K]) Route(near Vector) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	routed, err := r.route(near)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(routed))
+	for i, c := range routed {
+		names[i] = c.Name
+	}
+	return names, nil
+}
+
+// route returns the Probe categories closest to near. The caller must
+// hold the read lock.
+func (r *CategoryRouter[K]) route(near Vector) ([]Category[K], error) {
+	type scored struct {
+		category Category[K]
+		dist     float32
+	}
+	all := make([]scored, len(r.categories))
+	for i, c := range r.categories {
+		dist, err := r.Graph.Distance(near, c.Centroid)
+		if err != nil {
+			return nil, fmt.Errorf("category %q: %w", c.Name, err)
+		}
+		all[i] = scored{category: c, dist: dist}
+	}
+	slices.SortStableFunc(all, func(a, b scored) int { return cmp.Compare(a.dist, b.dist) })
+
+	probe := max(r.Probe, 1)
+	out := make([]Category[K], 0, min(probe, len(all)))
+	for _, s := range all[:min(probe, len(all))] {
+		out = append(out, s.category)
+	}
+	return out, nil
+}
+
+// Search finds the k nearest neighbors of near within the categories it
+// is routed to. With no categories registered it searches the whole
+// graph.
+func (r *CategoryRouter[K]) Search(near Vector, k int) ([]SearchResultNode[K], error) {
+	return r.SearchWithOptions(near, k, SearchOptions[K]{})
+}
+
+// SearchWithOptions is like Search but accepts per-query options. The
+// category restriction replaces opts.Allow and is combined with
+// opts.Filter.
+func (r *CategoryRouter[K]) SearchWithOptions(near Vector, k int, opts SearchOptions[K]) ([]SearchResultNode[K], error) {
+	r.mu.RLock()
+	if len(r.categories) == 0 {
+		r.mu.RUnlock()
+		return r.Graph.SearchWithOptions(near, k, opts)
+	}
+	routed, err := r.route(near)
+	r.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []SearchResultNode[K]
+	seen := make(map[K]bool)
+	for _, c := range routed {
+		opts.Allow = c.Members
+		results, err := r.Graph.SearchWithOptions(near, k, opts)
+		if err != nil {
+			return nil, fmt.Errorf("category %q: %w", c.Name, err)
+		}
+		for _, res := range results {
+			if !seen[res.Key] {
+				seen[res.Key] = true
+				out = append(out, res)
+			}
+		}
+	}
+	slices.SortStableFunc(out, func(a, b SearchResultNode[K]) int { return cmp.Compare(a.Distance, b.Distance) })
+	return out[:min(k, len(out))], nil
+}
@@ -0,0 +1,72 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCategoryRouter(t *testing.T) {
+	// Two clusters: even keys around (0, 0) and odd keys around (100, 0).
+	g := newTestGraph[int]()
+	var even, odd []int
+	for i := 0; i < 200; i++ {
+		vec := randFloats(2)
+		if i%2 == 1 {
+			vec[0] += 100
+			odd = append(odd, i)
+		} else {
+			even = append(even, i)
+		}
+		require.NoError(t, g.Add(MakeNode(i, vec)))
+	}
+
+	r := &CategoryRouter[int]{Graph: g}
+	results, err := r.Search(Vector{50, 0}, 4)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	require.NoError(t, r.Learn("even", even))
+	require.NoError(t, r.Learn("odd", odd))
+	names, err := r.Route(Vector{90, 0})
+	require.NoError(t, err)
+	require.Equal(t, []string{"odd"}, names)
+
+	// A query in between is routed to the closer category only.
+	results, err = r.Search(Vector{45, 0}, 10)
+	require.NoError(t, err)
+	require.Len(t, results, 10)
+	for _, res := range results {
+		require.Zero(t, res.Key%2)
+	}
+
+	// Probing both categories merges their results.
+	r.Probe = 2
+	names, err = r.Route(Vector{45, 0})
+	require.NoError(t, err)
+	require.Equal(t, []string{"even", "odd"}, names)
+	results, err = r.SearchWithOptions(Vector{50, 0}, 10, SearchOptions[int]{
+		Filter: func(key int) bool { return key < 100 },
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 10)
+	for i, res := range results {
+		require.Less(t, res.Key, 100)
+		if i > 0 {
+			require.LessOrEqual(t, results[i-1].Distance, res.Distance)
+		}
+	}
+
+	// Registering under an existing name replaces the category.
+	require.NoError(t, r.Register(Category[int]{Name: "even", Centroid: Vector{200, 0}, Members: AllowSet[int]{0: {}}}))
+	r.Probe = 1
+	results, err = r.Search(Vector{190, 0}, 3)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, 0, results[0].Key)
+
+	require.True(t, r.Unregister("even"))
+	require.False(t, r.Unregister("even"))
+	require.Error(t, r.Register(Category[int]{Name: "bad", Centroid: Vector{1}, Members: AllowSet[int]{}}))
+	require.Error(t, r.Learn("missing", []int{1000}))
+}
@@ -0,0 +1,163 @@
+package hnsw
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"golang.org/x/exp/maps"
+)
+
+// CentralityKind selects the measure computed by Centrality.
+type CentralityKind int
+
+const (
+	// DegreeCentrality is the number of nodes linking to a node, divided
+	// by the number of other nodes.
+	DegreeCentrality CentralityKind = iota
+	// PageRank is the stationary probability of a random walk over the
+	// base layer that follows an edge with probability 0.85 and jumps to
+	// a random node otherwise. The scores sum to 1.
+	PageRank
+	// HarmonicCentrality is the sum of 1/d over the distance d in hops
+	// from every other node, divided by the number of other nodes.
+	// Unreachable nodes contribute 0.
+	HarmonicCentrality
+)
+
+const (
+	pageRankDamping    = 0.85
+	pageRankTolerance  = 1e-9
+	pageRankIterations = 100
+)
+
+// Centrality scores every node by its centrality in the base layer, for
+// popularity priors or to find hubs. Edges are followed in their
+// direction: a node is central if many nodes link to it.
+func (g *Graph[K]) Centrality(kind CentralityKind) (map[K]float64, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if len(g.layers) == 0 {
+		return map[K]float64{}, nil
+	}
+	keys := maps.Keys(g.layers[0].nodes)
+	slices.SortFunc(keys, keyOrder[K]())
+
+	// Adjacency by index keeps the algorithms below free of map lookups.
+	index := make(map[K]int, len(keys))
+	for i, key := range keys {
+		index[key] = i
+	}
+	out := make([][]int, len(keys))
+	for i, key := range keys {
+		for _, n := range g.layers[0].nodes[key].neighborKeys() {
+			if j, ok := index[n]; ok {
+				out[i] = append(out[i], j)
+			}
+		}
+	}
+
+	var scores []float64
+	switch kind {
+	case DegreeCentrality:
+		scores = degreeCentrality(out)
+	case PageRank:
+		scores = pageRank(out)
+	case HarmonicCentrality:
+		scores = harmonicCentrality(out)
+	default:
+		return nil, fmt.Errorf("unknown centrality kind %d", kind)
+	}
+
+	result := make(map[K]float64, len(keys))
+	for i, key := range keys {
+		result[key] = scores[i]
+	}
+	return result, nil
+}
+
+func degreeCentrality(out [][]int) []float64 {
+	scores := make([]float64, len(out))
+	if len(out) < 2 {
+		return scores
+	}
+	for _, edges := range out {
+		for _, j := range edges {
+			scores[j]++
+		}
+	}
+	for i := range scores {
+		scores[i] /= float64(len(out) - 1)
+	}
+	return scores
+}
+
+func pageRank(out [][]int) []float64 {
+	n := float64(len(out))
+	rank := make([]float64, len(out))
+	for i := range rank {
+		rank[i] = 1 / n
+	}
+	next := make([]float64, len(out))
+	for iter := 0; iter < pageRankIterations; iter++ {
+		// Nodes without edges spread their rank evenly.
+		var dangling float64
+		for i, edges := range out {
+			if len(edges) == 0 {
+				dangling += rank[i]
+			}
+		}
+		base := (1-pageRankDamping)/n + pageRankDamping*dangling/n
+		for i := range next {
+			next[i] = base
+		}
+		for i, edges := range out {
+			share := pageRankDamping * rank[i] / float64(len(edges))
+			for _, j := range edges {
+				next[j] += share
+			}
+		}
+
+		var delta float64
+		for i := range rank {
+			delta += math.Abs(next[i] - rank[i])
+		}
+		rank, next = next, rank
+		if delta < pageRankTolerance {
+			break
+		}
+	}
+	return rank
+}
+
+func harmonicCentrality(out [][]int) []float64 {
+	scores := make([]float64, len(out))
+	if len(out) < 2 {
+		return scores
+	}
+	dist := make([]int, len(out))
+	for src := range out {
+		for i := range dist {
+			dist[i] = -1
+		}
+		dist[src] = 0
+		queue := []int{src}
+		for len(queue) > 0 {
+			i := queue[0]
+			queue = queue[1:]
+			for _, j := range out[i] {
+				if dist[j] >= 0 {
+					continue
+				}
+				dist[j] = dist[i] + 1
+				scores[j] += 1 / float64(dist[j])
+				queue = append(queue, j)
+			}
+		}
+	}
+	for i := range scores {
+		scores[i] /= float64(len(out) - 1)
+	}
+	return scores
+}
@@ -0,0 +1,37 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Centrality(t *testing.T) {
+	// In treeGraph, 0 and 1 are the hubs, 3, 4 and 5 the leaves.
+	g := treeGraph()
+
+	degree, err := g.Centrality(DegreeCentrality)
+	require.NoError(t, err)
+	require.InDelta(t, 3.0/5, degree[1], 1e-9)
+	require.InDelta(t, 1.0/5, degree[5], 1e-9)
+
+	harmonic, err := g.Centrality(HarmonicCentrality)
+	require.NoError(t, err)
+	// 0 is one hop from 1 and 2 and two hops from 3, 4 and 5.
+	require.InDelta(t, (1+1+0.5+0.5+0.5)/5, harmonic[0], 1e-9)
+	require.Greater(t, harmonic[0], harmonic[3])
+
+	rank, err := g.Centrality(PageRank)
+	require.NoError(t, err)
+	var sum float64
+	for _, r := range rank {
+		sum += r
+	}
+	require.InDelta(t, 1, sum, 1e-6)
+	require.Greater(t, rank[1], rank[0])
+	require.Greater(t, rank[0], rank[3])
+	require.InDelta(t, rank[3], rank[4], 1e-9)
+
+	_, err = g.Centrality(CentralityKind(-1))
+	require.Error(t, err)
+}
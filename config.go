@@ -0,0 +1,137 @@
+package hnsw
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+)
+
+const modulePath = "github.com/hypermodeinc/hnsw"
+
+// GraphConfig is a serializable record of the parameters of a graph, so
+// that the provenance of an index can be answered. It is embedded in
+// files written by Export.
+//
+// The seed of Graph.Rng is not part of it: a *rand.Rand doesn't expose
+// its seed.
+type GraphConfig struct {
+	M              int
+	M0             int `json:",omitempty"`
+	Ml             float64
+	EfSearch       int
+	EfConstruction int
+	// Distance is the name the distance function is registered under
+	// with RegisterDistanceFunc, or empty if it isn't registered.
+	Distance    string
+	Fields      []Field `json:",omitempty"`
+	HitSampling int     `json:",omitempty"`
+	HashLevels  bool    `json:",omitempty"`
+	// NeighborSelection is encoded as text, e.g. "heuristic+extend".
+	NeighborSelection NeighborSelection
+	DuplicateDistance float32         `json:",omitempty"`
+	Duplicates        DuplicatePolicy `json:",omitempty"`
+	// Hardening holds no random state: a graph made from the config
+	// draws from Seed afresh.
+	Hardening *Hardening `json:",omitempty"`
+
+	// Version is the version of this package that last saved the graph,
+	// or the running version for a graph that was never imported.
+	Version string
+}
+
+// Config returns the configuration of the graph.
+func (g *Graph[K]) Config() GraphConfig {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.config()
+}
+
+func (g *Graph[K]) config() GraphConfig {
+	name, _ := distanceFuncToName(g.Distance)
+	version := g.version
+	if version == "" {
+		version = packageVersion()
+	}
+	return GraphConfig{
+		M:                 g.M,
+		M0:                g.M0,
+		Ml:                g.Ml,
+		EfSearch:          g.EfSearch,
+		EfConstruction:    g.EfConstruction,
+		Distance:          name,
+		Fields:            g.Fields,
+		HitSampling:       g.HitSampling,
+		HashLevels:        g.HashLevels,
+		NeighborSelection: g.NeighborSelection,
+		DuplicateDistance: g.DuplicateDistance,
+		Duplicates:        g.Duplicates,
+		Hardening:         g.Hardening.copy(),
+		Version:           version,
+	}
+}
+
+// newGraphFromConfig returns an empty graph with the parameters of
+// config. An empty config.Distance means distance.
+func newGraphFromConfig[K comparable](config GraphConfig, distance DistanceFunc) (*Graph[K], error) {
+	if config.M <= 0 {
+		return nil, fmt.Errorf("M must be positive")
+	}
+	if config.Ml <= 0 || config.Ml >= 1 {
+		return nil, fmt.Errorf("Ml must be between 0 and 1")
+	}
+	if config.Distance != "" {
+		var ok bool
+		distance, ok = distanceFuncs[config.Distance]
+		if !ok {
+			return nil, fmt.Errorf("unknown distance function %q", config.Distance)
+		}
+	}
+	return &Graph[K]{
+		Distance:          distance,
+		Rng:               defaultRand(),
+		M:                 config.M,
+		M0:                config.M0,
+		Ml:                config.Ml,
+		EfSearch:          config.EfSearch,
+		EfConstruction:    config.EfConstruction,
+		HitSampling:       config.HitSampling,
+		HashLevels:        config.HashLevels,
+		NeighborSelection: config.NeighborSelection,
+		DuplicateDistance: config.DuplicateDistance,
+		Duplicates:        config.Duplicates,
+		Hardening:         config.Hardening.copy(),
+		Fields:            config.Fields,
+	}, nil
+}
+
+// packageVersion returns the version of this package in the running
+// binary, or "(devel)" if it is unknown. It is a variable for tests.
+var packageVersion = buildVersion
+
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "(devel)"
+}
+
+// marshalConfig encodes the configuration written by Export, recording
+// the running version as the one that saved it.
+func (g *Graph[K]) marshalConfig() (string, error) {
+	c := g.config()
+	c.Version = packageVersion()
+	b, err := json.Marshal(c)
+	return string(b), err
+}
@@ -0,0 +1,49 @@
+package hnsw
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Config(t *testing.T) {
+	g := newTestGraph[int]()
+	g.Fields = []Field{{Name: "title", Start: 0, End: 1}}
+	g.M0 = 12
+	g.HashLevels = true
+	g.NeighborSelection = SelectHeuristic(true, false)
+	g.DuplicateDistance = 0.01
+	g.Duplicates = MergeDuplicates
+	g.Hardening = &Hardening{Jitter: 0.05, Seed: 3}
+	g.Add(MakeNode(1, Vector{1, 2}))
+
+	config := g.Config()
+	require.Equal(t, 6, config.M)
+	require.Equal(t, 12, config.M0)
+	require.True(t, config.HashLevels)
+	require.Equal(t, SelectHeuristic(true, false), config.NeighborSelection)
+	require.Equal(t, float32(0.01), config.DuplicateDistance)
+	require.Equal(t, &Hardening{Jitter: 0.05, Seed: 3}, config.Hardening)
+	require.NotSame(t, g.Hardening, config.Hardening)
+	require.Equal(t, "euclidean", config.Distance)
+	require.NotEmpty(t, config.Version)
+
+	b, err := json.Marshal(config)
+	require.NoError(t, err)
+	var decoded GraphConfig
+	require.Contains(t, string(b), `"NeighborSelection":"heuristic+extend"`)
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Equal(t, config, decoded)
+
+	// The config travels with the graph.
+	var buf bytes.Buffer
+	require.NoError(t, g.Export(&buf))
+	g2 := &Graph[int]{}
+	require.NoError(t, g2.Import(&buf))
+	require.Equal(t, config, g2.Config())
+	require.Equal(t, g.NeighborSelection, g2.NeighborSelection)
+	require.Equal(t, MergeDuplicates, g2.Duplicates)
+	require.Equal(t, g.Hardening.Seed, g2.Hardening.Seed)
+}
@@ -0,0 +1,31 @@
+package hnsw
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Context(t *testing.T) {
+	g := newTestGraph[int]()
+	nodes := make([]Node[int], 128)
+	for i := range nodes {
+		nodes[i] = MakeNode(i, randFloats(2))
+	}
+	require.NoError(t, g.AddContext(context.Background(), nodes...))
+
+	results, err := g.SearchContext(context.Background(), Vector{0.5, 0.5}, 4)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = g.SearchContext(ctx, Vector{0.5, 0.5}, 4)
+	require.ErrorIs(t, err, context.Canceled)
+
+	err = g.AddContext(ctx, MakeNode(1000, randFloats(2)))
+	require.ErrorIs(t, err, context.Canceled)
+	_, ok := g.Lookup(1000)
+	require.False(t, ok)
+}
@@ -0,0 +1,110 @@
+package hnsw
+
+import (
+	"fmt"
+	"slices"
+)
+
+// ConvertKeys returns a copy of g with every key mapped through mapFn,
+// e.g. to migrate from integer IDs to string or UUID keys. The links of
+// g carry over as they are, so no distance is recomputed; the copy
+// searches exactly like g. mapFn must map distinct keys to distinct
+// keys.
+//
+// Vectors and payloads are shared with g, the parameters copied.
+// Subscriptions, hit counts and a migration in progress are not carried
+// over, and the copy gets a new Rng and its own Hardening random source.
+func ConvertKeys[K1, K2 comparable](g *Graph[K1], mapFn func(K1) K2) (*Graph[K2], error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	keys := make(map[K1]K2, g.len())
+	if len(g.layers) > 0 {
+		seen := make(map[K2]K1, len(g.layers[0].nodes))
+		for key := range g.layers[0].nodes {
+			mapped := mapFn(key)
+			if other, ok := seen[mapped]; ok {
+				return nil, fmt.Errorf("keys %v and %v both map to %v", other, key, mapped)
+			}
+			seen[mapped] = key
+			keys[key] = mapped
+		}
+	}
+
+	c := &Graph[K2]{
+		Distance:          g.Distance,
+		Rng:               defaultRand(),
+		M:                 g.M,
+		M0:                g.M0,
+		Ml:                g.Ml,
+		EfSearch:          g.EfSearch,
+		EfConstruction:    g.EfConstruction,
+		HitSampling:       g.HitSampling,
+		HashLevels:        g.HashLevels,
+		DisablePooling:    g.DisablePooling,
+		Hardening:         g.Hardening.copy(),
+		DuplicateDistance: g.DuplicateDistance,
+		Duplicates:        g.Duplicates,
+		NeighborSelection: g.NeighborSelection,
+		Fields:            slices.Clone(g.Fields),
+		stale:             convertSet(g.stale, keys),
+		tombstones:        convertSet(g.tombstones, keys),
+		now:               g.now,
+		sketch: embeddingSketch{
+			count: g.sketch.count,
+			sum:   slices.Clone(g.sketch.sum),
+			sumSq: slices.Clone(g.sketch.sumSq),
+			norms: g.sketch.norms,
+		},
+		version: g.version,
+	}
+	if g.payloads != nil {
+		c.payloads = make(map[K2][]byte, len(g.payloads))
+		for key, payload := range g.payloads {
+			if mapped, ok := keys[key]; ok {
+				c.payloads[mapped] = payload
+			}
+		}
+	}
+
+	c.layers = make([]*layer[K2], len(g.layers))
+	for i, l := range g.layers {
+		// The nodes keep their IDs, so the neighbor lists carry over.
+		cl := &layer[K2]{
+			nodes: make(map[K2]*layerNode[K2], len(l.nodes)),
+			byID:  make([]*layerNode[K2], len(l.byID)),
+			free:  slices.Clone(l.free),
+		}
+		for id, n := range l.byID {
+			if n == nil {
+				continue
+			}
+			mapped := keys[n.Key]
+			converted := &layerNode[K2]{
+				Node:      Node[K2]{Key: mapped, Value: n.Value},
+				neighbors: slices.Clone(n.neighbors),
+				layer:     cl,
+				id:        n.id,
+				added:     n.added,
+			}
+			cl.byID[id] = converted
+			cl.nodes[mapped] = converted
+		}
+		c.layers[i] = cl
+	}
+	return c, nil
+}
+
+// convertSet maps the keys of set that are in keys.
+func convertSet[K1, K2 comparable](set map[K1]struct{}, keys map[K1]K2) map[K2]struct{} {
+	if set == nil {
+		return nil
+	}
+	out := make(map[K2]struct{}, len(set))
+	for key := range set {
+		if mapped, ok := keys[key]; ok {
+			out[mapped] = struct{}{}
+		}
+	}
+	return out
+}
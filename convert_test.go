@@ -0,0 +1,59 @@
+package hnsw
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertKeys(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 300; i++ {
+		require.NoError(t, g.Add(MakeNode(i, randFloats(8))))
+	}
+	require.NoError(t, g.SetPayload(7, []byte("seven")))
+	g.MarkDeleted(9)
+
+	name := func(i int) string { return fmt.Sprintf("doc-%d", i) }
+	c, err := ConvertKeys(g, name)
+	require.NoError(t, err)
+	require.NoError(t, c.Verify())
+	require.Equal(t, g.Len(), c.Len())
+
+	// The copy searches exactly like the original.
+	for range 20 {
+		q := randFloats(8)
+		want, err := g.Search(q, 10)
+		require.NoError(t, err)
+		got, err := c.Search(q, 10)
+		require.NoError(t, err)
+		require.Len(t, got, len(want))
+		for i := range want {
+			require.Equal(t, name(want[i].Key), got[i].Key)
+			require.Equal(t, want[i].Distance, got[i].Distance)
+		}
+	}
+	payload, ok := c.Payload("doc-7")
+	require.True(t, ok)
+	require.Equal(t, []byte("seven"), payload)
+	require.Equal(t, 1, c.Tombstones())
+
+	// Both graphs can change independently.
+	require.True(t, c.Delete("doc-3"))
+	_, ok = g.Lookup(3)
+	require.True(t, ok)
+	require.NoError(t, c.Add(MakeNode("new", randFloats(8))))
+	require.NoError(t, c.Verify())
+	require.NoError(t, g.Verify())
+
+	_, err = ConvertKeys(g, func(i int) int { return i / 2 })
+	require.ErrorContains(t, err, "both map to")
+
+	// The copy draws from its own random source.
+	g.Hardening = &Hardening{Jitter: 0.05, Seed: 1}
+	c, err = ConvertKeys(g, name)
+	require.NoError(t, err)
+	require.NotSame(t, g.Hardening, c.Hardening)
+	require.Equal(t, *g.Hardening.copy(), *c.Hardening)
+}
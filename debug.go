@@ -0,0 +1,96 @@
+package hnsw
+
+import (
+	"context"
+	"slices"
+)
+
+// SearchTrace records how a search traversed the graph. See
+// SearchDebug.
+type SearchTrace[K comparable] struct {
+	// Strategy is the strategy the search ran with.
+	Strategy Strategy
+
+	// Layers traces the search in each layer it entered, from the top
+	// layer down. A brute-force search has a single layer 0 trace
+	// without an entry point.
+	Layers []LayerTrace[K]
+
+	// DistanceComputations is the number of distances computed over all
+	// layers, as in SearchStats.
+	DistanceComputations int
+}
+
+// LayerTrace records the search in one layer.
+type LayerTrace[K comparable] struct {
+	Layer int
+
+	// Entry is the node the search entered the layer at, and
+	// EntryDistance its distance to the query.
+	Entry         K
+	EntryDistance float32
+
+	// Hops is the number of nodes expanded, i.e. whose neighbors were
+	// read.
+	Hops int
+
+	// Visited lists the keys whose distance was computed, in order,
+	// starting with the entry.
+	Visited []K
+}
+
+// SearchDebug is like SearchWithOptions but also returns a trace of the
+// search: the entry point of each layer, the hops taken and the nodes
+// visited, e.g. to find out why an expected neighbor was missed. Tracing
+// makes the search slower.
+func (h *Graph[K]) SearchDebug(near Vector, k int, opts SearchOptions[K]) ([]SearchResultNode[K], *SearchTrace[K], error) {
+	trace := &SearchTrace[K]{}
+	opts.trace = trace
+	results, err := h.search(context.Background(), near, k, opts)
+	return results, trace, err
+}
+
+// Visited reports whether the search computed the distance of key in
+// the base layer. A nearest neighbor that was visited but not returned
+// was outranked or filtered out; one that wasn't visited was never
+// reached.
+func (t *SearchTrace[K]) Visited(key K) bool {
+	for _, l := range t.Layers {
+		if l.Layer == 0 && slices.Contains(l.Visited, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// layer starts the trace of a layer and returns it, or returns nil for a
+// nil trace.
+func (t *SearchTrace[K]) layer(i int) *LayerTrace[K] {
+	if t == nil {
+		return nil
+	}
+	t.Layers = append(t.Layers, LayerTrace[K]{Layer: i})
+	return &t.Layers[len(t.Layers)-1]
+}
+
+// enter records the entry point of a layer.
+func (l *LayerTrace[K]) enter(key K, dist float32) {
+	if l != nil {
+		l.Entry, l.EntryDistance = key, dist
+		l.Visited = append(l.Visited, key)
+	}
+}
+
+// hop records the expansion of a node.
+func (l *LayerTrace[K]) hop() {
+	if l != nil {
+		l.Hops++
+	}
+}
+
+// visit records the computation of the distance of key.
+func (l *LayerTrace[K]) visit(key K) {
+	if l != nil {
+		l.Visited = append(l.Visited, key)
+	}
+}
@@ -0,0 +1,57 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_SearchDebug(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, g.Add(MakeNode(i, randFloats(4))))
+	}
+	query := randFloats(4)
+
+	var stats SearchStats
+	want, err := g.SearchWithOptions(query, 5, SearchOptions[int]{Stats: &stats})
+	require.NoError(t, err)
+	results, trace, err := g.SearchDebug(query, 5, SearchOptions[int]{})
+	require.NoError(t, err)
+	require.Equal(t, want, results)
+	require.Equal(t, stats.Strategy, trace.Strategy)
+	require.Equal(t, stats.DistanceComputations, trace.DistanceComputations)
+
+	require.Len(t, trace.Layers, len(g.layers))
+	computed := 0
+	for i, l := range trace.Layers {
+		require.Equal(t, len(g.layers)-1-i, l.Layer)
+		require.Equal(t, l.Entry, l.Visited[0])
+		dist, err := g.Distance(g.layers[l.Layer].nodes[l.Entry].Value, query)
+		require.NoError(t, err)
+		require.Equal(t, dist, l.EntryDistance)
+		require.Positive(t, l.Hops)
+		computed += len(l.Visited)
+		if i > 0 {
+			// Each layer is entered at the closest node of the layer above.
+			above := trace.Layers[i-1]
+			require.Contains(t, above.Visited, l.Entry)
+		}
+	}
+	require.Equal(t, trace.DistanceComputations, computed)
+	for _, r := range results {
+		require.True(t, trace.Visited(r.Key))
+	}
+
+	t.Run("BruteForce", func(t *testing.T) {
+		filter := func(key int) bool { return key%100 == 0 }
+		results, trace, err := g.SearchDebug(query, 5, SearchOptions[int]{Filter: filter})
+		require.NoError(t, err)
+		require.Len(t, results, 5)
+		require.Equal(t, StrategyBruteForce, trace.Strategy)
+		require.Len(t, trace.Layers, 1)
+		require.Len(t, trace.Layers[0].Visited, 10)
+		require.Equal(t, 10, trace.DistanceComputations)
+		require.False(t, trace.Visited(1))
+	})
+}
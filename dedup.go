@@ -0,0 +1,89 @@
+package hnsw
+
+import (
+	"context"
+	"fmt"
+)
+
+// DuplicatePolicy decides what Add does with a node that is within
+// Graph.DuplicateDistance of a node with another key.
+type DuplicatePolicy int
+
+const (
+	// RejectDuplicates fails the Add with a *DuplicateError.
+	RejectDuplicates DuplicatePolicy = iota
+
+	// MergeDuplicates drops the node, as if it had been merged into
+	// the existing one. See AddUnique to learn the existing key.
+	MergeDuplicates
+)
+
+// DuplicateError is returned by Add when a node is rejected as a
+// duplicate.
+type DuplicateError[K comparable] struct {
+	// Key is the key of the rejected node.
+	Key K
+	// Existing is the key of the node it duplicates.
+	Existing K
+	// Distance is the distance between the two.
+	Distance float32
+}
+
+func (e *DuplicateError[K]) Error() string {
+	return fmt.Sprintf("node %v duplicates %v (distance %v)", e.Key, e.Existing, e.Distance)
+}
+
+// AddUnique adds node like Add and returns the key its vector is stored
+// under: node.Key, or with MergeDuplicates, the key of the existing node
+// it duplicates.
+func (g *Graph[K]) AddUnique(node Node[K]) (K, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.addUnique(context.Background(), node)
+}
+
+// addUnique inserts node unless it is a duplicate. The caller must hold
+// the write lock.
+func (g *Graph[K]) addUnique(ctx context.Context, node Node[K]) (K, error) {
+	dup, err := g.duplicateOf(ctx, node)
+	if err != nil {
+		return node.Key, err
+	}
+	if dup != nil {
+		if g.Duplicates == MergeDuplicates {
+			return dup.Key, nil
+		}
+		return node.Key, &DuplicateError[K]{Key: node.Key, Existing: dup.Key, Distance: dup.Distance}
+	}
+
+	level, err := g.randomLevel(node.Key)
+	if err != nil {
+		return node.Key, err
+	}
+	if err := g.insert(node, level); err != nil {
+		return node.Key, err
+	}
+	g.notifyAdd(node)
+	return node.Key, nil
+}
+
+// duplicateOf returns the nearest node with another key within
+// DuplicateDistance of node, or nil. Like any search it is approximate,
+// so a duplicate may occasionally go unnoticed. The caller must hold the
+// lock.
+func (g *Graph[K]) duplicateOf(ctx context.Context, node Node[K]) (*SearchResultNode[K], error) {
+	if g.DuplicateDistance <= 0 || g.len() == 0 {
+		return nil, nil
+	}
+	g.assertDims(node.Value)
+	nearest, err := g.searchScore(ctx, distanceTo[K](node.Value, g.Distance), 1, SearchOptions[K]{
+		Filter: func(key K) bool { return key != node.Key },
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(nearest) == 0 || nearest[0].Distance > g.DuplicateDistance {
+		return nil, nil
+	}
+	return &nearest[0], nil
+}
@@ -0,0 +1,45 @@
+package hnsw
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Duplicates(t *testing.T) {
+	g := newTestGraph[int]()
+	g.DuplicateDistance = 0.1
+	for i := 0; i < 100; i++ {
+		require.NoError(t, g.Add(MakeNode(i, Vector{float32(i)})))
+	}
+
+	err := g.Add(MakeNode(100, Vector{41.95}))
+	var dup *DuplicateError[int]
+	require.True(t, errors.As(err, &dup))
+	require.Equal(t, 100, dup.Key)
+	require.Equal(t, 42, dup.Existing)
+	require.InDelta(t, 0.05, dup.Distance, 1e-4)
+	require.Equal(t, 100, g.Len())
+
+	// Far enough, and replacing a node with a close vector, are fine.
+	require.NoError(t, g.Add(MakeNode(100, Vector{41.5})))
+	require.NoError(t, g.Add(MakeNode(42, Vector{42.01})))
+	require.Equal(t, 101, g.Len())
+
+	g.Duplicates = MergeDuplicates
+	key, err := g.AddUnique(MakeNode(101, Vector{7.02}))
+	require.NoError(t, err)
+	require.Equal(t, 7, key)
+	require.NoError(t, g.AddBatch([]Node[int]{MakeNode(102, Vector{8.01})}, 0))
+	_, ok := g.Lookup(101)
+	require.False(t, ok)
+	_, ok = g.Lookup(102)
+	require.False(t, ok)
+
+	key, err = g.AddUnique(MakeNode(103, Vector{200}))
+	require.NoError(t, err)
+	require.Equal(t, 103, key)
+	require.Equal(t, 102, g.Len())
+	require.NoError(t, g.Verify())
+}
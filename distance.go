@@ -28,7 +28,9 @@ func EuclideanDistance(a, b []float32) (float32, error) {
 	if len(a) != len(b) {
 		return 0, ErrDifferentVectorLengths
 	}
-	// TODO: can we speedup with vek?
+	// vek32.Distance is faster, but its square root is approximate:
+	// e.g. it returns 0.99999994 for a distance of 1, which breaks ties
+	// and exact matches.
 	var sum float32 = 0
 	for i := range a {
 		diff := a[i] - b[i]
@@ -37,9 +39,61 @@ func EuclideanDistance(a, b []float32) (float32, error) {
 	return math32.Sqrt(sum), nil
 }
 
+// DotProductDistance computes the negative inner product of two vectors,
+// so that maximum inner product search (MIPS) becomes a nearest neighbor
+// search. Distances can be negative.
+//
+// The inner product is not a metric: a vector isn't necessarily closest
+// to itself, and vectors with large norms are close to everything, which
+// makes the graph poorly navigable. If the norms of the vectors vary
+// a lot, consider MIPSTransform with EuclideanDistance instead.
+func DotProductDistance(a, b []float32) (float32, error) {
+	if len(a) != len(b) {
+		return 0, ErrDifferentVectorLengths
+	}
+	return -vek32.Dot(a, b), nil
+}
+
+// MIPSTransform reduces maximum inner product search over vecs to a
+// nearest neighbor search under EuclideanDistance. Each vector x gets an
+// extra dimension sqrt(maxNorm² - |x|²) so that all vectors have the same
+// norm; queries are extended with a zero via MIPSQuery. The Euclidean
+// order of the transformed vectors is then the inner product order of
+// the originals.
+//
+// It returns the transformed vectors and maxNorm, the largest norm of
+// vecs. Vectors added later must be transformed with MIPSVector and the
+// same maxNorm, and must not have a larger norm.
+func MIPSTransform(vecs []Vector) ([]Vector, float32) {
+	var maxNorm float32
+	for _, v := range vecs {
+		maxNorm = max(maxNorm, vek32.Norm(v))
+	}
+	out := make([]Vector, len(vecs))
+	for i, v := range vecs {
+		out[i] = MIPSVector(v, maxNorm)
+	}
+	return out, maxNorm
+}
+
+// MIPSVector transforms a stored vector for MIPS. See MIPSTransform.
+func MIPSVector(v Vector, maxNorm float32) Vector {
+	norm := vek32.Norm(v)
+	// Clamp rounding errors that would make the square root NaN.
+	extra := math32.Sqrt(max(0, maxNorm*maxNorm-norm*norm))
+	return append(append(make(Vector, 0, len(v)+1), v...), extra)
+}
+
+// MIPSQuery transforms a query vector for MIPS. See MIPSTransform.
+func MIPSQuery(q Vector) Vector {
+	return append(append(make(Vector, 0, len(q)+1), q...), 0)
+}
+
 var distanceFuncs = map[string]DistanceFunc{
-	"euclidean": EuclideanDistance,
-	"cosine":    CosineDistance,
+	"euclidean":  EuclideanDistance,
+	"cosine":     CosineDistance,
+	"dot":        DotProductDistance,
+	"sparse-dot": SparseDotProductDistance,
 }
 
 func distanceFuncToName(fn DistanceFunc) (string, bool) {
@@ -35,6 +35,37 @@ func TestCosineSimilarity(t *testing.T) {
 	require.InDelta(t, 0, distance, 0.000001)
 }
 
+func TestDotProductDistance(t *testing.T) {
+	distance, err := DotProductDistance([]float32{1, 2, 3}, []float32{4, 5, 6})
+	require.NoError(t, err)
+	require.Equal(t, float32(-32), distance)
+
+	_, err = DotProductDistance([]float32{1}, []float32{1, 2})
+	require.ErrorIs(t, err, ErrDifferentVectorLengths)
+
+	name, ok := distanceFuncToName(DotProductDistance)
+	require.True(t, ok)
+	require.Equal(t, "dot", name)
+}
+
+func TestMIPSTransform(t *testing.T) {
+	// The largest inner product with q is with the long vector c,
+	// although b points in the same direction as q.
+	q := Vector{1, 0}
+	vecs := []Vector{{0, 1}, {0.5, 0}, {3, 3}}
+	transformed, maxNorm := MIPSTransform(vecs)
+	require.InDelta(t, 4.2426, maxNorm, 1e-4)
+
+	g := newTestGraph[int]()
+	for i, v := range transformed {
+		require.Len(t, v, 3)
+		g.Add(MakeNode(i, v))
+	}
+	results, err := g.Search(MIPSQuery(q), 1)
+	require.NoError(t, err)
+	require.Equal(t, 2, results[0].Key)
+}
+
 func BenchmarkCosineSimilarity(b *testing.B) {
 	v1 := randFloats(1536)
 	v2 := randFloats(1536)
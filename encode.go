@@ -2,11 +2,16 @@ package hnsw
 
 import (
 	"bufio"
-	"cmp"
 	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
+	"math"
 	"os"
+	"slices"
 
 	"github.com/google/renameio"
 )
@@ -89,6 +94,7 @@ func binaryWrite(w io.Writer, data any) (int, error) {
 		if err != nil {
 			return n, err
 		}
+		v = canonicalNaNs(v)
 		return n + binary.Size(v), binary.Write(w, byteOrder, v)
 
 	default:
@@ -101,6 +107,37 @@ func binaryWrite(w io.Writer, data any) (int, error) {
 	}
 }
 
+// canonicalNaN is the bit pattern NaNs are encoded with. NaNs produced
+// by arithmetic differ in sign and payload bits between architectures.
+const canonicalNaN = 0x7fc00000
+
+// canonicalNaNs returns v with every NaN replaced by canonicalNaN. v is
+// copied only if it contains a NaN.
+func canonicalNaNs(v []float32) []float32 {
+	copied := false
+	for i, f := range v {
+		if !math.IsNaN(float64(f)) || math.Float32bits(f) == canonicalNaN {
+			continue
+		}
+		if !copied {
+			v = slices.Clone(v)
+			copied = true
+		}
+		v[i] = math.Float32frombits(canonicalNaN)
+	}
+	return v
+}
+
+// sortedMapKeys returns the keys of m in ascending order.
+func sortedMapKeys[K comparable, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, keyOrder[K]())
+	return keys
+}
+
 func multiBinaryWrite(w io.Writer, data ...any) (int, error) {
 	var written int
 	for _, d := range data {
@@ -125,23 +162,79 @@ func multiBinaryRead(r io.Reader, data ...any) (int, error) {
 	return read, nil
 }
 
-const encodingVersion = 1
+// encodingVersion is the version written by Export. Version 2 added
+// EfConstruction, version 3 payloads, version 4 the GraphConfig and
+// version 5 the checksum; Import still reads older versions.
+const encodingVersion = 5
+
+// checksumWriter computes the CRC-32 of the bytes written through it.
+type checksumWriter struct {
+	w   io.Writer
+	crc hash.Hash32
+}
+
+func (c *checksumWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.crc.Write(p[:n])
+	return n, err
+}
+
+// checksumReader computes the CRC-32 of the bytes read through it.
+type checksumReader struct {
+	r   io.Reader
+	crc hash.Hash32
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.crc.Write(p[:n])
+	return n, err
+}
+
+func (c *checksumReader) ReadByte() (byte, error) {
+	br, ok := c.r.(io.ByteReader)
+	if !ok {
+		return 0, errors.New("reader does not implement io.ByteReader")
+	}
+	b, err := br.ReadByte()
+	if err == nil {
+		c.crc.Write([]byte{b})
+	}
+	return b, err
+}
 
 // Export writes the graph to a writer.
 //
 // T must implement io.WriterTo.
+//
+// The encoding is the same on every platform: numbers are little-endian,
+// NaNs are canonicalized and everything is written in key order, so a
+// graph encodes to the same bytes wherever it is exported. It ends with
+// a CRC-32 of the bytes before it, which Import checks.
 func (h *Graph[K]) Export(w io.Writer) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	cw := &checksumWriter{w: w, crc: crc32.NewIEEE()}
+	w = cw
+
 	distFuncName, ok := distanceFuncToName(h.Distance)
 	if !ok {
 		return fmt.Errorf("distance function %v must be registered with RegisterDistanceFunc", h.Distance)
 	}
-	_, err := multiBinaryWrite(
+	config, err := h.marshalConfig()
+	if err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+	_, err = multiBinaryWrite(
 		w,
 		encodingVersion,
 		h.M,
 		h.Ml,
 		h.EfSearch,
+		h.EfConstruction,
 		distFuncName,
+		config,
 	)
 	if err != nil {
 		return fmt.Errorf("encode parameters: %w", err)
@@ -150,18 +243,41 @@ func (h *Graph[K]) Export(w io.Writer) error {
 	if err != nil {
 		return fmt.Errorf("encode number of layers: %w", err)
 	}
+	// Nodes marked with MarkDeleted are left out as if they were deleted.
+	skip := func(node *layerNode[K]) bool {
+		return node != nil && (node.removed || h.isTombstone(node.Key))
+	}
 	for _, layer := range h.layers {
-		_, err = binaryWrite(w, len(layer.nodes))
+		var tombstones int
+		for key := range h.tombstones {
+			if _, ok := layer.nodes[key]; ok {
+				tombstones++
+			}
+		}
+		_, err = binaryWrite(w, len(layer.nodes)-tombstones)
 		if err != nil {
 			return fmt.Errorf("encode number of nodes: %w", err)
 		}
-		for _, node := range layer.nodes {
-			_, err = multiBinaryWrite(w, node.Key, node.Value, len(node.neighbors))
+		// Nodes, neighbors and payloads are written in key order so that
+		// the same graph always encodes to the same bytes.
+		for _, key := range sortedMapKeys(layer.nodes) {
+			node := layer.nodes[key]
+			if skip(node) {
+				continue
+			}
+			var neighbors []K
+			for _, neighbor := range liveNeighbors(node) {
+				if !skip(neighbor) {
+					neighbors = append(neighbors, neighbor.Key)
+				}
+			}
+			slices.SortFunc(neighbors, keyOrder[K]())
+			_, err = multiBinaryWrite(w, node.Key, node.Value, len(neighbors))
 			if err != nil {
 				return fmt.Errorf("encode node data: %w", err)
 			}
 
-			for neighbor := range node.neighbors {
+			for _, neighbor := range neighbors {
 				_, err = binaryWrite(w, neighbor)
 				if err != nil {
 					return fmt.Errorf("encode neighbor %v: %w", neighbor, err)
@@ -170,6 +286,30 @@ func (h *Graph[K]) Export(w io.Writer) error {
 		}
 	}
 
+	var tombstonePayloads int
+	for key := range h.tombstones {
+		if _, ok := h.payloads[key]; ok {
+			tombstonePayloads++
+		}
+	}
+	_, err = binaryWrite(w, len(h.payloads)-tombstonePayloads)
+	if err != nil {
+		return fmt.Errorf("encode number of payloads: %w", err)
+	}
+	for _, key := range sortedMapKeys(h.payloads) {
+		if h.isTombstone(key) {
+			continue
+		}
+		_, err = multiBinaryWrite(w, key, string(h.payloads[key]))
+		if err != nil {
+			return fmt.Errorf("encode payload of %v: %w", key, err)
+		}
+	}
+
+	_, err = binaryWrite(cw.w, cw.crc.Sum32())
+	if err != nil {
+		return fmt.Errorf("encode checksum: %w", err)
+	}
 	return nil
 }
 
@@ -177,17 +317,60 @@ func (h *Graph[K]) Export(w io.Writer) error {
 // T must implement io.ReaderFrom.
 // The imported graph does not have to match the exported graph's parameters (except for
 // dimensionality). The graph will converge onto the new parameters.
+//
+// Import fails if the checksum written by Export doesn't match, e.g.
+// because the file was corrupted on disk.
 func (h *Graph[K]) Import(r io.Reader) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cr := &checksumReader{r: r, crc: crc32.NewIEEE()}
+	r = cr
+
 	var (
 		version int
 		dist    string
 	)
-	_, err := multiBinaryRead(r, &version, &h.M, &h.Ml, &h.EfSearch,
-		&dist,
-	)
+	_, err := multiBinaryRead(r, &version, &h.M, &h.Ml, &h.EfSearch)
 	if err != nil {
 		return err
 	}
+	if version < 1 || version > encodingVersion {
+		return fmt.Errorf("incompatible encoding version: %d", version)
+	}
+	if version >= 2 {
+		_, err = binaryRead(r, &h.EfConstruction)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = binaryRead(r, &dist)
+	if err != nil {
+		return err
+	}
+	h.version = ""
+	if version >= 4 {
+		var (
+			raw    string
+			config GraphConfig
+		)
+		_, err = binaryRead(r, &raw)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(raw), &config); err != nil {
+			return fmt.Errorf("decoding config: %w", err)
+		}
+		h.M0 = config.M0
+		h.Fields = config.Fields
+		h.HitSampling = config.HitSampling
+		h.HashLevels = config.HashLevels
+		h.NeighborSelection = config.NeighborSelection
+		h.DuplicateDistance = config.DuplicateDistance
+		h.Duplicates = config.Duplicates
+		h.Hardening = config.Hardening
+		h.version = config.Version
+	}
 
 	var ok bool
 	h.Distance, ok = distanceFuncs[dist]
@@ -198,10 +381,6 @@ func (h *Graph[K]) Import(r io.Reader) error {
 		h.Rng = defaultRand()
 	}
 
-	if version != encodingVersion {
-		return fmt.Errorf("incompatible encoding version: %d", version)
-	}
-
 	var nLayers int
 	_, err = binaryRead(r, &nLayers)
 	if err != nil {
@@ -216,7 +395,12 @@ func (h *Graph[K]) Import(r io.Reader) error {
 			return err
 		}
 
-		nodes := make(map[K]*layerNode[K], nNodes)
+		l := &layer[K]{
+			nodes: make(map[K]*layerNode[K], nNodes),
+			byID:  make([]*layerNode[K], 0, nNodes),
+		}
+		// The neighbors are linked once all the nodes have IDs.
+		neighborKeys := make([][]K, 0, nNodes)
 		for j := 0; j < nNodes; j++ {
 			var key K
 			var vec Vector
@@ -236,28 +420,66 @@ func (h *Graph[K]) Import(r io.Reader) error {
 				neighbors[k] = neighbor
 			}
 
-			node := &layerNode[K]{
+			l.add(&layerNode[K]{
 				Node: Node[K]{
 					Key:   key,
 					Value: vec,
 				},
-				neighbors: make(map[K]*layerNode[K]),
+			})
+			neighborKeys = append(neighborKeys, neighbors)
+		}
+		for id, keys := range neighborKeys {
+			node := l.byID[id]
+			node.neighbors = make([]uint32, 0, len(keys))
+			for _, key := range keys {
+				neighbor := l.nodes[key]
+				if neighbor == nil {
+					return fmt.Errorf("node %v in layer %d links to unknown node %v", node.Key, i, key)
+				}
+				node.neighbors = append(node.neighbors, neighbor.id)
 			}
+		}
+		h.layers[i] = l
+	}
+	h.sketch = embeddingSketch{}
+	if len(h.layers) > 0 {
+		h.sketch = rebuildSketch(h.layers[0])
+	}
 
-			nodes[key] = node
-			for _, neighbor := range neighbors {
-				node.neighbors[neighbor] = nil
-			}
+	h.payloads = nil
+	if version >= 3 {
+		var nPayloads int
+		_, err = binaryRead(r, &nPayloads)
+		if err != nil {
+			return err
 		}
-		// Fill in neighbor pointers
-		for _, node := range nodes {
-			for key := range node.neighbors {
-				node.neighbors[key] = nodes[key]
+		if nPayloads > 0 {
+			h.payloads = make(map[K][]byte, nPayloads)
+		}
+		for i := 0; i < nPayloads; i++ {
+			var (
+				key     K
+				payload string
+			)
+			_, err = multiBinaryRead(r, &key, &payload)
+			if err != nil {
+				return fmt.Errorf("decoding payload %d: %w", i, err)
 			}
+			h.payloads[key] = []byte(payload)
 		}
-		h.layers[i] = &layer[K]{nodes: nodes}
 	}
 
+	if version >= 5 {
+		sum := cr.crc.Sum32()
+		var want uint32
+		_, err = binaryRead(cr.r, &want)
+		if err != nil {
+			return fmt.Errorf("decoding checksum: %w", err)
+		}
+		if sum != want {
+			return fmt.Errorf("checksum mismatch: %08x != %08x", sum, want)
+		}
+	}
 	return nil
 }
 
@@ -265,7 +487,10 @@ func (h *Graph[K]) Import(r io.Reader) error {
 // changes to a file upon calls to Save. It is more convenient
 // but less powerful than calling Graph.Export and Graph.Import
 // directly.
-type SavedGraph[K cmp.Ordered] struct {
+//
+// Save may be called while other goroutines use the graph; it
+// writes a consistent snapshot.
+type SavedGraph[K comparable] struct {
 	*Graph[K]
 	Path string
 }
@@ -277,7 +502,35 @@ type SavedGraph[K cmp.Ordered] struct {
 //
 // It does not hold open a file descriptor, so SavedGraph can be forgotten
 // without ever calling Save.
-func LoadSavedGraph[K cmp.Ordered](path string) (*SavedGraph[K], error) {
+func LoadSavedGraph[K comparable](path string) (*SavedGraph[K], error) {
+	return Open[K](path)
+}
+
+type openOptions struct {
+	selfTest bool
+}
+
+// OpenOption configures Open.
+type OpenOption func(*openOptions)
+
+// WithSelfTest makes Open validate the graph after loading it. The
+// graph's structural invariants are checked (see Graph.Verify) and a
+// sample of stored vectors is searched for. Open fails if the graph
+// is inconsistent, so a bad file is caught before it serves wrong
+// neighbors.
+func WithSelfTest() OpenOption {
+	return func(o *openOptions) {
+		o.selfTest = true
+	}
+}
+
+// Open is like LoadSavedGraph but accepts options.
+func Open[K comparable](path string, opts ...OpenOption) (*SavedGraph[K], error) {
+	var o openOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
 	if err != nil {
 		return nil, err
@@ -296,6 +549,13 @@ func LoadSavedGraph[K cmp.Ordered](path string) (*SavedGraph[K], error) {
 		}
 	}
 
+	if o.selfTest {
+		err = g.selfTest()
+		if err != nil {
+			return nil, fmt.Errorf("self-test: %w", err)
+		}
+	}
+
 	return &SavedGraph[K]{Graph: g, Path: path}, nil
 }
 
@@ -1,8 +1,14 @@
 package hnsw
 
 import (
+	"bufio"
 	"bytes"
-	"cmp"
+	"encoding/binary"
+	"flag"
+	"math"
+	"os"
+	"slices"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -49,10 +55,15 @@ func Test_binaryWrite_string(t *testing.T) {
 	require.Empty(t, buf.Bytes())
 }
 
-func verifyGraphNodes[K cmp.Ordered](t *testing.T, g *Graph[K]) {
+func verifyGraphNodes[K comparable](t *testing.T, g *Graph[K]) {
 	for _, layer := range g.layers {
 		for _, node := range layer.nodes {
-			for neighborKey, neighbor := range node.neighbors {
+			for _, id := range node.neighbors {
+				neighbor := node.neighbor(id)
+				if neighbor == nil {
+					t.Errorf("node %v has removed neighbor %d", node.Key, id)
+					continue
+				}
 				_, ok := layer.nodes[neighbor.Key]
 				if !ok {
 					t.Errorf(
@@ -60,20 +71,13 @@ func verifyGraphNodes[K cmp.Ordered](t *testing.T, g *Graph[K]) {
 						node.Key, neighbor.Key,
 					)
 				}
-
-				if neighborKey != neighbor.Key {
-					t.Errorf("node %v has neighbor %v, but neighbor key is %v", node.Key,
-						neighbor.Key,
-						neighborKey,
-					)
-				}
 			}
 		}
 	}
 }
 
 // requireGraphApproxEquals checks that two graphs are equal.
-func requireGraphApproxEquals[K cmp.Ordered](t *testing.T, g1, g2 *Graph[K]) {
+func requireGraphApproxEquals[K comparable](t *testing.T, g1, g2 *Graph[K]) {
 	require.Equal(t, g1.Len(), g2.Len())
 	a1 := Analyzer[K]{g1}
 	a2 := Analyzer[K]{g2}
@@ -117,6 +121,11 @@ func requireGraphApproxEquals[K cmp.Ordered](t *testing.T, g1, g2 *Graph[K]) {
 		g2.EfSearch,
 	)
 
+	require.Equal(t,
+		g1.EfConstruction,
+		g2.EfConstruction,
+	)
+
 	require.NotNil(t, g1.Rng)
 	require.NotNil(t, g2.Rng)
 }
@@ -159,6 +168,48 @@ func TestGraph_ExportImport(t *testing.T) {
 	verifyGraphNodes(t, g2)
 }
 
+func TestGraph_ImportChecksum(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 32; i++ {
+		require.NoError(t, g.Add(MakeNode(i, Vector{float32(i) + 0.5})))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, g.Export(&buf))
+	data := buf.Bytes()
+	require.NoError(t, (&Graph[int]{}).Import(bytes.NewReader(data)))
+
+	// Flip a bit of a vector: the graph still decodes, but with a wrong
+	// vector.
+	corrupted := slices.Clone(data)
+	i := bytes.Index(corrupted, binary.LittleEndian.AppendUint32(nil, math.Float32bits(7.5)))
+	require.Positive(t, i)
+	corrupted[i] ^= 1
+	require.ErrorContains(t, (&Graph[int]{}).Import(bytes.NewReader(corrupted)), "checksum mismatch")
+
+	// A missing checksum fails too.
+	require.ErrorContains(t, (&Graph[int]{}).Import(bytes.NewReader(data[:len(data)-2])), "decoding checksum")
+}
+
+func TestGraph_ImportVersion1(t *testing.T) {
+	buf := &bytes.Buffer{}
+	// Version 1 did not encode EfConstruction.
+	_, err := multiBinaryWrite(buf, 1, 8, 0.25, 20, "cosine", 0)
+	require.NoError(t, err)
+
+	g := newTestGraph[int]()
+	g.EfConstruction = 30
+	require.NoError(t, g.Import(buf))
+	require.Equal(t, 8, g.M)
+	require.Equal(t, 20, g.EfSearch)
+	require.Equal(t, 30, g.EfConstruction)
+	require.Zero(t, g.Len())
+
+	buf.Reset()
+	_, err = multiBinaryWrite(buf, encodingVersion+1, 8, 0.25, 20)
+	require.NoError(t, err)
+	require.Error(t, g.Import(buf))
+}
+
 func TestSavedGraph(t *testing.T) {
 	dir := t.TempDir()
 
@@ -182,6 +233,86 @@ func TestSavedGraph(t *testing.T) {
 	requireGraphApproxEquals(t, g1.Graph, g2.Graph)
 }
 
+func TestSavedGraph_SaveConcurrent(t *testing.T) {
+	g, err := LoadSavedGraph[int](t.TempDir() + "/graph")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 128; i++ {
+			g.Add(MakeNode(i, randFloats(1)))
+		}
+	}()
+	for i := 0; i < 8; i++ {
+		require.NoError(t, g.Save())
+	}
+	wg.Wait()
+
+	require.NoError(t, g.Save())
+	g2, err := LoadSavedGraph[int](g.Path)
+	require.NoError(t, err)
+	require.Equal(t, 128, g2.Len())
+}
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+// TestGraph_ExportGolden checks that the encoding is byte-for-byte stable:
+// a graph decoded from a golden file encodes back to the same bytes.
+// Regenerate the file with -update after changing the encoding.
+func TestGraph_ExportGolden(t *testing.T) {
+	defer func(v func() string) { packageVersion = v }(packageVersion)
+	packageVersion = func() string { return "v0.0.0-golden" }
+
+	const path = "testdata/graph.golden"
+	if *updateGolden {
+		g := newTestGraph[int]()
+		for i := 0; i < 32; i++ {
+			g.Add(MakeNode(i, Vector{float32(i), float32(i % 7), -float32(i) / 3}))
+		}
+		require.NoError(t, g.SetPayload(3, []byte("three")))
+		require.NoError(t, g.SetPayload(30, []byte("thirty")))
+		var buf bytes.Buffer
+		require.NoError(t, g.Export(&buf))
+		require.NoError(t, os.MkdirAll("testdata", 0o755))
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+	}
+
+	golden, err := os.ReadFile(path)
+	require.NoError(t, err)
+	g := &Graph[int]{}
+	require.NoError(t, g.Import(bufio.NewReader(bytes.NewReader(golden))))
+	require.Equal(t, 32, g.Len())
+	require.NoError(t, g.Verify())
+	payload, _ := g.Payload(30)
+	require.Equal(t, "thirty", string(payload))
+
+	for i := 0; i < 2; i++ {
+		var buf bytes.Buffer
+		require.NoError(t, g.Export(&buf))
+		require.Equal(t, golden, buf.Bytes())
+	}
+}
+
+func TestGraph_ExportCanonicalNaN(t *testing.T) {
+	// A negative NaN with a payload, as produced on some platforms.
+	nan := math.Float32frombits(0xffc00001)
+	vec := Vector{1, nan}
+
+	g := newTestGraph[int]()
+	g.Add(MakeNode(1, vec))
+	var buf bytes.Buffer
+	require.NoError(t, g.Export(&buf))
+	require.Equal(t, uint32(0xffc00001), math.Float32bits(vec[1]), "vector was modified")
+
+	g2 := &Graph[int]{}
+	require.NoError(t, g2.Import(&buf))
+	got, ok := g2.Lookup(1)
+	require.True(t, ok)
+	require.Equal(t, uint32(canonicalNaN), math.Float32bits(got[1]))
+}
+
 const benchGraphSize = 100
 
 func BenchmarkGraph_Import(b *testing.B) {
@@ -0,0 +1,163 @@
+package hnsw
+
+import "fmt"
+
+// EraseReport records what Erase removed, for deletion-compliance
+// records.
+type EraseReport[K comparable] struct {
+	// Erased are the keys that were in the graph and have been removed.
+	Erased []K
+	// Missing are the keys that weren't in the graph.
+	Missing []K
+
+	// LayerEntries is the number of nodes removed across all layers.
+	LayerEntries int
+	// Payloads is the number of payloads removed.
+	Payloads int
+	// Migration is the number of nodes removed from the vector space
+	// being migrated to, if a migration is in progress.
+	Migration int
+
+	// Files are the files rewritten without the erased data, by
+	// SavedGraph.Erase and WALGraph.Erase.
+	Files []string
+
+	// Verified reports that a scan after the erasure found none of the
+	// keys anywhere in the graph: not as a node, a neighbor, or in the
+	// payloads, hit counts or marks.
+	Verified bool
+}
+
+// Erase deletes the nodes with the given keys and drops every record of
+// them the graph holds: their nodes, links, payloads, hit counts and
+// marks. It returns a report of what was removed, verified by a scan of
+// the graph.
+//
+// Vectors and payloads are not copied on insertion, so the graph doesn't
+// own their memory and leaves it as it is: the caller's slices and
+// snapshots of the graph, e.g. of a SnapshotGraph, may still hold them.
+// Erase doesn't touch files; see SavedGraph.Erase and WALGraph.Erase
+// for that.
+func (g *Graph[K]) Erase(keys ...K) EraseReport[K] {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var report EraseReport[K]
+	erase := make(map[K]bool, len(keys))
+	for _, key := range keys {
+		if erase[key] {
+			continue
+		}
+		if g.level(key) < 0 {
+			report.Missing = append(report.Missing, key)
+			continue
+		}
+		erase[key] = true
+		report.Erased = append(report.Erased, key)
+	}
+
+	// top is the highest layer holding an erased node.
+	top := -1
+	for _, key := range report.Erased {
+		top = max(top, g.level(key))
+		if _, ok := g.payloads[key]; ok {
+			report.Payloads++
+		}
+		for _, layer := range g.layers {
+			if _, ok := layer.nodes[key]; ok {
+				report.LayerEntries++
+			}
+		}
+		if g.next != nil && g.next.level(key) >= 0 {
+			report.Migration++
+		}
+		g.markTombstone(key)
+	}
+	// Unlink the nodes in bulk, along with any other marked nodes.
+	g.compact()
+
+	g.hits.mu.Lock()
+	for key := range erase {
+		delete(g.hits.hits, key)
+	}
+	g.hits.mu.Unlock()
+
+	report.Verified = g.verifyErased(erase, top) == nil
+	return report
+}
+
+// verifyErased returns an error if any of keys is still referenced by
+// the graph. Links to removed nodes only count up to layer top, the
+// highest that held one of keys: compact doesn't sweep the layers above,
+// so links found there aren't the erasure's to clear. The caller must
+// hold the lock.
+func (g *Graph[K]) verifyErased(keys map[K]bool, top int) error {
+	for i, layer := range g.layers {
+		for key, node := range layer.nodes {
+			if keys[key] {
+				return fmt.Errorf("node %v remains in layer %d", key, i)
+			}
+			for _, id := range node.neighbors {
+				neighbor := node.neighbor(id)
+				if neighbor == nil {
+					if i > top {
+						continue
+					}
+					return fmt.Errorf("node %v in layer %d links to a removed node", key, i)
+				}
+				if keys[neighbor.Key] {
+					return fmt.Errorf("node %v in layer %d links to %v", key, i, neighbor.Key)
+				}
+			}
+		}
+	}
+	for key := range keys {
+		if _, ok := g.payloads[key]; ok {
+			return fmt.Errorf("payload of %v remains", key)
+		}
+		if _, ok := g.stale[key]; ok {
+			return fmt.Errorf("stale mark of %v remains", key)
+		}
+		if g.isTombstone(key) {
+			return fmt.Errorf("tombstone of %v remains", key)
+		}
+		g.hits.mu.Lock()
+		_, ok := g.hits.hits[key]
+		g.hits.mu.Unlock()
+		if ok {
+			return fmt.Errorf("hit count of %v remains", key)
+		}
+		if g.next != nil && g.next.level(key) >= 0 {
+			return fmt.Errorf("node %v remains in the migration", key)
+		}
+	}
+	return nil
+}
+
+// Erase erases the nodes like Graph.Erase and saves the graph, so that
+// the file no longer contains them. The file is replaced rather than
+// overwritten in place; the storage may keep the old blocks until they
+// are reused.
+func (g *SavedGraph[K]) Erase(keys ...K) (EraseReport[K], error) {
+	report := g.Graph.Erase(keys...)
+	if err := g.Save(); err != nil {
+		return report, fmt.Errorf("saving: %w", err)
+	}
+	report.Files = append(report.Files, g.Path)
+	return report, nil
+}
+
+// Erase erases the nodes like Graph.Erase and checkpoints the graph, so
+// that neither the snapshot nor the write-ahead log, which holds the
+// vectors of recent additions, contains them any more.
+func (g *WALGraph[K]) Erase(keys ...K) (EraseReport[K], error) {
+	g.walMu.Lock()
+	defer g.walMu.Unlock()
+
+	report := g.saved.Graph.Erase(keys...)
+	if err := g.checkpoint(); err != nil {
+		return report, err
+	}
+	report.Files = append(report.Files, g.saved.Path, g.log.Name())
+	return report, nil
+}
@@ -0,0 +1,141 @@
+package hnsw
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Erase(t *testing.T) {
+	g := newTestGraph[int]()
+	g.HitSampling = 1
+	vecs := make([]Vector, 200)
+	for i := range vecs {
+		vecs[i] = Vector{float32(i) + 0.5}
+		require.NoError(t, g.Add(MakeNode(i, vecs[i])))
+	}
+	payload := []byte("secret")
+	require.NoError(t, g.SetPayload(7, payload))
+	_, err := g.Search(Vector{7}, 3)
+	require.NoError(t, err)
+	g.MarkStale(7)
+
+	report := g.Erase(7, 8, 8, 1000)
+	require.Equal(t, []int{7, 8}, report.Erased)
+	require.Equal(t, []int{1000}, report.Missing)
+	require.Equal(t, 1, report.Payloads)
+	require.GreaterOrEqual(t, report.LayerEntries, 2)
+	require.True(t, report.Verified)
+
+	require.Equal(t, 198, g.Len())
+	require.NoError(t, g.Verify())
+	require.NotContains(t, g.HitCounts(), 7)
+	// The caller's slices are left alone.
+	require.Equal(t, Vector{7.5}, vecs[7])
+	require.Equal(t, []byte("secret"), payload)
+}
+
+func TestGraph_EraseMultiLayer(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := range 500 {
+		require.NoError(t, g.Add(MakeNode(i, randFloats(4))))
+	}
+	require.Greater(t, len(g.layers), 2)
+	// A link to a removed node that no sweep reached.
+	l := g.layers[1]
+	l.byID = append(l.byID, nil)
+	node := l.entry()
+	node.neighbors = append(node.neighbors, uint32(len(l.byID)-1))
+
+	// Erasing nodes of the base layer only sweeps that layer, which
+	// doesn't make the upper layers' links an erasure failure.
+	var base []int
+	for key := range g.layers[0].nodes {
+		if g.level(key) == 0 && len(base) < 5 {
+			base = append(base, key)
+		}
+	}
+	report := g.Erase(base...)
+	require.Len(t, report.Erased, 5)
+	require.True(t, report.Verified)
+
+	// Nodes of the upper layers are verified across them.
+	top := g.layers[len(g.layers)-1].entry().Key
+	report = g.Erase(top)
+	require.True(t, report.Verified)
+}
+
+func TestGraph_EraseWhileSearchingSnapshot(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := range 200 {
+		require.NoError(t, g.Add(MakeNode(i, Vector{float32(i)})))
+	}
+	require.NoError(t, g.SetPayload(7, []byte("secret")))
+	s := NewSnapshotGraph(g)
+	snapshot := s.Snapshot()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			results, err := snapshot.Search(Vector{7}, 1)
+			if err != nil || len(results) != 1 || results[0].Key != 7 || results[0].Value[0] != 7 {
+				t.Errorf("snapshot search: %v, %v", results, err)
+				return
+			}
+		}
+	}()
+	require.NoError(t, s.Update(func(g *Graph[int]) error {
+		report := g.Erase(7)
+		require.True(t, report.Verified)
+		return nil
+	}))
+	<-done
+
+	// The snapshot keeps the node until the next Publish.
+	vec, ok := snapshot.Lookup(7)
+	require.True(t, ok)
+	require.Equal(t, Vector{7}, vec)
+	payload, ok := snapshot.Payload(7)
+	require.True(t, ok)
+	require.Equal(t, []byte("secret"), payload)
+	s.Publish()
+	_, ok = s.Lookup(7)
+	require.False(t, ok)
+}
+
+func TestWALGraph_Erase(t *testing.T) {
+	path := t.TempDir() + "/graph"
+	g, err := OpenWAL[int](path)
+	require.NoError(t, err)
+	for i := 0; i < 32; i++ {
+		require.NoError(t, g.Add(MakeNode(i, Vector{float32(i) + 0.25})))
+	}
+	require.NoError(t, g.Checkpoint())
+	// A marker value, only in the log.
+	require.NoError(t, g.Add(MakeNode(100, Vector{12345.5})))
+
+	report, err := g.Erase(3, 100)
+	require.NoError(t, err)
+	require.True(t, report.Verified)
+	require.Equal(t, []string{path, path + ".wal"}, report.Files)
+	require.NoError(t, g.Close())
+
+	marker := binary.LittleEndian.AppendUint32(nil, math.Float32bits(12345.5))
+	for _, file := range report.Files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		require.False(t, bytes.Contains(data, marker), file)
+	}
+
+	g, err = OpenWAL[int](path)
+	require.NoError(t, err)
+	defer g.Close()
+	require.Equal(t, 31, g.Len())
+	_, ok := g.Lookup(3)
+	require.False(t, ok)
+}
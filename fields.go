@@ -0,0 +1,57 @@
+package hnsw
+
+import "fmt"
+
+// Field names a segment of the vectors in a graph, e.g. the title part
+// of an embedding that concatenates a title and a body embedding.
+type Field struct {
+	Name string
+
+	// Start and End delimit the dimensions of the field: [Start, End).
+	Start, End int
+}
+
+// fieldDistance returns a DistanceFunc computing the weighted sum of
+// distance over the weighted fields. Fields without a weight are left
+// out.
+func fieldDistance(fields []Field, weights map[string]float32, distance DistanceFunc, dims int) (DistanceFunc, error) {
+	type weightedField struct {
+		Field
+		weight float32
+	}
+	var weighted []weightedField
+	for name, weight := range weights {
+		i := -1
+		for j, f := range fields {
+			if f.Name == name {
+				i = j
+				break
+			}
+		}
+		if i == -1 {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		f := fields[i]
+		if f.Start < 0 || f.Start >= f.End || f.End > dims {
+			return nil, fmt.Errorf("field %q spans [%d, %d), outside of %d dimensions", f.Name, f.Start, f.End, dims)
+		}
+		if weight != 0 {
+			weighted = append(weighted, weightedField{Field: f, weight: weight})
+		}
+	}
+
+	return func(a, b []float32) (float32, error) {
+		if len(a) != len(b) {
+			return 0, ErrDifferentVectorLengths
+		}
+		var sum float32
+		for _, f := range weighted {
+			d, err := distance(a[f.Start:f.End], b[f.Start:f.End])
+			if err != nil {
+				return 0, err
+			}
+			sum += f.weight * d
+		}
+		return sum, nil
+	}, nil
+}
@@ -0,0 +1,40 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_SearchFieldWeights(t *testing.T) {
+	g := newTestGraph[string]()
+	g.Fields = []Field{
+		{Name: "title", Start: 0, End: 2},
+		{Name: "body", Start: 2, End: 4},
+	}
+	g.Add(
+		MakeNode("title match", Vector{1, 0, 0, 1}),
+		MakeNode("body match", Vector{0, 1, 1, 0}),
+	)
+
+	query := Vector{1, 0, 1, 0}
+
+	results, err := g.SearchWithOptions(query, 1, SearchOptions[string]{
+		FieldWeights: map[string]float32{"title": 1},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "title match", results[0].Key)
+	require.Zero(t, results[0].Distance)
+
+	results, err = g.SearchWithOptions(query, 1, SearchOptions[string]{
+		FieldWeights: map[string]float32{"title": 1, "body": 3},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "body match", results[0].Key)
+	require.InDelta(t, 1.4142135, results[0].Distance, 1e-6)
+
+	_, err = g.SearchWithOptions(query, 1, SearchOptions[string]{
+		FieldWeights: map[string]float32{"summary": 1},
+	})
+	require.ErrorContains(t, err, `unknown field "summary"`)
+}
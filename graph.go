@@ -1,8 +1,9 @@
 package hnsw
 
 import (
-	"cmp"
+	"context"
 	"fmt"
+	"hash/fnv"
 	"math"
 	"math/rand"
 	"slices"
@@ -10,18 +11,17 @@ import (
 	"time"
 
 	"github.com/hypermodeinc/hnsw/heap"
-	"golang.org/x/exp/maps"
 )
 
 type Vector = []float32
 
 // Node is a node in the graph.
-type Node[K cmp.Ordered] struct {
+type Node[K comparable] struct {
 	Key   K
 	Value Vector
 }
 
-func MakeNodes[K cmp.Ordered](keys []K, vecs []Vector) ([]Node[K], error) {
+func MakeNodes[K comparable](keys []K, vecs []Vector) ([]Node[K], error) {
 	if len(keys) != len(vecs) {
 		return nil, fmt.Errorf("keys and vecs must have the same length")
 	}
@@ -33,28 +33,64 @@ func MakeNodes[K cmp.Ordered](keys []K, vecs []Vector) ([]Node[K], error) {
 	return nodes, nil
 }
 
-func MakeNode[K cmp.Ordered](key K, vec Vector) Node[K] {
+func MakeNode[K comparable](key K, vec Vector) Node[K] {
 	return Node[K]{Key: key, Value: vec}
 }
 
 // layerNode is a node in a layer of the graph.
-type layerNode[K cmp.Ordered] struct {
+type layerNode[K comparable] struct {
 	Node[K]
 
-	// neighbors is map of neighbor keys to neighbor nodes.
-	// It is a map and not a slice to allow for efficient deletes, esp.
-	// when M is high.
-	neighbors map[K]*layerNode[K]
+	// neighbors are the IDs of the node's neighbors in its layer. IDs
+	// take 4 bytes per edge, where a map of keys to nodes took tens of
+	// bytes, and keep the edges of a node together in memory.
+	neighbors []uint32
+
+	// layer is the layer the node belongs to, which resolves the IDs.
+	layer *layer[K]
+
+	// id is the ID of the node in its layer.
+	id uint32
+
+	// removed is set once the node is deleted from its layer. Edges are
+	// not always bi-directional, so other nodes may still point at a
+	// removed node. Such edges are ignored, and pruned when the
+	// neighbor set overflows.
+	removed bool
+
+	// added is when the node was inserted, in Unix nanoseconds. It is
+	// zero for nodes loaded with Import.
+	added int64
+}
+
+// neighbor returns the node with the given ID in n's layer, or nil if it
+// has been removed.
+func (n *layerNode[K]) neighbor(id uint32) *layerNode[K] {
+	return n.layer.byID[id]
+}
+
+// hasNeighbor reports whether n links to o.
+func (n *layerNode[K]) hasNeighbor(o *layerNode[K]) bool {
+	return slices.Contains(n.neighbors, o.id)
+}
+
+// unlink removes the edge from n to the node with the given ID.
+func (n *layerNode[K]) unlink(id uint32) {
+	if i := slices.Index(n.neighbors, id); i >= 0 {
+		n.neighbors = slices.Delete(n.neighbors, i, i+1)
+	}
 }
 
 // addNeighbor adds a o neighbor to the node, replacing the neighbor
 // with the worst distance if the neighbor set is full.
 func (n *layerNode[K]) addNeighbor(newNode *layerNode[K], m int, dist DistanceFunc) error {
+	if n.hasNeighbor(newNode) {
+		return nil
+	}
 	if n.neighbors == nil {
-		n.neighbors = make(map[K]*layerNode[K], m)
+		n.neighbors = make([]uint32, 0, m+1)
 	}
-
-	n.neighbors[newNode.Key] = newNode
+	n.neighbors = append(n.neighbors, newNode.id)
 	if len(n.neighbors) <= m {
 		return nil
 	}
@@ -64,7 +100,13 @@ func (n *layerNode[K]) addNeighbor(newNode *layerNode[K], m int, dist DistanceFu
 		worstDist = float32(math.Inf(-1))
 		worst     *layerNode[K]
 	)
-	for _, neighbor := range n.neighbors {
+	for _, id := range n.neighbors {
+		neighbor := n.neighbor(id)
+		if neighbor == nil {
+			// A removed neighbor goes first.
+			n.unlink(id)
+			return nil
+		}
 		d, err := dist(neighbor.Value, n.Value)
 		if err != nil {
 			return err
@@ -77,15 +119,15 @@ func (n *layerNode[K]) addNeighbor(newNode *layerNode[K], m int, dist DistanceFu
 		}
 	}
 
-	delete(n.neighbors, worst.Key)
+	n.unlink(worst.id)
 	// Delete backlink from the worst neighbor.
-	delete(worst.neighbors, n.Key)
-	worst.replenish(m)
+	worst.unlink(n.id)
+	worst.replenish(m, dist)
 
 	return nil
 }
 
-type searchCandidate[K cmp.Ordered] struct {
+type searchCandidate[K comparable] struct {
 	node *layerNode[K]
 	dist float32
 }
@@ -94,90 +136,232 @@ func (s searchCandidate[K]) Less(o searchCandidate[K]) bool {
 	return s.dist < o.dist
 }
 
-// search returns the layer node closest to the target node
-// within the same layer.
-func (n *layerNode[K]) search(
+// scoreFunc returns the distance of a node to the target of a search.
+type scoreFunc[K comparable] func(node *layerNode[K]) (float32, error)
+
+// distanceTo returns a scoreFunc measuring the distance to target.
+func distanceTo[K comparable](target Vector, distance DistanceFunc) scoreFunc[K] {
+	return func(node *layerNode[K]) (float32, error) {
+		return distance(node.Value, target)
+	}
+}
+
+// layerSearch holds the parameters of a search within one layer.
+type layerSearch[K comparable] struct {
 	// k is the number of candidates in the result set.
-	k int,
-	efSearch int,
-	target Vector,
-	distance DistanceFunc,
-) ([]searchCandidate[K], error) {
-	// This is a basic greedy algorithm to find the entry point at the given level
-	// that is closest to the target node.
+	k        int
+	efSearch int
+	score    scoreFunc[K]
+
+	// filter, if set, excludes nodes from the result set. Excluded nodes
+	// are still traversed so that they don't cut off the nodes behind
+	// them.
+	filter func(K) bool
+
+	// allow, if set, excludes nodes from the result set like filter.
+	// In addition, the allowed neighbors of a disallowed neighbor are
+	// considered right away, so that the search reaches allowed nodes
+	// quickly when there are few of them.
+	allow func(K) bool
+
+	// ctx, if set, aborts the search once it is done.
+	ctx context.Context
+
+	// visited, if set, is cleared and used as the visited set instead of
+	// allocating one.
+	visited map[K]bool
+
+	// budget, if set, limits the work of the search.
+	budget *searchBudget
+
+	// trace, if set, records the search. See SearchDebug.
+	trace *LayerTrace[K]
+}
+
+// searchBudget limits the work of a search across layers. See
+// SearchOptions.MaxDistanceComputations and SearchOptions.MaxVisited.
+type searchBudget struct {
+	maxDistances, maxVisited int
+	stats                    SearchStats
+}
+
+// compute accounts for computing a distance and reports whether the
+// budget allowed it. It returns true for a nil budget.
+func (b *searchBudget) compute() bool {
+	if b == nil {
+		return true
+	}
+	if b.maxDistances > 0 && b.stats.DistanceComputations >= b.maxDistances {
+		b.stats.Truncated = true
+		return false
+	}
+	b.stats.DistanceComputations++
+	return true
+}
+
+// visit accounts for expanding a node, i.e. reading its neighbors, and
+// reports whether the budget allowed it. It returns true for a nil
+// budget.
+func (b *searchBudget) visit() bool {
+	if b == nil {
+		return true
+	}
+	if b.maxVisited > 0 && b.stats.Visited >= b.maxVisited {
+		b.stats.Truncated = true
+		return false
+	}
+	b.stats.Visited++
+	return true
+}
+
+// ctxCheckInterval is the number of candidates a search expands between
+// checks of its context.
+const ctxCheckInterval = 64
+
+// admits reports whether key may appear in the result set.
+func (s layerSearch[K]) admits(key K) bool {
+	return (s.filter == nil || s.filter(key)) && (s.allow == nil || s.allow(key))
+}
+
+// search returns the k nodes closest to the target of s.score within
+// the layer, starting from n.
+//
+// It is the beam search of the HNSW paper: up to max(k, efSearch) of the
+// best nodes found so far are kept, and the search stops once the
+// closest unexpanded candidate is farther than all of them.
+func (n *layerNode[K]) search(s layerSearch[K]) ([]searchCandidate[K], error) {
 	if n == nil {
 		return nil, fmt.Errorf("node is nil")
 	}
-	candidates := heap.Heap[searchCandidate[K]]{}
-	candidates.Init(make([]searchCandidate[K], 0, efSearch))
-	dist, err := distance(n.Value, target)
+	ef := max(s.k, s.efSearch)
+
+	// The entry is always scored, but counts towards the budget.
+	s.budget.compute()
+	dist, err := s.score(n)
 	if err != nil {
 		return nil, err
 	}
-	candidates.Push(
-		searchCandidate[K]{
-			node: n,
-			dist: dist,
-		},
-	)
+	s.trace.enter(n.Key, dist)
 	var (
-		result  = heap.Heap[searchCandidate[K]]{}
-		visited = make(map[K]bool)
+		candidates heap.Heap[searchCandidate[K]]
+		// result is a max-heap, so that the worst result can be replaced.
+		result  heap.Heap[farthest[K]]
+		visited = s.visited
 	)
-	result.Init(make([]searchCandidate[K], 0, k))
-
-	// Begin with the entry node in the result set.
-	result.Push(candidates.Min())
+	if visited == nil {
+		visited = make(map[K]bool)
+	} else {
+		clear(visited)
+	}
 	visited[n.Key] = true
+	candidates.Init(make([]searchCandidate[K], 0, ef))
+	result.Init(make([]farthest[K], 0, ef+1))
+
+	entry := searchCandidate[K]{node: n, dist: dist}
+	candidates.Push(entry)
+	if s.admits(n.Key) {
+		result.Push(farthest[K]{entry})
+	}
+
+	var next []*layerNode[K]
+
+	for expanded := 0; candidates.Len() > 0; expanded++ {
+		if s.ctx != nil && expanded%ctxCheckInterval == 0 {
+			if err := s.ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		current := candidates.Pop()
+		if result.Len() >= ef && current.dist > result.Min().dist {
+			// Every remaining candidate is farther than the results.
+			break
+		}
+		if !s.budget.visit() {
+			break
+		}
+		s.trace.hop()
 
-	for candidates.Len() > 0 {
-		var (
-			current  = candidates.Pop().node
-			improved = false
-		)
-
-		// We iterate the map in a sorted, deterministic fashion for
-		// tests.
-		neighborKeys := maps.Keys(current.neighbors)
-		slices.Sort(neighborKeys)
-		for _, neighborID := range neighborKeys {
-			neighbor := current.neighbors[neighborID]
-			if visited[neighborID] {
+		next = next[:0]
+		for _, id := range current.node.neighbors {
+			neighbor := n.neighbor(id)
+			if neighbor == nil || visited[neighbor.Key] {
 				continue
 			}
-			visited[neighborID] = true
+			visited[neighbor.Key] = true
+			next = append(next, neighbor)
+			if s.allow == nil || s.allow(neighbor.Key) {
+				continue
+			}
+			// Look past the disallowed neighbor at its neighbors.
+			for _, id := range neighbor.neighbors {
+				hop := n.neighbor(id)
+				if hop != nil && !visited[hop.Key] && s.allow(hop.Key) {
+					visited[hop.Key] = true
+					next = append(next, hop)
+				}
+			}
+		}
 
-			dist, err := distance(neighbor.Value, target)
+		for _, neighbor := range next {
+			if !s.budget.compute() {
+				break
+			}
+			dist, err := s.score(neighbor)
 			if err != nil {
 				return nil, err
 			}
-
-			improved = improved || dist < result.Min().dist
-			if result.Len() < k {
-				result.Push(searchCandidate[K]{node: neighbor, dist: dist})
-			} else if dist < result.Max().dist {
-				result.PopLast()
-				result.Push(searchCandidate[K]{node: neighbor, dist: dist})
+			s.trace.visit(neighbor.Key)
+			if result.Len() >= ef && dist >= result.Min().dist {
+				continue
 			}
 
-			candidates.Push(searchCandidate[K]{node: neighbor, dist: dist})
-			// Always store candidates if we haven't reached the limit.
-			if candidates.Len() > efSearch {
-				candidates.PopLast()
+			candidate := searchCandidate[K]{node: neighbor, dist: dist}
+			candidates.Push(candidate)
+			if s.admits(neighbor.Key) {
+				result.Push(farthest[K]{candidate})
+				if result.Len() > ef {
+					result.Pop()
+				}
 			}
 		}
-
-		// Termination condition: no improvement in distance and at least
-		// kMin candidates in the result set.
-		if !improved && result.Len() >= k {
+		if s.budget != nil && s.budget.stats.Truncated {
 			break
 		}
 	}
 
-	return result.Slice(), nil
+	for result.Len() > s.k {
+		result.Pop()
+	}
+	out := make([]searchCandidate[K], result.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = result.Pop().searchCandidate
+	}
+	return out, nil
+}
+
+// farthest orders search candidates farthest first.
+type farthest[K comparable] struct {
+	searchCandidate[K]
+}
+
+func (f farthest[K]) Less(o farthest[K]) bool {
+	return f.dist > o.dist
+}
+
+// liveNeighbors returns the neighbors of n that haven't been removed.
+func liveNeighbors[K comparable](n *layerNode[K]) []*layerNode[K] {
+	out := make([]*layerNode[K], 0, len(n.neighbors))
+	for _, id := range n.neighbors {
+		if neighbor := n.neighbor(id); neighbor != nil {
+			out = append(out, neighbor)
+		}
+	}
+	return out
 }
 
-func (n *layerNode[K]) replenish(m int) {
+// replenish tops up the neighbors of n to m from the neighbors of its
+// neighbors, then from extra.
+func (n *layerNode[K]) replenish(m int, dist DistanceFunc, extra ...*layerNode[K]) {
 	if len(n.neighbors) >= m {
 		return
 	}
@@ -185,39 +369,90 @@ func (n *layerNode[K]) replenish(m int) {
 	// Restore connectivity by adding new neighbors.
 	// This is a naive implementation that could be improved by
 	// using a priority queue to find the best candidates.
-	for _, neighbor := range n.neighbors {
-		for key, candidate := range neighbor.neighbors {
-			if _, ok := n.neighbors[key]; ok {
-				// do not add duplicates
-				continue
-			}
-			if candidate == n {
-				continue
-			}
-			n.addNeighbor(candidate, m, CosineDistance)
-			if len(n.neighbors) >= m {
-				return
-			}
+	var candidates []*layerNode[K]
+	for _, neighbor := range liveNeighbors(n) {
+		candidates = append(candidates, liveNeighbors(neighbor)...)
+	}
+	for _, candidate := range append(candidates, extra...) {
+		if candidate == n || candidate.removed || n.hasNeighbor(candidate) {
+			// do not add duplicates
+			continue
+		}
+		n.addNeighbor(candidate, m, dist)
+		if len(n.neighbors) >= m {
+			return
 		}
 	}
 }
 
 // isolates remove the node from the graph by removing all connections
 // to neighbors.
-func (n *layerNode[K]) isolate(m int) {
-	for _, neighbor := range n.neighbors {
-		delete(neighbor.neighbors, n.Key)
-		neighbor.replenish(m)
+//
+// Links aren't always mutual, so the nodes linking to n are found by
+// sweeping the layer, as compact does. Those losing n as a neighbor are
+// replenished, with the neighbors of n as fallback candidates: a node
+// may have linked to nothing else.
+func (n *layerNode[K]) isolate(m int, dist DistanceFunc) {
+	n.removed = true
+	neighbors := liveNeighbors(n)
+	affected := slices.Clone(neighbors)
+	for _, node := range n.layer.byID {
+		if node != nil && node.hasNeighbor(n) && !n.hasNeighbor(node) {
+			affected = append(affected, node)
+		}
+	}
+	for _, node := range affected {
+		node.unlink(n.id)
+	}
+	for _, node := range affected {
+		node.replenish(m, dist, neighbors...)
 	}
 }
 
-type layer[K cmp.Ordered] struct {
-	// nodes is a map of nodes IDs to nodes.
+type layer[K comparable] struct {
+	// nodes is a map of keys to nodes.
 	// All nodes in a higher layer are also in the lower layers, an essential
 	// property of the graph.
-	//
-	// nodes is exported for interop with encoding/gob.
 	nodes map[K]*layerNode[K]
+
+	// byID maps the IDs of the nodes to the nodes. The slots of removed
+	// nodes are nil.
+	byID []*layerNode[K]
+
+	// free are IDs of removed nodes that no edge refers to any more, to
+	// be reused. IDs are only freed by compact, which sweeps the layer:
+	// reusing an ID that an edge still points at would redirect the edge.
+	free []uint32
+}
+
+// add adds n to the layer and assigns it an ID. A node with the same key
+// must have been removed first.
+func (l *layer[K]) add(n *layerNode[K]) {
+	if l.nodes == nil {
+		l.nodes = make(map[K]*layerNode[K])
+	}
+	n.layer = l
+	if len(l.free) > 0 {
+		n.id = l.free[len(l.free)-1]
+		l.free = l.free[:len(l.free)-1]
+		l.byID[n.id] = n
+	} else {
+		n.id = uint32(len(l.byID))
+		l.byID = append(l.byID, n)
+	}
+	l.nodes[n.Key] = n
+}
+
+// remove removes the node with the given key from the layer, without
+// unlinking it, and returns it, or nil if there is no such node.
+func (l *layer[K]) remove(key K) *layerNode[K] {
+	n := l.nodes[key]
+	if n == nil {
+		return nil
+	}
+	delete(l.nodes, key)
+	l.byID[n.id] = nil
+	return n
 }
 
 // entry returns the entry node of the layer.
@@ -243,8 +478,9 @@ func (l *layer[K]) size() int {
 
 // Graph is a Hierarchical Navigable Small World graph.
 // All public parameters must be set before adding nodes to the graph.
-// K is cmp.Ordered instead of of comparable so that they can be sorted.
-type Graph[K cmp.Ordered] struct {
+// Keys are ordered with CompareKeys wherever order matters, e.g. in
+// Export; key types may implement Comparer to set their order.
+type Graph[K comparable] struct {
 	mu sync.RWMutex
 	// Distance is the distance function used to compare embeddings.
 	Distance DistanceFunc
@@ -254,10 +490,22 @@ type Graph[K cmp.Ordered] struct {
 	// degenerate graphs when exposed to adversarial inputs.
 	Rng *rand.Rand
 
+	// HashLevels, if set, derives the level of each node from a hash of
+	// its key instead of drawing it from Rng. A node then gets the same
+	// level in every build, whatever the order of insertion, e.g. in
+	// concurrent builds. Like a deterministic Rng, it lets adversarial
+	// keys pile up in the upper layers.
+	HashLevels bool
+
 	// M is the maximum number of neighbors to keep for each node.
 	// A good default for OpenAI embeddings is 16.
 	M int
 
+	// M0, if positive, is the maximum number of neighbors in the base
+	// layer, which holds every node and carries most of the recall. The
+	// HNSW paper recommends 2*M. Zero means M.
+	M0 int
+
 	// Ml is the level generation factor.
 	// E.g., for Ml = 0.25, each layer is 1/4 the size of the previous layer.
 	Ml float64
@@ -272,8 +520,76 @@ type Graph[K cmp.Ordered] struct {
 	// expense of memory.
 	EfConstruction int
 
+	// HitSampling, if positive, samples one in HitSampling searches and
+	// counts how often each node is returned by them. See HitCounts and
+	// HitHeatmap.
+	HitSampling int
+
+	// DisablePooling stops searches from keeping their scratch memory for
+	// reuse by later searches. Searches allocate more, but the graph
+	// holds no memory beyond its contents between searches.
+	DisablePooling bool
+
+	// Hardening, if set, randomizes insertions to resist adversarial
+	// inputs. See Hardening.
+	Hardening *Hardening
+
+	// DuplicateDistance, if positive, makes Add treat a node within
+	// DuplicateDistance of a node with another key as a duplicate, and
+	// handle it according to Duplicates. This keeps repeated content,
+	// e.g. from a crawler, from growing the graph.
+	DuplicateDistance float32
+
+	// Duplicates is what Add does with duplicates.
+	Duplicates DuplicatePolicy
+
+	// NeighborSelection decides which nodes are linked on insertion. The
+	// zero value is SelectSimple.
+	NeighborSelection NeighborSelection
+
+	// Fields optionally declares named segments of the vectors, which
+	// queries can weight individually with SearchOptions.FieldWeights.
+	// The graph itself is built with the distance over whole vectors.
+	Fields []Field
+
 	// layers is a slice of layers in the graph.
 	layers []*layer[K]
+
+	// stale is the set of keys marked with MarkStale.
+	stale map[K]struct{}
+
+	// tombstones is the set of keys marked with MarkDeleted.
+	tombstones map[K]struct{}
+
+	// next is the vector space being migrated to, if a migration is in
+	// progress.
+	next *Graph[K]
+
+	// now returns the current time. It is overridden in tests.
+	now func() time.Time
+
+	// subs are the active subscriptions created with Subscribe.
+	subs map[*subscription[K]]struct{}
+
+	// feeds are the running iterations of Changes.
+	feeds map[*changeFeed[K]]struct{}
+
+	// payloads holds the payloads attached with SetPayload.
+	payloads map[K][]byte
+
+	// hits counts the results of sampled searches.
+	hits hitCounter[K]
+
+	// sketch summarizes the vectors in the base layer.
+	sketch embeddingSketch
+
+	// version is the version of this package that saved the imported
+	// graph. See GraphConfig.Version.
+	version string
+
+	// visited pools the visited sets (map[K]bool) of base layer searches,
+	// which would otherwise be allocated by every search.
+	visited sync.Pool
 }
 
 func defaultRand() *rand.Rand {
@@ -282,7 +598,7 @@ func defaultRand() *rand.Rand {
 
 // NewGraph returns a new graph with default parameters, roughly designed for
 // storing OpenAI embeddings.
-func NewGraph[K cmp.Ordered]() *Graph[K] {
+func NewGraph[K comparable]() *Graph[K] {
 	return &Graph[K]{
 		M:              16,
 		Ml:             0.25,
@@ -312,8 +628,13 @@ func maxLevel(ml float64, numNodes int) (int, error) {
 	return m, nil
 }
 
-// randomLevel generates a random level for a new node.
-func (h *Graph[K]) randomLevel() (int, error) {
+// randomLevel generates a random level for a new node with the given
+// key.
+func (h *Graph[K]) randomLevel(key K) (int, error) {
+	if h.HashLevels {
+		return h.hashLevel(key)
+	}
+
 	// max avoids having to accept an additional parameter for the maximum level
 	// by calculating a probably good one from the size of the base layer.
 	max := 1
@@ -341,24 +662,85 @@ func (h *Graph[K]) randomLevel() (int, error) {
 	return max, nil
 }
 
+// hashLevel derives the level of a node from a hash of its key. See
+// Graph.HashLevels.
+func (h *Graph[K]) hashLevel(key K) (int, error) {
+	if h.Ml <= 0 || h.Ml >= 1 {
+		return 0, fmt.Errorf("(*Graph).Ml must be between 0 and 1")
+	}
+	// The maximum level can't depend on the size of the graph, which
+	// depends on the insertion order. Cap it for the largest graphs.
+	max, err := maxLevel(h.Ml, math.MaxInt32)
+	if err != nil {
+		return 0, err
+	}
+
+	hash := fnv.New64a()
+	fmt.Fprint(hash, key)
+	u := float64(mix64(hash.Sum64())>>11) / (1 << 53)
+
+	// The node reaches level l with probability Ml^l, as with Rng.
+	level, p := 0, h.Ml
+	for level < max && u < p {
+		level++
+		p *= h.Ml
+	}
+	return level, nil
+}
+
 func (g *Graph[K]) assertDims(n Vector) error {
-	if len(g.layers) == 0 {
+	dims := g.Dims()
+	if dims == 0 {
 		return nil
 	}
-	dims := g.Dims()
 	if dims != len(n) {
 		return fmt.Errorf("embedding dimension mismatch: %d != %d", dims, len(n))
 	}
 	return nil
 }
 
+// assertQueryDims is assertDims for queries. Sparse encodings of any
+// width can be compared, so queries of graphs using
+// SparseDotProductDistance only need an even length.
+func (g *Graph[K]) assertQueryDims(q Vector) error {
+	if isSparseDistance(g.Distance) {
+		if len(q)%2 != 0 {
+			return fmt.Errorf("sparse encoding has odd length %d", len(q))
+		}
+		return nil
+	}
+	return g.assertDims(q)
+}
+
 // Dims returns the number of dimensions in the graph, or
 // 0 if the graph is empty.
 func (g *Graph[K]) Dims() int {
 	if len(g.layers) == 0 {
 		return 0
 	}
-	return len(g.layers[0].entry().Value)
+	entry := g.layers[0].entry()
+	if entry == nil {
+		// Every node has been deleted.
+		return 0
+	}
+	return len(entry.Value)
+}
+
+// clock returns the current time.
+func (g *Graph[K]) clock() time.Time {
+	if g.now == nil {
+		return time.Now()
+	}
+	return g.now()
+}
+
+// maxNeighbors returns the maximum number of neighbors of the nodes in
+// the given layer.
+func (g *Graph[K]) maxNeighbors(level int) int {
+	if level == 0 && g.M0 > 0 {
+		return g.M0
+	}
+	return g.M
 }
 
 func ptr[T any](v T) *T {
@@ -366,173 +748,588 @@ func ptr[T any](v T) *T {
 }
 
 // Add inserts nodes into the graph.
-// If another node with the same ID exists, it is replaced.
+// If another node with the same ID exists, it is replaced. Nodes close to
+// another node may be rejected or dropped; see DuplicateDistance.
 func (g *Graph[K]) Add(nodes ...Node[K]) error {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	for _, node := range nodes {
-		wasUpdated := false
-		key := node.Key
-		vec := node.Value
+	return g.AddContext(context.Background(), nodes...)
+}
 
-		g.assertDims(vec)
-		insertLevel, err := g.randomLevel()
-		if err != nil {
+// AddContext is like Add but stops with the context's error once ctx is
+// done. It checks ctx between nodes; nodes inserted until then remain in
+// the graph.
+//
+// The write lock is taken for each node in turn, so searches can run
+// between the insertions of a long list of nodes.
+func (g *Graph[K]) AddContext(ctx context.Context, nodes ...Node[K]) error {
+	for _, node := range nodes {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
-		// Create layers that don't exist yet.
-		for insertLevel >= len(g.layers) {
-			g.layers = append(g.layers, &layer[K]{})
+		if err := g.addOne(ctx, node); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		if insertLevel < 0 {
-			return fmt.Errorf("invalid level: %d", insertLevel)
-		}
+func (g *Graph[K]) addOne(ctx context.Context, node Node[K]) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, err := g.addUnique(ctx, node)
+	return err
+}
 
-		var elevator *K
+// insert inserts a node into every layer up to and including insertLevel.
+// The caller must hold the write lock.
+func (g *Graph[K]) insert(node Node[K], insertLevel int) error {
+	wasUpdated := false
+	key := node.Key
+	vec := node.Value
+	delete(g.stale, key)
+	delete(g.tombstones, key)
+
+	g.assertDims(vec)
+	// Create layers that don't exist yet.
+	for insertLevel >= len(g.layers) {
+		g.layers = append(g.layers, &layer[K]{})
+	}
+
+	if insertLevel < 0 {
+		return fmt.Errorf("invalid level: %d", insertLevel)
+	}
 
-		preLen := g.Len()
+	var elevator *K
+
+	var replaced *layerNode[K]
+	if len(g.layers) > 0 {
+		replaced = g.layers[0].nodes[key]
+	}
+	preLen := g.len()
+	added := g.clock().UnixNano()
+	var score scoreFunc[K]
+
+	// Insert node at each layer, beginning with the highest.
+	for i := len(g.layers) - 1; i >= 0; i-- {
+		layer := g.layers[i]
+		newNode := &layerNode[K]{
+			Node: Node[K]{
+				Key:   key,
+				Value: vec,
+			},
+			added: added,
+		}
 
-		// Insert node at each layer, beginning with the highest.
-		for i := len(g.layers) - 1; i >= 0; i-- {
-			layer := g.layers[i]
-			newNode := &layerNode[K]{
-				Node: Node[K]{
-					Key:   key,
-					Value: vec,
-				},
+		// A node being replaced at a lower level leaves the layers above
+		// the new level.
+		if insertLevel < i {
+			if node := layer.remove(key); node != nil {
+				node.isolate(g.maxNeighbors(i), g.Distance)
+				wasUpdated = true
 			}
+		}
 
-			// Insert the new node into the layer.
-			if layer.entry() == nil {
-				layer.nodes = map[K]*layerNode[K]{key: newNode}
-				continue
+		// Insert the new node into the layer. An upper layer emptied by
+		// deletions only takes nodes of its level.
+		if layer.entry() == nil {
+			if insertLevel >= i {
+				layer.add(newNode)
 			}
+			continue
+		}
 
-			// Now at the highest layer with more than one node, so we can begin
-			// searching for the best way to enter the graph.
-			searchPoint := layer.entry()
+		// Now at the highest layer with more than one node, so we can begin
+		// searching for the best way to enter the graph.
+		var searchPoint *layerNode[K]
 
-			// On subsequent layers, we use the elevator node to enter the graph
-			// at the best point.
-			if elevator != nil {
-				searchPoint = layer.nodes[*elevator]
-			}
+		// On subsequent layers, we use the elevator node to enter the graph
+		// at the best point.
+		if elevator != nil {
+			searchPoint = layer.nodes[*elevator]
+		} else {
+			searchPoint = g.entryPoint(layer)
+		}
 
-			if g.Distance == nil {
-				return fmt.Errorf("(*Graph).Distance must be set")
-			}
+		if g.Distance == nil {
+			return fmt.Errorf("(*Graph).Distance must be set")
+		}
+		if score == nil {
+			score = g.insertScore(vec)
+		}
+
+		neighborhood, err := searchPoint.search(layerSearch[K]{
+			k:        g.candidates(i),
+			efSearch: g.EfConstruction,
+			score:    score,
+		})
+		if err != nil {
+			return err
+		}
+		if len(neighborhood) == 0 {
+			// This should never happen because the searchPoint itself
+			// should be in the result set.
+			return fmt.Errorf("empty neighborhood")
+		}
 
-			neighborhood, err := searchPoint.search(g.M, g.EfConstruction, vec, g.Distance)
+		// Re-set the elevator node for the next layer.
+		elevator = ptr(neighborhood[0].node.Key)
+
+		if insertLevel >= i {
+			if node := layer.remove(key); node != nil {
+				node.isolate(g.maxNeighbors(i), g.Distance)
+				wasUpdated = true
+			}
+			// Insert the new node into the layer. The node being replaced
+			// is not a neighbor candidate.
+			layer.add(newNode)
+			m := g.maxNeighbors(i)
+			selected, err := g.selectNeighbors(key, vec, neighborhood, m)
 			if err != nil {
 				return err
 			}
-			if len(neighborhood) == 0 {
-				// This should never happen because the searchPoint itself
-				// should be in the result set.
-				return fmt.Errorf("empty neighborhood")
+			for _, node := range selected {
+				// Create a bi-directional edge between the new node and the best node.
+				g.link(node.node, newNode, m)
+				g.link(newNode, node.node, m)
 			}
+		}
+	}
 
-			// Re-set the elevator node for the next layer.
-			elevator = ptr(neighborhood[0].node.Key)
+	if replaced != nil {
+		g.sketch.remove(replaced.Value)
+	}
+	g.sketch.insert(vec)
 
-			if insertLevel >= i {
-				if node, ok := layer.nodes[key]; ok {
-					delete(layer.nodes, key)
-					node.isolate(g.M)
-					wasUpdated = true
-				}
-				// Insert the new node into the layer.
-				layer.nodes[key] = newNode
-				for _, node := range neighborhood {
-					// Create a bi-directional edge between the new node and the best node.
-					node.node.addNeighbor(newNode, g.M, g.Distance)
-					newNode.addNeighbor(node.node, g.M, g.Distance)
-				}
-			}
+	// Invariant check: the node should have been added to the graph.
+	if wasUpdated {
+		if g.len() != preLen {
+			return fmt.Errorf("node not updated")
 		}
-
-		// Invariant check: the node should have been added to the graph.
-		if wasUpdated {
-			if g.Len() != preLen {
-				return fmt.Errorf("node not updated")
-			}
-		} else {
-			if g.Len() != preLen+1 {
-				return fmt.Errorf("node not added")
-			}
+	} else {
+		if g.len() != preLen+1 {
+			return fmt.Errorf("node not added")
 		}
 	}
 	return nil
 }
 
-type SearchResultNode[K cmp.Ordered] struct {
+type SearchResultNode[K comparable] struct {
 	Node[K]
 	Distance float32
+
+	// Payload is the payload attached to the node with SetPayload.
+	Payload []byte
+}
+
+// SearchOptions configures a single search. The zero value searches
+// the same way as Search.
+type SearchOptions[K comparable] struct {
+	// StalePenalty is added to the distance of nodes marked with
+	// MarkStale, ranking them below fresh nodes at a similar distance.
+	// The penalty is included in SearchResultNode.Distance.
+	StalePenalty float32
+
+	// EfSearch overrides Graph.EfSearch for this search if positive, so
+	// queries can trade speed for accuracy without changing the graph.
+	EfSearch int
+
+	// Next routes the search to the vector space being migrated to.
+	// See BeginMigration.
+	Next bool
+
+	// Filter, if set, excludes nodes for which it returns false from the
+	// results. Excluded nodes are still traversed, so they don't cut off
+	// the region of the graph behind them. When few nodes pass, the
+	// planner searches as with Allow instead, or ranks the passing nodes
+	// exhaustively; see Strategy.
+	Filter func(K) bool
+
+	// Allow, if set, restricts the results to the keys it contains. Unlike
+	// Filter, the search looks two hops ahead past disallowed nodes, as
+	// in ACORN, so it finds allowed nodes sooner when they are rare.
+	// An AllowSet with about as many keys as a search visits,
+	// EfSearch*M, is scanned exhaustively instead.
+	Allow Allowlist[K]
+
+	// Penalty, if set, returns an amount added to a node's distance,
+	// e.g. to down-weight popular items. It is included in
+	// SearchResultNode.Distance.
+	Penalty func(K) float32
+
+	// Decay favors recently added nodes: Decay times the node's age in
+	// seconds is added to its distance. It is included in
+	// SearchResultNode.Distance. Nodes loaded with Import have no
+	// recorded insert time and are treated as new.
+	Decay float32
+
+	// Now is the time ages are measured against. Zero means the current
+	// time.
+	Now time.Time
+
+	// IgnoreDims lists dimensions left out of distance computations,
+	// e.g. the part of a composite embedding that encodes language or
+	// modality.
+	IgnoreDims []int
+
+	// FieldWeights weights the fields declared in Graph.Fields by name.
+	// When set, the distance is the weighted sum of the distances between
+	// the fields. Fields without a weight are left out.
+	FieldWeights map[string]float32
+
+	// Negatives are vectors results should be far from: NegativeWeight
+	// times the mean distance to the negatives is subtracted from each
+	// node's distance. It is included in SearchResultNode.Distance.
+	Negatives []Vector
+
+	// NegativeWeight scales the influence of Negatives. Zero means 1.
+	NegativeWeight float32
+
+	// Strategy forces how the search runs. By default, a planner picks
+	// the strategy of each search; SearchStats.Strategy reports it.
+	Strategy Strategy
+
+	// MaxDistanceComputations and MaxVisited, if positive, bound the work
+	// of the search, and with it its latency, on any graph. Once either
+	// is reached, the search stops and returns the best nodes found so
+	// far, which may be fewer than k; Stats.Truncated reports it.
+	MaxDistanceComputations int
+	MaxVisited              int
+
+	// Stats, if set, receives statistics about the search.
+	Stats *SearchStats
+
+	// trace, if set, records the search. See SearchDebug.
+	trace *SearchTrace[K]
+}
+
+// SearchStats describes the work done by a search. See
+// SearchOptions.Stats.
+type SearchStats struct {
+	// DistanceComputations is the number of distances computed.
+	DistanceComputations int
+
+	// Visited is the number of nodes visited.
+	Visited int
+
+	// Strategy is the strategy the search ran with.
+	Strategy Strategy
+
+	// Truncated reports whether the search was stopped by
+	// SearchOptions.MaxDistanceComputations or SearchOptions.MaxVisited.
+	Truncated bool
 }
 
 // Search finds the k nearest neighbors from the target node.
 func (h *Graph[K]) Search(near Vector, k int) ([]SearchResultNode[K], error) {
+	return h.SearchWithOptions(near, k, SearchOptions[K]{})
+}
+
+// SearchWithOptions is like Search but accepts per-query options.
+func (h *Graph[K]) SearchWithOptions(near Vector, k int, opts SearchOptions[K]) ([]SearchResultNode[K], error) {
+	return h.search(context.Background(), near, k, opts)
+}
+
+// SearchContext is like Search but gives up with the context's error
+// once ctx is done, e.g. when a request deadline passes.
+func (h *Graph[K]) SearchContext(ctx context.Context, near Vector, k int) ([]SearchResultNode[K], error) {
+	return h.search(ctx, near, k, SearchOptions[K]{})
+}
+
+func (h *Graph[K]) search(ctx context.Context, near Vector, k int, opts SearchOptions[K]) ([]SearchResultNode[K], error) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
+	if opts.Next {
+		if h.next == nil {
+			return nil, ErrNoMigration
+		}
+		// Nodes marked deleted here are hidden there too.
+		opts.Next = false
+		opts.Filter = h.hideTombstones(opts.Filter)
+		out, err := h.next.search(ctx, near, k, opts)
+		h.attachPayloads(out)
+		return out, err
+	}
 	h.assertDims(near)
+
+	score, err := h.queryScore(near, opts)
+	if err != nil {
+		return nil, err
+	}
+	out, err := h.searchScore(ctx, score, k, opts)
+	h.recordHits(out)
+	return out, err
+}
+
+// searchScore finds the k nodes with the lowest score. The caller must
+// hold the read lock.
+func (h *Graph[K]) searchScore(ctx context.Context, score scoreFunc[K], k int, opts SearchOptions[K]) ([]SearchResultNode[K], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	efSearch := h.EfSearch
+	if opts.EfSearch > 0 {
+		efSearch = opts.EfSearch
+	}
 	if len(h.layers) == 0 {
 		return nil, fmt.Errorf("graph is empty")
 	}
+	strategy := h.plan(k, efSearch, opts)
 
-	var (
-		efSearch = h.EfSearch
+	var budget *searchBudget
+	if opts.MaxDistanceComputations > 0 || opts.MaxVisited > 0 || opts.Stats != nil || opts.trace != nil {
+		budget = &searchBudget{
+			maxDistances: opts.MaxDistanceComputations,
+			maxVisited:   opts.MaxVisited,
+		}
+		budget.stats.Strategy = strategy
+		if opts.Stats != nil {
+			defer func() { *opts.Stats = budget.stats }()
+		}
+		if opts.trace != nil {
+			opts.trace.Strategy = strategy
+			defer func() { opts.trace.DistanceComputations = budget.stats.DistanceComputations }()
+		}
+	}
+	if strategy == StrategyBruteForce {
+		return h.scan(score, k, opts, budget)
+	}
 
-		elevator *K
-	)
+	searchPoint, err := h.descend(score, budget, opts.trace)
+	if err != nil {
+		return nil, err
+	}
+
+	var visited map[K]bool
+	if !h.DisablePooling {
+		var ok bool
+		visited, ok = h.visited.Get().(map[K]bool)
+		if !ok {
+			visited = make(map[K]bool)
+		}
+		defer h.visited.Put(visited)
+	}
+	filter, allow := h.hideTombstones(opts.Filter), opts.allow()
+	switch strategy {
+	case StrategyFiltered:
+		filter, allow = h.hideTombstones(nil), opts.predicate()
+	case StrategyPostFilter:
+		filter, allow = h.hideTombstones(opts.predicate()), nil
+	}
+	nodes, err := searchPoint.search(layerSearch[K]{
+		k:        k,
+		efSearch: efSearch,
+		score:    h.rankScore(score, opts),
+		filter:   filter,
+		allow:    allow,
+		ctx:      ctx,
+		visited:  visited,
+		budget:   budget,
+		trace:    opts.trace.layer(0),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SearchResultNode[K], 0, len(nodes))
+
+	for _, node := range nodes {
+		resNode := SearchResultNode[K]{
+			Node:     node.node.Node,
+			Distance: node.dist,
+		}
+		out = append(out, resNode)
+	}
+	h.attachPayloads(out)
+
+	return out, nil
+}
 
-	for layer := len(h.layers) - 1; layer >= 0; layer-- {
+// SearchWithFilter finds the k nearest neighbors of near for which
+// filter returns true, e.g. to isolate tenants. See SearchOptions.Filter.
+func (h *Graph[K]) SearchWithFilter(near Vector, k int, filter func(K) bool) ([]SearchResultNode[K], error) {
+	return h.SearchWithOptions(near, k, SearchOptions[K]{Filter: filter})
+}
+
+// SearchWithNegatives finds the k nodes closest to positive and far from
+// the negatives: "more like this, less like that". See
+// SearchOptions.Negatives.
+func (h *Graph[K]) SearchWithNegatives(positive Vector, negatives []Vector, k int) ([]SearchResultNode[K], error) {
+	return h.SearchWithOptions(positive, k, SearchOptions[K]{Negatives: negatives})
+}
+
+// descend walks down the upper layers towards the target of score and
+// returns the node to enter the base layer from. Its work counts towards
+// budget, if set, and is recorded in trace, if set.
+func (h *Graph[K]) descend(score scoreFunc[K], budget *searchBudget, trace *SearchTrace[K]) (*layerNode[K], error) {
+	if len(h.layers) == 0 {
+		return nil, fmt.Errorf("graph is empty")
+	}
+
+	var elevator *K
+
+	// Descending hierarchies
+	for layer := len(h.layers) - 1; layer > 0; layer-- {
 		searchPoint := h.layers[layer].entry()
 		if elevator != nil {
 			searchPoint = h.layers[layer].nodes[*elevator]
 		}
-
-		// Descending hierarchies
-		if layer > 0 {
-			nodes, err := searchPoint.search(1, efSearch, near, h.Distance)
-			if err != nil {
-				return nil, err
-			}
-			elevator = ptr(nodes[0].node.Key)
+		if searchPoint == nil {
+			// Every node in this layer has been deleted.
 			continue
 		}
 
-		nodes, err := searchPoint.search(k, efSearch, near, h.Distance)
+		// A greedy walk (ef = 1) suffices on the sparse upper layers.
+		nodes, err := searchPoint.search(layerSearch[K]{
+			k:        1,
+			efSearch: 1,
+			score:    score,
+			budget:   budget,
+			trace:    trace.layer(layer),
+		})
+		if err != nil {
+			return nil, err
+		}
+		elevator = ptr(nodes[0].node.Key)
+	}
+
+	entry := h.layers[0].entry()
+	if elevator != nil {
+		entry = h.layers[0].nodes[*elevator]
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("graph is empty")
+	}
+	return entry, nil
+}
+
+// queryScore returns the scoreFunc measuring the distance of nodes to
+// near under opts.
+func (g *Graph[K]) queryScore(near Vector, opts SearchOptions[K]) (scoreFunc[K], error) {
+	distance := g.Distance
+	if len(opts.FieldWeights) > 0 {
+		var err error
+		distance, err = fieldDistance(g.Fields, opts.FieldWeights, g.Distance, len(near))
+		if err != nil {
+			return nil, err
+		}
+	}
+	to := func(target Vector) (scoreFunc[K], error) {
+		if len(opts.IgnoreDims) == 0 {
+			return distanceTo[K](target, distance), nil
+		}
+		return maskedDistanceTo[K](target, distance, opts.IgnoreDims)
+	}
+
+	score, err := to(near)
+	if err != nil || len(opts.Negatives) == 0 {
+		return score, err
+	}
+
+	negatives := make([]scoreFunc[K], len(opts.Negatives))
+	for i, negative := range opts.Negatives {
+		negatives[i], err = to(negative)
 		if err != nil {
 			return nil, err
 		}
-		out := make([]SearchResultNode[K], 0, len(nodes))
+	}
+	weight := opts.NegativeWeight
+	if weight == 0 {
+		weight = 1
+	}
+	weight /= float32(len(negatives))
 
-		for _, node := range nodes {
-			resNode := SearchResultNode[K]{
-				Node:     node.node.Node,
-				Distance: node.dist,
+	return func(node *layerNode[K]) (float32, error) {
+		dist, err := score(node)
+		if err != nil {
+			return 0, err
+		}
+		for _, negative := range negatives {
+			d, err := negative(node)
+			if err != nil {
+				return 0, err
 			}
-			out = append(out, resNode)
+			dist -= weight * d
 		}
+		return dist, nil
+	}, nil
+}
 
-		return out, nil
+// maskedDistanceTo is like distanceTo but zeroes the ignored dimensions of
+// both vectors first.
+func maskedDistanceTo[K comparable](target Vector, distance DistanceFunc, ignore []int) (scoreFunc[K], error) {
+	masked := slices.Clone(target)
+	for _, dim := range ignore {
+		if dim < 0 || dim >= len(masked) {
+			return nil, fmt.Errorf("ignored dimension %d out of range [0, %d)", dim, len(masked))
+		}
+		masked[dim] = 0
 	}
 
-	return nil, fmt.Errorf("unreachable")
+	// Searches are sequential, so a single scratch buffer suffices.
+	scratch := make(Vector, len(target))
+	return func(node *layerNode[K]) (float32, error) {
+		if len(node.Value) != len(scratch) {
+			return distance(node.Value, masked)
+		}
+		copy(scratch, node.Value)
+		for _, dim := range ignore {
+			scratch[dim] = 0
+		}
+		return distance(scratch, masked)
+	}, nil
+}
+
+// rankScore wraps score with the adjustments opts makes to the ranking
+// of results.
+func (g *Graph[K]) rankScore(score scoreFunc[K], opts SearchOptions[K]) scoreFunc[K] {
+	stale := opts.StalePenalty != 0 && len(g.stale) > 0
+	if !stale && opts.Penalty == nil && opts.Decay == 0 {
+		return score
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = g.clock()
+	}
+	return func(node *layerNode[K]) (float32, error) {
+		dist, err := score(node)
+		if err != nil {
+			return 0, err
+		}
+		if stale {
+			if _, ok := g.stale[node.Key]; ok {
+				dist += opts.StalePenalty
+			}
+		}
+		if opts.Penalty != nil {
+			dist += opts.Penalty(node.Key)
+		}
+		if opts.Decay != 0 && node.added != 0 {
+			age := now.Sub(time.Unix(0, node.added)).Seconds()
+			dist += opts.Decay * float32(max(age, 0))
+		}
+		return dist, nil
+	}
 }
 
 // Len returns the number of nodes in the graph.
 func (h *Graph[K]) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.len()
+}
+
+// len is Len for callers holding the lock.
+func (h *Graph[K]) len() int {
 	if len(h.layers) == 0 {
 		return 0
 	}
-	return h.layers[0].size()
+	return h.layers[0].size() - len(h.tombstones)
 }
 
 // Delete removes a node from the graph by key.
 // It tries to preserve the clustering properties of the graph by
-// replenishing connectivity in the affected neighborhoods.
+// replenishing connectivity in the affected neighborhoods. Finding the
+// nodes linking to it takes a sweep of every layer it is in, so many
+// nodes are better deleted with MarkDeleted and Compact, which sweep
+// once.
 func (h *Graph[K]) Delete(key K) bool {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -544,16 +1341,29 @@ func (h *Graph[K]) DeleteWithLock(key K) bool {
 		return false
 	}
 
+	// The deletion of a marked node was notified when it was marked.
+	marked := h.isTombstone(key)
 	var deleted bool
-	for _, layer := range h.layers {
-		node, ok := layer.nodes[key]
-		if !ok {
+	for i, layer := range h.layers {
+		node := layer.remove(key)
+		if node == nil {
 			continue
 		}
-		delete(layer.nodes, key)
-		node.isolate(h.M)
+		node.isolate(h.maxNeighbors(i), h.Distance)
+		if i == 0 {
+			h.sketch.remove(node.Value)
+		}
 		deleted = true
 	}
+	delete(h.stale, key)
+	delete(h.tombstones, key)
+	delete(h.payloads, key)
+	if h.next != nil {
+		h.next.Delete(key)
+	}
+	if deleted && !marked {
+		h.notifyDelete(key)
+	}
 
 	return deleted
 }
@@ -567,7 +1377,7 @@ func (h *Graph[K]) Lookup(key K) (Vector, bool) {
 	}
 
 	node, ok := h.layers[0].nodes[key]
-	if !ok {
+	if !ok || h.isTombstone(key) {
 		return nil, false
 	}
 	return node.Value, ok
@@ -2,9 +2,12 @@ package hnsw
 
 import (
 	"cmp"
+	"math"
 	"math/rand"
+	"slices"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -20,55 +23,31 @@ func Test_maxLevel(t *testing.T) {
 }
 
 func Test_layerNode_search(t *testing.T) {
-	entry := &layerNode[int]{
-		Node: Node[int]{
-			Value: Vector{0},
-			Key:   0,
-		},
-		neighbors: map[int]*layerNode[int]{
-			1: {
-				Node: Node[int]{
-					Value: Vector{1},
-					Key:   1,
-				},
-			},
-			2: {
-				Node: Node[int]{
-					Value: Vector{2},
-					Key:   2,
-				},
-			},
-			3: {
-				Node: Node[int]{
-					Value: Vector{3},
-					Key:   3,
-				},
-				neighbors: map[int]*layerNode[int]{
-					4: {
-						Node: Node[int]{
-							Value: Vector{4},
-							Key:   5,
-						},
-					},
-					5: {
-						Node: Node[int]{
-							Value: Vector{5},
-							Key:   5,
-						},
-					},
-				},
-			},
-		},
+	l := &layer[int]{}
+	for i, v := range []float32{0, 1, 2, 3, 4, 5.5} {
+		l.add(&layerNode[int]{Node: MakeNode(i, Vector{v})})
 	}
+	link := func(a int, bs ...int) {
+		for _, b := range bs {
+			l.nodes[a].neighbors = append(l.nodes[a].neighbors, l.nodes[b].id)
+		}
+	}
+	link(0, 1, 2, 3)
+	link(3, 4, 5)
+	entry := l.nodes[0]
+
+	best, _ := entry.search(layerSearch[int]{
+		k:        2,
+		efSearch: 4,
+		score:    distanceTo[int]([]float32{4}, EuclideanDistance),
+	})
 
-	best, _ := entry.search(2, 4, []float32{4}, EuclideanDistance)
-
-	require.Equal(t, 5, best[0].node.Key)
+	require.Equal(t, 4, best[0].node.Key)
 	require.Equal(t, 3, best[1].node.Key)
 	require.Len(t, best, 2)
 }
 
-func newTestGraph[K cmp.Ordered]() *Graph[K] {
+func newTestGraph[K comparable]() *Graph[K] {
 	return &Graph[K]{
 		M:        6,
 		Distance: EuclideanDistance,
@@ -116,9 +95,9 @@ func TestGraph_AddSearch(t *testing.T) {
 	require.EqualValues(
 		t,
 		[]SearchResultNode[int]{
-			{Node: Node[int]{Key: 64, Value: Vector{64}}, Distance: 0.5},
 			{Node: Node[int]{Key: 65, Value: Vector{65}}, Distance: 0.5},
-			{Node: Node[int]{Key: 62, Value: Vector{62}}, Distance: 2.5},
+			{Node: Node[int]{Key: 64, Value: Vector{64}}, Distance: 0.5},
+			{Node: Node[int]{Key: 66, Value: Vector{66}}, Distance: 1.5},
 			{Node: Node[int]{Key: 63, Value: Vector{63}}, Distance: 1.5},
 		},
 		nearest,
@@ -161,6 +140,97 @@ func TestGraph_AddDelete(t *testing.T) {
 		ok := g.Delete(-1)
 		require.False(t, ok)
 	})
+
+	t.Run("SearchSkipsDeleted", func(t *testing.T) {
+		require.NoError(t, g.Verify())
+		for i := 0; i < 128; i += 2 {
+			results, err := g.Search(Vector{float32(i)}, 4)
+			require.NoError(t, err)
+			for _, r := range results {
+				require.Equal(t, 1, r.Key%2, "deleted node %d returned", r.Key)
+			}
+		}
+	})
+
+	t.Run("ReAddAfterEmptyingUpperLayers", func(t *testing.T) {
+		g := newTestGraph[int]()
+		for i := 0; i < 2000; i++ {
+			key := g.Rng.Intn(30)
+			if g.Rng.Intn(5) == 0 {
+				g.Delete(key)
+			} else {
+				require.NoError(t, g.Add(MakeNode(key, randFloats(4))))
+			}
+			require.NoError(t, g.Verify())
+		}
+	})
+}
+
+func TestGraph_DeleteReplenishes(t *testing.T) {
+	t.Parallel()
+
+	// Four clusters, one along each axis, so that a few nodes route
+	// between them.
+	rng := rand.New(rand.NewSource(1))
+	g := newTestGraph[int]()
+	exact := NewBruteForce[int]()
+	exact.Distance = EuclideanDistance
+	for i := 0; i < 1000; i++ {
+		vec := make(Vector, 4)
+		for d := range vec {
+			vec[d] = rng.Float32()
+		}
+		vec[i%4] += 10
+		require.NoError(t, g.Add(MakeNode(i, vec)))
+		require.NoError(t, exact.Add(MakeNode(i, vec)))
+	}
+
+	queries := make([]Vector, 200)
+	for i := range queries {
+		queries[i] = Vector{rng.Float32() * 11, rng.Float32() * 11, rng.Float32() * 11, rng.Float32() * 11}
+	}
+	recall := func() int {
+		var found int
+		for _, q := range queries {
+			want, err := exact.Search(q, 10)
+			require.NoError(t, err)
+			got, err := g.Search(q, 10)
+			require.NoError(t, err)
+			for _, w := range want {
+				if slices.ContainsFunc(got, func(r SearchResultNode[int]) bool { return r.Key == w.Key }) {
+					found++
+				}
+			}
+		}
+		return found
+	}
+	before := recall()
+
+	// Delete the hubs: the nodes most linked to in the base layer.
+	inDegree := make(map[int]int)
+	for _, node := range g.layers[0].nodes {
+		for _, neighbor := range liveNeighbors(node) {
+			inDegree[neighbor.Key]++
+		}
+	}
+	hubs := sortedMapKeys(inDegree)
+	slices.SortStableFunc(hubs, func(a, b int) int { return cmp.Compare(inDegree[b], inDegree[a]) })
+	for _, key := range hubs[:50] {
+		require.True(t, g.Delete(key))
+		exact.Delete(key)
+	}
+	require.NoError(t, g.Verify())
+
+	for i, l := range g.layers {
+		if l.size() < 2 {
+			continue
+		}
+		for key, node := range l.nodes {
+			require.NotEmpty(t, liveNeighbors(node), "node %d in layer %d", key, i)
+			require.Len(t, liveNeighbors(node), len(node.neighbors), "node %d in layer %d", key, i)
+		}
+	}
+	require.GreaterOrEqual(t, recall(), before)
 }
 
 func Benchmark_HSNW(b *testing.B) {
@@ -248,3 +318,225 @@ func TestGraph_DefaultCosine(t *testing.T) {
 		neighbors,
 	)
 }
+
+func TestGraph_SearchDecay(t *testing.T) {
+	g := newTestGraph[int]()
+	now := time.Unix(1000, 0)
+	g.now = func() time.Time { return now }
+
+	g.Add(MakeNode(1, Vector{1}))
+	now = now.Add(time.Hour)
+	g.Add(MakeNode(2, Vector{2}))
+
+	results, err := g.SearchWithOptions(Vector{1.4}, 1, SearchOptions[int]{})
+	require.NoError(t, err)
+	require.Equal(t, 1, results[0].Key)
+
+	// An hour of age costs 1.
+	results, err = g.SearchWithOptions(Vector{1.4}, 1, SearchOptions[int]{
+		Decay: 1.0 / 3600,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, results[0].Key)
+	require.InDelta(t, 0.6, results[0].Distance, 1e-6)
+
+	// Measured against an earlier time, both nodes are new.
+	results, err = g.SearchWithOptions(Vector{1.4}, 1, SearchOptions[int]{
+		Decay: 1.0 / 3600,
+		Now:   time.Unix(0, 0),
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, results[0].Key)
+}
+
+func TestGraph_SearchIgnoreDims(t *testing.T) {
+	g := newTestGraph[int]()
+	g.Add(
+		MakeNode(1, Vector{1, 0, 0}),
+		MakeNode(2, Vector{2, 0, 5}),
+	)
+
+	results, err := g.SearchWithOptions(Vector{2, 0, 0}, 1, SearchOptions[int]{})
+	require.NoError(t, err)
+	require.Equal(t, 1, results[0].Key)
+
+	results, err = g.SearchWithOptions(Vector{2, 0, 0}, 1, SearchOptions[int]{
+		IgnoreDims: []int{2},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, results[0].Key)
+	require.Zero(t, results[0].Distance)
+
+	_, err = g.SearchWithOptions(Vector{2, 0, 0}, 1, SearchOptions[int]{
+		IgnoreDims: []int{3},
+	})
+	require.Error(t, err)
+}
+
+func TestGraph_SearchEfSearch(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 1000; i++ {
+		g.Add(MakeNode(i, randFloats(8)))
+	}
+
+	recall := func(efSearch int) int {
+		var found int
+		for i := 0; i < 100; i++ {
+			vec, _ := g.Lookup(i)
+			results, err := g.SearchWithOptions(vec, 1, SearchOptions[int]{EfSearch: efSearch})
+			require.NoError(t, err)
+			if len(results) == 1 && results[0].Key == i {
+				found++
+			}
+		}
+		return found
+	}
+	require.Greater(t, recall(200), recall(1))
+	require.Equal(t, 20, g.EfSearch)
+}
+
+func TestGraph_SearchWithFilter(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 128; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+
+	// Only odd keys, so the nearest results are not the nearest nodes.
+	results, err := g.SearchWithFilter(Vector{64}, 4, func(key int) bool {
+		return key%2 == 1
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	for _, r := range results {
+		require.Equal(t, 1, r.Key%2)
+		require.LessOrEqual(t, r.Distance, float32(3))
+	}
+}
+
+func TestGraph_SearchWithNegatives(t *testing.T) {
+	g := newTestGraph[int]()
+	g.Add(
+		MakeNode(1, Vector{1, 1}),
+		MakeNode(2, Vector{1, -1}),
+		MakeNode(3, Vector{-1, 1}),
+	)
+
+	// 1 and 2 are equally close to the positive, 1 is closer to the
+	// negative.
+	results, err := g.SearchWithNegatives(Vector{2, 0}, []Vector{{1, 3}}, 1)
+	require.NoError(t, err)
+	require.Equal(t, 2, results[0].Key)
+
+	results, err = g.SearchWithNegatives(Vector{2, 0}, []Vector{{1, -3}}, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, results[0].Key)
+	require.InDelta(t, math.Sqrt2-4, results[0].Distance, 1e-6)
+}
+
+func TestGraph_SearchBudget(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 1000; i++ {
+		g.Add(MakeNode(i, randFloats(8)))
+	}
+	query := randFloats(8)
+
+	var stats SearchStats
+	full, err := g.SearchWithOptions(query, 10, SearchOptions[int]{Stats: &stats})
+	require.NoError(t, err)
+	require.Len(t, full, 10)
+	require.False(t, stats.Truncated)
+	require.Greater(t, stats.DistanceComputations, 50)
+	require.Greater(t, stats.Visited, 10)
+
+	for _, opts := range []SearchOptions[int]{
+		{MaxDistanceComputations: 50},
+		{MaxVisited: 10},
+	} {
+		opts.Stats = &stats
+		results, err := g.SearchWithOptions(query, 10, opts)
+		require.NoError(t, err)
+		require.NotEmpty(t, results)
+		require.True(t, stats.Truncated)
+		if opts.MaxDistanceComputations > 0 {
+			require.Equal(t, 50, stats.DistanceComputations)
+		} else {
+			require.Equal(t, 10, stats.Visited)
+		}
+	}
+}
+
+func TestGraph_M0(t *testing.T) {
+	g := newTestGraph[int]()
+	g.M = 4
+	g.M0 = 12
+	g.EfConstruction = 32
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, g.Add(MakeNode(i, randFloats(8))))
+	}
+	require.NoError(t, g.Verify())
+
+	stats := g.Stats()
+	require.LessOrEqual(t, stats.Degrees[0].Max, 12)
+	require.Greater(t, stats.Degrees[0].Mean, 4.0)
+	for _, d := range stats.Degrees[1:] {
+		require.LessOrEqual(t, d.Max, 4)
+	}
+
+	for i := 0; i < 500; i++ {
+		require.True(t, g.Delete(i))
+	}
+	require.NoError(t, g.Verify())
+	require.LessOrEqual(t, g.Stats().Degrees[0].Max, 12)
+}
+
+func TestGraph_HashLevels(t *testing.T) {
+	build := func(seed int64, keys []int) *Graph[int] {
+		g := newTestGraph[int]()
+		g.Rng = rand.New(rand.NewSource(seed))
+		g.HashLevels = true
+		for _, key := range keys {
+			require.NoError(t, g.Add(MakeNode(key, randFloats(4))))
+		}
+		return g
+	}
+	keys := make([]int, 2000)
+	for i := range keys {
+		keys[i] = i
+	}
+	g1 := build(1, keys)
+	rand.New(rand.NewSource(2)).Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+	g2 := build(2, keys)
+
+	// The levels don't depend on Rng or on the order of insertion.
+	for _, key := range keys {
+		require.Equal(t, g1.level(key), g2.level(key), "key %d", key)
+	}
+	// Each layer holds about Ml of the layer below.
+	require.InDelta(t, 0.5, float64(g1.layers[1].size())/float64(g1.layers[0].size()), 0.05)
+	require.InDelta(t, 0.25, float64(g1.layers[2].size())/float64(g1.layers[0].size()), 0.05)
+	require.NoError(t, g1.Verify())
+}
+
+func TestGraph_LenWhileAdding(t *testing.T) {
+	// Len takes the read lock, so it can run alongside writes. The race
+	// detector flags it otherwise.
+	g := newTestGraph[int]()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			require.NoError(t, g.Add(MakeNode(i, randFloats(4))))
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			require.Equal(t, 200, g.Len())
+			return
+		default:
+			require.LessOrEqual(t, g.Len(), 200)
+		}
+	}
+}
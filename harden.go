@@ -0,0 +1,95 @@
+package hnsw
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+)
+
+// Hardening randomizes how nodes are inserted, so that an attacker who
+// controls the inserted vectors can't predict, and therefore can't
+// steer, which links the graph ends up with. It is meant for graphs fed
+// with untrusted content. Set it as Graph.Hardening before adding
+// nodes.
+//
+// All randomness is derived from Seed: inserting the same nodes in the
+// same order, with the same Seed and Graph.Rng, builds the same graph,
+// so a suspicious graph can be rebuilt for an audit. Keep the seed
+// secret while the graph is exposed.
+type Hardening struct {
+	// Jitter scales each distance used to choose the neighbors of an
+	// inserted node by a random factor in [1-Jitter, 1+Jitter]. Small
+	// values such as 0.05 disturb crafted inputs without costing much
+	// recall. The links themselves are pruned by true distance.
+	Jitter float32
+
+	// Seed seeds the randomness.
+	Seed int64
+
+	rng *rand.Rand
+}
+
+func (h *Hardening) rand() *rand.Rand {
+	if h.rng == nil {
+		h.rng = rand.New(rand.NewSource(h.Seed))
+	}
+	return h.rng
+}
+
+// copy returns a copy of h with its own random source, for a copy of the
+// graph h belongs to: sources aren't safe for concurrent use. It returns
+// nil if h is nil.
+func (h *Hardening) copy() *Hardening {
+	if h == nil {
+		return nil
+	}
+	return &Hardening{Jitter: h.Jitter, Seed: h.Seed}
+}
+
+// entryPoint returns the node to start an insertion into l from. With
+// hardening it is a random node of l rather than an arbitrary one.
+func (g *Graph[K]) entryPoint(l *layer[K]) *layerNode[K] {
+	if g.Hardening == nil || l.size() == 0 {
+		return l.entry()
+	}
+	keys := sortedMapKeys(l.nodes)
+	return l.nodes[keys[g.Hardening.rand().Intn(len(keys))]]
+}
+
+// insertScore returns the score used to find the neighbors of vec when
+// inserting it. With hardening it is jittered.
+//
+// Searches visit neighbors in the order of their ID slices, so drawing
+// the jitter of a node as it is scored would be reproducible, but the
+// number of draws would vary with the nodes scored, shifting every later
+// draw, e.g. of entry points. Instead one salt is drawn per insertion
+// and hashed with the node's key, which also jitters a node the same way
+// in every layer.
+func (g *Graph[K]) insertScore(vec Vector) scoreFunc[K] {
+	score := distanceTo[K](vec, g.Distance)
+	if g.Hardening == nil || g.Hardening.Jitter <= 0 {
+		return score
+	}
+	jitter := g.Hardening.Jitter
+	salt := g.Hardening.rand().Uint64()
+	return func(n *layerNode[K]) (float32, error) {
+		dist, err := score(n)
+		if err != nil {
+			return 0, err
+		}
+		h := fnv.New64a()
+		fmt.Fprint(h, n.Key)
+		u := float32(mix64(h.Sum64()^salt)>>40) / (1 << 24)
+		return dist * (1 + jitter*(2*u-1)), nil
+	}
+}
+
+// mix64 is the splitmix64 finalizer, spreading the bits of x.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
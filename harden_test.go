@@ -0,0 +1,54 @@
+package hnsw
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Hardening(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	nodes := make([]Node[int], 500)
+	for i := range nodes {
+		vec := make(Vector, 8)
+		for j := range vec {
+			vec[j] = rng.Float32()
+		}
+		nodes[i] = MakeNode(i, vec)
+	}
+
+	build := func(seed int64) *Graph[int] {
+		g := newTestGraph[int]()
+		g.Hardening = &Hardening{Jitter: 0.05, Seed: seed}
+		require.NoError(t, g.AddBatch(nodes, 0))
+		require.NoError(t, g.Verify())
+		return g
+	}
+	export := func(g *Graph[int]) []byte {
+		var buf bytes.Buffer
+		require.NoError(t, g.Export(&buf))
+		return buf.Bytes()
+	}
+
+	a, b, c := build(7), build(7), build(8)
+	require.Equal(t, export(a), export(b), "same seed, same graph")
+	require.NotEqual(t, export(a), export(c))
+
+	// Hardening costs little recall.
+	plain := newTestGraph[int]()
+	require.NoError(t, plain.AddBatch(nodes, 0))
+	recall := func(g *Graph[int]) int {
+		var found int
+		for _, n := range nodes[:100] {
+			results, err := g.Search(n.Value, 1)
+			require.NoError(t, err)
+			if results[0].Key == n.Key {
+				found++
+			}
+		}
+		return found
+	}
+	require.GreaterOrEqual(t, recall(a), recall(plain)-5)
+}
@@ -0,0 +1,128 @@
+package hnsw
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/exp/maps"
+)
+
+// hitCounter counts how often nodes are returned by searches.
+type hitCounter[K comparable] struct {
+	searches atomic.Uint64
+
+	mu   sync.Mutex
+	hits map[K]uint64
+}
+
+// recordHits counts results as hits if the search is sampled. It is
+// safe to call with only the read lock held.
+func (g *Graph[K]) recordHits(results []SearchResultNode[K]) {
+	if g.HitSampling <= 0 || len(results) == 0 {
+		return
+	}
+	if g.hits.searches.Add(1)%uint64(g.HitSampling) != 0 {
+		return
+	}
+	g.hits.mu.Lock()
+	defer g.hits.mu.Unlock()
+	if g.hits.hits == nil {
+		g.hits.hits = make(map[K]uint64)
+	}
+	for _, r := range results {
+		g.hits.hits[r.Key]++
+	}
+}
+
+// HitCounts returns how often each node was returned by the sampled
+// searches. See Graph.HitSampling.
+func (g *Graph[K]) HitCounts() map[K]uint64 {
+	g.hits.mu.Lock()
+	defer g.hits.mu.Unlock()
+	return maps.Clone(g.hits.hits)
+}
+
+// ResetHits clears the hit counts.
+func (g *Graph[K]) ResetHits() {
+	g.hits.mu.Lock()
+	defer g.hits.mu.Unlock()
+	g.hits.hits = nil
+}
+
+// RegionHits is the traffic served by one region of the graph.
+type RegionHits[K comparable] struct {
+	// Hub is the upper-layer node the region is centered on.
+	Hub K
+	// Nodes is the number of nodes in the region.
+	Nodes int
+	// Hits is the sum of the hit counts of the nodes in the region.
+	Hits uint64
+}
+
+// HitHeatmap groups the nodes into about regions regions and sums their
+// hit counts, hottest region first. Regions are centered on the nodes of
+// the highest layer with at least that many nodes, and every node
+// belongs to the region of its closest hub. It shows which parts of the
+// vector space serve the traffic, to inform tiering, sharding and
+// pruning.
+func (g *Graph[K]) HitHeatmap(regions int) ([]RegionHits[K], error) {
+	if regions <= 0 {
+		return nil, fmt.Errorf("regions must be positive")
+	}
+	hits := g.HitCounts()
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.len() == 0 {
+		return nil, nil
+	}
+
+	// Nodes marked with MarkDeleted are left out, as hubs and as members.
+	live := func(l *layer[K]) []K {
+		keys := slices.DeleteFunc(maps.Keys(l.nodes), g.isTombstone)
+		slices.SortFunc(keys, keyOrder[K]())
+		return keys
+	}
+	var hubs []K
+	for i := len(g.layers) - 1; i > 0; i-- {
+		if keys := live(g.layers[i]); len(keys) >= regions {
+			hubs = keys
+			break
+		}
+	}
+	if hubs == nil {
+		// Too few nodes for hubs; every node is its own region.
+		hubs = live(g.layers[0])
+		hubs = hubs[:min(regions, len(hubs))]
+	}
+
+	out := make([]RegionHits[K], len(hubs))
+	for i, hub := range hubs {
+		out[i].Hub = hub
+	}
+	for key, node := range g.layers[0].nodes {
+		if g.isTombstone(key) {
+			continue
+		}
+		best, bestDist := -1, float32(0)
+		for i, hub := range hubs {
+			d, err := g.Distance(node.Value, g.layers[0].nodes[hub].Value)
+			if err != nil {
+				return nil, err
+			}
+			if best == -1 || d < bestDist {
+				best, bestDist = i, d
+			}
+		}
+		out[best].Nodes++
+		out[best].Hits += hits[key]
+	}
+
+	slices.SortStableFunc(out, func(a, b RegionHits[K]) int {
+		return cmp.Compare(b.Hits, a.Hits)
+	})
+	return out, nil
+}
@@ -0,0 +1,49 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_HitCounts(t *testing.T) {
+	g := newTestGraph[int]()
+	g.M = 16
+	g.HitSampling = 2
+	for i := 0; i < 16; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+
+	for i := 0; i < 10; i++ {
+		_, err := g.Search(Vector{3}, 1)
+		require.NoError(t, err)
+	}
+	require.Equal(t, map[int]uint64{3: 5}, g.HitCounts())
+
+	heatmap, err := g.HitHeatmap(4)
+	require.NoError(t, err)
+	var nodes int
+	for _, region := range heatmap {
+		nodes += region.Nodes
+	}
+	require.Equal(t, 16, nodes)
+	require.Equal(t, uint64(5), heatmap[0].Hits)
+	require.Zero(t, heatmap[len(heatmap)-1].Hits)
+
+	// Marked nodes are left out, as members and as hubs.
+	hub := heatmap[len(heatmap)-1].Hub
+	require.NotEqual(t, 3, hub)
+	g.MarkDeleted(3, hub)
+	heatmap, err = g.HitHeatmap(4)
+	require.NoError(t, err)
+	nodes = 0
+	for _, region := range heatmap {
+		require.NotContains(t, []int{3, hub}, region.Hub)
+		require.Zero(t, region.Hits)
+		nodes += region.Nodes
+	}
+	require.Equal(t, 14, nodes)
+
+	g.ResetHits()
+	require.Empty(t, g.HitCounts())
+}
@@ -0,0 +1,179 @@
+// Package hnswhttp serves an hnsw index over HTTP with JSON bodies, for
+// a small self-hosted vector endpoint:
+//
+//	POST   /vectors        {"vectors": [{"key": "a", "vector": [0.1, 0.2]}]}
+//	POST   /search         {"vector": [0.1, 0.2], "k": 10}
+//	DELETE /vectors/{key}
+//
+// Handler can be mounted into an existing mux under a prefix with
+// http.StripPrefix.
+package hnswhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hypermodeinc/hnsw"
+)
+
+// Handler is an http.Handler exposing an index. Create it with
+// NewHandler.
+type Handler struct {
+	Index hnsw.Index[string]
+
+	// MaxK caps the number of results of a search. Zero means 1000.
+	MaxK int
+
+	// MaxBodyBytes caps the size of request bodies. Zero means 32 MiB.
+	MaxBodyBytes int64
+
+	mux *http.ServeMux
+}
+
+// NewHandler returns a handler serving index.
+func NewHandler(index hnsw.Index[string]) *Handler {
+	h := &Handler{Index: index, mux: http.NewServeMux()}
+	h.mux.HandleFunc("POST /vectors", h.add)
+	h.mux.HandleFunc("POST /search", h.search)
+	h.mux.HandleFunc("DELETE /vectors/{key}", h.delete)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// Vector is a node in request and response bodies.
+type Vector struct {
+	Key    string    `json:"key"`
+	Vector []float32 `json:"vector"`
+}
+
+// AddRequest is the body of POST /vectors.
+type AddRequest struct {
+	Vectors []Vector `json:"vectors"`
+}
+
+// AddResponse is the response to POST /vectors.
+type AddResponse struct {
+	Added int `json:"added"`
+}
+
+// SearchRequest is the body of POST /search.
+type SearchRequest struct {
+	Vector []float32 `json:"vector"`
+	K      int       `json:"k"`
+}
+
+// SearchResult is a result in a SearchResponse.
+type SearchResult struct {
+	Key      string  `json:"key"`
+	Distance float32 `json:"distance"`
+}
+
+// SearchResponse is the response to POST /search. Results are ordered
+// closest first.
+type SearchResponse struct {
+	Results []SearchResult `json:"results"`
+}
+
+// errorResponse is the body of error responses.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (h *Handler) add(w http.ResponseWriter, r *http.Request) {
+	var req AddRequest
+	if !h.decode(w, r, &req) {
+		return
+	}
+	nodes := make([]hnsw.Node[string], len(req.Vectors))
+	for i, v := range req.Vectors {
+		if v.Key == "" || len(v.Vector) == 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("vector %d: key and vector are required", i))
+			return
+		}
+		nodes[i] = hnsw.MakeNode(v.Key, v.Vector)
+	}
+	if err := h.Index.Add(nodes...); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, AddResponse{Added: len(nodes)})
+}
+
+func (h *Handler) search(w http.ResponseWriter, r *http.Request) {
+	var req SearchRequest
+	if !h.decode(w, r, &req) {
+		return
+	}
+	maxK := h.MaxK
+	if maxK <= 0 {
+		maxK = 1000
+	}
+	if req.K <= 0 || req.K > maxK {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("k must be between 1 and %d", maxK))
+		return
+	}
+	if len(req.Vector) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("vector is required"))
+		return
+	}
+	if h.Index.Len() == 0 {
+		writeJSON(w, http.StatusOK, SearchResponse{Results: []SearchResult{}})
+		return
+	}
+	results, err := h.Index.Search(req.Vector, req.K)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	resp := SearchResponse{Results: make([]SearchResult, len(results))}
+	for i, res := range results {
+		resp.Results[i] = SearchResult{Key: res.Key, Distance: res.Distance}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if !h.Index.Delete(key) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("key %q not found", key))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decode reads the JSON request body into v, writing an error response
+// and returning false if it is invalid.
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	maxBytes := h.MaxBodyBytes
+	if maxBytes <= 0 {
+		maxBytes = 32 << 20
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeError(w, status, fmt.Errorf("invalid request body: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,77 @@
+package hnswhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hypermodeinc/hnsw"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	g := hnsw.NewGraph[string]()
+	g.Distance = hnsw.EuclideanDistance
+	mux := http.NewServeMux()
+	mux.Handle("/api/", http.StripPrefix("/api", NewHandler(g)))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	do := func(method, path, body string) (*http.Response, map[string]any) {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var out map[string]any
+		if resp.Header.Get("Content-Type") == "application/json" {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		}
+		return resp, out
+	}
+
+	resp, out := do("POST", "/api/search", `{"vector": [1, 0], "k": 2}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, out["results"])
+
+	resp, out = do("POST", "/api/vectors", `{"vectors": [
+		{"key": "a", "vector": [0, 0]},
+		{"key": "b", "vector": [1, 0]},
+		{"key": "c", "vector": [5, 5]}
+	]}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, 3.0, out["added"])
+	require.Equal(t, 3, g.Len())
+
+	resp, out = do("POST", "/api/search", `{"vector": [0.9, 0], "k": 2}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	results := out["results"].([]any)
+	require.Len(t, results, 2)
+	require.Equal(t, "b", results[0].(map[string]any)["key"])
+	require.Equal(t, "a", results[1].(map[string]any)["key"])
+
+	resp, _ = do("DELETE", "/api/vectors/b", "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, out = do("DELETE", "/api/vectors/b", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.Contains(t, out["error"], "not found")
+	_, ok := g.Lookup("b")
+	require.False(t, ok)
+
+	for _, body := range []string{
+		`{"vector": [0, 0], "k": 0}`,
+		`{"vector": [], "k": 1}`,
+		`{"vector": [0, 0], "k": 1, "ef": 10}`,
+		`not json`,
+	} {
+		resp, out = do("POST", "/api/search", body)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+		require.NotEmpty(t, out["error"])
+	}
+	resp, _ = do("POST", "/api/vectors", `{"vectors": [{"key": "", "vector": [1, 1]}]}`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = do("GET", "/api/search", "")
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
@@ -0,0 +1,230 @@
+package hnsw
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// HNSWLibOptions configures ReadHNSWLib.
+type HNSWLibOptions[K comparable] struct {
+	// Space is the space the index was built in: "l2", "ip" or "cosine",
+	// as passed to hnswlib.Index in Python. It defaults to "l2". The file
+	// doesn't record it.
+	Space string
+
+	// Key maps the label of each element to its key.
+	Key func(label uint64) K
+}
+
+// hnswlibSpaces maps hnswlib spaces to the distances ranking like them.
+// hnswlib's l2 space is the squared Euclidean distance, and its cosine
+// space the inner product of vectors normalized on insertion.
+var hnswlibSpaces = map[string]DistanceFunc{
+	"l2":     EuclideanDistance,
+	"ip":     DotProductDistance,
+	"cosine": CosineDistance,
+}
+
+// hnswlibHeader is the header written by HierarchicalNSW::saveIndex.
+type hnswlibHeader struct {
+	OffsetLevel0   uint64
+	MaxElements    uint64
+	Count          uint64
+	ElementSize    uint64
+	LabelOffset    uint64
+	OffsetData     uint64
+	MaxLevel       int32
+	EntryPoint     uint32
+	MaxM           uint64
+	MaxM0          uint64
+	M              uint64
+	Mult           float64
+	EfConstruction uint64
+}
+
+// hnswlibElement is an element of an hnswlib index as it is read.
+type hnswlibElement struct {
+	label   uint64
+	vector  Vector
+	deleted bool
+	// links holds the neighbors of the element in each of its layers.
+	links [][]uint32
+}
+
+// ReadHNSWLib reads an index saved by the C++ hnswlib library, e.g. with
+// save_index in Python, into a new graph, so that indexes built in other
+// pipelines can be served without rebuilding them. The links are kept
+// as they are. M, M0, Ml and EfConstruction are taken from the index;
+// the other parameters are those of NewGraph.
+//
+// Elements marked deleted in the index are marked with MarkDeleted in
+// the graph, so they keep guiding searches without being returned. Only
+// indexes of float32 vectors, with the l2, ip and cosine spaces, are
+// supported.
+func ReadHNSWLib[K comparable](r io.Reader, opts HNSWLibOptions[K]) (*Graph[K], error) {
+	if opts.Key == nil {
+		return nil, fmt.Errorf("no key mapping")
+	}
+	space := opts.Space
+	if space == "" {
+		space = "l2"
+	}
+	distance, ok := hnswlibSpaces[space]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hnswlib space %q", space)
+	}
+
+	br := bufio.NewReader(r)
+	var h hnswlibHeader
+	if err := binary.Read(br, binary.LittleEndian, &h); err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	linksSize := 4 + 4*h.MaxM
+	if h.OffsetLevel0 != 0 || h.OffsetData != 4+4*h.MaxM0 || h.LabelOffset < h.OffsetData ||
+		(h.LabelOffset-h.OffsetData)%4 != 0 || h.ElementSize != h.LabelOffset+8 ||
+		h.Count > h.MaxElements || h.MaxM == 0 || h.MaxM0 > math.MaxUint16 || h.MaxM > math.MaxUint16 || h.ElementSize > 1<<24 ||
+		!(h.Mult > 0 && h.Mult < math.Inf(1)) {
+		return nil, fmt.Errorf("invalid hnswlib header")
+	}
+	dims := int((h.LabelOffset - h.OffsetData) / 4)
+
+	// The base layer holds the links, vector and label of every element.
+	elements := make([]hnswlibElement, 0, min(h.Count, 1<<20))
+	buf := make([]byte, h.ElementSize)
+	for i := uint64(0); i < h.Count; i++ {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, fmt.Errorf("reading element %d: %w", i, err)
+		}
+		links, err := hnswlibLinks(buf, h.MaxM0, h.Count)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		vec := make(Vector, dims)
+		for j := range vec {
+			vec[j] = math.Float32frombits(binary.LittleEndian.Uint32(buf[h.OffsetData+4*uint64(j):]))
+		}
+		elements = append(elements, hnswlibElement{
+			label:   binary.LittleEndian.Uint64(buf[h.LabelOffset:]),
+			vector:  vec,
+			deleted: buf[2]&1 != 0,
+			links:   [][]uint32{links},
+		})
+	}
+
+	// The upper layers follow, with the links of each element in each
+	// layer above the base.
+	buf = make([]byte, linksSize)
+	maxLevel := 0
+	for i := range elements {
+		var size uint32
+		if err := binary.Read(br, binary.LittleEndian, &size); err != nil {
+			return nil, fmt.Errorf("reading links of element %d: %w", i, err)
+		}
+		if uint64(size)%linksSize != 0 {
+			return nil, fmt.Errorf("element %d: invalid link list size %d", i, size)
+		}
+		levels := int(uint64(size) / linksSize)
+		if levels > int(max(h.MaxLevel, 0)) {
+			return nil, fmt.Errorf("element %d: level %d above the top level %d", i, levels, h.MaxLevel)
+		}
+		maxLevel = max(maxLevel, levels)
+		for level := 1; level <= levels; level++ {
+			if _, err := io.ReadFull(br, buf); err != nil {
+				return nil, fmt.Errorf("reading links of element %d: %w", i, err)
+			}
+			links, err := hnswlibLinks(buf, h.MaxM, h.Count)
+			if err != nil {
+				return nil, fmt.Errorf("element %d, level %d: %w", i, level, err)
+			}
+			elements[i].links = append(elements[i].links, links)
+		}
+	}
+
+	g := NewGraph[K]()
+	g.Distance = distance
+	g.M = int(h.MaxM)
+	g.M0 = int(h.MaxM0)
+	// hnswlib draws levels as -ln(U)·mult, so that a node reaches each
+	// next level with probability exp(-1/mult). It sets mult to 1/ln(M).
+	g.Ml = math.Exp(-1 / h.Mult)
+	g.EfConstruction = int(h.EfConstruction)
+	if len(elements) == 0 {
+		return g, nil
+	}
+
+	g.layers = make([]*layer[K], maxLevel+1)
+	for level := range g.layers {
+		l := &layer[K]{nodes: make(map[K]*layerNode[K])}
+		// ids maps the element IDs of the index to the node IDs of the
+		// layer plus one, so that zero means absent; members maps them
+		// back.
+		ids := make([]uint32, len(elements))
+		var members []int
+		for i, e := range elements {
+			if len(e.links) <= level {
+				continue
+			}
+			var key K
+			var vec Vector
+			if level == 0 {
+				key, vec = opts.Key(e.label), e.vector
+				if _, ok := l.nodes[key]; ok {
+					return nil, fmt.Errorf("duplicate key %v for label %d", key, e.label)
+				}
+			} else {
+				// Element IDs are node IDs in the base layer.
+				base := g.layers[0].byID[i]
+				key, vec = base.Key, base.Value
+			}
+			node := &layerNode[K]{Node: Node[K]{Key: key, Value: vec}}
+			l.add(node)
+			ids[i] = node.id + 1
+			members = append(members, i)
+		}
+		for id, node := range l.byID {
+			i := members[id]
+			links := elements[i].links[level]
+			node.neighbors = make([]uint32, 0, len(links))
+			for _, link := range links {
+				if ids[link] == 0 {
+					return nil, fmt.Errorf("element %d links to element %d, absent from level %d", i, link, level)
+				}
+				if int(link) != i {
+					node.neighbors = append(node.neighbors, ids[link]-1)
+				}
+			}
+		}
+		g.layers[level] = l
+	}
+
+	for i, e := range elements {
+		if e.deleted {
+			if g.tombstones == nil {
+				g.tombstones = make(map[K]struct{})
+			}
+			g.tombstones[g.layers[0].byID[i].Key] = struct{}{}
+		}
+	}
+	g.sketch = rebuildSketch(g.layers[0])
+	return g, nil
+}
+
+// hnswlibLinks decodes a link list of an hnswlib index: a count in the
+// low 16 bits of a 32-bit header, followed by room for maxM element IDs.
+func hnswlibLinks(buf []byte, maxM, count uint64) ([]uint32, error) {
+	n := uint64(binary.LittleEndian.Uint16(buf))
+	if n > maxM {
+		return nil, fmt.Errorf("%d links, more than %d", n, maxM)
+	}
+	links := make([]uint32, n)
+	for i := range links {
+		links[i] = binary.LittleEndian.Uint32(buf[4+4*i:])
+		if uint64(links[i]) >= count {
+			return nil, fmt.Errorf("link to element %d of %d", links[i], count)
+		}
+	}
+	return links, nil
+}
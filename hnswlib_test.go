@@ -0,0 +1,129 @@
+package hnsw
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeHNSWLib writes g in the format of HierarchicalNSW::saveIndex, with
+// the keys as labels, marking deleted the elements in deleted.
+func writeHNSWLib(t *testing.T, g *Graph[int], deleted map[int]bool) []byte {
+	maxM, maxM0 := uint64(g.M), uint64(g.maxNeighbors(0))
+	base := g.layers[0]
+	dims := uint64(len(base.byID[0].Value))
+	offsetData := 4 + 4*maxM0
+	h := hnswlibHeader{
+		MaxElements:    uint64(len(base.byID)) + 10,
+		Count:          uint64(len(base.byID)),
+		ElementSize:    offsetData + 4*dims + 8,
+		LabelOffset:    offsetData + 4*dims,
+		OffsetData:     offsetData,
+		MaxLevel:       int32(len(g.layers) - 1),
+		MaxM:           maxM,
+		MaxM0:          maxM0,
+		M:              maxM,
+		Mult:           -1 / math.Log(g.Ml),
+		EfConstruction: uint64(g.EfConstruction),
+	}
+	var buf bytes.Buffer
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, h))
+
+	// Element IDs are the node IDs of the base layer.
+	links := func(n *layerNode[int], m uint64) []byte {
+		out := make([]byte, 4+4*m)
+		binary.LittleEndian.PutUint16(out, uint16(len(n.neighbors)))
+		for i, id := range n.neighbors {
+			neighbor := n.layer.byID[id]
+			binary.LittleEndian.PutUint32(out[4+4*i:], base.nodes[neighbor.Key].id)
+		}
+		return out
+	}
+	for _, n := range base.byID {
+		elem := links(n, maxM0)
+		if deleted[n.Key] {
+			elem[2] = 1
+		}
+		for _, v := range n.Value {
+			elem = binary.LittleEndian.AppendUint32(elem, math.Float32bits(v))
+		}
+		elem = binary.LittleEndian.AppendUint64(elem, uint64(n.Key))
+		buf.Write(elem)
+	}
+	for _, n := range base.byID {
+		var upper []byte
+		for _, l := range g.layers[1:] {
+			if ln, ok := l.nodes[n.Key]; ok {
+				upper = append(upper, links(ln, maxM)...)
+			}
+		}
+		require.NoError(t, binary.Write(&buf, binary.LittleEndian, uint32(len(upper))))
+		buf.Write(upper)
+	}
+	return buf.Bytes()
+}
+
+func TestReadHNSWLib(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 500; i++ {
+		require.NoError(t, g.Add(MakeNode(i, randFloats(8))))
+	}
+	require.Greater(t, len(g.layers), 1)
+	data := writeHNSWLib(t, g, map[int]bool{42: true})
+
+	read, err := ReadHNSWLib(bytes.NewReader(data), HNSWLibOptions[string]{
+		Key: func(label uint64) string { return fmt.Sprint("doc-", label) },
+	})
+	require.NoError(t, err)
+	require.NoError(t, read.Verify())
+	require.Equal(t, 499, read.Len())
+	require.Equal(t, len(g.layers), len(read.layers))
+	for i, l := range g.layers {
+		require.Equal(t, len(l.nodes), len(read.layers[i].nodes))
+	}
+	require.Equal(t, g.M, read.M)
+	require.InDelta(t, g.Ml, read.Ml, 1e-12)
+
+	// The links carry over, so searches find the same nodes.
+	for range 20 {
+		q := randFloats(8)
+		want, err := g.SearchWithOptions(q, 5, SearchOptions[int]{Filter: func(key int) bool { return key != 42 }})
+		require.NoError(t, err)
+		got, err := read.Search(q, 5)
+		require.NoError(t, err)
+		require.Len(t, got, len(want))
+		for i := range want {
+			require.Equal(t, fmt.Sprint("doc-", want[i].Key), got[i].Key)
+		}
+	}
+	vec, ok := read.Lookup("doc-7")
+	require.True(t, ok)
+	want, _ := g.Lookup(7)
+	require.Equal(t, want, vec)
+
+	_, err = ReadHNSWLib(bytes.NewReader(data[:len(data)-3]), HNSWLibOptions[string]{
+		Key: func(label uint64) string { return "" },
+	})
+	require.ErrorContains(t, err, "reading links")
+	_, err = ReadHNSWLib(bytes.NewReader(data), HNSWLibOptions[string]{
+		Key: func(label uint64) string { return "" },
+	})
+	require.ErrorContains(t, err, "duplicate key")
+	_, err = ReadHNSWLib(bytes.NewReader(data), HNSWLibOptions[int]{Space: "hamming", Key: func(label uint64) int { return 0 }})
+	require.Error(t, err)
+	// hnswlib sets mult to 1/ln(M): with M = 16, nodes reach each next
+	// level with probability 1/16.
+	h := hnswlibHeader{OffsetData: 4 + 4*32, LabelOffset: 4 + 4*32, ElementSize: 4 + 4*32 + 8, MaxM: 16, MaxM0: 32, M: 16, Mult: 0.36067376022224085}
+	var header bytes.Buffer
+	require.NoError(t, binary.Write(&header, binary.LittleEndian, h))
+	empty, err := ReadHNSWLib(&header, HNSWLibOptions[int]{Key: func(label uint64) int { return 0 }})
+	require.NoError(t, err)
+	require.InDelta(t, 1.0/16, empty.Ml, 1e-12)
+
+	_, err = ReadHNSWLib(bytes.NewReader(make([]byte, 200)), HNSWLibOptions[int]{Key: func(label uint64) int { return 0 }})
+	require.ErrorContains(t, err, "invalid hnswlib header")
+}
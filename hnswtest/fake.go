@@ -0,0 +1,151 @@
+// Package hnswtest provides testing utilities for hnsw: a fake
+// hnsw.Index for testing code that depends on the hnsw package without
+// building real graphs, a harness measuring the recall of a graph, a
+// soak harness checking an index under a long concurrent workload, and
+// writers of search results in the run formats of IR evaluation tools.
+package hnswtest
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hypermodeinc/hnsw"
+)
+
+// Fake is an in-memory, deterministic hnsw.Index. By default, Search
+// ranks all stored nodes exactly, ties broken by key; the exported fields
+// script other behavior. They must be set before the Fake is used.
+//
+// The zero value is ready to use.
+type Fake[K comparable] struct {
+	// Distance ranks nodes in Search. Nil means hnsw.EuclideanDistance.
+	Distance hnsw.DistanceFunc
+
+	// Results, if not nil, are returned by every Search, up to k of them,
+	// whatever the query and the stored nodes.
+	Results []hnsw.SearchResultNode[K]
+
+	// Latency delays Add, Search, Delete and Export.
+	Latency time.Duration
+
+	// Errors maps operations ("add", "search" and "export") to the error
+	// they fail with. Delete, Lookup and Len can't fail.
+	Errors map[string]error
+
+	mu      sync.Mutex
+	nodes   map[K]hnsw.Vector
+	queries []hnsw.Vector
+}
+
+var _ hnsw.Index[int] = (*Fake[int])(nil)
+
+// call applies the latency and returns the scripted error of op.
+func (f *Fake[K]) call(op string) error {
+	time.Sleep(f.Latency)
+	return f.Errors[op]
+}
+
+// Add stores nodes, replacing nodes with the same key.
+func (f *Fake[K]) Add(nodes ...hnsw.Node[K]) error {
+	if err := f.call("add"); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.nodes == nil {
+		f.nodes = make(map[K]hnsw.Vector)
+	}
+	for _, n := range nodes {
+		f.nodes[n.Key] = n.Value
+	}
+	return nil
+}
+
+// Search returns the k stored nodes nearest to near, or Results if set.
+func (f *Fake[K]) Search(near hnsw.Vector, k int) ([]hnsw.SearchResultNode[K], error) {
+	if err := f.call("search"); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries = append(f.queries, near)
+
+	if f.Results != nil {
+		return slices.Clone(f.Results[:min(k, len(f.Results))]), nil
+	}
+
+	distance := f.Distance
+	if distance == nil {
+		distance = hnsw.EuclideanDistance
+	}
+	out := make([]hnsw.SearchResultNode[K], 0, len(f.nodes))
+	for key, vec := range f.nodes {
+		dist, err := distance(vec, near)
+		if err != nil {
+			return nil, fmt.Errorf("distance to %v: %w", key, err)
+		}
+		out = append(out, hnsw.SearchResultNode[K]{Node: hnsw.MakeNode(key, vec), Distance: dist})
+	}
+	slices.SortFunc(out, func(a, b hnsw.SearchResultNode[K]) int {
+		if c := cmp.Compare(a.Distance, b.Distance); c != 0 {
+			return c
+		}
+		return hnsw.CompareKeys(a.Key, b.Key)
+	})
+	return out[:min(k, len(out))], nil
+}
+
+// Delete removes the node with the given key and reports whether it
+// existed.
+func (f *Fake[K]) Delete(key K) bool {
+	f.call("delete")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.nodes[key]
+	delete(f.nodes, key)
+	return ok
+}
+
+// Lookup returns the vector stored under key.
+func (f *Fake[K]) Lookup(key K) (hnsw.Vector, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	vec, ok := f.nodes[key]
+	return vec, ok
+}
+
+// Len returns the number of stored nodes.
+func (f *Fake[K]) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.nodes)
+}
+
+// Export writes the stored nodes to w as JSON, ordered by key.
+func (f *Fake[K]) Export(w io.Writer) error {
+	if err := f.call("export"); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	nodes := make([]hnsw.Node[K], 0, len(f.nodes))
+	for key, vec := range f.nodes {
+		nodes = append(nodes, hnsw.MakeNode(key, vec))
+	}
+	slices.SortFunc(nodes, func(a, b hnsw.Node[K]) int {
+		return hnsw.CompareKeys(a.Key, b.Key)
+	})
+	return json.NewEncoder(w).Encode(nodes)
+}
+
+// Queries returns the queries passed to Search so far, in order.
+func (f *Fake[K]) Queries() []hnsw.Vector {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.queries)
+}
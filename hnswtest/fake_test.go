@@ -0,0 +1,60 @@
+package hnswtest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hypermodeinc/hnsw"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake(t *testing.T) {
+	var f Fake[int]
+	require.NoError(t, f.Add(
+		hnsw.MakeNode(1, hnsw.Vector{1}),
+		hnsw.MakeNode(2, hnsw.Vector{2}),
+		hnsw.MakeNode(3, hnsw.Vector{3}),
+	))
+	require.Equal(t, 3, f.Len())
+
+	results, err := f.Search(hnsw.Vector{1.5}, 2)
+	require.NoError(t, err)
+	require.Equal(t, []hnsw.SearchResultNode[int]{
+		{Node: hnsw.MakeNode(1, hnsw.Vector{1}), Distance: 0.5},
+		{Node: hnsw.MakeNode(2, hnsw.Vector{2}), Distance: 0.5},
+	}, results)
+	require.Equal(t, []hnsw.Vector{{1.5}}, f.Queries())
+
+	require.True(t, f.Delete(1))
+	require.False(t, f.Delete(1))
+	_, ok := f.Lookup(1)
+	require.False(t, ok)
+
+	var buf bytes.Buffer
+	require.NoError(t, f.Export(&buf))
+	require.JSONEq(t, `[{"Key":2,"Value":[2]},{"Key":3,"Value":[3]}]`, buf.String())
+}
+
+func TestFake_Scripted(t *testing.T) {
+	fixed := []hnsw.SearchResultNode[string]{
+		{Node: hnsw.MakeNode("a", hnsw.Vector{1})},
+		{Node: hnsw.MakeNode("b", hnsw.Vector{2})},
+	}
+	errDown := errors.New("down")
+	f := &Fake[string]{
+		Results: fixed,
+		Latency: 10 * time.Millisecond,
+		Errors:  map[string]error{"add": errDown},
+	}
+
+	start := time.Now()
+	results, err := f.Search(hnsw.Vector{9}, 1)
+	require.NoError(t, err)
+	require.Equal(t, fixed[:1], results)
+	require.GreaterOrEqual(t, time.Since(start), f.Latency)
+
+	require.ErrorIs(t, f.Add(hnsw.MakeNode("c", hnsw.Vector{3})), errDown)
+	require.Zero(t, f.Len())
+}
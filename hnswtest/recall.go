@@ -0,0 +1,101 @@
+package hnswtest
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+
+	"github.com/hypermodeinc/hnsw"
+)
+
+// RecallReport is the result of evaluating a graph's search against
+// exact search.
+type RecallReport struct {
+	// EfSearch is the EfSearch the searches used.
+	EfSearch int
+
+	// Recall is the mean recall@k over the queries: the fraction of the
+	// exact k nearest neighbors that the search returned.
+	Recall float64
+
+	// MinRecall is the recall of the worst query.
+	MinRecall float64
+
+	// MeanLatency is the mean duration of a search.
+	MeanLatency time.Duration
+}
+
+// SampleQueries returns n vectors stored in g, chosen at random with rng,
+// to use as queries. Stored vectors are a convenient stand-in when no
+// real queries are at hand, but real queries give more realistic recall.
+func SampleQueries[K comparable](g *hnsw.Graph[K], n int, rng *rand.Rand) []hnsw.Vector {
+	var vecs []hnsw.Vector
+	for _, vec := range g.All() {
+		vecs = append(vecs, vec)
+	}
+	rng.Shuffle(len(vecs), func(i, j int) {
+		vecs[i], vecs[j] = vecs[j], vecs[i]
+	})
+	return vecs[:min(n, len(vecs))]
+}
+
+// Recall measures the recall@k of g's search for queries, at each of
+// efSearch, against exact search over all nodes, so that M and EfSearch
+// can be tuned for a dataset. An empty efSearch measures g.EfSearch.
+//
+// A returned node counts as correct if it is no farther than the k-th
+// exact neighbor, so that ties don't lower recall.
+func Recall[K comparable](g *hnsw.Graph[K], queries []hnsw.Vector, k int, efSearch ...int) ([]RecallReport, error) {
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("no queries")
+	}
+	if len(efSearch) == 0 {
+		efSearch = []int{g.EfSearch}
+	}
+
+	// The distance of the k-th exact neighbor of each query.
+	bounds := make([]float32, len(queries))
+	for i, q := range queries {
+		var dists []float32
+		for _, vec := range g.All() {
+			d, err := g.Distance(vec, q)
+			if err != nil {
+				return nil, fmt.Errorf("query %d: %w", i, err)
+			}
+			dists = append(dists, d)
+		}
+		if len(dists) < k {
+			return nil, fmt.Errorf("graph has fewer than %d nodes", k)
+		}
+		slices.Sort(dists)
+		bounds[i] = dists[k-1]
+	}
+
+	var reports []RecallReport
+	for _, ef := range efSearch {
+		report := RecallReport{EfSearch: ef, MinRecall: 1}
+		var elapsed time.Duration
+		for i, q := range queries {
+			start := time.Now()
+			results, err := g.SearchWithOptions(q, k, hnsw.SearchOptions[K]{EfSearch: ef})
+			elapsed += time.Since(start)
+			if err != nil {
+				return nil, fmt.Errorf("query %d: %w", i, err)
+			}
+			var correct int
+			for _, r := range results {
+				if r.Distance <= bounds[i] {
+					correct++
+				}
+			}
+			recall := float64(correct) / float64(k)
+			report.Recall += recall
+			report.MinRecall = min(report.MinRecall, recall)
+		}
+		report.Recall /= float64(len(queries))
+		report.MeanLatency = elapsed / time.Duration(len(queries))
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
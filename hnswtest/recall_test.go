@@ -0,0 +1,41 @@
+package hnswtest
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/hypermodeinc/hnsw"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecall(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	g := hnsw.NewGraph[int]()
+	g.Rng = rng
+	for i := 0; i < 2000; i++ {
+		vec := make(hnsw.Vector, 8)
+		for j := range vec {
+			vec[j] = rng.Float32()
+		}
+		g.Add(hnsw.MakeNode(i, vec))
+	}
+
+	queries := SampleQueries(g, 50, rng)
+	require.Len(t, queries, 50)
+
+	reports, err := Recall(g, queries, 10, 10, 200)
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	require.Equal(t, 10, reports[0].EfSearch)
+	require.Greater(t, reports[1].Recall, reports[0].Recall)
+	require.Greater(t, reports[1].Recall, 0.9)
+	require.LessOrEqual(t, reports[1].Recall, 1.0)
+	require.LessOrEqual(t, reports[0].MinRecall, reports[0].Recall)
+
+	reports, err = Recall(g, queries[:1], 10)
+	require.NoError(t, err)
+	require.Equal(t, g.EfSearch, reports[0].EfSearch)
+
+	_, err = Recall(g, queries, 5000)
+	require.Error(t, err)
+}
@@ -0,0 +1,90 @@
+package hnswtest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hypermodeinc/hnsw"
+)
+
+// RunQuery is the ranked results of one query of a run: a set of
+// searches to be scored against relevance judgments (qrels) with IR
+// evaluation tools.
+type RunQuery[K comparable] struct {
+	// QueryID identifies the query in the qrels.
+	QueryID string
+	// Results are the search results, closest first. Their keys are
+	// formatted with fmt.Sprint to give the document IDs of the qrels.
+	Results []hnsw.SearchResultNode[K]
+}
+
+// runEntry is a ranked result with the fields of both formats. The score
+// is the negated distance, as evaluation tools rank by descending score;
+// subtracting from zero avoids writing -0.
+type runEntry struct {
+	QueryID string  `json:"qid"`
+	DocID   string  `json:"docid"`
+	Rank    int     `json:"rank"`
+	Score   float32 `json:"score"`
+}
+
+func runEntries[K comparable](queries []RunQuery[K], emit func(runEntry) error) error {
+	for _, q := range queries {
+		if q.QueryID == "" || strings.ContainsFunc(q.QueryID, isSpace) {
+			return fmt.Errorf("invalid query ID %q", q.QueryID)
+		}
+		for i, r := range q.Results {
+			docID := fmt.Sprint(r.Key)
+			if docID == "" || strings.ContainsFunc(docID, isSpace) {
+				return fmt.Errorf("query %s: invalid document ID %q", q.QueryID, docID)
+			}
+			err := emit(runEntry{QueryID: q.QueryID, DocID: docID, Rank: i + 1, Score: 0 - r.Distance})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// IDs are whitespace-separated in the TREC format.
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// WriteTRECRun writes queries in the TREC run format read by trec_eval
+// and ranx, one result per line:
+//
+//	qid Q0 docid rank score tag
+//
+// The score is the negated distance, so that the closest result scores
+// highest, and tag names the run. IDs must not contain whitespace.
+func WriteTRECRun[K comparable](w io.Writer, tag string, queries []RunQuery[K]) error {
+	if tag == "" || strings.ContainsFunc(tag, isSpace) {
+		return fmt.Errorf("invalid run tag %q", tag)
+	}
+	bw := bufio.NewWriter(w)
+	err := runEntries(queries, func(e runEntry) error {
+		_, err := fmt.Fprintf(bw, "%s Q0 %s %d %g %s\n", e.QueryID, e.DocID, e.Rank, e.Score, tag)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// WriteRunJSONL writes queries as JSON lines, one result per line, with
+// the fields "qid", "docid", "rank" and "score" as in WriteTRECRun. The
+// lines load directly into a data frame, e.g. for ranx's Run.from_df.
+func WriteRunJSONL[K comparable](w io.Writer, queries []RunQuery[K]) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err := runEntries(queries, func(e runEntry) error { return enc.Encode(e) }); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,41 @@
+package hnswtest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hypermodeinc/hnsw"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteRun(t *testing.T) {
+	queries := []RunQuery[int]{
+		{QueryID: "q1", Results: []hnsw.SearchResultNode[int]{
+			{Node: hnsw.MakeNode(7, nil), Distance: 0.5},
+			{Node: hnsw.MakeNode(3, nil), Distance: 1.25},
+		}},
+		{QueryID: "q2", Results: []hnsw.SearchResultNode[int]{
+			{Node: hnsw.MakeNode(1, nil), Distance: 0},
+		}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteTRECRun(&buf, "hnsw-m16", queries))
+	require.Equal(t, "q1 Q0 7 1 -0.5 hnsw-m16\n"+
+		"q1 Q0 3 2 -1.25 hnsw-m16\n"+
+		"q2 Q0 1 1 0 hnsw-m16\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteRunJSONL(&buf, queries))
+	require.Equal(t, `{"qid":"q1","docid":"7","rank":1,"score":-0.5}
+{"qid":"q1","docid":"3","rank":2,"score":-1.25}
+{"qid":"q2","docid":"1","rank":1,"score":0}
+`, buf.String())
+
+	require.Error(t, WriteTRECRun(&buf, "my run", queries))
+	require.Error(t, WriteTRECRun(&buf, "run", []RunQuery[string]{{
+		QueryID: "q1",
+		Results: []hnsw.SearchResultNode[string]{{Node: hnsw.MakeNode("a b", nil)}},
+	}}))
+	require.Error(t, WriteRunJSONL(&buf, []RunQuery[int]{{QueryID: ""}}))
+}
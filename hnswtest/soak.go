@@ -0,0 +1,364 @@
+package hnswtest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hypermodeinc/hnsw"
+)
+
+// SoakOptions configures Soak.
+type SoakOptions struct {
+	// Duration is how long the workload runs. Zero means until the
+	// context is done.
+	Duration time.Duration
+
+	// Workers is the number of goroutines running operations; <= 0 means
+	// GOMAXPROCS, and at least 2.
+	Workers int
+
+	// Keys is the size of the key space, [0, Keys). Adds of existing
+	// keys replace their nodes. Zero means 10000.
+	Keys int
+
+	// Dims is the number of dimensions of the vectors. Zero means 16.
+	Dims int
+
+	// K is the number of results of searches. Zero means 10.
+	K int
+
+	// AddWeight, DeleteWeight and SearchWeight weight the operations of
+	// the workload. All zero means 4, 1 and 5.
+	AddWeight, DeleteWeight, SearchWeight int
+
+	// CheckInterval is how often the workload pauses for a check of the
+	// invariants and of recall. Zero means one minute.
+	CheckInterval time.Duration
+
+	// MinRecall fails the soak if the recall@K of a check falls below it.
+	// Zero disables the recall check.
+	MinRecall float64
+
+	// Distance is the distance the index ranks with, used by the exact
+	// model recall is measured against. Nil means
+	// hnsw.EuclideanDistance.
+	Distance hnsw.DistanceFunc
+
+	// Seed seeds the workload, for reproducible operation sequences up to
+	// the interleaving of the workers.
+	Seed int64
+
+	// Progress, if set, receives the report after every check.
+	Progress func(SoakReport)
+}
+
+// SoakReport summarizes a soak.
+type SoakReport struct {
+	Elapsed time.Duration
+
+	// Adds, Deletes and Searches count the operations run.
+	Adds, Deletes, Searches int
+
+	// Checks is the number of checks passed.
+	Checks int
+
+	// Len is the number of nodes at the last check.
+	Len int
+
+	// Recall is the recall@K of the last check, and MinRecall the lowest
+	// of all checks.
+	Recall, MinRecall float64
+}
+
+// soakQueries is the number of queries of each recall check, and
+// soakLookups the number of keys whose presence and vector each check
+// compares with the model.
+const (
+	soakQueries = 50
+	soakLookups = 200
+)
+
+// Soak runs a randomized, concurrent workload of adds, deletes and
+// searches on index for a long time, e.g. hours, to certify a fork or a
+// new storage backend before production. It returns the first violation
+// found, with the report so far.
+//
+// The workers own disjoint sets of keys, so that an exact model of the
+// index can be kept alongside it. Every search is checked for sorted,
+// distinct results. At every check, the workload pauses and Soak
+// compares the length of the index and a sample of its keys and vectors
+// with the model, runs Verify if the index has a Verify method, as
+// hnsw.Graph does, and measures recall@K against exact search over the
+// model.
+func Soak(ctx context.Context, index hnsw.Index[int], opts SoakOptions) (SoakReport, error) {
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+	s := newSoak(index, opts)
+	start := time.Now()
+
+	var (
+		pause    sync.RWMutex
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+	)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	fail := func(err error) {
+		errMu.Lock()
+		defer errMu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	failed := func() error {
+		errMu.Lock()
+		defer errMu.Unlock()
+		return firstErr
+	}
+
+	for w := range s.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(opts.Seed + int64(w)))
+			for ctx.Err() == nil {
+				pause.RLock()
+				err := s.step(w, rng)
+				pause.RUnlock()
+				if err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+
+	interval := opts.CheckInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	rng := rand.New(rand.NewSource(opts.Seed - 1))
+	final := false
+	for !final {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			final = true
+		}
+		pause.Lock()
+		if failed() == nil {
+			if err := s.check(rng); err != nil {
+				fail(err)
+			}
+		}
+		s.count(func(r *SoakReport) { r.Elapsed = time.Since(start) })
+		report := s.snapshot()
+		pause.Unlock()
+		if failed() != nil {
+			break
+		}
+		if opts.Progress != nil {
+			opts.Progress(report)
+		}
+	}
+	cancel()
+	wg.Wait()
+	return s.snapshot(), failed()
+}
+
+// soak is the state of a running Soak.
+type soak struct {
+	index   hnsw.Index[int]
+	model   *Fake[int]
+	opts    SoakOptions
+	workers int
+	keys    int
+	dims    int
+	k       int
+	weights [3]int
+
+	mu     sync.Mutex
+	report SoakReport
+}
+
+func newSoak(index hnsw.Index[int], opts SoakOptions) *soak {
+	s := &soak{
+		index:   index,
+		model:   &Fake[int]{Distance: opts.Distance},
+		opts:    opts,
+		workers: opts.Workers,
+		keys:    opts.Keys,
+		dims:    opts.Dims,
+		k:       opts.K,
+		weights: [3]int{opts.AddWeight, opts.DeleteWeight, opts.SearchWeight},
+	}
+	if s.workers <= 0 {
+		s.workers = max(2, runtime.GOMAXPROCS(0))
+	}
+	if s.keys <= 0 {
+		s.keys = 10000
+	}
+	if s.dims <= 0 {
+		s.dims = 16
+	}
+	if s.k <= 0 {
+		s.k = 10
+	}
+	if s.weights == [3]int{} {
+		s.weights = [3]int{4, 1, 5}
+	}
+	s.report.MinRecall = 1
+	return s
+}
+
+// step runs a random operation of worker w, on a key it owns.
+func (s *soak) step(w int, rng *rand.Rand) error {
+	key := w + s.workers*rng.Intn(max(1, s.keys/s.workers))
+	op := rng.Intn(s.weights[0] + s.weights[1] + s.weights[2])
+	switch {
+	case op < s.weights[0]:
+		node := hnsw.MakeNode(key, s.vector(rng))
+		if err := s.index.Add(node); err != nil {
+			return fmt.Errorf("adding %d: %w", key, err)
+		}
+		s.model.Add(node)
+		s.count(func(r *SoakReport) { r.Adds++ })
+	case op < s.weights[0]+s.weights[1]:
+		_, want := s.model.Lookup(key)
+		if got := s.index.Delete(key); got != want {
+			return fmt.Errorf("deleting %d: got %t, want %t", key, got, want)
+		}
+		s.model.Delete(key)
+		s.count(func(r *SoakReport) { r.Deletes++ })
+	default:
+		if s.index.Len() == 0 {
+			return nil
+		}
+		results, err := s.index.Search(s.vector(rng), s.k)
+		if err != nil {
+			if s.index.Len() == 0 {
+				// Emptied by another worker.
+				return nil
+			}
+			return fmt.Errorf("searching: %w", err)
+		}
+		if err := checkResults(results, s.k); err != nil {
+			return err
+		}
+		s.count(func(r *SoakReport) { r.Searches++ })
+	}
+	return nil
+}
+
+// checkResults checks the results of a search for consistency.
+func checkResults(results []hnsw.SearchResultNode[int], k int) error {
+	if len(results) > k {
+		return fmt.Errorf("search returned %d results, want at most %d", len(results), k)
+	}
+	seen := make(map[int]bool, len(results))
+	for i, r := range results {
+		if seen[r.Key] {
+			return fmt.Errorf("search returned %d twice", r.Key)
+		}
+		seen[r.Key] = true
+		if i > 0 && r.Distance < results[i-1].Distance {
+			return fmt.Errorf("search results out of order at %d", i)
+		}
+	}
+	return nil
+}
+
+// check compares the index with the model. The workers must be paused.
+func (s *soak) check(rng *rand.Rand) error {
+	if got, want := s.index.Len(), s.model.Len(); got != want {
+		return fmt.Errorf("index has %d nodes, want %d", got, want)
+	}
+	for range soakLookups {
+		key := rng.Intn(s.keys)
+		got, ok := s.index.Lookup(key)
+		want, wantOK := s.model.Lookup(key)
+		if ok != wantOK {
+			return fmt.Errorf("lookup of %d: found %t, want %t", key, ok, wantOK)
+		}
+		if !slices.Equal(got, want) {
+			return fmt.Errorf("lookup of %d: vector differs from the one added", key)
+		}
+	}
+	if v, ok := s.index.(interface{ Verify() error }); ok {
+		if err := v.Verify(); err != nil {
+			return fmt.Errorf("verifying: %w", err)
+		}
+	}
+
+	recall := 1.0
+	if n := s.model.Len(); n > 0 {
+		var correct, total int
+		for range soakQueries {
+			q := s.vector(rng)
+			exact, err := s.model.Search(q, s.k)
+			if err != nil {
+				return err
+			}
+			results, err := s.index.Search(q, s.k)
+			if err != nil {
+				return fmt.Errorf("searching: %w", err)
+			}
+			// Ties with the k-th exact neighbor count as correct.
+			bound := exact[len(exact)-1].Distance
+			for _, r := range results {
+				if r.Distance <= bound {
+					correct++
+				}
+			}
+			total += len(exact)
+		}
+		recall = float64(correct) / float64(total)
+	}
+	// The model's record of queries is not needed.
+	s.model.mu.Lock()
+	s.model.queries = nil
+	s.model.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report.Checks++
+	s.report.Len = s.model.Len()
+	s.report.Recall = recall
+	s.report.MinRecall = min(s.report.MinRecall, recall)
+	if s.opts.MinRecall > 0 && recall < s.opts.MinRecall {
+		return fmt.Errorf("recall@%d %.3f below %.3f", s.k, recall, s.opts.MinRecall)
+	}
+	return nil
+}
+
+func (s *soak) vector(rng *rand.Rand) hnsw.Vector {
+	vec := make(hnsw.Vector, s.dims)
+	for i := range vec {
+		vec[i] = rng.Float32()
+	}
+	return vec
+}
+
+func (s *soak) count(f func(*SoakReport)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(&s.report)
+}
+
+func (s *soak) snapshot() SoakReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.report
+}
@@ -0,0 +1,47 @@
+package hnswtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hypermodeinc/hnsw"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSoak(t *testing.T) {
+	g := hnsw.NewGraph[int]()
+	g.Distance = hnsw.EuclideanDistance
+	var progress []SoakReport
+	report, err := Soak(context.Background(), g, SoakOptions{
+		Duration:      400 * time.Millisecond,
+		Workers:       4,
+		Keys:          400,
+		Dims:          4,
+		CheckInterval: 100 * time.Millisecond,
+		MinRecall:     0.8,
+		Progress:      func(r SoakReport) { progress = append(progress, r) },
+	})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, report.Checks, 3)
+	require.Len(t, progress, report.Checks)
+	require.Positive(t, report.Adds)
+	require.Positive(t, report.Deletes)
+	require.Positive(t, report.Searches)
+	require.Equal(t, g.Len(), report.Len)
+	require.GreaterOrEqual(t, report.MinRecall, 0.8)
+}
+
+// leakyIndex reports deletions without deleting.
+type leakyIndex struct{ *Fake[int] }
+
+func (leakyIndex) Delete(int) bool { return true }
+
+func TestSoak_Violation(t *testing.T) {
+	_, err := Soak(context.Background(), leakyIndex{&Fake[int]{}}, SoakOptions{
+		Duration:      time.Second,
+		Keys:          50,
+		CheckInterval: 20 * time.Millisecond,
+	})
+	require.ErrorContains(t, err, "deleting")
+}
@@ -0,0 +1,135 @@
+package hnsw
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+type fusionKind int
+
+const (
+	fuseRRF fusionKind = iota
+	fuseWeighted
+)
+
+// Fusion merges the ranked lists of the searches of a HybridIndex into
+// one. The zero value is FuseRRF(0).
+type Fusion struct {
+	kind fusionKind
+	// k is the constant of reciprocal rank fusion.
+	k float32
+	// dense and sparse weight the lists.
+	dense, sparse float32
+}
+
+// FuseRRF merges the lists by reciprocal rank fusion: a key scores
+// 1/(k+rank) in each list it is in, with ranks starting at 1. It needs no
+// tuning, as it ignores the distances, which aren't comparable between
+// dense and sparse spaces. k <= 0 means 60, the usual value.
+func FuseRRF(k float32) Fusion {
+	if k <= 0 {
+		k = 60
+	}
+	return Fusion{kind: fuseRRF, k: k, dense: 1, sparse: 1}
+}
+
+// FuseWeighted merges the lists by their distances: each list's distances
+// are scaled to scores from 1 for its best result to 0 for its worst, and
+// a key scores the weighted sum of its scores in the lists it is in.
+func FuseWeighted(dense, sparse float32) Fusion {
+	return Fusion{kind: fuseWeighted, dense: dense, sparse: sparse}
+}
+
+// HybridResult is a key found by HybridIndex.Search.
+type HybridResult[K comparable] struct {
+	Key K
+
+	// Score is the fused score; higher is better.
+	Score float32
+
+	// DenseRank and SparseRank are the ranks of the key in the dense and
+	// sparse results, starting at 1, or 0 if it isn't in them.
+	DenseRank, SparseRank int
+}
+
+// HybridIndex combines a dense and a sparse index over the same keys,
+// e.g. a graph of embeddings and a graph of SPLADE or BM25 term weights
+// using SparseDotProductDistance, and searches both at once. Hybrid
+// retrieval finds both the documents that match the meaning of a query
+// and those that match its exact terms.
+type HybridIndex[K comparable] struct {
+	Dense, Sparse Index[K]
+
+	// Depth is the number of results fetched from each index. Zero means
+	// 2*k.
+	Depth int
+}
+
+// Search searches Dense for dense and Sparse for sparse, and merges the
+// results with fusion into the k best keys, best first. Ties are broken
+// by key order.
+func (h *HybridIndex[K]) Search(dense Vector, sparse SparseVector, k int, fusion Fusion) ([]HybridResult[K], error) {
+	if fusion == (Fusion{}) {
+		fusion = FuseRRF(0)
+	}
+	depth := h.Depth
+	if depth <= 0 {
+		depth = 2 * k
+	}
+	query, err := sparse.Encode(max(1, len(sparse.Indices)))
+	if err != nil {
+		return nil, fmt.Errorf("sparse query: %w", err)
+	}
+	denseResults, err := h.Dense.Search(dense, depth)
+	if err != nil {
+		return nil, fmt.Errorf("dense search: %w", err)
+	}
+	sparseResults, err := h.Sparse.Search(query, depth)
+	if err != nil {
+		return nil, fmt.Errorf("sparse search: %w", err)
+	}
+
+	byKey := make(map[K]*HybridResult[K])
+	merge := func(results []SearchResultNode[K], weight float32, rank func(*HybridResult[K]) *int) {
+		lo, hi := float32(0), float32(0)
+		if len(results) > 0 {
+			lo, hi = results[0].Distance, results[len(results)-1].Distance
+		}
+		for i, r := range results {
+			res, ok := byKey[r.Key]
+			if !ok {
+				res = &HybridResult[K]{Key: r.Key}
+				byKey[r.Key] = res
+			}
+			*rank(res) = i + 1
+			switch fusion.kind {
+			case fuseRRF:
+				res.Score += weight / (fusion.k + float32(i+1))
+			case fuseWeighted:
+				score := float32(1)
+				if hi > lo {
+					score = (hi - r.Distance) / (hi - lo)
+				}
+				res.Score += weight * score
+			}
+		}
+	}
+	merge(denseResults, fusion.dense, func(r *HybridResult[K]) *int { return &r.DenseRank })
+	merge(sparseResults, fusion.sparse, func(r *HybridResult[K]) *int { return &r.SparseRank })
+
+	out := make([]HybridResult[K], 0, len(byKey))
+	for _, r := range byKey {
+		out = append(out, *r)
+	}
+	slices.SortFunc(out, func(a, b HybridResult[K]) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return CompareKeys(a.Key, b.Key)
+	})
+	if len(out) > k {
+		out = out[:k]
+	}
+	return out, nil
+}
@@ -0,0 +1,73 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHybridIndex(t *testing.T) {
+	dense := newTestGraph[string]()
+	sparse := newTestGraph[string]()
+	sparse.Distance = SparseDotProductDistance
+	for _, doc := range []struct {
+		key   string
+		dense float32
+		terms SparseVector
+	}{
+		// x is closest in meaning, y matches the query term best, and z is
+		// second in both.
+		{"x", 0, SparseVector{Indices: []uint32{1, 2}, Values: []float32{0.1, 1}}},
+		{"y", 5, SparseVector{Indices: []uint32{1}, Values: []float32{3}}},
+		{"z", 1, SparseVector{Indices: []uint32{1}, Values: []float32{2}}},
+		{"a", 6, SparseVector{Indices: []uint32{2}, Values: []float32{1}}},
+		{"b", 7, SparseVector{Indices: []uint32{3}, Values: []float32{1}}},
+	} {
+		require.NoError(t, dense.Add(MakeNode(doc.key, Vector{doc.dense})))
+		node, err := MakeSparseNode(doc.key, doc.terms, 2)
+		require.NoError(t, err)
+		require.NoError(t, sparse.Add(node))
+	}
+
+	h := &HybridIndex[string]{Dense: dense, Sparse: sparse, Depth: 3}
+	query := SparseVector{Indices: []uint32{1}, Values: []float32{1}}
+	keys := func(results []HybridResult[string]) []string {
+		var keys []string
+		for _, r := range results {
+			keys = append(keys, r.Key)
+		}
+		return keys
+	}
+
+	results, err := h.Search(Vector{0}, query, 3, FuseRRF(0))
+	require.NoError(t, err)
+	require.Equal(t, []string{"x", "y", "z"}, keys(results))
+	require.Equal(t, HybridResult[string]{Key: "z", Score: 2.0 / 62, DenseRank: 2, SparseRank: 2}, results[2])
+
+	// By distance, z is close to the best in both lists.
+	results, err = h.Search(Vector{0}, query, 3, FuseWeighted(1, 0.9))
+	require.NoError(t, err)
+	require.Equal(t, []string{"z", "x", "y"}, keys(results))
+	require.InDelta(t, 0.8+0.9*1.9/2.9, results[0].Score, 1e-6)
+
+	results, err = h.Search(Vector{0}, query, 3, FuseWeighted(1, 0))
+	require.NoError(t, err)
+	require.Equal(t, []string{"x", "z", "y"}, keys(results))
+
+	// Every key found by either index is ranked.
+	h.Depth = 5
+	results, err = h.Search(Vector{6}, SparseVector{Indices: []uint32{3}, Values: []float32{1}}, 5, FuseRRF(0))
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	require.Equal(t, "b", results[0].Key)
+
+	// The zero Fusion is reciprocal rank fusion.
+	zero, err := h.Search(Vector{0}, query, 3, Fusion{})
+	require.NoError(t, err)
+	rrf, err := h.Search(Vector{0}, query, 3, FuseRRF(0))
+	require.NoError(t, err)
+	require.Equal(t, rrf, zero)
+
+	_, err = h.Search(Vector{0}, SparseVector{Indices: []uint32{1}}, 3, FuseRRF(0))
+	require.ErrorContains(t, err, "sparse query")
+}
@@ -0,0 +1,32 @@
+package hnsw
+
+import (
+	"io"
+)
+
+// Index is the interface of a vector index, so that applications can
+// switch between implementations through configuration.
+//
+// Graph, SavedGraph and BruteForce implement it. WALGraph doesn't, because its Delete
+// also reports logging errors.
+type Index[K comparable] interface {
+	// Add inserts nodes, replacing nodes with the same key.
+	Add(nodes ...Node[K]) error
+	// Search finds the k nearest neighbors of near.
+	Search(near Vector, k int) ([]SearchResultNode[K], error)
+	// Delete removes the node with the given key and reports whether it
+	// existed.
+	Delete(key K) bool
+	// Lookup returns the vector stored under key.
+	Lookup(key K) (Vector, bool)
+	// Len returns the number of nodes.
+	Len() int
+	// Export writes the index to w, to be read back with the
+	// implementation's Import.
+	Export(w io.Writer) error
+}
+
+var (
+	_ Index[int] = (*Graph[int])(nil)
+	_ Index[int] = (*SavedGraph[int])(nil)
+)
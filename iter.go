@@ -0,0 +1,170 @@
+package hnsw
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"sync"
+)
+
+// The iterators of a Graph don't hold its lock while the loop body runs,
+// so the body may use the graph, including writing to it. Each iterator
+// documents what it sees of concurrent writes.
+
+// All returns an iterator over the keys and vectors of the nodes in the
+// graph, in ascending key order, e.g. for backfills and audits. It
+// iterates over a snapshot of the keys taken when the loop starts; nodes
+// deleted since are skipped, and the vector yielded is the current one.
+func (g *Graph[K]) All() iter.Seq2[K, Vector] {
+	return func(yield func(K, Vector) bool) {
+		for _, key := range g.sortedKeys() {
+			vec, ok := g.Lookup(key)
+			if !ok {
+				continue
+			}
+			if !yield(key, vec) {
+				return
+			}
+		}
+	}
+}
+
+// Keys returns an iterator over the keys of the nodes in the graph, in
+// ascending order, as they were when the loop starts.
+func (g *Graph[K]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for _, key := range g.sortedKeys() {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// sortedKeys returns the keys of the nodes in the graph in ascending
+// order.
+func (g *Graph[K]) sortedKeys() []K {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if len(g.layers) == 0 {
+		return nil
+	}
+	keys := make([]K, 0, g.len())
+	for key := range g.layers[0].nodes {
+		if !g.isTombstone(key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, keyOrder[K]())
+	return keys
+}
+
+// Nearest returns an iterator over the nodes nearest to near, closest
+// first, for when the number of results needed isn't known up front,
+// e.g. when results are post-filtered.
+//
+// Results are fetched in pages of growing size, each a separate search
+// that sees the graph as it is then. A node is yielded at most once.
+// Since larger pages search more thoroughly, a page may find nodes that
+// are closer than the last node of the previous page, so the order is
+// only approximately by distance. A search error is yielded once and
+// ends the iteration.
+func (g *Graph[K]) Nearest(near Vector) iter.Seq2[SearchResultNode[K], error] {
+	return func(yield func(SearchResultNode[K], error) bool) {
+		seen := make(map[K]struct{})
+		for k := 16; ; k *= 4 {
+			page, err := g.SearchWithOptions(near, k, SearchOptions[K]{EfSearch: k})
+			if err != nil {
+				yield(SearchResultNode[K]{}, err)
+				return
+			}
+			for _, r := range page {
+				if _, ok := seen[r.Key]; ok {
+					continue
+				}
+				seen[r.Key] = struct{}{}
+				if !yield(r, nil) {
+					return
+				}
+			}
+			if len(page) < k {
+				return
+			}
+		}
+	}
+}
+
+// Change is an insertion or deletion reported by Changes.
+type Change[K comparable] struct {
+	Key K
+	// Value is the vector of an added node, or nil if Deleted.
+	Value   Vector
+	Deleted bool
+}
+
+// changeFeed queues the changes for one iteration of Changes.
+type changeFeed[K comparable] struct {
+	mu      sync.Mutex
+	pending []Change[K]
+	// wake has a value when pending was appended to.
+	wake chan struct{}
+}
+
+// Changes returns an iterator over the changes to the graph, for keeping
+// another store in sync. Iterating starts a live feed: every Add and
+// Delete from then on is yielded in order, until ctx is done or the loop
+// breaks. Changes before the loop starts aren't included; use All for
+// the initial contents.
+//
+// Changes are queued while the loop body runs, so a slow body doesn't
+// block writes but its queue can grow without bound.
+func (g *Graph[K]) Changes(ctx context.Context) iter.Seq[Change[K]] {
+	return func(yield func(Change[K]) bool) {
+		feed := &changeFeed[K]{wake: make(chan struct{}, 1)}
+		g.mu.Lock()
+		if g.feeds == nil {
+			g.feeds = make(map[*changeFeed[K]]struct{})
+		}
+		g.feeds[feed] = struct{}{}
+		g.mu.Unlock()
+		defer func() {
+			g.mu.Lock()
+			delete(g.feeds, feed)
+			g.mu.Unlock()
+		}()
+
+		for {
+			feed.mu.Lock()
+			batch := feed.pending
+			feed.pending = nil
+			feed.mu.Unlock()
+			for _, c := range batch {
+				if !yield(c) {
+					return
+				}
+			}
+			if len(batch) > 0 {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-feed.wake:
+			}
+		}
+	}
+}
+
+// publishChange queues c for every iteration of Changes. The caller must
+// hold the write lock.
+func (g *Graph[K]) publishChange(c Change[K]) {
+	for feed := range g.feeds {
+		feed.mu.Lock()
+		feed.pending = append(feed.pending, c)
+		feed.mu.Unlock()
+		select {
+		case feed.wake <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package hnsw
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_All(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 9; i >= 0; i-- {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+	g.MarkDeleted(3)
+
+	var keys []int
+	for key, vec := range g.All() {
+		require.Equal(t, Vector{float32(key)}, vec)
+		keys = append(keys, key)
+		// The loop body may write to the graph.
+		g.Delete(key + 1)
+	}
+	require.Equal(t, []int{0, 2, 4, 6, 8}, keys)
+
+	keys = keys[:0]
+	for key := range g.Keys() {
+		keys = append(keys, key)
+		if len(keys) == 2 {
+			break
+		}
+	}
+	require.Equal(t, []int{0, 2}, keys)
+	require.Empty(t, slices.Collect(newTestGraph[int]().Keys()))
+}
+
+func TestGraph_Nearest(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 100; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+
+	var keys []int
+	for r, err := range g.Nearest(Vector{0}) {
+		require.NoError(t, err)
+		keys = append(keys, r.Key)
+	}
+	require.Len(t, keys, 100)
+	require.Equal(t, []int{0, 1, 2, 3}, keys[:4])
+
+	// Breaking early.
+	keys = keys[:0]
+	for r := range g.Nearest(Vector{50}) {
+		keys = append(keys, r.Key)
+		if len(keys) == 3 {
+			break
+		}
+	}
+	require.Len(t, keys, 3)
+	require.Equal(t, 50, keys[0])
+
+	for _, err := range g.Nearest(Vector{1, 2}) {
+		require.Error(t, err)
+	}
+}
+
+func TestGraph_Changes(t *testing.T) {
+	g := newTestGraph[int]()
+	g.Add(MakeNode(1, Vector{1}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan Change[int])
+	go func() {
+		defer close(changes)
+		for c := range g.Changes(ctx) {
+			changes <- c
+		}
+	}()
+	require.Eventually(t, func() bool {
+		g.mu.RLock()
+		defer g.mu.RUnlock()
+		return len(g.feeds) == 1
+	}, time.Second, time.Millisecond)
+
+	g.Add(MakeNode(2, Vector{2}))
+	g.Delete(1)
+	g.Delete(3)
+	require.Equal(t, Change[int]{Key: 2, Value: Vector{2}}, <-changes)
+	require.Equal(t, Change[int]{Key: 1, Deleted: true}, <-changes)
+
+	// Marked nodes are deleted when they are marked, not again when they
+	// are compacted or deleted.
+	g.Add(MakeNode(3, Vector{3}), MakeNode(4, Vector{4}))
+	g.MarkDeleted(2, 3)
+	g.Compact()
+	g.MarkDeleted(4)
+	g.Delete(4)
+	g.Add(MakeNode(5, Vector{5}))
+	require.Equal(t, Change[int]{Key: 3, Value: Vector{3}}, <-changes)
+	require.Equal(t, Change[int]{Key: 4, Value: Vector{4}}, <-changes)
+	require.Equal(t, Change[int]{Key: 2, Deleted: true}, <-changes)
+	require.Equal(t, Change[int]{Key: 3, Deleted: true}, <-changes)
+	require.Equal(t, Change[int]{Key: 4, Deleted: true}, <-changes)
+	require.Equal(t, Change[int]{Key: 5, Value: Vector{5}}, <-changes)
+
+	cancel()
+	_, ok := <-changes
+	require.False(t, ok)
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	require.Empty(t, g.feeds)
+}
@@ -0,0 +1,146 @@
+package hnsw
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// Comparer is implemented by key types with a natural order, like
+// time.Time and netip.Addr. Keys are sorted with Compare when it is
+// available.
+type Comparer[K any] interface {
+	// Compare returns -1, 0 or +1 depending on whether the receiver is
+	// less than, equal to or greater than other.
+	Compare(other K) int
+}
+
+// CompareKeys orders keys like cmp.Compare, so that graphs are built,
+// exported and iterated deterministically. Keys implementing Comparer
+// compare with Compare, and keys of ordered types, including named ones,
+// as usual. Other keys, such as structs and arrays, compare field by
+// field or element by element.
+func CompareKeys[K comparable](a, b K) int {
+	return keyOrder[K]()(a, b)
+}
+
+// keyOrders caches the result of keyOrder by key type.
+var keyOrders sync.Map // reflect.Type -> func(a, b K) int
+
+// keyOrder returns CompareKeys for K. Sorts call it once rather than
+// CompareKeys for every comparison.
+func keyOrder[K comparable]() func(a, b K) int {
+	t := reflect.TypeFor[K]()
+	if order, ok := keyOrders.Load(t); ok {
+		return order.(func(a, b K) int)
+	}
+	order := newKeyOrder[K](t)
+	keyOrders.Store(t, order)
+	return order
+}
+
+func newKeyOrder[K comparable](t reflect.Type) func(a, b K) int {
+	if t.Implements(reflect.TypeFor[Comparer[K]]()) {
+		return func(a, b K) int {
+			return any(a).(Comparer[K]).Compare(b)
+		}
+	}
+	switch t.Kind() {
+	case reflect.Int:
+		return orderAs[int, K]
+	case reflect.Int8:
+		return orderAs[int8, K]
+	case reflect.Int16:
+		return orderAs[int16, K]
+	case reflect.Int32:
+		return orderAs[int32, K]
+	case reflect.Int64:
+		return orderAs[int64, K]
+	case reflect.Uint:
+		return orderAs[uint, K]
+	case reflect.Uint8:
+		return orderAs[uint8, K]
+	case reflect.Uint16:
+		return orderAs[uint16, K]
+	case reflect.Uint32:
+		return orderAs[uint32, K]
+	case reflect.Uint64:
+		return orderAs[uint64, K]
+	case reflect.Uintptr:
+		return orderAs[uintptr, K]
+	case reflect.Float32:
+		return orderAs[float32, K]
+	case reflect.Float64:
+		return orderAs[float64, K]
+	case reflect.String:
+		return orderAs[string, K]
+	}
+	// The values are taken through pointers so that interface keys keep
+	// their kind, and mixed or nil dynamic types are ordered like nested
+	// interfaces.
+	return func(a, b K) int {
+		return compareValues(reflect.ValueOf(&a).Elem(), reflect.ValueOf(&b).Elem())
+	}
+}
+
+// orderAs compares keys as their underlying ordered type T, without
+// converting them to interfaces.
+func orderAs[T cmp.Ordered, K comparable](a, b K) int {
+	return cmp.Compare(*(*T)(unsafe.Pointer(&a)), *(*T)(unsafe.Pointer(&b)))
+}
+
+// compareValues orders two values of the same comparable type.
+func compareValues(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Bool:
+		return cmp.Compare(boolInt(a.Bool()), boolInt(b.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return cmp.Compare(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(a.Float(), b.Float())
+	case reflect.Complex64, reflect.Complex128:
+		if c := cmp.Compare(real(a.Complex()), real(b.Complex())); c != 0 {
+			return c
+		}
+		return cmp.Compare(imag(a.Complex()), imag(b.Complex()))
+	case reflect.String:
+		return cmp.Compare(a.String(), b.String())
+	case reflect.Array:
+		for i := range a.Len() {
+			if c := compareValues(a.Index(i), b.Index(i)); c != 0 {
+				return c
+			}
+		}
+		return 0
+	case reflect.Struct:
+		for i := range a.NumField() {
+			if c := compareValues(a.Field(i), b.Field(i)); c != 0 {
+				return c
+			}
+		}
+		return 0
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		// Ordered by address: consistent, but not across runs.
+		return cmp.Compare(a.Pointer(), b.Pointer())
+	case reflect.Interface:
+		switch {
+		case a.IsNil() || b.IsNil():
+			return cmp.Compare(boolInt(!a.IsNil()), boolInt(!b.IsNil()))
+		case a.Elem().Type() != b.Elem().Type():
+			return cmp.Compare(a.Elem().Type().String(), b.Elem().Type().String())
+		}
+		return compareValues(a.Elem(), b.Elem())
+	}
+	panic(fmt.Sprintf("hnsw: keys of kind %v cannot be compared", a.Kind()))
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
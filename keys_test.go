@@ -0,0 +1,74 @@
+package hnsw
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type compositeKey struct {
+	Tenant uint32
+	Doc    [16]byte
+}
+
+type namedKey int
+
+func TestCompareKeys(t *testing.T) {
+	require.Negative(t, CompareKeys(1, 2))
+	require.Zero(t, CompareKeys("a", "a"))
+	require.Positive(t, CompareKeys(namedKey(3), namedKey(-1)))
+	require.Negative(t, CompareKeys(2.5, 3.0))
+
+	// Comparer is used when available.
+	now := time.Now()
+	require.Negative(t, CompareKeys(now, now.Add(time.Second)))
+
+	keys := []compositeKey{
+		{Tenant: 2, Doc: [16]byte{1}},
+		{Tenant: 1, Doc: [16]byte{2}},
+		{Tenant: 1, Doc: [16]byte{1, 5}},
+		{Tenant: 1, Doc: [16]byte{1}},
+	}
+	slices.SortFunc(keys, CompareKeys)
+	require.Equal(t, []compositeKey{
+		{Tenant: 1, Doc: [16]byte{1}},
+		{Tenant: 1, Doc: [16]byte{1, 5}},
+		{Tenant: 1, Doc: [16]byte{2}},
+		{Tenant: 2, Doc: [16]byte{1}},
+	}, keys)
+
+	// Keys of interface type may hold different dynamic types, or nil.
+	mixed := []any{"a", 2, nil, 1, "b"}
+	slices.SortFunc(mixed, CompareKeys)
+	require.Equal(t, []any{nil, 1, 2, "a", "b"}, mixed)
+	require.Equal(t, -CompareKeys[any](1, "a"), CompareKeys[any]("a", 1))
+}
+
+func TestGraph_CompositeKeys(t *testing.T) {
+	g := newTestGraph[compositeKey]()
+	var keys []compositeKey
+	for i := 0; i < 100; i++ {
+		key := compositeKey{Tenant: uint32(i % 3), Doc: [16]byte{byte(i)}}
+		keys = append(keys, key)
+		require.NoError(t, g.Add(MakeNode(key, Vector{float32(i), 1})))
+	}
+	require.Equal(t, 100, g.Len())
+	results, err := g.Search(Vector{42, 1}, 1)
+	require.NoError(t, err)
+	require.Equal(t, keys[42], results[0].Key)
+
+	var buf bytes.Buffer
+	require.NoError(t, g.Export(&buf))
+	g2 := NewGraph[compositeKey]()
+	require.NoError(t, g2.Import(&buf))
+	require.Equal(t, 100, g2.Len())
+	vec, ok := g2.Lookup(keys[7])
+	require.True(t, ok)
+	require.Equal(t, Vector{7, 1}, vec)
+
+	require.True(t, g.Delete(keys[42]))
+	require.NoError(t, g.Verify())
+}
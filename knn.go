@@ -0,0 +1,105 @@
+package hnsw
+
+import "fmt"
+
+// ImportKNNGraph builds the graph from a precomputed k-nearest-neighbor
+// graph, e.g. one built on a GPU, instead of searching for the
+// neighbors of every node. knn[i] lists the neighbors of nodes[i],
+// nearest first; at most M of them are kept.
+//
+// The base layer is taken from knn, with reverse edges added where a
+// node has room for them, so it is only as good as the kNN graph. The
+// upper layers hold few nodes and are built by regular insertion.
+// The graph must be empty and its parameters set.
+func (g *Graph[K]) ImportKNNGraph(nodes []Node[K], knn [][]K) error {
+	if len(knn) != len(nodes) {
+		return fmt.Errorf("got %d neighbor lists for %d nodes", len(knn), len(nodes))
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.len() != 0 {
+		return fmt.Errorf("graph is not empty")
+	}
+	if g.Distance == nil {
+		return fmt.Errorf("(*Graph).Distance must be set")
+	}
+
+	added := g.clock().UnixNano()
+	m := g.maxNeighbors(0)
+	base := &layer[K]{
+		nodes: make(map[K]*layerNode[K], len(nodes)),
+		byID:  make([]*layerNode[K], 0, len(nodes)),
+	}
+	for _, node := range nodes {
+		if _, ok := base.nodes[node.Key]; ok {
+			return fmt.Errorf("duplicate key %v", node.Key)
+		}
+		if len(node.Value) != len(nodes[0].Value) {
+			return fmt.Errorf("node %v has %d dimensions, want %d", node.Key, len(node.Value), len(nodes[0].Value))
+		}
+		base.add(&layerNode[K]{
+			Node:      node,
+			neighbors: make([]uint32, 0, m),
+			added:     added,
+		})
+	}
+
+	for i, keys := range knn {
+		node := base.nodes[nodes[i].Key]
+		for _, key := range keys {
+			if len(node.neighbors) >= m {
+				break
+			}
+			neighbor, ok := base.nodes[key]
+			if !ok {
+				return fmt.Errorf("node %v has unknown neighbor %v", node.Key, key)
+			}
+			if neighbor != node && !node.hasNeighbor(neighbor) {
+				node.neighbors = append(node.neighbors, neighbor.id)
+			}
+		}
+	}
+	// kNN edges are one-way; add the reverse edges that fit to keep the
+	// base layer navigable.
+	for i := range knn {
+		node := base.nodes[nodes[i].Key]
+		for _, neighbor := range liveNeighbors(node) {
+			if len(neighbor.neighbors) < m && !neighbor.hasNeighbor(node) {
+				neighbor.neighbors = append(neighbor.neighbors, node.id)
+			}
+		}
+	}
+
+	// randomLevel scales the maximum level with the size of the base
+	// layer, so draw the levels with the base layer in place.
+	g.layers = []*layer[K]{base}
+	levels := make([]int, len(nodes))
+	for i := range nodes {
+		level, err := g.randomLevel(nodes[i].Key)
+		if err != nil {
+			g.layers = nil
+			return err
+		}
+		levels[i] = level
+	}
+
+	g.layers = nil
+	for i, node := range nodes {
+		if levels[i] == 0 {
+			continue
+		}
+		if err := g.insert(node, levels[i]); err != nil {
+			g.layers = nil
+			return err
+		}
+	}
+	if len(g.layers) == 0 {
+		g.layers = []*layer[K]{base}
+	} else {
+		g.layers[0] = base
+	}
+	g.sketch = rebuildSketch(base)
+	return nil
+}
@@ -0,0 +1,67 @@
+package hnsw
+
+import (
+	"cmp"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// bruteForceKNN returns the k nearest other nodes of every node.
+func bruteForceKNN(t *testing.T, nodes []Node[int], k int, dist DistanceFunc) [][]int {
+	knn := make([][]int, len(nodes))
+	for i, a := range nodes {
+		type scored struct {
+			key  int
+			dist float32
+		}
+		var all []scored
+		for _, b := range nodes {
+			if a.Key == b.Key {
+				continue
+			}
+			d, err := dist(a.Value, b.Value)
+			require.NoError(t, err)
+			all = append(all, scored{b.Key, d})
+		}
+		slices.SortFunc(all, func(x, y scored) int { return cmp.Compare(x.dist, y.dist) })
+		for _, s := range all[:k] {
+			knn[i] = append(knn[i], s.key)
+		}
+	}
+	return knn
+}
+
+func TestGraph_ImportKNNGraph(t *testing.T) {
+	g := newTestGraph[int]()
+	nodes := make([]Node[int], 256)
+	for i := range nodes {
+		nodes[i] = MakeNode(i, randFloats(4))
+	}
+	knn := bruteForceKNN(t, nodes, g.M, g.Distance)
+
+	require.NoError(t, g.ImportKNNGraph(nodes, knn))
+	require.NoError(t, g.Verify())
+	require.Equal(t, len(nodes), g.Len())
+	require.Greater(t, len(g.layers), 1)
+
+	var found int
+	for _, node := range nodes {
+		results, err := g.Search(node.Value, 1)
+		require.NoError(t, err)
+		if len(results) == 1 && results[0].Key == node.Key {
+			found++
+		}
+	}
+	require.Greater(t, found, len(nodes)*8/10)
+
+	t.Run("Errors", func(t *testing.T) {
+		require.Error(t, g.ImportKNNGraph(nodes, knn), "graph is not empty")
+
+		g := newTestGraph[int]()
+		require.Error(t, g.ImportKNNGraph(nodes, knn[1:]))
+		require.Error(t, g.ImportKNNGraph(nodes[:2], [][]int{{1}, {99}}))
+		require.Error(t, g.ImportKNNGraph([]Node[int]{nodes[0], nodes[0]}, [][]int{nil, nil}))
+	})
+}
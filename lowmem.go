@@ -0,0 +1,29 @@
+package hnsw
+
+// LowMemoryBytesPerNode is the approximate memory used per node by a
+// graph from NewLowMemoryGraph, on top of the node's key and 4 bytes per
+// vector dimension, on 64-bit platforms with int keys.
+const LowMemoryBytesPerNode = 400
+
+// NewLowMemoryGraph returns a new graph for memory-constrained devices,
+// such as phones and embedded boards. It trades recall and speed for
+// memory:
+//
+//   - M is 6, which keeps each neighbor set in a single block of a Go
+//     map. Graphs from NewGraph use about 1000 bytes per node for M = 16.
+//   - EfSearch is 16 and EfConstruction 24, so searches and insertions
+//     touch, and allocate for, fewer nodes.
+//   - DisablePooling is set, so no scratch memory is retained between
+//     searches.
+//
+// See LowMemoryBytesPerNode for the resulting overhead per node. Expect
+// noticeably lower recall than with NewGraph; raising EfSearch per query
+// with SearchOptions.EfSearch recovers some of it without costing memory.
+func NewLowMemoryGraph[K comparable]() *Graph[K] {
+	g := NewGraph[K]()
+	g.M = 6
+	g.EfSearch = 16
+	g.EfConstruction = 24
+	g.DisablePooling = true
+	return g
+}
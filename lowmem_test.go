@@ -0,0 +1,39 @@
+package hnsw
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func heapAlloc() uint64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+func TestNewLowMemoryGraph(t *testing.T) {
+	const n = 5000
+	vecs := make([]Vector, n)
+	for i := range vecs {
+		vecs[i] = randFloats(16)
+	}
+
+	before := heapAlloc()
+	g := NewLowMemoryGraph[int]()
+	for i, vec := range vecs {
+		require.NoError(t, g.Add(MakeNode(i, vec)))
+	}
+	results, err := g.Search(vecs[0], 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	after := heapAlloc()
+
+	// The vectors were allocated before.
+	perNode := int(after-before) / n
+	t.Logf("%d bytes per node", perNode)
+	require.LessOrEqual(t, perNode, LowMemoryBytesPerNode)
+	runtime.KeepAlive(g)
+}
@@ -0,0 +1,90 @@
+package hnsw
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Merge inserts the nodes of other, along with their payloads, into g,
+// e.g. to combine shards built in parallel into one index. The nodes are
+// linked into g with AddBatch using workers goroutines, so the result is
+// about as good as building g from all the nodes. Nodes of other that
+// have the same key as a node in g replace it, and nodes marked with
+// MarkDeleted are skipped. other is not modified.
+//
+// The graphs must use the same distance function and number of
+// dimensions. g keeps its parameters.
+func (g *Graph[K]) Merge(other *Graph[K], workers int) error {
+	if g == other {
+		return fmt.Errorf("cannot merge a graph into itself")
+	}
+
+	nodes, payloads := other.mergeable()
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	g.mu.RLock()
+	err := g.checkMergeable(other, nodes[0].Value)
+	g.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if err := g.AddBatch(nodes, workers); err != nil {
+		return fmt.Errorf("inserting: %w", err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for key, payload := range payloads {
+		// The node may have been deleted concurrently.
+		if g.layers[0].nodes[key] == nil {
+			continue
+		}
+		if g.payloads == nil {
+			g.payloads = make(map[K][]byte)
+		}
+		g.payloads[key] = payload
+	}
+	return nil
+}
+
+// mergeable returns the live nodes of g, in key order, and their
+// payloads.
+func (g *Graph[K]) mergeable() ([]Node[K], map[K][]byte) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if len(g.layers) == 0 {
+		return nil, nil
+	}
+
+	var nodes []Node[K]
+	payloads := make(map[K][]byte)
+	for _, key := range sortedMapKeys(g.layers[0].nodes) {
+		if g.isTombstone(key) {
+			continue
+		}
+		nodes = append(nodes, g.layers[0].nodes[key].Node)
+		if payload, ok := g.payloads[key]; ok {
+			payloads[key] = payload
+		}
+	}
+	return slices.Clip(nodes), payloads
+}
+
+// checkMergeable returns an error if the nodes of other, of which vec is
+// one, can't be merged into g. The caller must hold the read lock.
+func (g *Graph[K]) checkMergeable(other *Graph[K], vec Vector) error {
+	if err := g.assertDims(vec); err != nil {
+		return err
+	}
+	name, _ := distanceFuncToName(g.Distance)
+	other.mu.RLock()
+	otherName, _ := distanceFuncToName(other.Distance)
+	other.mu.RUnlock()
+	if name != otherName {
+		return fmt.Errorf("distance functions differ: %q != %q", name, otherName)
+	}
+	return nil
+}
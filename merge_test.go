@@ -0,0 +1,57 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Merge(t *testing.T) {
+	a, b := newTestGraph[int](), newTestGraph[int]()
+	var nodes []Node[int]
+	for i := range 400 {
+		nodes = append(nodes, MakeNode(i, unitFloats(16)))
+	}
+	// Interleave the shards so that both cover the whole space.
+	for _, node := range nodes {
+		shard := a
+		if node.Key%2 == 1 {
+			shard = b
+		}
+		require.NoError(t, shard.Add(node))
+	}
+	require.NoError(t, b.SetPayload(1, []byte("one")))
+	b.MarkDeleted(3)
+
+	require.NoError(t, a.Merge(b, 2))
+	require.Equal(t, 399, a.Len())
+	require.Equal(t, 199, b.Len())
+	require.NoError(t, a.Verify())
+	_, ok := a.Lookup(3)
+	require.False(t, ok)
+	payload, ok := a.Payload(1)
+	require.True(t, ok)
+	require.Equal(t, []byte("one"), payload)
+
+	// Recall is about as good as that of a graph built in one go.
+	whole := newTestGraph[int]()
+	require.NoError(t, whole.Add(nodes...))
+	whole.Delete(3)
+	found := func(g *Graph[int]) int {
+		var n int
+		for _, node := range nodes {
+			results, err := g.Search(node.Value, 1)
+			require.NoError(t, err)
+			if results[0].Key == node.Key {
+				n++
+			}
+		}
+		return n
+	}
+	require.GreaterOrEqual(t, found(a), found(whole)-10)
+
+	require.Error(t, a.Merge(a, 1))
+	c := newTestGraph[int]()
+	require.NoError(t, c.Add(MakeNode(1, Vector{1, 2, 3})))
+	require.Error(t, a.Merge(c, 1))
+}
@@ -0,0 +1,219 @@
+package hnsw
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Middleware wraps an Index to add a cross-cutting concern, such as
+// metrics or caching, without changing the index itself.
+type Middleware[K comparable] func(Index[K]) Index[K]
+
+// Chain wraps idx with middlewares. The first middleware is the
+// outermost, so it sees every call first.
+func Chain[K comparable](idx Index[K], middlewares ...Middleware[K]) Index[K] {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		idx = middlewares[i](idx)
+	}
+	return idx
+}
+
+var (
+	// ErrReadOnly is returned by writes to an index wrapped with ReadOnly.
+	ErrReadOnly = errors.New("index is read-only")
+	// ErrRateLimited is returned by calls rejected by RateLimit.
+	ErrRateLimited = errors.New("rate limit exceeded")
+)
+
+// ReadOnly rejects Add with ErrReadOnly and makes Delete a no-op that
+// returns false, e.g. to serve a replica that must not diverge.
+func ReadOnly[K comparable]() Middleware[K] {
+	return func(idx Index[K]) Index[K] {
+		return readOnly[K]{idx}
+	}
+}
+
+type readOnly[K comparable] struct {
+	Index[K]
+}
+
+func (readOnly[K]) Add(...Node[K]) error { return ErrReadOnly }
+func (readOnly[K]) Delete(K) bool        { return false }
+
+// Instrument calls start before each Add, Search, Delete and Export, with
+// the name of the operation, and the function it returns afterwards, with
+// the operation's error. It is the hook for metrics and tracing, e.g.
+// starting a span in start and ending it in the returned function.
+// Deleting a key that doesn't exist is not an error.
+func Instrument[K comparable](start func(op string) (done func(error))) Middleware[K] {
+	return func(idx Index[K]) Index[K] {
+		return instrumented[K]{idx, start}
+	}
+}
+
+type instrumented[K comparable] struct {
+	Index[K]
+	start func(op string) func(error)
+}
+
+func (i instrumented[K]) Add(nodes ...Node[K]) error {
+	done := i.start("add")
+	err := i.Index.Add(nodes...)
+	done(err)
+	return err
+}
+
+func (i instrumented[K]) Search(near Vector, k int) ([]SearchResultNode[K], error) {
+	done := i.start("search")
+	out, err := i.Index.Search(near, k)
+	done(err)
+	return out, err
+}
+
+func (i instrumented[K]) Delete(key K) bool {
+	done := i.start("delete")
+	ok := i.Index.Delete(key)
+	done(nil)
+	return ok
+}
+
+func (i instrumented[K]) Export(w io.Writer) error {
+	done := i.start("export")
+	err := i.Index.Export(w)
+	done(err)
+	return err
+}
+
+// CacheSearches caches the results of up to size distinct searches, by
+// exact query vector and k. Any Add or Delete clears the cache, so results
+// are never stale. A size <= 0 disables caching.
+func CacheSearches[K comparable](size int) Middleware[K] {
+	return func(idx Index[K]) Index[K] {
+		if size <= 0 {
+			return idx
+		}
+		return &searchCache[K]{Index: idx, size: size}
+	}
+}
+
+type searchCache[K comparable] struct {
+	Index[K]
+	size int
+
+	mu      sync.Mutex
+	results map[string][]SearchResultNode[K]
+	// generation counts the invalidations, so that a search that
+	// overlapped a write doesn't cache what it found.
+	generation uint64
+}
+
+// searchCacheKey encodes a query exactly.
+func searchCacheKey(near Vector, k int) string {
+	b := make([]byte, 8+4*len(near))
+	binary.LittleEndian.PutUint64(b, uint64(k))
+	for i, v := range near {
+		binary.LittleEndian.PutUint32(b[8+4*i:], math.Float32bits(v))
+	}
+	return string(b)
+}
+
+func (c *searchCache[K]) Search(near Vector, k int) ([]SearchResultNode[K], error) {
+	key := searchCacheKey(near, k)
+	c.mu.Lock()
+	out, ok := c.results[key]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok {
+		// Callers may modify the results they get.
+		return slices.Clone(out), nil
+	}
+
+	out, err := c.Index.Search(near, k)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation != generation {
+		// A write finished during the search, which may not have seen it.
+		return out, nil
+	}
+	if c.results == nil || len(c.results) >= c.size {
+		// Start over rather than tracking recency: the cache is cleared
+		// by every write anyway.
+		c.results = make(map[string][]SearchResultNode[K])
+	}
+	c.results[key] = slices.Clone(out)
+	return out, nil
+}
+
+func (c *searchCache[K]) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = nil
+	c.generation++
+}
+
+func (c *searchCache[K]) Add(nodes ...Node[K]) error {
+	defer c.invalidate()
+	return c.Index.Add(nodes...)
+}
+
+func (c *searchCache[K]) Delete(key K) bool {
+	defer c.invalidate()
+	return c.Index.Delete(key)
+}
+
+// RateLimit limits Add and Search calls together to rate per second, with
+// bursts of up to burst calls. Calls over the limit fail immediately with
+// ErrRateLimited.
+func RateLimit[K comparable](rate float64, burst int) Middleware[K] {
+	return func(idx Index[K]) Index[K] {
+		return &rateLimited[K]{Index: idx, rate: rate, burst: float64(burst), tokens: float64(burst), now: time.Now}
+	}
+}
+
+type rateLimited[K comparable] struct {
+	Index[K]
+	rate, burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// allow takes a token from the bucket if there is one.
+func (r *rateLimited[K]) allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	if !r.last.IsZero() {
+		r.tokens = min(r.burst, r.tokens+now.Sub(r.last).Seconds()*r.rate)
+	}
+	r.last = now
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+func (r *rateLimited[K]) Add(nodes ...Node[K]) error {
+	if !r.allow() {
+		return ErrRateLimited
+	}
+	return r.Index.Add(nodes...)
+}
+
+func (r *rateLimited[K]) Search(near Vector, k int) ([]SearchResultNode[K], error) {
+	if !r.allow() {
+		return nil, ErrRateLimited
+	}
+	return r.Index.Search(near, k)
+}
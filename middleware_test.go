@@ -0,0 +1,110 @@
+package hnsw
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	newIndex := func() *Graph[int] {
+		g := newTestGraph[int]()
+		g.Add(MakeNode(1, Vector{1}), MakeNode(2, Vector{2}))
+		return g
+	}
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		g := newIndex()
+		idx := Chain[int](g, ReadOnly[int]())
+		require.ErrorIs(t, idx.Add(MakeNode(3, Vector{3})), ErrReadOnly)
+		require.False(t, idx.Delete(1))
+		require.Equal(t, 2, idx.Len())
+		_, err := idx.Search(Vector{1}, 1)
+		require.NoError(t, err)
+	})
+
+	t.Run("Instrument", func(t *testing.T) {
+		var ops []string
+		idx := Chain[int](newIndex(), Instrument[int](func(op string) func(error) {
+			ops = append(ops, op)
+			return func(err error) {
+				if err != nil {
+					ops = append(ops, "error")
+				}
+			}
+		}), ReadOnly[int]())
+		idx.Search(Vector{1}, 1)
+		idx.Add(MakeNode(3, Vector{3}))
+		idx.Delete(1)
+		require.Equal(t, []string{"search", "add", "error", "delete"}, ops)
+	})
+
+	t.Run("CacheSearches", func(t *testing.T) {
+		g := newIndex()
+		var searches int
+		counting := Instrument[int](func(op string) func(error) {
+			if op == "search" {
+				searches++
+			}
+			return func(error) {}
+		})
+		idx := Chain[int](g, CacheSearches[int](8), counting)
+
+		first, err := idx.Search(Vector{1.9}, 1)
+		require.NoError(t, err)
+		second, err := idx.Search(Vector{1.9}, 1)
+		require.NoError(t, err)
+		require.Equal(t, first, second)
+		require.Equal(t, 1, searches)
+
+		require.NoError(t, idx.Add(MakeNode(3, Vector{1.95})))
+		results, err := idx.Search(Vector{1.9}, 1)
+		require.NoError(t, err)
+		require.Equal(t, 3, results[0].Key)
+		require.Equal(t, 2, searches)
+
+		// A search that overlaps a write isn't cached.
+		var idx2 Index[int]
+		var write bool
+		overlapping := Instrument[int](func(op string) func(error) {
+			if op == "search" {
+				searches++
+				if write {
+					write = false
+					require.NoError(t, idx2.Add(MakeNode(4, Vector{5})))
+				}
+			}
+			return func(error) {}
+		})
+		idx2 = Chain[int](newIndex(), CacheSearches[int](8), overlapping)
+		searches = 0
+		write = true
+		_, err = idx2.Search(Vector{1.9}, 1)
+		require.NoError(t, err)
+		_, err = idx2.Search(Vector{1.9}, 1)
+		require.NoError(t, err)
+		require.Equal(t, 2, searches)
+
+		// A size <= 0 disables the cache.
+		g = newIndex()
+		require.Same(t, Index[int](g), Chain[int](g, CacheSearches[int](0)))
+	})
+
+	t.Run("RateLimit", func(t *testing.T) {
+		now := time.Unix(0, 0)
+		idx := Chain[int](newIndex(), RateLimit[int](1, 2))
+		idx.(*rateLimited[int]).now = func() time.Time { return now }
+
+		_, err := idx.Search(Vector{1}, 1)
+		require.NoError(t, err)
+		_, err = idx.Search(Vector{1}, 1)
+		require.NoError(t, err)
+		_, err = idx.Search(Vector{1}, 1)
+		require.ErrorIs(t, err, ErrRateLimited)
+
+		now = now.Add(time.Second)
+		_, err = idx.Search(Vector{1}, 1)
+		require.NoError(t, err)
+	})
+}
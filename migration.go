@@ -0,0 +1,139 @@
+package hnsw
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoMigration is returned by migration operations when no migration
+// is in progress.
+var ErrNoMigration = errors.New("no migration in progress")
+
+// BeginMigration starts a migration to a new vector space, e.g. the
+// embeddings of a new model. While the migration is in progress every key
+// can hold a second vector, added with AddNext, and searches are routed to
+// either space with SearchOptions.Next.
+//
+// The new space shares the graph's parameters and key set: a key that is
+// already in the graph keeps its level, and Delete removes a key from both
+// spaces. Once every key has been re-embedded, Cutover replaces the old
+// space with the new one.
+func (g *Graph[K]) BeginMigration() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.next != nil {
+		return fmt.Errorf("migration already in progress")
+	}
+	g.next = &Graph[K]{
+		Distance:       g.Distance,
+		Rng:            g.Rng,
+		M:              g.M,
+		M0:             g.M0,
+		Ml:             g.Ml,
+		EfSearch:       g.EfSearch,
+		EfConstruction: g.EfConstruction,
+		HashLevels:     g.HashLevels,
+	}
+	return nil
+}
+
+// AddNext inserts nodes into the space being migrated to.
+// If another node with the same ID exists in that space, it is replaced.
+// Every key must be in the graph; AddNext fails without inserting any
+// node otherwise.
+func (g *Graph[K]) AddNext(nodes ...Node[K]) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.next == nil {
+		return ErrNoMigration
+	}
+	for _, node := range nodes {
+		if g.level(node.Key) < 0 || g.isTombstone(node.Key) {
+			return fmt.Errorf("key %v not found", node.Key)
+		}
+	}
+
+	g.next.mu.Lock()
+	defer g.next.mu.Unlock()
+	for _, node := range nodes {
+		err := g.next.insert(node, g.level(node.Key))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrationProgress returns the number of keys in the graph that have a
+// vector in the space being migrated to.
+func (g *Graph[K]) MigrationProgress() (migrated, total int, err error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.next == nil {
+		return 0, 0, ErrNoMigration
+	}
+	return g.migrated(), g.len(), nil
+}
+
+// migrated returns the number of keys counted by len that have a vector
+// in the new space. The caller must hold the read lock.
+func (g *Graph[K]) migrated() int {
+	if len(g.layers) == 0 || len(g.next.layers) == 0 {
+		return 0
+	}
+	var n int
+	for key := range g.layers[0].nodes {
+		if g.isTombstone(key) {
+			continue
+		}
+		if _, ok := g.next.layers[0].nodes[key]; ok {
+			n++
+		}
+	}
+	return n
+}
+
+// Cutover completes the migration: the new space replaces the old one,
+// whose vectors and edges are released. Cutover fails if any key in the
+// graph has no vector in the new space. Nodes marked with MarkDeleted
+// don't need one; they are removed like Compact does.
+func (g *Graph[K]) Cutover() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.next == nil {
+		return ErrNoMigration
+	}
+	if migrated := g.migrated(); migrated != g.len() {
+		return fmt.Errorf("%d of %d keys have no vector in the new space", g.len()-migrated, g.len())
+	}
+
+	g.layers = g.next.layers
+	g.Distance = g.next.Distance
+	g.next = nil
+	// Every vector comes from the new model now.
+	g.stale = nil
+	g.sketch = embeddingSketch{}
+	if len(g.layers) > 0 {
+		g.sketch = rebuildSketch(g.layers[0])
+	}
+	g.compact()
+	return nil
+}
+
+// AbortMigration discards the space being migrated to.
+func (g *Graph[K]) AbortMigration() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.next = nil
+}
+
+// level returns the highest layer the key is in, or -1 if it isn't in
+// the graph.
+func (g *Graph[K]) level(key K) int {
+	for i := len(g.layers) - 1; i >= 0; i-- {
+		if _, ok := g.layers[i].nodes[key]; ok {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,108 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Migration(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 64; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+
+	_, err := g.SearchWithOptions(Vector{1}, 1, SearchOptions[int]{Next: true})
+	require.ErrorIs(t, err, ErrNoMigration)
+
+	require.NoError(t, g.BeginMigration())
+
+	// The new model reverses the order of the keys and has two
+	// dimensions.
+	for i := 0; i < 64; i++ {
+		require.NoError(t, g.AddNext(MakeNode(i, Vector{float32(-i), 0})))
+		require.Equal(t, g.level(i), g.next.level(i))
+	}
+
+	migrated, total, err := g.MigrationProgress()
+	require.NoError(t, err)
+	require.Equal(t, 64, migrated)
+	require.Equal(t, 64, total)
+
+	results, err := g.Search(Vector{10}, 1)
+	require.NoError(t, err)
+	require.Equal(t, 10, results[0].Key)
+
+	results, err = g.SearchWithOptions(Vector{-20, 0}, 1, SearchOptions[int]{Next: true})
+	require.NoError(t, err)
+	require.Equal(t, 20, results[0].Key)
+
+	// Deleting removes the key from both spaces.
+	g.Delete(20)
+	results, err = g.SearchWithOptions(Vector{-20, 0}, 1, SearchOptions[int]{Next: true})
+	require.NoError(t, err)
+	require.NotEqual(t, 20, results[0].Key)
+
+	// Only keys in the graph can be migrated.
+	require.ErrorContains(t, g.AddNext(MakeNode(1, Vector{-1, 0}), MakeNode(200, Vector{-200, 0})), "200 not found")
+	require.Equal(t, -1, g.next.level(200))
+
+	// Keys without a new vector block the cutover.
+	g.Add(MakeNode(100, Vector{100}))
+	require.ErrorContains(t, g.Cutover(), "1 of 64 keys")
+
+	// Marked nodes don't need a new vector, and don't count.
+	g.Add(MakeNode(101, Vector{101}))
+	g.MarkDeleted(101, 5)
+	require.ErrorContains(t, g.AddNext(MakeNode(101, Vector{-101, 0})), "101 not found")
+	migrated, total, err = g.MigrationProgress()
+	require.NoError(t, err)
+	require.Equal(t, 62, migrated)
+	require.Equal(t, 63, total)
+
+	require.NoError(t, g.AddNext(MakeNode(100, Vector{-100, 0})))
+	require.NoError(t, g.Cutover())
+	require.Equal(t, 2, g.Dims())
+	require.Equal(t, 63, g.Len())
+	require.Zero(t, g.Tombstones())
+	require.NoError(t, g.Verify())
+
+	// The embedding statistics describe the new space.
+	stats := g.Stats().Embeddings
+	require.Equal(t, 63, stats.Count)
+	require.Len(t, stats.Mean, 2)
+
+	results, err = g.Search(Vector{-100, 0}, 1)
+	require.NoError(t, err)
+	require.Equal(t, 100, results[0].Key)
+
+	_, _, err = g.MigrationProgress()
+	require.ErrorIs(t, err, ErrNoMigration)
+}
+
+func TestGraph_MigrationTombstones(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 64; i++ {
+		require.NoError(t, g.Add(MakeNode(i, Vector{float32(i)})))
+	}
+	require.NoError(t, g.BeginMigration())
+	for i := 0; i < 64; i++ {
+		require.NoError(t, g.AddNext(MakeNode(i, Vector{float32(-i), 0})))
+	}
+
+	// Nodes marked deleted are hidden from migrated searches too.
+	require.Equal(t, 1, g.MarkDeleted(20))
+	results, err := g.SearchWithOptions(Vector{-20, 0}, 3, SearchOptions[int]{Next: true})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	for _, r := range results {
+		require.NotEqual(t, 20, r.Key)
+	}
+
+	results, err = g.SearchWithOptions(Vector{-20, 0}, 1, SearchOptions[int]{
+		Next:   true,
+		Filter: func(key int) bool { return key != 21 },
+	})
+	require.NoError(t, err)
+	require.Equal(t, 19, results[0].Key)
+}
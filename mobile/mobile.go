@@ -0,0 +1,106 @@
+// Package mobile wraps hnsw for gomobile bindings, so that iOS and Android
+// apps can embed an on-device index.
+//
+// gomobile can't bind generics or slices other than []byte, so the index
+// has string keys, vectors are passed as bytes, and search results are
+// read through accessors. A vector is encoded as consecutive
+// little-endian IEEE 754 float32 values, e.g. with a ByteBuffer in
+// LITTLE_ENDIAN order on Android or the raw bytes of a [Float] on iOS.
+//
+// Build the bindings with e.g.:
+//
+//	gomobile bind -target=android github.com/hypermodeinc/hnsw/mobile
+package mobile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/hypermodeinc/hnsw"
+)
+
+// Index is a graph of string keys persisted to a file.
+type Index struct {
+	g *hnsw.SavedGraph[string]
+}
+
+// Open loads the index stored at path, or creates an empty one if the
+// file doesn't exist. Changes are written to the file by Save.
+func Open(path string) (*Index, error) {
+	g, err := hnsw.Open[string](path)
+	if err != nil {
+		return nil, err
+	}
+	return &Index{g: g}, nil
+}
+
+// decodeVector decodes a vector encoded as little-endian float32 values.
+func decodeVector(b []byte) ([]float32, error) {
+	if len(b)%4 != 0 {
+		return nil, fmt.Errorf("vector of %d bytes is not a sequence of float32", len(b))
+	}
+	vec := make([]float32, len(b)/4)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return vec, nil
+}
+
+// Add inserts a vector under key, replacing any previous one.
+func (i *Index) Add(key string, vector []byte) error {
+	vec, err := decodeVector(vector)
+	if err != nil {
+		return err
+	}
+	return i.g.Add(hnsw.MakeNode(key, vec))
+}
+
+// Delete removes the vector stored under key and reports whether it
+// existed.
+func (i *Index) Delete(key string) bool {
+	return i.g.Delete(key)
+}
+
+// Len returns the number of vectors in the index.
+func (i *Index) Len() int {
+	return i.g.Len()
+}
+
+// Search finds the k nearest neighbors of vector.
+func (i *Index) Search(vector []byte, k int) (*Results, error) {
+	vec, err := decodeVector(vector)
+	if err != nil {
+		return nil, err
+	}
+	results, err := i.g.Search(vec, k)
+	if err != nil {
+		return nil, err
+	}
+	return &Results{results: results}, nil
+}
+
+// Save writes the index to its file.
+func (i *Index) Save() error {
+	return i.g.Save()
+}
+
+// Results are the results of a search, closest first.
+type Results struct {
+	results []hnsw.SearchResultNode[string]
+}
+
+// Len returns the number of results.
+func (r *Results) Len() int {
+	return len(r.results)
+}
+
+// Key returns the key of the i-th result.
+func (r *Results) Key(i int) string {
+	return r.results[i].Key
+}
+
+// Distance returns the distance of the i-th result to the query.
+func (r *Results) Distance(i int) float32 {
+	return r.results[i].Distance
+}
@@ -0,0 +1,44 @@
+package mobile
+
+import (
+	"encoding/binary"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func encodeVector(vec ...float32) []byte {
+	b := make([]byte, 4*len(vec))
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(v))
+	}
+	return b
+}
+
+func TestIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index")
+	idx, err := Open(path)
+	require.NoError(t, err)
+	require.NoError(t, idx.Add("x", encodeVector(1, 0)))
+	require.NoError(t, idx.Add("y", encodeVector(0, 1)))
+	require.NoError(t, idx.Add("z", encodeVector(-1, 0)))
+	require.Error(t, idx.Add("bad", []byte{1, 2, 3}))
+	require.NoError(t, idx.Save())
+
+	idx, err = Open(path)
+	require.NoError(t, err)
+	require.Equal(t, 3, idx.Len())
+
+	results, err := idx.Search(encodeVector(1, 0.1), 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, results.Len())
+	require.Equal(t, "x", results.Key(0))
+	require.Equal(t, "y", results.Key(1))
+	require.Less(t, results.Distance(0), results.Distance(1))
+
+	require.True(t, idx.Delete("x"))
+	require.False(t, idx.Delete("x"))
+	require.Equal(t, 2, idx.Len())
+}
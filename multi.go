@@ -0,0 +1,88 @@
+package hnsw
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+type aggregationKind int
+
+const (
+	aggregateMax aggregationKind = iota
+	aggregateMean
+	aggregateWeightedSum
+)
+
+// Aggregation combines the distances of a node to several query vectors
+// into one score.
+type Aggregation struct {
+	kind    aggregationKind
+	weights []float32
+}
+
+// AggregateMax scores a node by its distance to the closest query, i.e.
+// its maximum similarity: a node ranks high if it matches any query.
+func AggregateMax() Aggregation {
+	return Aggregation{kind: aggregateMax}
+}
+
+// AggregateMean scores a node by its mean distance to the queries: a node
+// ranks high if it matches all queries reasonably well.
+func AggregateMean() Aggregation {
+	return Aggregation{kind: aggregateMean}
+}
+
+// AggregateWeightedSum scores a node by the weighted sum of its distances
+// to the queries, with one weight per query.
+func AggregateWeightedSum(weights ...float32) Aggregation {
+	return Aggregation{kind: aggregateWeightedSum, weights: weights}
+}
+
+// SearchMulti finds the k best nodes for several query vectors at once,
+// e.g. for query expansion or multi-turn context. The distances to the
+// queries are combined with agg during a single traversal, so every node
+// is visited at most once.
+func (h *Graph[K]) SearchMulti(queries []Vector, k int, agg Aggregation) ([]SearchResultNode[K], error) {
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("no queries")
+	}
+	if agg.kind == aggregateWeightedSum && len(agg.weights) != len(queries) {
+		return nil, fmt.Errorf("got %d weights for %d queries", len(agg.weights), len(queries))
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	scores := make([]scoreFunc[K], len(queries))
+	for i, query := range queries {
+		h.assertDims(query)
+		scores[i] = distanceTo[K](query, h.Distance)
+	}
+
+	score := func(node *layerNode[K]) (float32, error) {
+		var total float32
+		if agg.kind == aggregateMax {
+			total = float32(math.Inf(1))
+		}
+		for i, s := range scores {
+			d, err := s(node)
+			if err != nil {
+				return 0, err
+			}
+			switch agg.kind {
+			case aggregateMax:
+				total = min(total, d)
+			case aggregateMean:
+				total += d / float32(len(scores))
+			case aggregateWeightedSum:
+				total += agg.weights[i] * d
+			}
+		}
+		return total, nil
+	}
+
+	out, err := h.searchScore(context.Background(), score, k, SearchOptions[K]{})
+	h.recordHits(out)
+	return out, err
+}
@@ -0,0 +1,38 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_SearchMulti(t *testing.T) {
+	g := newTestGraph[int]()
+	g.M = 16
+	for i := 0; i < 16; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+	queries := []Vector{{2}, {10}}
+
+	results, err := g.SearchMulti(queries, 2, AggregateMax())
+	require.NoError(t, err)
+	require.Equal(t, map[int]bool{2: true, 10: true}, resultKeys(results))
+	require.Zero(t, results[0].Distance)
+
+	// Every node between the queries has the same mean distance.
+	results, err = g.SearchMulti(queries, 1, AggregateMean())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, results[0].Key, 2)
+	require.LessOrEqual(t, results[0].Key, 10)
+	require.Equal(t, float32(4), results[0].Distance)
+
+	results, err = g.SearchMulti(queries, 1, AggregateWeightedSum(3, 1))
+	require.NoError(t, err)
+	require.Equal(t, 2, results[0].Key)
+	require.Equal(t, float32(8), results[0].Distance)
+
+	_, err = g.SearchMulti(queries, 1, AggregateWeightedSum(1))
+	require.Error(t, err)
+	_, err = g.SearchMulti(nil, 1, AggregateMax())
+	require.Error(t, err)
+}
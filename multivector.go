@@ -0,0 +1,192 @@
+package hnsw
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// VectorKey identifies one of the vectors of a key in a
+// MultiVectorGraph.
+type VectorKey[K comparable] struct {
+	Key K
+	// Index is the position of the vector among the vectors of Key.
+	Index int
+}
+
+// Compare orders vector keys by key, then by index.
+func (k VectorKey[K]) Compare(other VectorKey[K]) int {
+	if c := CompareKeys(k.Key, other.Key); c != 0 {
+		return c
+	}
+	return cmp.Compare(k.Index, other.Index)
+}
+
+// MultiVectorResult is a key found by MultiVectorGraph.Search.
+type MultiVectorResult[K comparable] struct {
+	Key K
+
+	// Distance is the aggregated distance of the vectors of Key.
+	Distance float32
+
+	// Best is the index of the vector of Key closest to the query, e.g.
+	// the chunk of a document to quote.
+	Best int
+}
+
+// MultiVectorGraph lets a key own several vectors, e.g. the embeddings of
+// the chunks of a document, and ranks keys rather than vectors. The
+// vectors are stored in Graph under VectorKeys, so it can be saved and
+// tuned like any graph; change it only through the MultiVectorGraph.
+// Create it with NewMultiVectorGraph.
+type MultiVectorGraph[K comparable] struct {
+	Graph *Graph[VectorKey[K]]
+
+	mu sync.RWMutex
+	// counts is the number of vectors of each key.
+	counts map[K]int
+}
+
+// NewMultiVectorGraph returns a MultiVectorGraph storing its vectors in
+// g, which may hold the vectors of an earlier one, e.g. after Import.
+func NewMultiVectorGraph[K comparable](g *Graph[VectorKey[K]]) *MultiVectorGraph[K] {
+	m := &MultiVectorGraph[K]{Graph: g, counts: make(map[K]int)}
+	for key := range g.Keys() {
+		m.counts[key.Key] = max(m.counts[key.Key], key.Index+1)
+	}
+	return m
+}
+
+// Add sets the vectors of key, replacing any it had.
+func (m *MultiVectorGraph[K]) Add(key K, vecs ...Vector) error {
+	if len(vecs) == 0 {
+		return fmt.Errorf("no vectors for %v", key)
+	}
+	nodes := make([]Node[VectorKey[K]], len(vecs))
+	for i, vec := range vecs {
+		nodes[i] = MakeNode(VectorKey[K]{Key: key, Index: i}, vec)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.Graph.Add(nodes...); err != nil {
+		// Some of the vectors may have been added.
+		m.counts[key] = max(m.counts[key], len(vecs))
+		return err
+	}
+	for i := len(vecs); i < m.counts[key]; i++ {
+		m.Graph.Delete(VectorKey[K]{Key: key, Index: i})
+	}
+	m.counts[key] = len(vecs)
+	return nil
+}
+
+// Delete removes key and its vectors, and reports whether it existed.
+func (m *MultiVectorGraph[K]) Delete(key K) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.counts[key]
+	for i := range n {
+		m.Graph.Delete(VectorKey[K]{Key: key, Index: i})
+	}
+	delete(m.counts, key)
+	return ok
+}
+
+// Lookup returns the vectors of key.
+func (m *MultiVectorGraph[K]) Lookup(key K) ([]Vector, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.lookup(key)
+}
+
+func (m *MultiVectorGraph[K]) lookup(key K) ([]Vector, bool) {
+	n, ok := m.counts[key]
+	if !ok {
+		return nil, false
+	}
+	vecs := make([]Vector, 0, n)
+	for i := range n {
+		if vec, ok := m.Graph.Lookup(VectorKey[K]{Key: key, Index: i}); ok {
+			vecs = append(vecs, vec)
+		}
+	}
+	return vecs, true
+}
+
+// Len returns the number of keys.
+func (m *MultiVectorGraph[K]) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.counts)
+}
+
+// Search finds the k keys whose vectors best match near, aggregated with
+// agg: AggregateMax ranks a key by its closest vector (max-sim),
+// AggregateMean by the mean distance of all its vectors (mean-sim).
+//
+// The candidates are the keys of the vectors nearest to near; vectors
+// are searched until k distinct keys are found. With AggregateMean, a key
+// none of whose vectors is near the query may be missed even if its
+// mean is low.
+func (m *MultiVectorGraph[K]) Search(near Vector, k int, agg Aggregation) ([]MultiVectorResult[K], error) {
+	if agg.kind != aggregateMax && agg.kind != aggregateMean {
+		return nil, fmt.Errorf("unsupported aggregation; use AggregateMax or AggregateMean")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var (
+		results []MultiVectorResult[K]
+		seen    = make(map[K]bool)
+	)
+	// Keys have several vectors each, so search for more vectors than
+	// keys, and more again if they fall on too few keys.
+	for n := 4 * k; ; n *= 4 {
+		page, err := m.Graph.SearchWithOptions(near, n, SearchOptions[VectorKey[K]]{
+			EfSearch: max(n, m.Graph.EfSearch),
+		})
+		if err != nil {
+			return nil, err
+		}
+		results = results[:0]
+		clear(seen)
+		for _, r := range page {
+			if seen[r.Key.Key] {
+				continue
+			}
+			seen[r.Key.Key] = true
+			results = append(results, MultiVectorResult[K]{
+				Key:      r.Key.Key,
+				Distance: r.Distance,
+				Best:     r.Key.Index,
+			})
+		}
+		if len(results) >= k || len(page) < n {
+			break
+		}
+	}
+
+	if agg.kind == aggregateMean {
+		for i, r := range results {
+			vecs, _ := m.lookup(r.Key)
+			var sum float32
+			for _, vec := range vecs {
+				d, err := m.Graph.Distance(vec, near)
+				if err != nil {
+					return nil, err
+				}
+				sum += d
+			}
+			results[i].Distance = sum / float32(len(vecs))
+		}
+		slices.SortStableFunc(results, func(a, b MultiVectorResult[K]) int {
+			return cmp.Compare(a.Distance, b.Distance)
+		})
+	}
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
@@ -0,0 +1,56 @@
+package hnsw
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiVectorGraph(t *testing.T) {
+	m := NewMultiVectorGraph(newTestGraph[VectorKey[string]]())
+	// a has one chunk on the query and two far from it, b three chunks
+	// close to it.
+	require.NoError(t, m.Add("a", Vector{0, 0}, Vector{10, 10}, Vector{10, -10}))
+	require.NoError(t, m.Add("b", Vector{1, 0}, Vector{0, 2}, Vector{-2, 0}))
+	for i := range 20 {
+		require.NoError(t, m.Add(fmt.Sprint("filler", i), Vector{50 + float32(i), 50}, Vector{50, 50 + float32(i)}))
+	}
+	require.Equal(t, 22, m.Len())
+	require.Equal(t, 46, m.Graph.Len())
+
+	results, err := m.Search(Vector{0, 0}, 2, AggregateMax())
+	require.NoError(t, err)
+	require.Equal(t, []MultiVectorResult[string]{
+		{Key: "a", Distance: 0, Best: 0},
+		{Key: "b", Distance: 1, Best: 0},
+	}, results)
+
+	results, err = m.Search(Vector{0, 0}, 2, AggregateMean())
+	require.NoError(t, err)
+	require.Equal(t, "b", results[0].Key)
+	require.InDelta(t, 5.0/3, results[0].Distance, 1e-6)
+	require.Equal(t, "a", results[1].Key)
+
+	_, err = m.Search(Vector{0, 0}, 2, AggregateWeightedSum(1))
+	require.Error(t, err)
+
+	// Replacing a key drops its extra vectors.
+	require.NoError(t, m.Add("a", Vector{20, 20}))
+	vecs, ok := m.Lookup("a")
+	require.True(t, ok)
+	require.Equal(t, []Vector{{20, 20}}, vecs)
+	require.Equal(t, 44, m.Graph.Len())
+	results, err = m.Search(Vector{0, 0}, 1, AggregateMax())
+	require.NoError(t, err)
+	require.Equal(t, "b", results[0].Key)
+
+	// The keys are recovered from the graph.
+	require.Equal(t, m.counts, NewMultiVectorGraph(m.Graph).counts)
+
+	require.True(t, m.Delete("b"))
+	require.False(t, m.Delete("b"))
+	require.Equal(t, 41, m.Graph.Len())
+	_, ok = m.Lookup("b")
+	require.False(t, ok)
+}
@@ -0,0 +1,373 @@
+package hnsw
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// NPYArray is an array read from a NumPy .npy file, converted to the
+// closest Go type: floating-point arrays to float32, integer arrays to
+// int64 and string arrays to string. Exactly one of Floats, Ints and
+// Strings is set, holding the elements in row-major order.
+type NPYArray struct {
+	Shape []int
+
+	Floats  []float32
+	Ints    []int64
+	Strings []string
+}
+
+// Vectors returns the rows of a 2-D floating-point array, e.g. the
+// embeddings saved with numpy.save, to pass to MakeNodes. The vectors
+// share the array's memory.
+func (a *NPYArray) Vectors() ([]Vector, error) {
+	if len(a.Shape) != 2 || a.Floats == nil {
+		return nil, fmt.Errorf("want a 2-D floating-point array, have shape %v", a.Shape)
+	}
+	rows, dims := a.Shape[0], a.Shape[1]
+	vecs := make([]Vector, rows)
+	for i := range vecs {
+		vecs[i] = a.Floats[i*dims : (i+1)*dims : (i+1)*dims]
+	}
+	return vecs, nil
+}
+
+// npyHeader describes the array of a .npy file.
+type npyHeader struct {
+	order   binary.ByteOrder
+	kind    byte // f, i, u, U or S.
+	size    int  // Bytes per element.
+	fortran bool
+	shape   []int
+}
+
+func (h npyHeader) count() int {
+	n := 1
+	for _, d := range h.shape {
+		n *= d
+	}
+	return n
+}
+
+var (
+	npyMagic = []byte("\x93NUMPY")
+
+	npyDescr   = regexp.MustCompile(`'descr'\s*:\s*'([<>|=])([a-zA-Z])(\d+)'`)
+	npyFortran = regexp.MustCompile(`'fortran_order'\s*:\s*(True|False)`)
+	npyShape   = regexp.MustCompile(`'shape'\s*:\s*\(([\d,\s]*)\)`)
+)
+
+// readNPYHeader reads the header of a .npy file, leaving r at the data.
+func readNPYHeader(r io.Reader) (npyHeader, error) {
+	var h npyHeader
+	var prefix [8]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return h, fmt.Errorf("reading .npy header: %w", err)
+	}
+	if !bytes.Equal(prefix[:6], npyMagic) {
+		return h, fmt.Errorf("not a .npy file")
+	}
+	var size int
+	switch prefix[6] {
+	case 1:
+		var n uint16
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return h, fmt.Errorf("reading .npy header: %w", err)
+		}
+		size = int(n)
+	case 2, 3:
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return h, fmt.Errorf("reading .npy header: %w", err)
+		}
+		size = int(n)
+	default:
+		return h, fmt.Errorf("unsupported .npy version %d.%d", prefix[6], prefix[7])
+	}
+	if size > 1<<20 {
+		return h, fmt.Errorf("invalid .npy header size %d", size)
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return h, fmt.Errorf("reading .npy header: %w", err)
+	}
+	header := string(buf)
+
+	descr := npyDescr.FindStringSubmatch(header)
+	fortran := npyFortran.FindStringSubmatch(header)
+	shape := npyShape.FindStringSubmatch(header)
+	if descr == nil || fortran == nil || shape == nil {
+		return h, fmt.Errorf("unsupported .npy header %q", strings.TrimSpace(header))
+	}
+	h.order = binary.ByteOrder(binary.LittleEndian)
+	if descr[1] == ">" {
+		h.order = binary.BigEndian
+	}
+	h.kind = descr[2][0]
+	h.size, _ = strconv.Atoi(descr[3])
+	switch {
+	case h.kind == 'f' && (h.size == 2 || h.size == 4 || h.size == 8):
+	case (h.kind == 'i' || h.kind == 'u') && (h.size == 1 || h.size == 2 || h.size == 4 || h.size == 8):
+	case h.kind == 'U' && h.size > 0:
+		// The size counts UTF-32 code points.
+		h.size *= 4
+	case h.kind == 'S' && h.size > 0:
+	default:
+		return h, fmt.Errorf("unsupported .npy dtype %q", descr[0])
+	}
+	h.fortran = fortran[1] == "True"
+	for _, d := range strings.Split(shape[1], ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 0 {
+			return h, fmt.Errorf("invalid .npy shape (%s)", shape[1])
+		}
+		h.shape = append(h.shape, n)
+	}
+	return h, nil
+}
+
+// ReadNPY reads an array from a .npy file written by numpy.save.
+// Floating-point (float16, float32 and float64), integer and string
+// arrays are supported; arrays of Python objects are not.
+func ReadNPY(r io.Reader) (*NPYArray, error) {
+	br := bufio.NewReader(r)
+	h, err := readNPYHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	a := &NPYArray{Shape: h.shape}
+	n := h.count()
+	buf := make([]byte, h.size)
+	switch h.kind {
+	case 'f':
+		a.Floats = make([]float32, 0, min(n, 1<<24))
+	case 'i', 'u':
+		a.Ints = make([]int64, 0, min(n, 1<<24))
+	default:
+		a.Strings = make([]string, 0, min(n, 1<<24))
+	}
+	for i := 0; i < n; i++ {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, fmt.Errorf("reading element %d of %d: %w", i, n, err)
+		}
+		switch h.kind {
+		case 'f':
+			a.Floats = append(a.Floats, npyFloat(h, buf))
+		case 'i', 'u':
+			v, err := npyInt(h, buf)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			a.Ints = append(a.Ints, v)
+		default:
+			a.Strings = append(a.Strings, npyString(h, buf))
+		}
+	}
+	if h.fortran && len(h.shape) > 1 {
+		a.toRowMajor()
+	}
+	return a, nil
+}
+
+// toRowMajor reorders the elements of an array read in column-major
+// (Fortran) order.
+func (a *NPYArray) toRowMajor() {
+	coords := make([]int, len(a.Shape))
+	reorder := func(n int, move func(dst, src int)) {
+		for i := 0; i < n; i++ {
+			// Decompose i in row-major order, recompose in column-major.
+			rest := i
+			for d := len(a.Shape) - 1; d >= 0; d-- {
+				coords[d] = rest % a.Shape[d]
+				rest /= a.Shape[d]
+			}
+			src, stride := 0, 1
+			for d := range a.Shape {
+				src += coords[d] * stride
+				stride *= a.Shape[d]
+			}
+			move(i, src)
+		}
+	}
+	switch {
+	case a.Floats != nil:
+		src := a.Floats
+		a.Floats = make([]float32, len(src))
+		reorder(len(src), func(dst, s int) { a.Floats[dst] = src[s] })
+	case a.Ints != nil:
+		src := a.Ints
+		a.Ints = make([]int64, len(src))
+		reorder(len(src), func(dst, s int) { a.Ints[dst] = src[s] })
+	default:
+		src := a.Strings
+		a.Strings = make([]string, len(src))
+		reorder(len(src), func(dst, s int) { a.Strings[dst] = src[s] })
+	}
+}
+
+// NPYVectors streams the rows of a 2-D floating-point array from a .npy
+// file, so that files larger than memory can be inserted in batches with
+// Add or AddBatch. Each row is a new Vector. Arrays in Fortran order
+// can't be streamed; read them with ReadNPY.
+func NPYVectors(r io.Reader) iter.Seq2[Vector, error] {
+	return func(yield func(Vector, error) bool) {
+		br := bufio.NewReader(r)
+		h, err := readNPYHeader(br)
+		if err == nil && (h.kind != 'f' || len(h.shape) != 2) {
+			err = fmt.Errorf("want a 2-D floating-point array, have dtype %c%d and shape %v", h.kind, h.size, h.shape)
+		}
+		if err == nil && h.fortran {
+			err = fmt.Errorf("can't stream an array in Fortran order")
+		}
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		rows, dims := h.shape[0], h.shape[1]
+		buf := make([]byte, h.size*dims)
+		for i := 0; i < rows; i++ {
+			if _, err := io.ReadFull(br, buf); err != nil {
+				yield(nil, fmt.Errorf("reading row %d of %d: %w", i, rows, err))
+				return
+			}
+			vec := make(Vector, dims)
+			for j := range vec {
+				vec[j] = npyFloat(h, buf[j*h.size:(j+1)*h.size])
+			}
+			if !yield(vec, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ReadNPZ reads the arrays of a .npz archive written by numpy.savez or
+// numpy.savez_compressed, by name without the .npy extension, e.g. the
+// keys and the vectors of
+//
+//	numpy.savez("embeddings.npz", ids=ids, vectors=vectors)
+func ReadNPZ(path string) (map[string]*NPYArray, error) {
+	z, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+
+	arrays := make(map[string]*NPYArray, len(z.File))
+	for _, f := range z.File {
+		name, ok := strings.CutSuffix(f.Name, ".npy")
+		if !ok {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		a, err := ReadNPY(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		arrays[name] = a
+	}
+	return arrays, nil
+}
+
+func npyFloat(h npyHeader, b []byte) float32 {
+	switch h.size {
+	case 2:
+		return float16ToFloat32(h.order.Uint16(b))
+	case 4:
+		return math.Float32frombits(h.order.Uint32(b))
+	default:
+		return float32(math.Float64frombits(h.order.Uint64(b)))
+	}
+}
+
+func npyInt(h npyHeader, b []byte) (int64, error) {
+	if h.kind == 'i' {
+		switch h.size {
+		case 1:
+			return int64(int8(b[0])), nil
+		case 2:
+			return int64(int16(h.order.Uint16(b))), nil
+		case 4:
+			return int64(int32(h.order.Uint32(b))), nil
+		default:
+			return int64(h.order.Uint64(b)), nil
+		}
+	}
+	switch h.size {
+	case 1:
+		return int64(b[0]), nil
+	case 2:
+		return int64(h.order.Uint16(b)), nil
+	case 4:
+		return int64(h.order.Uint32(b)), nil
+	default:
+		v := h.order.Uint64(b)
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("%d overflows int64", v)
+		}
+		return int64(v), nil
+	}
+}
+
+// npyString decodes a fixed-size string, padded with NULs: UTF-32 for
+// dtype U, bytes for dtype S.
+func npyString(h npyHeader, b []byte) string {
+	if h.kind == 'S' {
+		return string(bytes.TrimRight(b, "\x00"))
+	}
+	var sb strings.Builder
+	for i := 0; i+4 <= len(b); i += 4 {
+		r := rune(h.order.Uint32(b[i:]))
+		if r == 0 {
+			break
+		}
+		if !utf8.ValidRune(r) {
+			r = utf8.RuneError
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// float16ToFloat32 converts an IEEE 754 half-precision float.
+func float16ToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch {
+	case exp == 0x1f:
+		// Infinity or NaN.
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	case exp == 0 && frac == 0:
+		return math.Float32frombits(sign)
+	case exp == 0:
+		// Subnormal: normalize the fraction.
+		exp = 127 - 15 + 1
+		for frac&0x400 == 0 {
+			frac <<= 1
+			exp--
+		}
+		frac &= 0x3ff
+		return math.Float32frombits(sign | exp<<23 | frac<<13)
+	default:
+		return math.Float32frombits(sign | (exp+127-15)<<23 | frac<<13)
+	}
+}
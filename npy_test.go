@@ -0,0 +1,127 @@
+package hnsw
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// npyFile returns a version 1.0 .npy file with the given header fields
+// and raw data, as numpy.save writes it.
+func npyFile(descr string, fortran bool, shape string, data []byte) []byte {
+	order := "False"
+	if fortran {
+		order = "True"
+	}
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': %s, 'shape': %s, }", descr, order, shape)
+	// The header is padded with spaces to align the data on 64 bytes.
+	for (10+len(header)+1)%64 != 0 {
+		header += " "
+	}
+	header += "\n"
+	var buf bytes.Buffer
+	buf.Write(npyMagic)
+	buf.Write([]byte{1, 0})
+	binary.Write(&buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func npyData(order binary.ByteOrder, values ...any) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		binary.Write(&buf, order, v)
+	}
+	return buf.Bytes()
+}
+
+func TestReadNPY(t *testing.T) {
+	data := npyFile("<f4", false, "(2, 3)", npyData(binary.LittleEndian, []float32{1, 2, 3, 4, 5, 6}))
+	a, err := ReadNPY(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, []int{2, 3}, a.Shape)
+	vecs, err := a.Vectors()
+	require.NoError(t, err)
+	require.Equal(t, []Vector{{1, 2, 3}, {4, 5, 6}}, vecs)
+
+	var streamed []Vector
+	for vec, err := range NPYVectors(bytes.NewReader(data)) {
+		require.NoError(t, err)
+		streamed = append(streamed, vec)
+	}
+	require.Equal(t, vecs, streamed)
+
+	// Column-major float64, big-endian.
+	data = npyFile(">f8", true, "(2, 3)", npyData(binary.BigEndian, []float64{1, 4, 2, 5, 3, 6}))
+	a, err = ReadNPY(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, []float32{1, 2, 3, 4, 5, 6}, a.Floats)
+	for _, err := range NPYVectors(bytes.NewReader(data)) {
+		require.ErrorContains(t, err, "Fortran")
+	}
+
+	// Half precision: 1, -2, 0.5, the smallest subnormal and infinity.
+	data = npyFile("<f2", false, "(5,)", npyData(binary.LittleEndian, []uint16{0x3c00, 0xc000, 0x3800, 0x0001, 0x7c00}))
+	a, err = ReadNPY(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, []float32{1, -2, 0.5, float32(math.Ldexp(1, -24)), float32(math.Inf(1))}, a.Floats)
+	_, err = a.Vectors()
+	require.Error(t, err)
+
+	data = npyFile("<i8", false, "(3,)", npyData(binary.LittleEndian, []int64{7, -1, 1 << 40}))
+	a, err = ReadNPY(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, []int64{7, -1, 1 << 40}, a.Ints)
+
+	// Fixed-size UTF-32 strings, padded with NULs.
+	data = npyFile("<U3", false, "(2,)", npyData(binary.LittleEndian, []uint32{'a', 'b', 0, 'é', 'x', 'y'}))
+	a, err = ReadNPY(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, []string{"ab", "éxy"}, a.Strings)
+
+	_, err = ReadNPY(bytes.NewReader(npyFile("|O", false, "(1,)", nil)))
+	require.ErrorContains(t, err, "unsupported")
+	_, err = ReadNPY(bytes.NewReader(npyFile("<f4", false, "(2, 2)", make([]byte, 12))))
+	require.ErrorContains(t, err, "element 3 of 4")
+	_, err = ReadNPY(bytes.NewReader([]byte("not numpy")))
+	require.ErrorContains(t, err, "not a .npy file")
+}
+
+func TestReadNPZ(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "embeddings.npz")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	z := zip.NewWriter(f)
+	for name, data := range map[string][]byte{
+		"ids.npy":     npyFile("|S2", false, "(2,)", []byte("a\x00bb")),
+		"vectors.npy": npyFile("<f4", false, "(2, 2)", npyData(binary.LittleEndian, []float32{1, 0, 0, 1})),
+	} {
+		w, err := z.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, z.Close())
+	require.NoError(t, f.Close())
+
+	arrays, err := ReadNPZ(path)
+	require.NoError(t, err)
+	vecs, err := arrays["vectors"].Vectors()
+	require.NoError(t, err)
+	nodes, err := MakeNodes(arrays["ids"].Strings, vecs)
+	require.NoError(t, err)
+
+	g := newTestGraph[string]()
+	require.NoError(t, g.Add(nodes...))
+	vec, ok := g.Lookup("bb")
+	require.True(t, ok)
+	require.Equal(t, Vector{0, 1}, vec)
+}
@@ -0,0 +1,48 @@
+package hnsw
+
+import "fmt"
+
+// SetPayload attaches a payload to the node with the given key, replacing
+// any previous one; a nil payload removes it. Payloads are returned in
+// SearchResultNode, survive Export and Import, and are dropped when the
+// node is deleted. Adding a node with the same key again keeps its
+// payload.
+//
+// Payloads are bytes so that they can be serialized; encode structured
+// metadata with e.g. encoding/json.
+func (g *Graph[K]) SetPayload(key K, payload []byte) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.layers) == 0 || g.layers[0].nodes[key] == nil {
+		return fmt.Errorf("key %v not found", key)
+	}
+	if payload == nil {
+		delete(g.payloads, key)
+		return nil
+	}
+	if g.payloads == nil {
+		g.payloads = make(map[K][]byte)
+	}
+	g.payloads[key] = payload
+	return nil
+}
+
+// Payload returns the payload attached to the node with the given key.
+func (g *Graph[K]) Payload(key K) ([]byte, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	payload, ok := g.payloads[key]
+	return payload, ok
+}
+
+// attachPayloads sets the payloads of results. The caller must hold the
+// read lock.
+func (g *Graph[K]) attachPayloads(results []SearchResultNode[K]) {
+	if len(g.payloads) == 0 {
+		return
+	}
+	for i := range results {
+		results[i].Payload = g.payloads[results[i].Key]
+	}
+}
@@ -0,0 +1,39 @@
+package hnsw
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Payload(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 8; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+	require.NoError(t, g.SetPayload(3, []byte("three")))
+	require.Error(t, g.SetPayload(99, []byte("x")))
+
+	results, err := g.Search(Vector{3}, 1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("three"), results[0].Payload)
+
+	// Replacing the vector keeps the payload.
+	g.Add(MakeNode(3, Vector{3.5}))
+	payload, ok := g.Payload(3)
+	require.True(t, ok)
+	require.Equal(t, []byte("three"), payload)
+
+	var buf bytes.Buffer
+	require.NoError(t, g.Export(&buf))
+	g2 := &Graph[int]{}
+	require.NoError(t, g2.Import(&buf))
+	payload, ok = g2.Payload(3)
+	require.True(t, ok)
+	require.Equal(t, []byte("three"), payload)
+
+	g.Delete(3)
+	_, ok = g.Payload(3)
+	require.False(t, ok)
+}
@@ -0,0 +1,201 @@
+package hnsw
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// Strategy is how a search finds its results. See
+// SearchOptions.Strategy.
+type Strategy int
+
+const (
+	// StrategyAuto lets the planner choose the strategy of each search
+	// from the size of the graph, the estimated selectivity of its
+	// filters and k.
+	StrategyAuto Strategy = iota
+
+	// StrategyBruteForce ranks the candidate nodes exhaustively: the
+	// nodes of an AllowSet, or else every node. It is exact, and the
+	// fastest on tiny graphs and tiny filtered subsets.
+	StrategyBruteForce
+
+	// StrategyFiltered traverses the graph looking two hops ahead past
+	// the nodes excluded by Allow and Filter, as in ACORN, so it reaches
+	// the allowed nodes sooner when they are rare.
+	StrategyFiltered
+
+	// StrategyPostFilter traverses the graph as if it were unfiltered,
+	// dropping the excluded nodes from the results only. It is the
+	// strategy of unfiltered searches, and the best when most nodes pass
+	// the filters.
+	StrategyPostFilter
+)
+
+func (s Strategy) String() string {
+	switch s {
+	case StrategyAuto:
+		return "auto"
+	case StrategyBruteForce:
+		return "brute-force"
+	case StrategyFiltered:
+		return "filtered"
+	case StrategyPostFilter:
+		return "post-filter"
+	default:
+		return fmt.Sprintf("Strategy(%d)", int(s))
+	}
+}
+
+const (
+	// selectivitySamples is the number of nodes the planner tests against
+	// the filters to estimate their selectivity.
+	selectivitySamples = 128
+
+	// filteredSelectivity is the selectivity under which filtered
+	// traversal beats post-filtering.
+	filteredSelectivity = 0.3
+)
+
+// plan chooses the strategy of a search. The caller must hold the read
+// lock.
+func (g *Graph[K]) plan(k, efSearch int, opts SearchOptions[K]) Strategy {
+	if opts.Strategy != StrategyAuto {
+		return opts.Strategy
+	}
+	// A search visits about efSearch*M nodes, or more to find k results.
+	visits := max(efSearch, k) * g.M
+
+	if set, ok := opts.Allow.(AllowSet[K]); ok && len(set) <= visits {
+		return StrategyBruteForce
+	}
+	live := len(g.layers[0].nodes) - len(g.tombstones)
+	if live <= max(efSearch, k) {
+		return StrategyBruteForce
+	}
+	filter := opts.predicate()
+	if filter == nil {
+		return StrategyPostFilter
+	}
+	selectivity := g.selectivity(filter)
+	switch {
+	case selectivity*float64(live) <= float64(visits):
+		return StrategyBruteForce
+	case selectivity < filteredSelectivity:
+		return StrategyFiltered
+	default:
+		return StrategyPostFilter
+	}
+}
+
+// predicate returns the conjunction of Allow and Filter, or nil if
+// neither is set.
+func (opts SearchOptions[K]) predicate() func(K) bool {
+	allow, filter := opts.allow(), opts.Filter
+	switch {
+	case allow == nil:
+		return filter
+	case filter == nil:
+		return allow
+	}
+	return func(key K) bool { return allow(key) && filter(key) }
+}
+
+// selectivity estimates the fraction of the nodes for which filter
+// returns true. It tests up to selectivitySamples nodes of the base layer
+// at golden-ratio offsets, which spread evenly over it without aliasing
+// with periodic keys. The caller must hold the read lock.
+func (g *Graph[K]) selectivity(filter func(K) bool) float64 {
+	byID := g.layers[0].byID
+	var sampled, passed int
+	test := func(node *layerNode[K]) {
+		if node == nil || g.isTombstone(node.Key) {
+			return
+		}
+		sampled++
+		if filter(node.Key) {
+			passed++
+		}
+	}
+	if len(byID) <= selectivitySamples {
+		for _, node := range byID {
+			test(node)
+		}
+	} else {
+		const phi = 0.6180339887498949
+		var x float64
+		for range selectivitySamples {
+			test(byID[int(x*float64(len(byID)))])
+			x += phi
+			x -= math.Floor(x)
+		}
+	}
+	if sampled == 0 {
+		return 1
+	}
+	return float64(passed) / float64(sampled)
+}
+
+// scan ranks the candidate nodes of a search exhaustively: the nodes of
+// opts.Allow if it is an AllowSet, or else every node. The caller must
+// hold the read lock.
+func (g *Graph[K]) scan(score scoreFunc[K], k int, opts SearchOptions[K], budget *searchBudget) ([]SearchResultNode[K], error) {
+	score = g.rankScore(score, opts)
+	base := g.layers[0]
+	filter := g.hideTombstones(opts.predicate())
+	trace := opts.trace.layer(0)
+
+	var out []SearchResultNode[K]
+	rank := func(node *layerNode[K]) error {
+		if filter != nil && !filter(node.Key) {
+			return nil
+		}
+		if !budget.visit() || !budget.compute() {
+			return errBudgetSpent
+		}
+		dist, err := score(node)
+		if err != nil {
+			return err
+		}
+		trace.visit(node.Key)
+		out = append(out, SearchResultNode[K]{Node: node.Node, Distance: dist})
+		return nil
+	}
+
+	var err error
+	if set, ok := opts.Allow.(AllowSet[K]); ok {
+		for key := range set {
+			if node, ok := base.nodes[key]; ok {
+				if err = rank(node); err != nil {
+					break
+				}
+			}
+		}
+	} else {
+		for _, node := range base.byID {
+			if node != nil {
+				if err = rank(node); err != nil {
+					break
+				}
+			}
+		}
+	}
+	if err != nil && err != errBudgetSpent {
+		return nil, err
+	}
+
+	slices.SortStableFunc(out, func(a, b SearchResultNode[K]) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+	if len(out) > k {
+		out = out[:k]
+	}
+	g.attachPayloads(out)
+	return out, nil
+}
+
+// errBudgetSpent stops a scan once the search budget is spent.
+var errBudgetSpent = errors.New("search budget spent")
@@ -0,0 +1,91 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Planner(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, g.Add(MakeNode(i, randFloats(4))))
+	}
+	var stats SearchStats
+	_, err := g.SearchWithOptions(randFloats(4), 3, SearchOptions[int]{Stats: &stats})
+	require.NoError(t, err)
+	require.Equal(t, StrategyBruteForce, stats.Strategy)
+
+	for i := 10; i < 2000; i++ {
+		require.NoError(t, g.Add(MakeNode(i, randFloats(4))))
+	}
+	for _, tt := range []struct {
+		name  string
+		opts  SearchOptions[int]
+		want  Strategy
+		match func(int) bool
+	}{
+		{name: "Unfiltered", want: StrategyPostFilter},
+		{
+			name: "TinySubset",
+			opts: SearchOptions[int]{Filter: func(key int) bool { return key%100 == 0 }},
+			want: StrategyBruteForce,
+		},
+		{
+			name: "Selective",
+			opts: SearchOptions[int]{Filter: func(key int) bool { return key%10 == 0 }},
+			want: StrategyFiltered,
+		},
+		{
+			name: "Broad",
+			opts: SearchOptions[int]{Filter: func(key int) bool { return key%10 != 0 }},
+			want: StrategyPostFilter,
+		},
+		{
+			name: "AllowAndFilter",
+			opts: SearchOptions[int]{
+				Allow:  allowFunc(func(key int) bool { return key%2 == 0 }),
+				Filter: func(key int) bool { return key%5 == 0 },
+			},
+			want: StrategyFiltered,
+		},
+		{
+			name: "Forced",
+			opts: SearchOptions[int]{Filter: func(key int) bool { return key%10 == 0 }, Strategy: StrategyBruteForce},
+			want: StrategyBruteForce,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats SearchStats
+			opts := tt.opts
+			opts.Stats = &stats
+			results, err := g.SearchWithOptions(randFloats(4), 10, opts)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, stats.Strategy, stats.Strategy.String())
+			require.Len(t, results, 10)
+			for _, r := range results {
+				if opts.Filter != nil {
+					require.True(t, opts.Filter(r.Key))
+				}
+				if opts.Allow != nil {
+					require.True(t, opts.Allow.Contains(r.Key))
+				}
+			}
+		})
+	}
+
+	// Brute force honors the budget.
+	var budgeted SearchStats
+	results, err := g.SearchWithOptions(randFloats(4), 10, SearchOptions[int]{
+		Strategy:                StrategyBruteForce,
+		MaxDistanceComputations: 5,
+		Stats:                   &budgeted,
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	require.True(t, budgeted.Truncated)
+}
+
+type allowFunc func(int) bool
+
+func (f allowFunc) Contains(key int) bool { return f(key) }
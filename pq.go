@@ -0,0 +1,305 @@
+package hnsw
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+
+	"github.com/chewxy/math32"
+	"github.com/viterin/vek/vek32"
+)
+
+// ProductQuantizer is a Quantizer that splits vectors into Subspaces
+// parts and encodes each part as the nearest of up to 256 centroids
+// learned for that part, in one byte. A vector of d float32s, 4d bytes,
+// is compressed to Subspaces bytes, e.g. 32x for d = 128 and 16
+// subspaces.
+//
+// Its distances approximate EuclideanDistance, or DotProductDistance
+// with InnerProduct. For cosine distance, normalize the vectors and
+// queries first; the Euclidean order of unit vectors is their cosine
+// order.
+type ProductQuantizer struct {
+	// Subspaces is the number of parts, and bytes, per vector. More
+	// subspaces are more accurate and less compact.
+	Subspaces int
+
+	// Iterations is the number of k-means iterations in Train. Zero
+	// means 25.
+	Iterations int
+
+	// Rng picks the initial centroids. Nil means a time-seeded source.
+	Rng *rand.Rand
+
+	// InnerProduct makes Distances approximate DotProductDistance, the
+	// negative inner product, for maximum inner product search.
+	InnerProduct bool
+
+	// AnisotropicWeight, if above 1, trains and encodes with the
+	// score-aware loss of Guo et al., "Accelerating Large-Scale Inference
+	// with Anisotropic Vector Quantization" (2020), meant for use with
+	// InnerProduct. The error parallel to a vector, which shifts its
+	// inner product with the queries it scores highest against, weighs
+	// AnisotropicWeight times as much as the error orthogonal to it. The
+	// reconstruction error grows, but the top inner products are ranked
+	// better. Values around 4 are a good start.
+	//
+	// Encoding searches the centroids of one subspace at a time, so it is
+	// a few times slower, and the codes found are locally, not globally,
+	// optimal. Train runs plain k-means, then a few rounds of anisotropic
+	// encoding and centroid refitting.
+	AnisotropicWeight float32
+
+	dims int
+	// centroids holds the centroids of each subspace, one after the
+	// other.
+	centroids [][]float32
+}
+
+var _ Quantizer = (*ProductQuantizer)(nil)
+
+// bounds returns the range of dimensions of subspace s.
+func (p *ProductQuantizer) bounds(s int) (int, int) {
+	return s * p.dims / p.Subspaces, (s + 1) * p.dims / p.Subspaces
+}
+
+// squaredDistance returns the squared Euclidean distance of a and b,
+// which must have the same length.
+func squaredDistance(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return sum
+}
+
+// Train learns the centroids of each subspace with k-means over sample.
+func (p *ProductQuantizer) Train(sample []Vector) error {
+	if len(sample) == 0 {
+		return fmt.Errorf("empty sample")
+	}
+	dims := len(sample[0])
+	for _, v := range sample {
+		if len(v) != dims {
+			return fmt.Errorf("embedding dimension mismatch: %d != %d", dims, len(v))
+		}
+	}
+	if p.Subspaces < 1 || p.Subspaces > dims {
+		return fmt.Errorf("subspaces must be between 1 and %d, got %d", dims, p.Subspaces)
+	}
+	iterations := p.Iterations
+	if iterations == 0 {
+		iterations = 25
+	}
+	rng := p.Rng
+	if rng == nil {
+		rng = defaultRand()
+	}
+
+	p.dims = dims
+	p.centroids = make([][]float32, p.Subspaces)
+	parts := make([][]float32, len(sample))
+	for s := range p.centroids {
+		lo, hi := p.bounds(s)
+		for i, v := range sample {
+			parts[i] = v[lo:hi]
+		}
+		p.centroids[s] = kmeans(parts, min(256, len(sample)), iterations, rng)
+	}
+	if p.AnisotropicWeight > 1 {
+		for i := 0; i < anisotropicRounds; i++ {
+			p.refitAnisotropic(sample)
+		}
+	}
+	return nil
+}
+
+// kmeans clusters points into k clusters and returns the centroids, one
+// after the other.
+func kmeans(points [][]float32, k, iterations int, rng *rand.Rand) []float32 {
+	dims := len(points[0])
+	centroids := make([]float32, 0, k*dims)
+	for _, i := range rng.Perm(len(points))[:k] {
+		centroids = append(centroids, points[i]...)
+	}
+
+	assignment := make([]int, len(points))
+	sums := make([]float32, k*dims)
+	counts := make([]int, k)
+	for it := 0; it < iterations; it++ {
+		changed := false
+		for i, pt := range points {
+			c := nearestCentroid(centroids, pt)
+			if c != assignment[i] || it == 0 {
+				changed = true
+			}
+			assignment[i] = c
+		}
+		if !changed {
+			break
+		}
+
+		clear(sums)
+		clear(counts)
+		for i, pt := range points {
+			c := assignment[i]
+			counts[c]++
+			for j, v := range pt {
+				sums[c*dims+j] += v
+			}
+		}
+		for c := 0; c < k; c++ {
+			centroid := centroids[c*dims : (c+1)*dims]
+			if counts[c] == 0 {
+				// Move an empty cluster onto a random point.
+				copy(centroid, points[rng.Intn(len(points))])
+				continue
+			}
+			for j := range centroid {
+				centroid[j] = sums[c*dims+j] / float32(counts[c])
+			}
+		}
+	}
+	return centroids
+}
+
+// nearestCentroid returns the index of the centroid closest to pt.
+func nearestCentroid(centroids, pt []float32) int {
+	dims := len(pt)
+	best, bestDist := 0, float32(0)
+	for c := 0; c*dims < len(centroids); c++ {
+		d := squaredDistance(centroids[c*dims:(c+1)*dims], pt)
+		if c == 0 || d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// Encode returns the index of the nearest centroid in each subspace.
+func (p *ProductQuantizer) Encode(v Vector) ([]byte, error) {
+	if p.centroids == nil {
+		return nil, fmt.Errorf("product quantizer is not trained")
+	}
+	if len(v) != p.dims {
+		return nil, fmt.Errorf("embedding dimension mismatch: %d != %d", p.dims, len(v))
+	}
+	return p.encode(v), nil
+}
+
+func (p *ProductQuantizer) encode(v Vector) []byte {
+	code := make([]byte, p.Subspaces)
+	for s := range code {
+		lo, hi := p.bounds(s)
+		code[s] = byte(nearestCentroid(p.centroids[s], v[lo:hi]))
+	}
+	if p.AnisotropicWeight > 1 {
+		p.encodeAnisotropic(v, code)
+	}
+	return code
+}
+
+// Decode concatenates the centroids of code.
+func (p *ProductQuantizer) Decode(code []byte) Vector {
+	v := make(Vector, 0, p.dims)
+	for s, c := range code {
+		lo, hi := p.bounds(s)
+		n := hi - lo
+		v = append(v, p.centroids[s][int(c)*n:(int(c)+1)*n]...)
+	}
+	return v
+}
+
+// Distances precomputes the squared distances from each part of q to the
+// centroids of its subspace, or their negative inner products with
+// InnerProduct, so that the distance to a code is a sum of Subspaces
+// table lookups.
+func (p *ProductQuantizer) Distances(q Vector) (func(code []byte) float32, error) {
+	if p.centroids == nil {
+		return nil, fmt.Errorf("product quantizer is not trained")
+	}
+	if len(q) != p.dims {
+		return nil, fmt.Errorf("embedding dimension mismatch: %d != %d", p.dims, len(q))
+	}
+	tables := make([][]float32, p.Subspaces)
+	for s := range tables {
+		lo, hi := p.bounds(s)
+		n := hi - lo
+		centroids := p.centroids[s]
+		tables[s] = make([]float32, len(centroids)/n)
+		for c := range tables[s] {
+			if p.InnerProduct {
+				tables[s][c] = -vek32.Dot(centroids[c*n:(c+1)*n], q[lo:hi])
+			} else {
+				tables[s][c] = squaredDistance(centroids[c*n:(c+1)*n], q[lo:hi])
+			}
+		}
+	}
+	if p.InnerProduct {
+		return func(code []byte) float32 {
+			var sum float32
+			for s, c := range code {
+				sum += tables[s][c]
+			}
+			return sum
+		}, nil
+	}
+	return func(code []byte) float32 {
+		var sum float32
+		for s, c := range code {
+			sum += tables[s][c]
+		}
+		return math32.Sqrt(sum)
+	}, nil
+}
+
+// MarshalBinary encodes the trained state of the quantizer.
+func (p *ProductQuantizer) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := multiBinaryWrite(&buf, p.dims, p.Subspaces)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range p.centroids {
+		if _, err := binaryWrite(&buf, c); err != nil {
+			return nil, err
+		}
+	}
+	_, err = multiBinaryWrite(&buf, boolInt(p.InnerProduct), p.AnisotropicWeight)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary restores a state encoded by MarshalBinary.
+func (p *ProductQuantizer) UnmarshalBinary(data []byte) error {
+	r := bufio.NewReader(bytes.NewReader(data))
+	var dims, subspaces int
+	_, err := multiBinaryRead(r, &dims, &subspaces)
+	if err != nil {
+		return err
+	}
+	centroids := make([][]float32, subspaces)
+	for s := range centroids {
+		if _, err := binaryRead(r, &centroids[s]); err != nil {
+			return fmt.Errorf("decoding subspace %d: %w", s, err)
+		}
+	}
+	// States encoded before InnerProduct and AnisotropicWeight end here.
+	var (
+		innerProduct int
+		weight       float32
+	)
+	_, err = multiBinaryRead(r, &innerProduct, &weight)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	p.dims, p.Subspaces, p.centroids = dims, subspaces, centroids
+	p.InnerProduct, p.AnisotropicWeight = innerProduct == 1, weight
+	return nil
+}
@@ -0,0 +1,160 @@
+package hnsw
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductQuantizer(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	vecs := make([]Vector, 2000)
+	for i := range vecs {
+		vecs[i] = randFloats(32)
+	}
+
+	pq := &ProductQuantizer{Subspaces: 8, Iterations: 10, Rng: rng}
+	_, err := pq.Encode(vecs[0])
+	require.Error(t, err)
+	require.Error(t, (&ProductQuantizer{Subspaces: 33}).Train(vecs))
+	require.NoError(t, pq.Train(vecs))
+
+	code, err := pq.Encode(vecs[0])
+	require.NoError(t, err)
+	require.Len(t, code, 8)
+	_, err = pq.Encode(Vector{1})
+	require.Error(t, err)
+
+	// Reconstructions are much closer to the original than a random
+	// vector is.
+	distances, err := pq.Distances(vecs[0])
+	require.NoError(t, err)
+	approx := distances(code)
+	exact, _ := EuclideanDistance(pq.Decode(code), vecs[0])
+	require.InDelta(t, exact, approx, 1e-4)
+	random, _ := EuclideanDistance(vecs[1], vecs[0])
+	require.Less(t, approx, random/2)
+
+	state, err := pq.MarshalBinary()
+	require.NoError(t, err)
+	var pq2 ProductQuantizer
+	require.NoError(t, pq2.UnmarshalBinary(state))
+	code2, err := pq2.Encode(vecs[0])
+	require.NoError(t, err)
+	require.Equal(t, code, code2)
+}
+
+func TestQuantizedIndex(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	vecs := make(map[int]Vector)
+	sample := make([]Vector, 0, 2000)
+	for i := 0; i < 2000; i++ {
+		vecs[i] = randFloats(32)
+		sample = append(sample, vecs[i])
+	}
+	pq := &ProductQuantizer{Subspaces: 8, Iterations: 10, Rng: rng}
+	require.NoError(t, pq.Train(sample))
+
+	idx := &QuantizedIndex[int]{Quantizer: pq}
+	exact := NewBruteForce[int]()
+	exact.Distance = EuclideanDistance
+	for i := 0; i < 2000; i++ {
+		require.NoError(t, idx.Add(MakeNode(i, vecs[i])))
+		exact.Add(MakeNode(i, vecs[i]))
+	}
+	require.Equal(t, 2000, idx.Len())
+
+	recall := func() int {
+		var found int
+		for i := 0; i < 50; i++ {
+			q := randFloats(32)
+			want, _ := exact.Search(q, 10)
+			got, err := idx.Search(q, 10)
+			require.NoError(t, err)
+			require.Len(t, got, 10)
+			for _, w := range want {
+				for _, g := range got {
+					if g.Key == w.Key {
+						found++
+					}
+				}
+			}
+		}
+		return found
+	}
+	approximate := recall()
+
+	idx.Rerank = 100
+	idx.Originals = func(key int) (Vector, bool) {
+		vec, ok := vecs[key]
+		return vec, ok
+	}
+	reranked := recall()
+	require.Greater(t, reranked, approximate)
+	require.Greater(t, reranked, 400)
+
+	results, err := idx.Search(vecs[7], 1)
+	require.NoError(t, err)
+	require.Equal(t, 7, results[0].Key)
+	require.Zero(t, results[0].Distance)
+	require.Equal(t, vecs[7], results[0].Value)
+
+	t.Run("RerankDecoded", func(t *testing.T) {
+		// Candidates without an original are re-ranked with their decoded
+		// codes rather than dropped.
+		idx.Originals = func(key int) (Vector, bool) {
+			vec, ok := vecs[key]
+			return vec, ok && key%2 == 0
+		}
+		idx.Distance = DotProductDistance
+		defer func() { idx.Distance = nil }()
+		q := randFloats(32)
+		results, err := idx.Search(q, 10)
+		require.NoError(t, err)
+		require.Len(t, results, 10)
+		for i, r := range results {
+			want := vecs[r.Key]
+			if r.Key%2 != 0 {
+				want, _ = idx.Lookup(r.Key)
+			}
+			require.Equal(t, want, r.Value)
+			dist, _ := DotProductDistance(want, q)
+			require.Equal(t, dist, r.Distance)
+			if i > 0 {
+				require.LessOrEqual(t, results[i-1].Distance, r.Distance)
+			}
+		}
+	})
+
+	t.Run("ExportImport", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, idx.Export(&buf))
+		data := buf.Bytes()
+
+		// The index being imported into has contents of its own.
+		old := &ProductQuantizer{Subspaces: 8, Iterations: 10, Rng: rng}
+		require.NoError(t, old.Train(sample[:500]))
+		idx2 := &QuantizedIndex[int]{Quantizer: old, HotSize: 10}
+		require.NoError(t, idx2.Add(MakeNode(-1, vecs[0])))
+
+		// A failed import leaves it unchanged.
+		require.Error(t, idx2.Import(bufio.NewReader(bytes.NewReader(data[:len(data)/2]))))
+		require.Same(t, old, idx2.Quantizer)
+		require.Equal(t, 1, idx2.Len())
+		require.Equal(t, 1, idx2.Stats().Hot)
+
+		require.NoError(t, idx2.Import(bufio.NewReader(bytes.NewReader(data))))
+		require.NotSame(t, old, idx2.Quantizer)
+		require.Same(t, rng, idx2.Quantizer.(*ProductQuantizer).Rng)
+		require.Equal(t, idx.Len(), idx2.Len())
+		_, ok := idx2.Lookup(-1)
+		require.False(t, ok)
+		require.Equal(t, QuantizedStats{Codes: idx.Len()}, idx2.Stats())
+		v1, _ := idx.Lookup(3)
+		v2, _ := idx2.Lookup(3)
+		require.Equal(t, v1, v2)
+	})
+}
@@ -0,0 +1,87 @@
+package hnsw
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// PruneOptions configures PruneUnretrieved.
+type PruneOptions[K comparable] struct {
+	// MinAge spares nodes added less than MinAge ago, which haven't had a
+	// chance to be retrieved yet.
+	MinAge time.Duration
+
+	// Hits are the hit counts to judge nodes by. Nil means HitCounts,
+	// which requires Graph.HitSampling.
+	Hits map[K]uint64
+
+	// DryRun only reports the nodes that would be deleted.
+	DryRun bool
+}
+
+// PruneReport describes the nodes found, and deleted, by
+// PruneUnretrieved.
+type PruneReport[K comparable] struct {
+	// Unretrieved lists the nodes older than MinAge without hits, in key
+	// order. Unless the run was dry, they were deleted.
+	Unretrieved []K
+
+	// Deleted is the number of nodes deleted.
+	Deleted int
+
+	// UnknownAge is the number of nodes without hits that were spared
+	// because their insert time isn't known, e.g. nodes loaded with
+	// Import.
+	UnknownAge int
+}
+
+// PruneUnretrieved finds the nodes that were never retrieved and are
+// older than opts.MinAge, and deletes them unless opts.DryRun is set,
+// e.g. to keep a semantic cache or a long-lived corpus lean. Run it dry
+// first and review the report. The nodes are removed in bulk like
+// Compact does, along with any nodes marked with MarkDeleted.
+//
+// Hit counts are sampled, so a node without hits may have been returned
+// by searches that weren't sampled; sample for long enough, relative to
+// MinAge, before pruning. See Graph.HitSampling.
+func (g *Graph[K]) PruneUnretrieved(opts PruneOptions[K]) (PruneReport[K], error) {
+	hits := opts.Hits
+	if hits == nil {
+		if g.HitSampling <= 0 {
+			return PruneReport[K]{}, fmt.Errorf("hit sampling is disabled; set HitSampling or pass Hits")
+		}
+		hits = g.HitCounts()
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var report PruneReport[K]
+	if len(g.layers) == 0 {
+		return report, nil
+	}
+	cutoff := g.clock().Add(-opts.MinAge).UnixNano()
+	for key, node := range g.layers[0].nodes {
+		if hits[key] > 0 || g.isTombstone(key) {
+			continue
+		}
+		switch {
+		case node.added == 0:
+			report.UnknownAge++
+		case node.added <= cutoff:
+			report.Unretrieved = append(report.Unretrieved, key)
+		}
+	}
+	slices.SortFunc(report.Unretrieved, keyOrder[K]())
+
+	if opts.DryRun || len(report.Unretrieved) == 0 {
+		return report, nil
+	}
+	for _, key := range report.Unretrieved {
+		g.markTombstone(key)
+	}
+	// Remove the nodes in bulk, along with any other marked nodes.
+	g.compact()
+	report.Deleted = len(report.Unretrieved)
+	return report, nil
+}
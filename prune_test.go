@@ -0,0 +1,55 @@
+package hnsw
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_PruneUnretrieved(t *testing.T) {
+	g := newTestGraph[int]()
+	g.HitSampling = 1
+	now := time.Unix(1000, 0)
+	g.now = func() time.Time { return now }
+	for i := 0; i < 16; i++ {
+		require.NoError(t, g.Add(MakeNode(i, Vector{float32(i)})))
+	}
+	_, err := g.Search(Vector{2.9}, 2)
+	require.NoError(t, err)
+
+	// Nodes added since are too young to prune.
+	now = now.Add(time.Hour)
+	require.NoError(t, g.Add(MakeNode(100, Vector{100})))
+	g.MarkDeleted(15)
+
+	report, err := g.PruneUnretrieved(PruneOptions[int]{MinAge: time.Minute, DryRun: true})
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}, report.Unretrieved)
+	require.Zero(t, report.Deleted)
+	require.Equal(t, 16, g.Len())
+
+	report, err = g.PruneUnretrieved(PruneOptions[int]{MinAge: time.Minute})
+	require.NoError(t, err)
+	require.Equal(t, 13, report.Deleted)
+	require.Equal(t, 3, g.Len())
+	// The marked node was compacted along with the pruned ones.
+	require.Zero(t, g.Tombstones())
+	require.NoError(t, g.Verify())
+	_, ok := g.Lookup(100)
+	require.True(t, ok)
+
+	// Imported nodes have no known age.
+	var buf bytes.Buffer
+	require.NoError(t, g.Export(&buf))
+	imported := newTestGraph[int]()
+	require.NoError(t, imported.Import(&buf))
+	report, err = imported.PruneUnretrieved(PruneOptions[int]{Hits: map[int]uint64{3: 1}})
+	require.NoError(t, err)
+	require.Empty(t, report.Unretrieved)
+	require.Equal(t, 2, report.UnknownAge)
+
+	_, err = newTestGraph[int]().PruneUnretrieved(PruneOptions[int]{})
+	require.ErrorContains(t, err, "hit sampling is disabled")
+}
@@ -0,0 +1,164 @@
+"""Python bindings for github.com/hypermodeinc/hnsw.
+
+The index is the Go implementation, loaded as a shared library through
+ctypes. It takes NumPy arrays and follows the shape of scikit-learn's
+NearestNeighbors:
+
+    import numpy as np
+    import hnsw
+
+    index = hnsw.Index(distance="euclidean").fit(X)
+    distances, keys = index.query(Q, k=10)
+
+Keys are int64. fit assigns the row numbers of X unless keys are given.
+"""
+
+import ctypes
+import os
+import sys
+
+import numpy as np
+
+__all__ = ["Index", "HNSWError"]
+
+_LIB_NAME = {"darwin": "libhnsw.dylib", "win32": "hnsw.dll"}.get(
+    sys.platform, "libhnsw.so"
+)
+
+
+class HNSWError(Exception):
+    """An error returned by the Go library."""
+
+
+def _load():
+    path = os.environ.get("HNSW_LIBRARY") or os.path.join(
+        os.path.dirname(__file__), _LIB_NAME
+    )
+    lib = ctypes.CDLL(path)
+
+    handle = ctypes.c_size_t
+    err = ctypes.c_void_p  # A char* that must be freed.
+    floats = ctypes.POINTER(ctypes.c_float)
+    int64s = ctypes.POINTER(ctypes.c_int64)
+
+    signatures = {
+        "hnsw_new": (err, [ctypes.c_int, ctypes.c_int, ctypes.c_char_p, ctypes.POINTER(handle)]),
+        "hnsw_add_batch": (err, [handle, int64s, floats, ctypes.c_int, ctypes.c_int]),
+        "hnsw_search": (err, [handle, floats, ctypes.c_int, ctypes.c_int, int64s, floats, ctypes.POINTER(ctypes.c_int)]),
+        "hnsw_delete": (ctypes.c_int, [handle, ctypes.c_int64]),
+        "hnsw_len": (ctypes.c_int, [handle]),
+        "hnsw_save": (err, [handle, ctypes.c_char_p]),
+        "hnsw_load": (err, [ctypes.c_char_p, ctypes.POINTER(handle)]),
+        "hnsw_free": (None, [handle]),
+        "hnsw_free_string": (None, [ctypes.c_void_p]),
+    }
+    for name, (restype, argtypes) in signatures.items():
+        fn = getattr(lib, name)
+        fn.restype = restype
+        fn.argtypes = argtypes
+    return lib
+
+
+_lib = _load()
+
+
+def _check(err):
+    if err:
+        message = ctypes.string_at(err).decode()
+        _lib.hnsw_free_string(err)
+        raise HNSWError(message)
+
+
+def _as_matrix(X):
+    X = np.ascontiguousarray(X, dtype=np.float32)
+    if X.ndim == 1:
+        X = X.reshape(1, -1)
+    if X.ndim != 2:
+        raise ValueError(f"expected a 2-D array, got {X.ndim} dimensions")
+    return X
+
+
+class Index:
+    """An HNSW index with int64 keys.
+
+    m and ef_search of 0 keep the library defaults. distance is
+    "cosine", "euclidean" or "dot".
+    """
+
+    def __init__(self, m=0, ef_search=0, distance="cosine"):
+        self._handle = ctypes.c_size_t()
+        _check(_lib.hnsw_new(m, ef_search, distance.encode(), ctypes.byref(self._handle)))
+
+    @classmethod
+    def load(cls, path):
+        """Load an index saved with save."""
+        index = cls.__new__(cls)
+        index._handle = ctypes.c_size_t()
+        _check(_lib.hnsw_load(os.fsencode(path), ctypes.byref(index._handle)))
+        return index
+
+    def __del__(self):
+        handle = getattr(self, "_handle", None)
+        if handle is not None and handle.value:
+            _lib.hnsw_free(handle)
+            handle.value = 0
+
+    def __len__(self):
+        return _lib.hnsw_len(self._handle)
+
+    def fit(self, X, keys=None):
+        """Add the rows of X, under keys or their row numbers.
+
+        Rows under existing keys replace them. Returns self.
+        """
+        X = _as_matrix(X)
+        if keys is None:
+            keys = np.arange(len(X), dtype=np.int64)
+        keys = np.ascontiguousarray(keys, dtype=np.int64)
+        if keys.shape != (len(X),):
+            raise ValueError("keys must have one entry per row of X")
+        _check(
+            _lib.hnsw_add_batch(
+                self._handle,
+                keys.ctypes.data_as(ctypes.POINTER(ctypes.c_int64)),
+                X.ctypes.data_as(ctypes.POINTER(ctypes.c_float)),
+                X.shape[0],
+                X.shape[1],
+            )
+        )
+        return self
+
+    add = fit
+
+    def query(self, X, k=1):
+        """Find the k nearest neighbors of each row of X.
+
+        Returns (distances, keys), arrays of shape (len(X), k), closest
+        first. If the index has fewer than k entries, the missing
+        distances are inf and the missing keys -1.
+        """
+        X = _as_matrix(X)
+        distances = np.full((len(X), k), np.inf, dtype=np.float32)
+        keys = np.full((len(X), k), -1, dtype=np.int64)
+        found = ctypes.c_int()
+        for i, row in enumerate(X):
+            _check(
+                _lib.hnsw_search(
+                    self._handle,
+                    row.ctypes.data_as(ctypes.POINTER(ctypes.c_float)),
+                    X.shape[1],
+                    k,
+                    keys[i].ctypes.data_as(ctypes.POINTER(ctypes.c_int64)),
+                    distances[i].ctypes.data_as(ctypes.POINTER(ctypes.c_float)),
+                    ctypes.byref(found),
+                )
+            )
+        return distances, keys
+
+    def delete(self, key):
+        """Remove key and return whether it existed."""
+        return bool(_lib.hnsw_delete(self._handle, key))
+
+    def save(self, path):
+        """Write the index to the file at path."""
+        _check(_lib.hnsw_save(self._handle, os.fsencode(path)))
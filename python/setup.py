@@ -0,0 +1,41 @@
+"""Builds the Go shared library into the package before building wheels.
+
+Requires a Go toolchain and a C compiler (for cgo).
+"""
+
+import os
+import subprocess
+import sys
+
+from setuptools import setup
+from setuptools.command.build_py import build_py
+from wheel.bdist_wheel import bdist_wheel
+
+HERE = os.path.dirname(os.path.abspath(__file__))
+LIB_NAME = {"darwin": "libhnsw.dylib", "win32": "hnsw.dll"}.get(sys.platform, "libhnsw.so")
+
+
+class build_go(build_py):
+    def run(self):
+        subprocess.check_call(
+            [
+                "go", "build", "-buildmode=c-shared",
+                "-o", os.path.join(HERE, "hnsw", LIB_NAME),
+                "./capi",
+            ],
+            cwd=os.path.dirname(HERE),
+        )
+        super().run()
+
+
+class platform_wheel(bdist_wheel):
+    # The wheel contains a native library.
+    def finalize_options(self):
+        super().finalize_options()
+        self.root_is_pure = False
+
+
+setup(
+    cmdclass={"build_py": build_go, "bdist_wheel": platform_wheel},
+    package_data={"hnsw": [LIB_NAME]},
+)
@@ -0,0 +1,30 @@
+import numpy as np
+import pytest
+
+import hnsw
+
+
+def test_fit_query(tmp_path):
+    X = np.arange(100, dtype=np.float32).reshape(-1, 1)
+    index = hnsw.Index(distance="euclidean").fit(X)
+    assert len(index) == 100
+
+    distances, keys = index.query([[41.2], [0]], k=3)
+    assert keys.tolist() == [[41, 42, 40], [0, 1, 2]]
+    assert distances[0, 0] == pytest.approx(0.2, abs=1e-5)
+
+    path = tmp_path / "index"
+    index.save(path)
+    loaded = hnsw.Index.load(path)
+    assert len(loaded) == 100
+    assert loaded.delete(41)
+    assert not loaded.delete(41)
+    assert loaded.query([41.2], k=1)[1].tolist() == [[42]]
+
+
+def test_errors():
+    index = hnsw.Index(distance="euclidean").fit([[1, 2]])
+    with pytest.raises(hnsw.HNSWError):
+        index.fit([[1, 2, 3]])
+    with pytest.raises(hnsw.HNSWError):
+        hnsw.Index(distance="manhattan")
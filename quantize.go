@@ -0,0 +1,369 @@
+package hnsw
+
+import (
+	"cmp"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/hypermodeinc/hnsw/heap"
+)
+
+// Quantizer compresses vectors into short codes and compares queries with
+// codes without decompressing them.
+type Quantizer interface {
+	// Train fits the quantizer to a representative sample of the vectors
+	// it will encode. It must be called before the other methods.
+	Train(sample []Vector) error
+
+	// Encode compresses v into a code.
+	Encode(v Vector) ([]byte, error)
+
+	// Decode returns an approximation of the vector encoded in code.
+	Decode(code []byte) Vector
+
+	// Distances returns a function measuring the distance from q to
+	// encoded vectors. It may precompute tables for q, so that each code
+	// is compared quickly (asymmetric distance computation).
+	Distances(q Vector) (func(code []byte) float32, error)
+}
+
+// QuantizedIndex is an exact-scan index over compressed vectors: it keeps
+// only the codes from a Quantizer in memory, and compares queries with
+// every code. Optionally, the best candidates are re-ranked with their
+// original vectors, e.g. read from disk, to recover exact distances.
+//
+// It is a flat index: it doesn't use the graph, and search time grows
+// linearly with its size. It suits collections whose full vectors don't
+// fit in memory but whose codes can be scanned fast enough; a Graph
+// stores and compares full vectors.
+//
+// Codes are only decoded on demand: for the results, and for the
+// candidates being re-ranked. Export persists the codes along with the
+// quantizer's codebooks, so the index is stored compressed too.
+//
+// With HotSize, the index also keeps the full vectors of the nodes used
+// most recently, for skewed workloads where a small set of nodes serves
+// most searches: those nodes are re-ranked and returned exactly, while
+// the rest cost only their codes.
+//
+// The zero value is not usable; set Quantizer to a trained quantizer.
+type QuantizedIndex[K comparable] struct {
+	// Quantizer encodes the vectors. It must be trained before the first
+	// Add.
+	Quantizer Quantizer
+
+	// Rerank is the number of candidates re-ranked with Distance. If
+	// Rerank is unset, results are ranked by the approximate distances of
+	// the quantizer.
+	Rerank int
+
+	// Originals returns the original vector stored under key, for
+	// re-ranking. Candidates without an original, or all of them if
+	// Originals is nil, are re-ranked with their decoded codes instead:
+	// this helps quantizers whose distances only roughly order codes,
+	// like BinaryQuantizer, and re-ranks the candidates of any quantizer
+	// by a metric it doesn't approximate.
+	Originals func(key K) (Vector, bool)
+
+	// Distance is used to re-rank. Nil means EuclideanDistance.
+	Distance DistanceFunc
+
+	// HotSize is the number of full vectors kept in memory, for the nodes
+	// added or returned by Search most recently; the least recently used
+	// are evicted. A node returned by Search becomes hot again if
+	// Originals has its vector. Hot vectors are used before Originals,
+	// and are not copied. Zero keeps none.
+	HotSize int
+
+	mu    sync.RWMutex
+	codes map[K][]byte
+	// codec counts the quantizers RetrainCodec and Import installed, so
+	// that Add can tell whether its codes are still current.
+	codec   uint64
+	errs    quantizationErrors
+	retrain *codecRetrain[K]
+	hot     hotTier[K]
+}
+
+var _ Index[int] = (*QuantizedIndex[int])(nil)
+
+// Add encodes and stores nodes, replacing nodes with the same key. The
+// vectors themselves are not retained. Each code is decoded once to
+// measure its quantization error; see Stats.
+func (q *QuantizedIndex[K]) Add(nodes ...Node[K]) error {
+	q.mu.RLock()
+	quantizer, codec := q.Quantizer, q.codec
+	q.mu.RUnlock()
+	for {
+		codes := make([][]byte, len(nodes))
+		errs := make([]float64, len(nodes))
+		for i, n := range nodes {
+			code, err := quantizer.Encode(n.Value)
+			if err != nil {
+				return fmt.Errorf("encode %v: %w", n.Key, err)
+			}
+			codes[i] = code
+			errs[i] = quantizationError(n.Value, quantizer.Decode(code))
+		}
+
+		q.mu.Lock()
+		if q.codec != codec {
+			// RetrainCodec replaced the quantizer meanwhile.
+			quantizer, codec = q.Quantizer, q.codec
+			q.mu.Unlock()
+			continue
+		}
+		if q.codes == nil {
+			q.codes = make(map[K][]byte)
+		}
+		for i, n := range nodes {
+			q.codes[n.Key] = codes[i]
+			q.errs.add(errs[i])
+			if q.HotSize > 0 {
+				q.hot.put(n.Key, n.Value, q.HotSize)
+			}
+			if q.retrain != nil {
+				q.retrain.added[n.Key] = n.Value
+			}
+		}
+		q.mu.Unlock()
+		return nil
+	}
+}
+
+// Search finds the k nearest neighbors of near. Distances are approximate
+// unless the results were re-ranked.
+func (q *QuantizedIndex[K]) Search(near Vector, k int) ([]SearchResultNode[K], error) {
+	rerank := q.Rerank > 0
+	n := k
+	if rerank {
+		n = max(k, q.Rerank)
+	}
+
+	q.mu.RLock()
+	quantizer := q.Quantizer
+	distance, err := quantizer.Distances(near)
+	if err != nil {
+		q.mu.RUnlock()
+		return nil, err
+	}
+	var best heap.Heap[codeCandidate[K]]
+	best.Init(make([]codeCandidate[K], 0, n+1))
+	for key, code := range q.codes {
+		d := distance(code)
+		if best.Len() == n && d >= best.Min().dist {
+			continue
+		}
+		best.Push(codeCandidate[K]{key: key, code: code, dist: d})
+		if best.Len() > n {
+			best.Pop()
+		}
+	}
+	q.mu.RUnlock()
+
+	candidates := make([]codeCandidate[K], best.Len())
+	for i := len(candidates) - 1; i >= 0; i-- {
+		candidates[i] = best.Pop()
+	}
+	if rerank {
+		out, err := q.rerank(quantizer, near, candidates)
+		if err != nil {
+			return nil, err
+		}
+		return out[:min(k, len(out))], nil
+	}
+	out := make([]SearchResultNode[K], len(candidates))
+	for i, c := range candidates {
+		vec, ok := q.hot.get(c.key)
+		if !ok {
+			vec = quantizer.Decode(c.code)
+		}
+		out[i] = SearchResultNode[K]{Node: MakeNode(c.key, vec), Distance: c.dist}
+	}
+	return out, nil
+}
+
+// codeCandidate is a search candidate of a QuantizedIndex, ordered
+// farthest first so that a heap of them keeps the closest.
+type codeCandidate[K comparable] struct {
+	key  K
+	code []byte
+	dist float32
+}
+
+func (c codeCandidate[K]) Less(o codeCandidate[K]) bool {
+	return c.dist > o.dist
+}
+
+// rerank ranks candidates by Distance from near, using their hot vectors,
+// their vectors from Originals, or else their codes, decoded by
+// quantizer. Candidates read from Originals become hot.
+func (q *QuantizedIndex[K]) rerank(quantizer Quantizer, near Vector, candidates []codeCandidate[K]) ([]SearchResultNode[K], error) {
+	distance := q.Distance
+	if distance == nil {
+		distance = EuclideanDistance
+	}
+	out := make([]SearchResultNode[K], 0, len(candidates))
+	var promoted []Node[K]
+	for _, c := range candidates {
+		vec, ok, cold := q.original(c.key)
+		if cold {
+			promoted = append(promoted, MakeNode(c.key, vec))
+		}
+		if !ok {
+			vec = quantizer.Decode(c.code)
+		}
+		d, err := distance(vec, near)
+		if err != nil {
+			return nil, fmt.Errorf("re-rank %v: %w", c.key, err)
+		}
+		out = append(out, SearchResultNode[K]{Node: MakeNode(c.key, vec), Distance: d})
+	}
+	q.promote(promoted)
+	slices.SortFunc(out, func(a, b SearchResultNode[K]) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+	return out, nil
+}
+
+// Delete removes the node with the given key and reports whether it
+// existed.
+func (q *QuantizedIndex[K]) Delete(key K) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.codes[key]
+	delete(q.codes, key)
+	q.hot.remove(key)
+	if q.retrain != nil {
+		delete(q.retrain.added, key)
+	}
+	return ok
+}
+
+// Lookup returns the vector stored under key if it is hot, and its
+// approximation otherwise.
+func (q *QuantizedIndex[K]) Lookup(key K) (Vector, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	code, ok := q.codes[key]
+	if !ok {
+		return nil, false
+	}
+	if vec, ok := q.hot.get(key); ok {
+		return vec, true
+	}
+	return q.Quantizer.Decode(code), true
+}
+
+// Len returns the number of nodes.
+func (q *QuantizedIndex[K]) Len() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return len(q.codes)
+}
+
+// quantizedVersion is the version of the encoding of
+// QuantizedIndex.Export.
+const quantizedVersion = 1
+
+// Export writes the quantizer and the codes, ordered by key, to w. The
+// quantizer must implement encoding.BinaryMarshaler.
+func (q *QuantizedIndex[K]) Export(w io.Writer) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	m, ok := q.Quantizer.(encoding.BinaryMarshaler)
+	if !ok {
+		return fmt.Errorf("quantizer %T does not implement encoding.BinaryMarshaler", q.Quantizer)
+	}
+	state, err := m.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("encode quantizer: %w", err)
+	}
+
+	_, err = multiBinaryWrite(w, quantizedVersion, string(state), len(q.codes))
+	if err != nil {
+		return fmt.Errorf("encode header: %w", err)
+	}
+	for _, key := range sortedMapKeys(q.codes) {
+		_, err = multiBinaryWrite(w, key, string(q.codes[key]))
+		if err != nil {
+			return fmt.Errorf("encode node %v: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Import replaces the contents of the index with those written by Export.
+// Quantizer must be set to a quantizer of the exported type, which must
+// be a pointer implementing encoding.BinaryUnmarshaler; the state is
+// decoded into a copy of it, which then replaces it. r must implement
+// io.ByteReader. If Import fails, the index is unchanged.
+func (q *QuantizedIndex[K]) Import(r io.Reader) error {
+	q.mu.RLock()
+	quantizer, err := copyQuantizer(q.Quantizer)
+	q.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	var (
+		version, n int
+		state      string
+	)
+	_, err = multiBinaryRead(r, &version, &state, &n)
+	if err != nil {
+		return err
+	}
+	if version != quantizedVersion {
+		return fmt.Errorf("incompatible encoding version: %d", version)
+	}
+	if err := quantizer.(encoding.BinaryUnmarshaler).UnmarshalBinary([]byte(state)); err != nil {
+		return fmt.Errorf("decode quantizer: %w", err)
+	}
+
+	codes := make(map[K][]byte, n)
+	for i := 0; i < n; i++ {
+		var (
+			key  K
+			code string
+		)
+		_, err = multiBinaryRead(r, &key, &code)
+		if err != nil {
+			return fmt.Errorf("decoding node %d: %w", i, err)
+		}
+		codes[key] = []byte(code)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.retrain != nil {
+		return errors.New("codec retraining in progress")
+	}
+	q.Quantizer = quantizer
+	q.codes = codes
+	// Adds encoding with the old quantizer start over.
+	q.codec++
+	q.errs = quantizationErrors{}
+	q.hot.reset()
+	return nil
+}
+
+// copyQuantizer returns a shallow copy of quantizer, which keeps its
+// configuration, to decode a state into while quantizer is in use.
+func copyQuantizer(quantizer Quantizer) (Quantizer, error) {
+	if _, ok := quantizer.(encoding.BinaryUnmarshaler); !ok {
+		return nil, fmt.Errorf("quantizer %T does not implement encoding.BinaryUnmarshaler", quantizer)
+	}
+	v := reflect.ValueOf(quantizer)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return nil, fmt.Errorf("quantizer %T must be a non-nil pointer", quantizer)
+	}
+	c := reflect.New(v.Elem().Type())
+	c.Elem().Set(v.Elem())
+	return c.Interface().(Quantizer), nil
+}
@@ -0,0 +1,106 @@
+package hnsw
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/hypermodeinc/hnsw/heap"
+)
+
+// Matches reports whether the stored vector with key b is within
+// threshold of a. It is meant for verification-style workloads, e.g.
+// checking a face or audio fingerprint against an enrolled one.
+func (g *Graph[K]) Matches(a Vector, b K, threshold float32) (bool, error) {
+	vec, ok := g.Lookup(b)
+	if !ok {
+		return false, fmt.Errorf("key %v not found", b)
+	}
+	d, err := g.Distance(a, vec)
+	if err != nil {
+		return false, err
+	}
+	return d <= threshold, nil
+}
+
+// FindMatches returns every node within threshold of vec, closest first.
+//
+// The search expands outwards from the closest node and stops once
+// EfSearch nodes beyond the threshold have been expanded without finding
+// a way back into it, so it exits early when nothing (more) matches.
+func (g *Graph[K]) FindMatches(vec Vector, threshold float32) ([]SearchResultNode[K], error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	g.assertDims(vec)
+
+	score := distanceTo[K](vec, g.Distance)
+	entry, err := g.descend(score, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := entry.searchRadius(threshold, g.EfSearch, score)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(matches, func(a, b searchCandidate[K]) int {
+		return cmp.Compare(a.dist, b.dist)
+	})
+	out := make([]SearchResultNode[K], 0, len(matches))
+	for _, m := range matches {
+		if !g.isTombstone(m.node.Key) {
+			out = append(out, SearchResultNode[K]{Node: m.node.Node, Distance: m.dist})
+		}
+	}
+	g.attachPayloads(out)
+	return out, nil
+}
+
+// searchRadius returns the nodes within threshold of the target of score
+// that are reachable from n. Up to efSearch nodes outside the threshold
+// are expanded to bridge gaps between matching regions.
+func (n *layerNode[K]) searchRadius(threshold float32, efSearch int, score scoreFunc[K]) ([]searchCandidate[K], error) {
+	dist, err := score(n)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		candidates heap.Heap[searchCandidate[K]]
+		matches    []searchCandidate[K]
+		visited    = map[K]bool{n.Key: true}
+		outside    int
+	)
+	candidates.Push(searchCandidate[K]{node: n, dist: dist})
+	if dist <= threshold {
+		matches = append(matches, candidates.Min())
+	}
+
+	for candidates.Len() > 0 {
+		current := candidates.Pop()
+		if current.dist > threshold {
+			outside++
+			if outside > efSearch {
+				break
+			}
+		}
+
+		for _, neighbor := range liveNeighbors(current.node) {
+			if visited[neighbor.Key] {
+				continue
+			}
+			visited[neighbor.Key] = true
+
+			dist, err := score(neighbor)
+			if err != nil {
+				return nil, err
+			}
+			candidate := searchCandidate[K]{node: neighbor, dist: dist}
+			if dist <= threshold {
+				matches = append(matches, candidate)
+			}
+			candidates.Push(candidate)
+		}
+	}
+	return matches, nil
+}
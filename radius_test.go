@@ -0,0 +1,46 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Matches(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 32; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+
+	ok, err := g.Matches(Vector{10.4}, 10, 0.5)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = g.Matches(Vector{10.6}, 10, 0.5)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, err = g.Matches(Vector{10}, 100, 0.5)
+	require.Error(t, err)
+}
+
+func TestGraph_FindMatches(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 256; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+
+	matches, err := g.FindMatches(Vector{100.2}, 3)
+	require.NoError(t, err)
+
+	var keys []int
+	for _, m := range matches {
+		keys = append(keys, m.Key)
+		require.LessOrEqual(t, m.Distance, float32(3))
+	}
+	require.Equal(t, []int{100, 101, 99, 102, 98, 103}, keys)
+
+	matches, err = g.FindMatches(Vector{-100}, 3)
+	require.NoError(t, err)
+	require.Empty(t, matches)
+}
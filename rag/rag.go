@@ -0,0 +1,140 @@
+// Package rag assembles search results into a context for
+// retrieval-augmented generation.
+package rag
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hypermodeinc/hnsw"
+)
+
+// Chunk is a piece of stored text, retrievable by node key.
+type Chunk struct {
+	Text string
+
+	// Source identifies the document the chunk belongs to. It is used for
+	// per-document caps and citations.
+	Source string
+}
+
+// Options configures Assemble.
+type Options struct {
+	// Budget is the maximum number of tokens in the assembled context,
+	// including separators.
+	Budget int
+
+	// MaxPerSource caps the number of chunks taken from one source.
+	// Zero means no cap.
+	MaxPerSource int
+
+	// CountTokens counts the tokens in a string. It defaults to
+	// ApproxTokens; set it to the tokenizer of the target model for exact
+	// budgets.
+	CountTokens func(string) int
+
+	// Separator is placed between chunks. It defaults to two newlines.
+	Separator string
+
+	// Format renders a chunk with its citation number. It defaults to
+	// "[n] text".
+	Format func(n int, c Chunk) string
+}
+
+// Citation describes a chunk included in the context.
+type Citation[K comparable] struct {
+	// N is the citation number the chunk was rendered with, starting at 1.
+	N        int
+	Key      K
+	Source   string
+	Distance float32
+	Tokens   int
+}
+
+// Context is an assembled context.
+type Context[K comparable] struct {
+	Text      string
+	Tokens    int
+	Citations []Citation[K]
+}
+
+// ApproxTokens estimates the number of tokens in s as one token per four
+// bytes, which is close for English text under common tokenizers.
+func ApproxTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+func defaultFormat(n int, c Chunk) string {
+	return fmt.Sprintf("[%d] %s", n, c.Text)
+}
+
+// Assemble builds a context from search results, closest first. chunks
+// returns the stored chunk for a key; results without a chunk are skipped.
+// Chunks that don't fit in the remaining budget are skipped too, so a
+// smaller chunk further down the list may still make it in.
+func Assemble[K comparable](
+	results []hnsw.SearchResultNode[K],
+	chunks func(K) (Chunk, bool),
+	opts Options,
+) Context[K] {
+	if opts.CountTokens == nil {
+		opts.CountTokens = ApproxTokens
+	}
+	if opts.Separator == "" {
+		opts.Separator = "\n\n"
+	}
+	if opts.Format == nil {
+		opts.Format = defaultFormat
+	}
+
+	results = slices.Clone(results)
+	slices.SortStableFunc(results, func(a, b hnsw.SearchResultNode[K]) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+
+	var (
+		ctx       Context[K]
+		text      strings.Builder
+		perSource = make(map[string]int)
+		sepTokens = opts.CountTokens(opts.Separator)
+	)
+	for _, result := range results {
+		chunk, ok := chunks(result.Key)
+		if !ok {
+			continue
+		}
+		if opts.MaxPerSource > 0 && perSource[chunk.Source] >= opts.MaxPerSource {
+			continue
+		}
+
+		n := len(ctx.Citations) + 1
+		rendered := opts.Format(n, chunk)
+		tokens := opts.CountTokens(rendered)
+		cost := tokens
+		if n > 1 {
+			cost += sepTokens
+		}
+		if ctx.Tokens+cost > opts.Budget {
+			continue
+		}
+
+		if n > 1 {
+			text.WriteString(opts.Separator)
+		}
+		text.WriteString(rendered)
+		ctx.Tokens += cost
+		perSource[chunk.Source]++
+		ctx.Citations = append(ctx.Citations, Citation[K]{
+			N:        n,
+			Key:      result.Key,
+			Source:   chunk.Source,
+			Distance: result.Distance,
+			Tokens:   tokens,
+		})
+	}
+
+	ctx.Text = text.String()
+	return ctx
+}
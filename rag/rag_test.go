@@ -0,0 +1,57 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hypermodeinc/hnsw"
+	"github.com/stretchr/testify/require"
+)
+
+func words(s string) int {
+	return len(strings.Fields(s))
+}
+
+func TestAssemble(t *testing.T) {
+	chunks := map[int]Chunk{
+		1: {Text: "alpha beta", Source: "a"},
+		2: {Text: "gamma delta epsilon", Source: "a"},
+		3: {Text: "zeta", Source: "a"},
+		4: {Text: "eta theta iota kappa lambda", Source: "b"},
+		5: {Text: "mu", Source: "c"},
+	}
+	results := []hnsw.SearchResultNode[int]{
+		{Node: hnsw.MakeNode(4, nil), Distance: 0.4},
+		{Node: hnsw.MakeNode(1, nil), Distance: 0.1},
+		{Node: hnsw.MakeNode(2, nil), Distance: 0.2},
+		{Node: hnsw.MakeNode(3, nil), Distance: 0.3},
+		{Node: hnsw.MakeNode(6, nil), Distance: 0.5},
+		{Node: hnsw.MakeNode(5, nil), Distance: 0.6},
+	}
+
+	ctx := Assemble(results, func(key int) (Chunk, bool) {
+		c, ok := chunks[key]
+		return c, ok
+	}, Options{
+		Budget:       11,
+		MaxPerSource: 2,
+		CountTokens:  words,
+		Separator:    " | ",
+	})
+
+	// 3 is over the per-source cap, 4 doesn't fit the budget and 6 has no
+	// chunk.
+	require.Equal(t, "[1] alpha beta | [2] gamma delta epsilon | [3] mu", ctx.Text)
+	require.Equal(t, 3+1+4+1+2, ctx.Tokens)
+	require.Equal(t, []Citation[int]{
+		{N: 1, Key: 1, Source: "a", Distance: 0.1, Tokens: 3},
+		{N: 2, Key: 2, Source: "a", Distance: 0.2, Tokens: 4},
+		{N: 3, Key: 5, Source: "c", Distance: 0.6, Tokens: 2},
+	}, ctx.Citations)
+}
+
+func TestApproxTokens(t *testing.T) {
+	require.Equal(t, 0, ApproxTokens(""))
+	require.Equal(t, 1, ApproxTokens("abc"))
+	require.Equal(t, 2, ApproxTokens("abcde"))
+}
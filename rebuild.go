@@ -0,0 +1,123 @@
+package hnsw
+
+import "fmt"
+
+// Rebuild rebuilds the graph with the parameters of config, e.g. to
+// raise M of an index built with too small a value, without exporting
+// and re-adding the nodes. Start from Config to change only some
+// parameters:
+//
+//	config := g.Config()
+//	config.M, config.EfConstruction = 32, 200
+//	err := g.Rebuild(config)
+//
+// The nodes are inserted into a new graph with AddBatch while the graph
+// stays in use: searches run on the old links and writes go through.
+// Once the new graph is complete, it catches up with the writes made in
+// the meantime and replaces the old links at once, under the write lock.
+// Call Rebuild in a goroutine to rebuild in the background.
+//
+// config.Distance must name a registered distance function, or be empty
+// to keep the current one. config.Version is ignored. Tombstones, stale
+// marks and payloads carry over. Rebuild fails while a migration is in
+// progress.
+func (g *Graph[K]) Rebuild(config GraphConfig) error {
+	g.mu.RLock()
+	rebuilt, err := g.rebuildTarget(config)
+	if err != nil {
+		g.mu.RUnlock()
+		return err
+	}
+	var (
+		nodes []Node[K]
+		// vecs records the vector of each node at the start, to find the
+		// nodes written to during the rebuild.
+		vecs map[K]Vector
+	)
+	if len(g.layers) > 0 {
+		vecs = make(map[K]Vector, len(g.layers[0].nodes))
+		for _, key := range sortedMapKeys(g.layers[0].nodes) {
+			node := g.layers[0].nodes[key].Node
+			nodes = append(nodes, node)
+			vecs[key] = node.Value
+		}
+	}
+	g.mu.RUnlock()
+
+	if err := rebuilt.AddBatch(nodes, 0); err != nil {
+		return fmt.Errorf("rebuilding: %w", err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.next != nil {
+		return fmt.Errorf("migration in progress")
+	}
+	var current map[K]*layerNode[K]
+	if len(g.layers) > 0 {
+		current = g.layers[0].nodes
+	}
+	// Catch up with the writes. Writes replace vectors rather than
+	// modify them, so a node whose vector is a different slice was
+	// written to.
+	for key := range vecs {
+		if _, ok := current[key]; !ok {
+			rebuilt.Delete(key)
+		}
+	}
+	for key, node := range current {
+		if vec, ok := vecs[key]; ok && sameVector(vec, node.Value) {
+			continue
+		}
+		level, err := rebuilt.randomLevel(key)
+		if err != nil {
+			return fmt.Errorf("rebuilding: %w", err)
+		}
+		if err := rebuilt.insert(node.Node, level); err != nil {
+			return fmt.Errorf("rebuilding: %w", err)
+		}
+	}
+	// Keep the insert times, which Decay ranks by.
+	for _, l := range rebuilt.layers {
+		for key, node := range l.nodes {
+			node.added = current[key].added
+		}
+	}
+
+	g.layers = rebuilt.layers
+	g.Distance = rebuilt.Distance
+	g.M = rebuilt.M
+	g.M0 = rebuilt.M0
+	g.Ml = rebuilt.Ml
+	g.EfSearch = rebuilt.EfSearch
+	g.EfConstruction = rebuilt.EfConstruction
+	g.HashLevels = rebuilt.HashLevels
+	g.NeighborSelection = rebuilt.NeighborSelection
+	g.DuplicateDistance = config.DuplicateDistance
+	g.Duplicates = rebuilt.Duplicates
+	g.Hardening = rebuilt.Hardening
+	g.HitSampling = rebuilt.HitSampling
+	g.Fields = rebuilt.Fields
+	return nil
+}
+
+// rebuildTarget returns the empty graph Rebuild inserts the nodes into,
+// with the parameters of config. The caller must hold the read lock.
+func (g *Graph[K]) rebuildTarget(config GraphConfig) (*Graph[K], error) {
+	if g.next != nil {
+		return nil, fmt.Errorf("migration in progress")
+	}
+	rebuilt, err := newGraphFromConfig[K](config, g.Distance)
+	if err != nil {
+		return nil, err
+	}
+	rebuilt.DisablePooling = true
+	// The nodes are in the graph already, duplicates or not.
+	rebuilt.DuplicateDistance = 0
+	return rebuilt, nil
+}
+
+// sameVector reports whether a and b are the same slice.
+func sameVector(a, b Vector) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
@@ -0,0 +1,92 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Rebuild(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := range 500 {
+		require.NoError(t, g.Add(MakeNode(i, randFloats(8))))
+	}
+	require.NoError(t, g.SetPayload(1, []byte("one")))
+	g.MarkDeleted(2)
+	g.MarkStale(3)
+	vec, _ := g.Lookup(4)
+
+	config := g.Config()
+	config.M, config.EfConstruction = 12, 64
+	require.NoError(t, g.Rebuild(config))
+	require.NoError(t, g.Verify())
+	require.Equal(t, 12, g.Config().M)
+	require.Equal(t, 499, g.Len())
+	require.LessOrEqual(t, g.Stats().Degrees[0].Max, 12)
+	require.Greater(t, g.Stats().Degrees[0].Mean, 6.0)
+
+	payload, ok := g.Payload(1)
+	require.True(t, ok)
+	require.Equal(t, []byte("one"), payload)
+	_, ok = g.Lookup(2)
+	require.False(t, ok)
+	require.True(t, g.IsStale(3))
+	results, err := g.Search(vec, 1)
+	require.NoError(t, err)
+	require.Equal(t, 4, results[0].Key)
+
+	// Nodes already in the graph aren't rejected as duplicates.
+	config.DuplicateDistance = 10
+	require.NoError(t, g.Rebuild(config))
+	require.Equal(t, 499, g.Len())
+	require.Equal(t, float32(10), g.DuplicateDistance)
+	g.DuplicateDistance = 0
+	config.DuplicateDistance = 0
+
+	config.Distance = "nope"
+	require.ErrorContains(t, g.Rebuild(config), "unknown distance function")
+	config.Distance, config.M = "", 0
+	require.ErrorContains(t, g.Rebuild(config), "M must be positive")
+
+	t.Run("ConcurrentWrites", func(t *testing.T) {
+		done := make(chan error)
+		config := g.Config()
+		config.M = 8
+		go func() { done <- g.Rebuild(config) }()
+
+		want := make(map[int]Vector)
+		for i := 0; ; i++ {
+			select {
+			case err := <-done:
+				require.NoError(t, err)
+			default:
+				key := 1000 + i%200
+				if i%3 == 2 {
+					g.Delete(key)
+					delete(want, key)
+				} else {
+					vec := randFloats(8)
+					require.NoError(t, g.Add(MakeNode(key, vec)))
+					want[key] = vec
+				}
+				continue
+			}
+			break
+		}
+
+		// The writes made during the rebuild are in the new links.
+		require.NoError(t, g.Verify())
+		require.Equal(t, 8, g.Config().M)
+		for key := 1000; key < 1200; key++ {
+			vec, ok := g.Lookup(key)
+			require.Equal(t, want[key], vec)
+			require.Equal(t, want[key] != nil, ok)
+			node, linked := g.layers[0].nodes[key]
+			require.Equal(t, ok, linked)
+			if ok {
+				require.Equal(t, vec, node.Value)
+				require.NotEmpty(t, node.neighbors)
+			}
+		}
+	})
+}
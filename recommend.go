@@ -0,0 +1,56 @@
+package hnsw
+
+import (
+	"fmt"
+)
+
+// Recommender answers collaborative-filtering style queries over a graph
+// of item vectors.
+type Recommender[K comparable] struct {
+	Graph *Graph[K]
+
+	// Popularity, if set, returns the popularity of an item, e.g. its
+	// share of all interactions.
+	Popularity func(K) float32
+
+	// PopularityWeight scales the popularity of an item into a penalty
+	// added to its distance, trading relevance for long-tail items.
+	PopularityWeight float32
+}
+
+// SimilarItems returns the k items closest to the given item, excluding
+// the item itself and any item in interacted.
+func (r *Recommender[K]) SimilarItems(item K, k int, interacted map[K]struct{}) ([]SearchResultNode[K], error) {
+	vec, ok := r.Graph.Lookup(item)
+	if !ok {
+		return nil, fmt.Errorf("item %v not found", item)
+	}
+	return r.Graph.SearchWithOptions(vec, k, SearchOptions[K]{
+		Filter: func(key K) bool {
+			if key == item {
+				return false
+			}
+			_, ok := interacted[key]
+			return !ok
+		},
+		Penalty: r.penalty(),
+	})
+}
+
+// RecommendForUser returns the k items closest to a user vector. If
+// filter is set, only items for which it returns true are recommended.
+func (r *Recommender[K]) RecommendForUser(user Vector, k int, filter func(K) bool) ([]SearchResultNode[K], error) {
+	return r.Graph.SearchWithOptions(user, k, SearchOptions[K]{
+		Filter:  filter,
+		Penalty: r.penalty(),
+	})
+}
+
+func (r *Recommender[K]) penalty() func(K) float32 {
+	if r.Popularity == nil || r.PopularityWeight == 0 {
+		return nil
+	}
+	return func(key K) float32 {
+		return r.PopularityWeight * r.Popularity(key)
+	}
+}
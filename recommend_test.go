@@ -0,0 +1,58 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func resultKeys[K comparable](results []SearchResultNode[K]) map[K]bool {
+	keys := make(map[K]bool, len(results))
+	for _, r := range results {
+		keys[r.Key] = true
+	}
+	return keys
+}
+
+func TestRecommender(t *testing.T) {
+	g := newTestGraph[int]()
+	// Keep every node connected to every other node so that results are
+	// exact.
+	g.M = 16
+	for i := 0; i < 16; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+	r := &Recommender[int]{Graph: g}
+
+	t.Run("SimilarItems", func(t *testing.T) {
+		results, err := r.SimilarItems(10, 2, map[int]struct{}{11: {}, 12: {}})
+		require.NoError(t, err)
+		require.Equal(t, map[int]bool{8: true, 9: true}, resultKeys(results))
+
+		_, err = r.SimilarItems(100, 2, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("RecommendForUser", func(t *testing.T) {
+		even := func(key int) bool { return key%2 == 0 }
+		results, err := r.RecommendForUser(Vector{7}, 2, even)
+		require.NoError(t, err)
+		require.Equal(t, map[int]bool{6: true, 8: true}, resultKeys(results))
+	})
+
+	t.Run("Popularity", func(t *testing.T) {
+		r := &Recommender[int]{
+			Graph: g,
+			Popularity: func(key int) float32 {
+				if key == 8 {
+					return 1
+				}
+				return 0
+			},
+			PopularityWeight: 10,
+		}
+		results, err := r.RecommendForUser(Vector{8}, 1, nil)
+		require.NoError(t, err)
+		require.NotEqual(t, 8, results[0].Key)
+	})
+}
@@ -0,0 +1,182 @@
+package hnsw
+
+import (
+	"errors"
+	"fmt"
+)
+
+// quantizationErrorWindow is roughly the number of recent additions
+// averaged by QuantizedStats.RecentError.
+const quantizationErrorWindow = 256
+
+// QuantizedStats reports how well the quantizer of a QuantizedIndex fits
+// the vectors it encodes.
+type QuantizedStats struct {
+	// Codes is the number of codes stored.
+	Codes int
+	// Hot is the number of full vectors kept; see HotSize.
+	Hot int
+
+	// Error is the mean relative quantization error, |v-d|²/|v|² where d
+	// is v decoded from its code, of the vectors added since the index
+	// was created or retrained. After RetrainCodec, it includes the
+	// retraining sample.
+	Error float64
+
+	// RecentError is the same error, averaged over about the last
+	// quantizationErrorWindow vectors added. A RecentError well above
+	// Error means that the data has drifted away from what the quantizer
+	// was trained on, and that it should be retrained.
+	RecentError float64
+}
+
+// quantizationErrors accumulates the errors reported by QuantizedStats.
+type quantizationErrors struct {
+	sum    float64
+	n      int
+	recent float64
+}
+
+func (e *quantizationErrors) add(err float64) {
+	e.sum += err
+	e.n++
+	if e.n == 1 {
+		e.recent = err
+		return
+	}
+	e.recent += (err - e.recent) / float64(min(e.n, quantizationErrorWindow))
+}
+
+// quantizationError returns the squared distance between v and its
+// approximation decoded, relative to the squared norm of v.
+func quantizationError(v, decoded Vector) float64 {
+	if len(v) != len(decoded) {
+		return 1
+	}
+	var diff, norm float64
+	for i, x := range v {
+		d := float64(x - decoded[i])
+		diff += d * d
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		return diff
+	}
+	return diff / norm
+}
+
+// Stats reports the number of codes and hot vectors, and the quantization
+// error of the codes.
+func (q *QuantizedIndex[K]) Stats() QuantizedStats {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	stats := QuantizedStats{Codes: len(q.codes), Hot: q.hot.len(), RecentError: q.errs.recent}
+	if q.errs.n > 0 {
+		stats.Error = q.errs.sum / float64(q.errs.n)
+	}
+	return stats
+}
+
+// codecRetrain tracks the writes made while RetrainCodec re-encodes the
+// codes.
+type codecRetrain[K comparable] struct {
+	// added are the vectors added since the re-encoding started, which
+	// are encoded again once it is done.
+	added map[K]Vector
+}
+
+// RetrainCodec trains next on sample, re-encodes every code with it and
+// makes it the Quantizer, e.g. when Stats shows that the data has drifted
+// away from what the current quantizer was trained on. next is usually a
+// fresh quantizer of the same type; the current one can't be retrained
+// in place while it encodes and searches.
+//
+// Vectors are re-encoded from the hot vectors or Originals when they have
+// them, and from their decoded codes otherwise, which adds the error of
+// the new quantizer to that of the old one. The lock is only held to
+// copy the codes and to swap them at the end: searches and writes
+// proceed in the meantime, against the old quantizer, so RetrainCodec
+// can run in the background. Nodes added meanwhile are encoded again
+// with next before the swap. If RetrainCodec fails, the index is
+// unchanged.
+func (q *QuantizedIndex[K]) RetrainCodec(next Quantizer, sample []Vector) error {
+	if err := next.Train(sample); err != nil {
+		return fmt.Errorf("train: %w", err)
+	}
+	var errs quantizationErrors
+	for _, v := range sample {
+		code, err := next.Encode(v)
+		if err != nil {
+			return fmt.Errorf("encode sample: %w", err)
+		}
+		errs.add(quantizationError(v, next.Decode(code)))
+	}
+
+	q.mu.Lock()
+	if q.retrain != nil {
+		q.mu.Unlock()
+		return errors.New("codec retraining already in progress")
+	}
+	q.retrain = &codecRetrain[K]{added: make(map[K]Vector)}
+	old := q.Quantizer
+	codes := make(map[K][]byte, len(q.codes))
+	for key, code := range q.codes {
+		codes[key] = code
+	}
+	q.mu.Unlock()
+
+	reencoded, err := reencode(codes, old, next, func(key K) (Vector, bool) {
+		if vec, ok := q.hot.peek(key); ok {
+			return vec, true
+		}
+		if q.Originals != nil {
+			return q.Originals(key)
+		}
+		return nil, false
+	})
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	added := q.retrain.added
+	q.retrain = nil
+	if err != nil {
+		return err
+	}
+	for key, v := range added {
+		code, err := next.Encode(v)
+		if err != nil {
+			return fmt.Errorf("encode %v: %w", key, err)
+		}
+		reencoded[key] = code
+	}
+	for key := range reencoded {
+		if _, ok := q.codes[key]; !ok {
+			// Deleted meanwhile.
+			delete(reencoded, key)
+		}
+	}
+
+	q.codes = reencoded
+	q.Quantizer = next
+	q.codec++
+	q.errs = errs
+	return nil
+}
+
+// reencode encodes the vectors behind codes, encoded by old, with next.
+// The vectors come from originals, or else from decoding the codes.
+func reencode[K comparable](codes map[K][]byte, old, next Quantizer, originals func(K) (Vector, bool)) (map[K][]byte, error) {
+	out := make(map[K][]byte, len(codes))
+	for key, code := range codes {
+		v, ok := originals(key)
+		if !ok {
+			v = old.Decode(code)
+		}
+		code, err := next.Encode(v)
+		if err != nil {
+			return nil, fmt.Errorf("encode %v: %w", key, err)
+		}
+		out[key] = code
+	}
+	return out, nil
+}
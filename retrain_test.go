@@ -0,0 +1,92 @@
+package hnsw
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuantizedIndex_RetrainCodec(t *testing.T) {
+	drifted := func() Vector {
+		v := randFloats(16)
+		for i := range v {
+			v[i] = 5 + 5*v[i]
+		}
+		return v
+	}
+	vecs := make(map[int]Vector)
+	sample := make([]Vector, 0, 500)
+	for i := 0; i < 500; i++ {
+		vecs[i] = randFloats(16)
+		sample = append(sample, vecs[i])
+	}
+	sq := &ScalarQuantizer{}
+	require.NoError(t, sq.Train(sample))
+
+	var (
+		once    sync.Once
+		started = make(chan struct{})
+		resume  = make(chan struct{})
+	)
+	idx := &QuantizedIndex[int]{Quantizer: sq}
+	for key, v := range vecs {
+		require.NoError(t, idx.Add(MakeNode(key, v)))
+	}
+	stats := idx.Stats()
+	require.Equal(t, 500, stats.Codes)
+	require.Less(t, stats.Error, 0.001)
+	require.Less(t, stats.RecentError, 0.001)
+
+	// Vectors outside the range the quantizer was trained on are clamped.
+	sample = sample[:0]
+	for i := 500; i < 1000; i++ {
+		vecs[i] = drifted()
+		sample = append(sample, vecs[i])
+		require.NoError(t, idx.Add(MakeNode(i, vecs[i])))
+	}
+	stats = idx.Stats()
+	require.Greater(t, stats.RecentError, 0.5)
+	require.Less(t, stats.Error, stats.RecentError)
+
+	// Write while the codes are re-encoded.
+	idx.Originals = func(key int) (Vector, bool) {
+		once.Do(func() {
+			close(started)
+			<-resume
+		})
+		v, ok := vecs[key]
+		return v, ok
+	}
+	retrained := make(chan error)
+	next := &ScalarQuantizer{}
+	go func() { retrained <- idx.RetrainCodec(next, append(sample, vecs[0])) }()
+	<-started
+	require.ErrorContains(t, idx.RetrainCodec(&ScalarQuantizer{}, sample), "in progress")
+	added := drifted()
+	require.NoError(t, idx.Add(MakeNode(1000, added)))
+	require.True(t, idx.Delete(0))
+	close(resume)
+	require.NoError(t, <-retrained)
+
+	require.Same(t, next, idx.Quantizer)
+	require.Equal(t, 1000, idx.Len())
+	_, ok := idx.Lookup(0)
+	require.False(t, ok)
+	v, ok := idx.Lookup(1000)
+	require.True(t, ok)
+	require.Less(t, quantizationError(added, v), 0.001)
+	v, _ = idx.Lookup(700)
+	require.Less(t, quantizationError(vecs[700], v), 0.001)
+	stats = idx.Stats()
+	require.Equal(t, 1000, stats.Codes)
+	require.Less(t, stats.RecentError, 0.01)
+
+	res, err := idx.Search(vecs[700], 1)
+	require.NoError(t, err)
+	require.Equal(t, 700, res[0].Key)
+
+	// A failed retraining leaves the index as it was.
+	require.Error(t, idx.RetrainCodec(&ScalarQuantizer{}, nil))
+	require.Same(t, next, idx.Quantizer)
+}
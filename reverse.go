@@ -0,0 +1,77 @@
+package hnsw
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+)
+
+// ReverseSearch returns the nodes that have key among their k nearest
+// neighbors, closest to key first, with their distance to it. It helps
+// to judge the influence of a node, e.g. before deleting a hub.
+//
+// Candidates are the nodes linking to key in the base layer and the
+// nodes found by a wide search around it; each candidate is checked
+// with a search of its own. Both steps are approximate, like Search.
+// Finding the linking nodes scans the links of every node, so a call
+// costs O(N·M) for N nodes besides the searches: it suits inspecting a
+// few nodes, not the query path. Nodes marked with MarkDeleted are
+// treated as deleted.
+func (g *Graph[K]) ReverseSearch(key K, k int) ([]SearchResultNode[K], error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	target, err := g.layerNode(key, 0)
+	if err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, fmt.Errorf("k must be positive")
+	}
+
+	toTarget := distanceTo[K](target.Value, g.Distance)
+	wide := max(g.EfSearch, g.M*k)
+	near, err := g.searchScore(context.Background(), toTarget, wide, SearchOptions[K]{})
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make(map[K]*layerNode[K], len(near))
+	for _, r := range near {
+		candidates[r.Key] = g.layers[0].nodes[r.Key]
+	}
+	for nk, node := range g.layers[0].nodes {
+		if node.hasNeighbor(target) && !g.isTombstone(nk) {
+			candidates[nk] = node
+		}
+	}
+	delete(candidates, key)
+
+	var out []SearchResultNode[K]
+	for _, c := range candidates {
+		dist, err := toTarget(c)
+		if err != nil {
+			return nil, err
+		}
+		// c's neighbors: itself and its k nearest others.
+		nn, err := g.searchScore(context.Background(), distanceTo[K](c.Value, g.Distance), k+1, SearchOptions[K]{})
+		if err != nil {
+			return nil, err
+		}
+		var closer int
+		for _, r := range nn {
+			if r.Key != c.Key && r.Key != key && r.Distance < dist {
+				closer++
+			}
+		}
+		if closer < k {
+			out = append(out, SearchResultNode[K]{Node: c.Node, Distance: dist})
+		}
+	}
+	slices.SortFunc(out, func(a, b SearchResultNode[K]) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+	g.attachPayloads(out)
+	return out, nil
+}
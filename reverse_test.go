@@ -0,0 +1,41 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_ReverseSearch(t *testing.T) {
+	g := newTestGraph[int]()
+	g.M = 16
+	// 0, 1, 2 are close together; 10 is an outlier whose nearest
+	// neighbor is 2, but which is nobody's nearest neighbor.
+	for _, x := range []int{0, 1, 2, 10} {
+		g.Add(MakeNode(x, Vector{float32(x)}))
+	}
+
+	results, err := g.ReverseSearch(2, 1)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 10}, keysOf(results))
+	require.Equal(t, float32(8), results[1].Distance)
+
+	results, err = g.ReverseSearch(10, 1)
+	require.NoError(t, err)
+	require.Empty(t, results)
+
+	results, err = g.ReverseSearch(10, 3)
+	require.NoError(t, err)
+	require.Equal(t, []int{2, 1, 0}, keysOf(results))
+
+	_, err = g.ReverseSearch(99, 1)
+	require.Error(t, err)
+
+	// Marked nodes are treated as deleted.
+	g.MarkDeleted(1)
+	results, err = g.ReverseSearch(2, 1)
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 10}, keysOf(results))
+	_, err = g.ReverseSearch(1, 1)
+	require.Error(t, err)
+}
@@ -0,0 +1,184 @@
+package hnsw
+
+import (
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ABRouter splits searches between two indexes, A, the current one, and
+// B, the candidate, e.g. a graph with new parameters or a quantized
+// index, and compares them on live traffic. Writes go to both, so either
+// can take all the traffic at any time; SetPercent(0) rolls back
+// instantly.
+//
+// ABRouter implements Index. Lookup, Len and Export use A. Create it
+// with NewABRouter.
+type ABRouter[K comparable] struct {
+	A, B Index[K]
+
+	// Shadow also runs every search on the index it isn't routed to,
+	// discarding the results, to measure the overlap of the two and
+	// compare their latencies on the same queries. It doubles the cost
+	// of searches.
+	Shadow bool
+
+	mu      sync.Mutex
+	percent float64
+	rng     *rand.Rand
+	report  ABReport
+}
+
+var _ Index[int] = (*ABRouter[int])(nil)
+
+// ABReport compares the two indexes of an ABRouter.
+type ABReport struct {
+	// Searches is the number of searches served by A and B.
+	Searches [2]int
+	// Latency is the total time A and B spent searching, including
+	// shadow searches.
+	Latency [2]time.Duration
+	// Shadowed is the number of searches run on both indexes.
+	Shadowed int
+	// Overlap is the mean fraction of the results of A that B also
+	// returned, over the shadowed searches: overlap@k.
+	Overlap float64
+	// Errors is the number of failed searches on A and B.
+	Errors [2]int
+}
+
+// NewABRouter returns a router sending percent of the searches, between 0
+// and 100, to b and the rest to a.
+func NewABRouter[K comparable](a, b Index[K], percent float64) *ABRouter[K] {
+	r := &ABRouter[K]{A: a, B: b, rng: defaultRand()}
+	r.SetPercent(percent)
+	return r
+}
+
+// SetPercent changes the percentage of searches sent to B. It takes
+// effect with the next search.
+func (r *ABRouter[K]) SetPercent(percent float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.percent = max(0, min(100, percent))
+}
+
+// Report returns the comparison so far.
+func (r *ABRouter[K]) Report() ABReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.report
+}
+
+// Search sends the search to A or B at random, in proportion to the
+// percentage.
+func (r *ABRouter[K]) Search(near Vector, k int) ([]SearchResultNode[K], error) {
+	r.mu.Lock()
+	b := r.rng.Float64()*100 < r.percent
+	r.mu.Unlock()
+	return r.search(b, near, k)
+}
+
+// SearchKey sends the search to A or B by a hash of routingKey, e.g. a
+// user or session ID, so that the same key always sees the same index
+// for a given percentage.
+func (r *ABRouter[K]) SearchKey(routingKey string, near Vector, k int) ([]SearchResultNode[K], error) {
+	h := fnv.New32a()
+	io.WriteString(h, routingKey)
+	bucket := float64(h.Sum32()%10000) / 100
+
+	r.mu.Lock()
+	b := bucket < r.percent
+	r.mu.Unlock()
+	return r.search(b, near, k)
+}
+
+func (r *ABRouter[K]) search(b bool, near Vector, k int) ([]SearchResultNode[K], error) {
+	indexes := [2]Index[K]{r.A, r.B}
+	served := 0
+	if b {
+		served = 1
+	}
+
+	var (
+		results [2][]SearchResultNode[K]
+		errs    [2]error
+		latency [2]time.Duration
+	)
+	run := func(i int) {
+		start := time.Now()
+		results[i], errs[i] = indexes[i].Search(near, k)
+		latency[i] = time.Since(start)
+	}
+	run(served)
+	if r.Shadow {
+		run(1 - served)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Searches[served]++
+	for i := range latency {
+		r.report.Latency[i] += latency[i]
+		if errs[i] != nil {
+			r.report.Errors[i]++
+		}
+	}
+	if r.Shadow && errs[0] == nil && errs[1] == nil {
+		n := r.report.Shadowed
+		r.report.Overlap = (r.report.Overlap*float64(n) + overlap(results[0], results[1])) / float64(n+1)
+		r.report.Shadowed++
+	}
+	return results[served], errs[served]
+}
+
+// overlap returns the fraction of a that is also in b, or 1 if a is
+// empty.
+func overlap[K comparable](a, b []SearchResultNode[K]) float64 {
+	if len(a) == 0 {
+		return 1
+	}
+	inB := make(map[K]bool, len(b))
+	for _, r := range b {
+		inB[r.Key] = true
+	}
+	var n int
+	for _, r := range a {
+		if inB[r.Key] {
+			n++
+		}
+	}
+	return float64(n) / float64(len(a))
+}
+
+// Add inserts nodes into both indexes.
+func (r *ABRouter[K]) Add(nodes ...Node[K]) error {
+	if err := r.A.Add(nodes...); err != nil {
+		return err
+	}
+	return r.B.Add(nodes...)
+}
+
+// Delete removes the node from both indexes and reports whether it
+// existed in A.
+func (r *ABRouter[K]) Delete(key K) bool {
+	r.B.Delete(key)
+	return r.A.Delete(key)
+}
+
+// Lookup returns the vector stored under key in A.
+func (r *ABRouter[K]) Lookup(key K) (Vector, bool) {
+	return r.A.Lookup(key)
+}
+
+// Len returns the number of nodes in A.
+func (r *ABRouter[K]) Len() int {
+	return r.A.Len()
+}
+
+// Export exports A.
+func (r *ABRouter[K]) Export(w io.Writer) error {
+	return r.A.Export(w)
+}
@@ -0,0 +1,74 @@
+package hnsw
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestABRouter(t *testing.T) {
+	// Fixed data and routing make the report exact.
+	rng := rand.New(rand.NewSource(0))
+	vec := func() Vector {
+		v := make(Vector, 4)
+		for i := range v {
+			v[i] = rng.Float32()
+		}
+		return v
+	}
+
+	a := newTestGraph[int]()
+	b := NewBruteForce[int]()
+	b.Distance = EuclideanDistance
+	r := NewABRouter[int](a, b, 25)
+	r.rng = rand.New(rand.NewSource(0))
+	for i := 0; i < 200; i++ {
+		require.NoError(t, r.Add(MakeNode(i, vec())))
+	}
+	require.Equal(t, 200, a.Len())
+	require.Equal(t, 200, b.Len())
+
+	for i := 0; i < 400; i++ {
+		results, err := r.Search(vec(), 5)
+		require.NoError(t, err)
+		require.Len(t, results, 5)
+	}
+	report := r.Report()
+	require.Equal(t, [2]int{307, 93}, report.Searches)
+	require.Zero(t, report.Shadowed)
+
+	// Routing by key is sticky.
+	r.Shadow = true
+	r.SetPercent(50)
+	for _, user := range []string{"user-1", "user-2", "user-3"} {
+		before := r.Report().Searches
+		for i := 0; i < 2; i++ {
+			_, err := r.SearchKey(user, vec(), 5)
+			require.NoError(t, err)
+		}
+		after := r.Report().Searches
+		require.True(t, after[0]-before[0] == 2 || after[1]-before[1] == 2)
+	}
+
+	report = r.Report()
+	require.Equal(t, 6, report.Shadowed)
+	// The graph misses one of the 30 exact neighbors.
+	require.InDelta(t, 29.0/30, report.Overlap, 1e-9)
+	require.Positive(t, report.Latency[0])
+	require.Positive(t, report.Latency[1])
+
+	// Rolling back sends everything to A.
+	r.SetPercent(0)
+	before := r.Report().Searches[1]
+	for i := 0; i < 50; i++ {
+		_, err := r.SearchKey(fmt.Sprint("user-", i), vec(), 5)
+		require.NoError(t, err)
+	}
+	require.Equal(t, before, r.Report().Searches[1])
+
+	require.True(t, r.Delete(3))
+	_, ok := b.Lookup(3)
+	require.False(t, ok)
+}
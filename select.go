@@ -0,0 +1,228 @@
+package hnsw
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type selectionKind int
+
+const (
+	selectSimple selectionKind = iota
+	selectHeuristic
+)
+
+// NeighborSelection decides which of the candidates found for a node
+// become its neighbors, and which neighbor a node drops when it has too
+// many. The zero value is SelectSimple.
+type NeighborSelection struct {
+	kind             selectionKind
+	extendCandidates bool
+	keepPruned       bool
+}
+
+// SelectSimple links a node to its M closest candidates, and drops the
+// farthest neighbor of a node with too many.
+func SelectSimple() NeighborSelection {
+	return NeighborSelection{kind: selectSimple}
+}
+
+// SelectHeuristic is the neighbor selection heuristic of the HNSW paper
+// (Algorithm 4). A candidate is only linked if it is closer to the node
+// than to every neighbor selected before it, so that the neighbors point
+// in different directions instead of into the same cluster. This gives
+// much better connectivity on clustered data, at the cost of wider
+// construction searches.
+//
+// With extendCandidates, the neighbors of the candidates are considered
+// too, which helps on extremely clustered data. With keepPruned, nodes
+// are filled up to M neighbors with the closest rejected candidates.
+//
+// A node with too many neighbors drops the farthest neighbor the
+// heuristic rejects, or the farthest neighbor if it rejects none.
+func SelectHeuristic(extendCandidates, keepPruned bool) NeighborSelection {
+	return NeighborSelection{
+		kind:             selectHeuristic,
+		extendCandidates: extendCandidates,
+		keepPruned:       keepPruned,
+	}
+}
+
+// MarshalText encodes s for GraphConfig: "simple", or "heuristic"
+// followed by "+extend" and "+keep-pruned" for the options set.
+func (s NeighborSelection) MarshalText() ([]byte, error) {
+	switch s.kind {
+	case selectSimple:
+		return []byte("simple"), nil
+	case selectHeuristic:
+		text := "heuristic"
+		if s.extendCandidates {
+			text += "+extend"
+		}
+		if s.keepPruned {
+			text += "+keep-pruned"
+		}
+		return []byte(text), nil
+	}
+	return nil, fmt.Errorf("unknown neighbor selection %d", s.kind)
+}
+
+// UnmarshalText decodes a selection encoded by MarshalText.
+func (s *NeighborSelection) UnmarshalText(text []byte) error {
+	parts := strings.Split(string(text), "+")
+	switch {
+	case len(parts) == 1 && parts[0] == "simple":
+		*s = SelectSimple()
+		return nil
+	case parts[0] == "heuristic":
+		var extend, keepPruned bool
+		for _, option := range parts[1:] {
+			switch option {
+			case "extend":
+				extend = true
+			case "keep-pruned":
+				keepPruned = true
+			default:
+				return fmt.Errorf("unknown neighbor selection option %q", option)
+			}
+		}
+		*s = SelectHeuristic(extend, keepPruned)
+		return nil
+	}
+	return fmt.Errorf("unknown neighbor selection %q", text)
+}
+
+// candidates returns how many candidates insertions search for in the
+// given layer before selecting neighbors among them.
+func (g *Graph[K]) candidates(level int) int {
+	m := g.maxNeighbors(level)
+	if g.NeighborSelection.kind == selectHeuristic {
+		return max(m, g.EfConstruction)
+	}
+	return m
+}
+
+// selectNeighbors picks up to m neighbors of a node with the given key
+// and vector from candidates, which are sorted closest first. The node
+// itself is never selected.
+func (g *Graph[K]) selectNeighbors(key K, vec Vector, candidates []searchCandidate[K], m int) ([]searchCandidate[K], error) {
+	candidates = slices.DeleteFunc(slices.Clone(candidates), func(c searchCandidate[K]) bool {
+		return c.node.Key == key
+	})
+	sel := g.NeighborSelection
+	if sel.kind == selectSimple {
+		return candidates[:min(m, len(candidates))], nil
+	}
+
+	if sel.extendCandidates {
+		seen := make(map[K]bool, len(candidates))
+		for _, c := range candidates {
+			seen[c.node.Key] = true
+		}
+		for _, c := range candidates {
+			for _, e := range liveNeighbors(c.node) {
+				if seen[e.Key] || e.Key == key {
+					continue
+				}
+				seen[e.Key] = true
+				dist, err := g.Distance(vec, e.Value)
+				if err != nil {
+					return nil, err
+				}
+				candidates = append(candidates, searchCandidate[K]{node: e, dist: dist})
+			}
+		}
+		slices.SortStableFunc(candidates, func(a, b searchCandidate[K]) int {
+			return cmp.Compare(a.dist, b.dist)
+		})
+	}
+
+	kept, pruned, err := heuristic(candidates, m, g.Distance)
+	if err != nil {
+		return nil, err
+	}
+	if sel.keepPruned {
+		kept = append(kept, pruned[:min(len(pruned), m-len(kept))]...)
+	}
+	return kept, nil
+}
+
+// heuristic splits candidates, sorted closest first, into up to m that
+// are closer to the target than to every candidate kept before them, and
+// the rest it looked at, in order.
+func heuristic[K comparable](candidates []searchCandidate[K], m int, dist DistanceFunc) (kept, pruned []searchCandidate[K], err error) {
+	for _, c := range candidates {
+		if len(kept) >= m {
+			break
+		}
+		good := true
+		for _, r := range kept {
+			d, err := dist(c.node.Value, r.node.Value)
+			if err != nil {
+				return nil, nil, err
+			}
+			if d < c.dist {
+				good = false
+				break
+			}
+		}
+		if good {
+			kept = append(kept, c)
+		} else {
+			pruned = append(pruned, c)
+		}
+	}
+	return kept, pruned, nil
+}
+
+// link adds b to the neighbors of a, dropping a neighbor of a if it has
+// more than m, as decided by NeighborSelection.
+func (g *Graph[K]) link(a, b *layerNode[K], m int) error {
+	if g.NeighborSelection.kind == selectSimple {
+		return a.addNeighbor(b, m, g.Distance)
+	}
+
+	if a.hasNeighbor(b) {
+		return nil
+	}
+	if a.neighbors == nil {
+		a.neighbors = make([]uint32, 0, m+1)
+	}
+	a.neighbors = append(a.neighbors, b.id)
+	if len(a.neighbors) <= m {
+		return nil
+	}
+
+	neighbors := make([]searchCandidate[K], 0, len(a.neighbors))
+	for _, id := range a.neighbors {
+		n := a.neighbor(id)
+		if n == nil {
+			// Drop a removed neighbor first, like addNeighbor.
+			a.unlink(id)
+			return nil
+		}
+		d, err := g.Distance(n.Value, a.Value)
+		if err != nil {
+			return err
+		}
+		neighbors = append(neighbors, searchCandidate[K]{node: n, dist: d})
+	}
+	slices.SortStableFunc(neighbors, func(a, b searchCandidate[K]) int {
+		return cmp.Compare(a.dist, b.dist)
+	})
+	_, pruned, err := heuristic(neighbors, len(neighbors), g.Distance)
+	if err != nil {
+		return err
+	}
+	worst := neighbors[len(neighbors)-1].node
+	if len(pruned) > 0 {
+		worst = pruned[len(pruned)-1].node
+	}
+
+	a.unlink(worst.id)
+	worst.unlink(a.id)
+	worst.replenish(m, g.Distance)
+	return nil
+}
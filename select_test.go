@@ -0,0 +1,88 @@
+package hnsw
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// clusteredNodes returns n nodes in tight, far apart clusters of size.
+func clusteredNodes(rng *rand.Rand, n, size, dims int) []Node[int] {
+	nodes := make([]Node[int], n)
+	var center Vector
+	for i := range nodes {
+		if i%size == 0 {
+			center = make(Vector, dims)
+			for d := range center {
+				center[d] = rng.Float32() * 100
+			}
+		}
+		vec := make(Vector, dims)
+		for d := range vec {
+			vec[d] = center[d] + rng.Float32()*0.1
+		}
+		nodes[i] = MakeNode(i, vec)
+	}
+	return nodes
+}
+
+func TestGraph_NeighborSelection(t *testing.T) {
+	nodes := clusteredNodes(rand.New(rand.NewSource(0)), 1000, 50, 4)
+
+	build := func(sel NeighborSelection) (components, found int) {
+		g := newTestGraph[int]()
+		g.EfConstruction = 32
+		g.NeighborSelection = sel
+		require.NoError(t, g.Add(nodes...))
+		require.NoError(t, g.Verify())
+
+		stats := g.Stats()
+		require.LessOrEqual(t, stats.Degrees[0].Max, g.M)
+		for _, n := range nodes {
+			results, err := g.Search(n.Value, 1)
+			require.NoError(t, err)
+			if results[0].Key == n.Key {
+				found++
+			}
+		}
+		return stats.Components, found
+	}
+
+	// Linking nodes to their closest candidates only links within
+	// clusters; the heuristic links across them.
+	simpleComponents, simpleFound := build(SelectSimple())
+	for _, sel := range []NeighborSelection{
+		SelectHeuristic(false, false),
+		SelectHeuristic(true, true),
+	} {
+		components, found := build(sel)
+		require.Less(t, components, simpleComponents)
+		require.Greater(t, found, simpleFound)
+		require.GreaterOrEqual(t, found, 990)
+	}
+}
+
+func TestNeighborSelection_Text(t *testing.T) {
+	for _, sel := range []NeighborSelection{
+		SelectSimple(),
+		SelectHeuristic(false, false),
+		SelectHeuristic(true, false),
+		SelectHeuristic(false, true),
+		SelectHeuristic(true, true),
+	} {
+		text, err := sel.MarshalText()
+		require.NoError(t, err)
+		var decoded NeighborSelection
+		require.NoError(t, decoded.UnmarshalText(text))
+		require.Equal(t, sel, decoded, string(text))
+	}
+
+	text, err := SelectHeuristic(true, true).MarshalText()
+	require.NoError(t, err)
+	require.Equal(t, "heuristic+extend+keep-pruned", string(text))
+	var sel NeighborSelection
+	require.Error(t, sel.UnmarshalText([]byte("simple+extend")))
+	require.Error(t, sel.UnmarshalText([]byte("heuristic+")))
+	require.Error(t, sel.UnmarshalText([]byte("random")))
+}
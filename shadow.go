@@ -0,0 +1,298 @@
+package hnsw
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShadowIndex builds a replacement index online: every Add and Delete is
+// applied to the primary index, which serves reads, and then, in the
+// background, to the shadow index, e.g. a graph with new parameters or
+// in a new format. Once the shadow has been backfilled and caught up,
+// Swap makes it the primary.
+//
+// Writes to the shadow don't slow down writes to the primary until
+// MaxPending writes are waiting; Lag reports how far the shadow is
+// behind. ShadowIndex implements Index. Create it with NewShadowIndex and
+// stop it with Close.
+type ShadowIndex[K comparable] struct {
+	// MaxPending bounds the writes queued for the shadow: once that many
+	// are pending, writes wait for the shadow to catch up. Zero means
+	// DefaultShadowPending. Set it before the first write.
+	MaxPending int
+
+	indexes atomic.Pointer[shadowIndexes[K]]
+
+	// writeMu orders writes, so that the shadow applies them in the same
+	// order as the primary.
+	writeMu sync.Mutex
+
+	mu    sync.Mutex
+	cond  *sync.Cond
+	queue []shadowWrite[K]
+	// processed counts every write taken off the queue, for Sync; the
+	// counts reported by Lag start over on Swap and ResetShadow.
+	processed int
+	applied   int
+	errs      int
+	lastErr   error
+	closed    bool
+	done      chan struct{}
+}
+
+var _ Index[int] = (*ShadowIndex[int])(nil)
+
+type shadowIndexes[K comparable] struct {
+	primary, shadow Index[K]
+}
+
+// shadowWrite is a write queued for the shadow index.
+type shadowWrite[K comparable] struct {
+	nodes  []Node[K]
+	delete bool
+	key    K
+	queued time.Time
+}
+
+// ShadowLag reports the progress of the shadow index of a ShadowIndex.
+type ShadowLag struct {
+	// Pending is the number of writes not yet applied to the shadow.
+	Pending int
+	// Behind is how long the oldest pending write has been waiting, or
+	// zero if the shadow has caught up.
+	Behind time.Duration
+	// Applied is the number of writes applied to the shadow.
+	Applied int
+	// Errors is the number of writes that failed on the shadow, and
+	// LastError the last of the errors.
+	Errors    int
+	LastError error
+}
+
+// ErrShadowClosed is returned by writes to a closed ShadowIndex.
+var ErrShadowClosed = errors.New("shadow index closed")
+
+// DefaultShadowPending is the default ShadowIndex.MaxPending.
+const DefaultShadowPending = 1024
+
+// NewShadowIndex returns a ShadowIndex serving reads from primary and
+// mirroring writes to shadow. Nodes already in primary are not copied;
+// add them to shadow first, e.g. with Import.
+func NewShadowIndex[K comparable](primary, shadow Index[K]) *ShadowIndex[K] {
+	s := &ShadowIndex[K]{done: make(chan struct{})}
+	s.cond = sync.NewCond(&s.mu)
+	s.indexes.Store(&shadowIndexes[K]{primary: primary, shadow: shadow})
+	go s.run()
+	return s
+}
+
+// Primary returns the index serving reads.
+func (s *ShadowIndex[K]) Primary() Index[K] {
+	return s.indexes.Load().primary
+}
+
+// Shadow returns the index being built.
+func (s *ShadowIndex[K]) Shadow() Index[K] {
+	return s.indexes.Load().shadow
+}
+
+// run applies the queued writes to the shadow until Close.
+func (s *ShadowIndex[K]) run() {
+	defer close(s.done)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if len(s.queue) == 0 {
+			return
+		}
+		w := s.queue[0]
+		shadow := s.indexes.Load().shadow
+		s.mu.Unlock()
+
+		var err error
+		if w.delete {
+			shadow.Delete(w.key)
+		} else {
+			err = shadow.Add(w.nodes...)
+		}
+
+		s.mu.Lock()
+		s.queue[0] = shadowWrite[K]{}
+		s.queue = s.queue[1:]
+		s.processed++
+		s.applied++
+		if err != nil {
+			s.errs++
+			s.lastErr = err
+		}
+		// Wake Sync.
+		s.cond.Broadcast()
+	}
+}
+
+// enqueue queues a write for the shadow, waiting while the queue is
+// full. The caller must hold writeMu.
+func (s *ShadowIndex[K]) enqueue(w shadowWrite[K]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	maxPending := s.MaxPending
+	if maxPending <= 0 {
+		maxPending = DefaultShadowPending
+	}
+	for len(s.queue) >= maxPending {
+		s.cond.Wait()
+	}
+	w.queued = time.Now()
+	s.queue = append(s.queue, w)
+	s.cond.Broadcast()
+}
+
+// Add inserts nodes into the primary index and, if that succeeds, queues
+// them for the shadow.
+func (s *ShadowIndex[K]) Add(nodes ...Node[K]) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if s.isClosed() {
+		return ErrShadowClosed
+	}
+	if err := s.Primary().Add(nodes...); err != nil {
+		return err
+	}
+	s.enqueue(shadowWrite[K]{nodes: append([]Node[K](nil), nodes...)})
+	return nil
+}
+
+// Delete removes the node from the primary index, queues its removal
+// from the shadow, and reports whether it existed in the primary.
+// It does nothing once the ShadowIndex is closed.
+func (s *ShadowIndex[K]) Delete(key K) bool {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if s.isClosed() {
+		return false
+	}
+	ok := s.Primary().Delete(key)
+	s.enqueue(shadowWrite[K]{delete: true, key: key})
+	return ok
+}
+
+func (s *ShadowIndex[K]) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// Lag reports how far the shadow is behind the primary.
+func (s *ShadowIndex[K]) Lag() ShadowLag {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lag := ShadowLag{
+		Pending:   len(s.queue),
+		Applied:   s.applied,
+		Errors:    s.errs,
+		LastError: s.lastErr,
+	}
+	if len(s.queue) > 0 {
+		lag.Behind = time.Since(s.queue[0].queued)
+	}
+	return lag
+}
+
+// Sync waits until the writes made so far have been applied to the
+// shadow.
+func (s *ShadowIndex[K]) Sync() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	target := s.processed + len(s.queue)
+	for s.processed < target {
+		s.cond.Wait()
+	}
+}
+
+// Swap makes the shadow the primary and the primary the shadow, so that
+// it keeps receiving writes and a swap can be undone. Writes are held
+// while the shadow catches up, then the indexes are swapped atomically:
+// every read sees either the old primary or the new one, with all the
+// writes before the swap.
+//
+// Swap fails if any write failed on the shadow, which is then missing
+// data; see ResetShadow. The counts reported by Lag start over with the
+// new shadow.
+func (s *ShadowIndex[K]) Swap() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.Sync()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.errs > 0 {
+		return fmt.Errorf("%d writes failed on the shadow, last: %w", s.errs, s.lastErr)
+	}
+	indexes := s.indexes.Load()
+	s.indexes.Store(&shadowIndexes[K]{primary: indexes.shadow, shadow: indexes.primary})
+	s.applied, s.errs, s.lastErr = 0, 0, nil
+	return nil
+}
+
+// ResetShadow replaces the shadow with shadow, e.g. after writes failed
+// on the old one, and starts the counts reported by Lag over. Writes are
+// held while the pending ones are applied to the old shadow and while
+// backfill, if not nil, copies the primary into the new one, so that the
+// new shadow misses none of them. If backfill fails, the old shadow is
+// kept.
+func (s *ShadowIndex[K]) ResetShadow(shadow Index[K], backfill func(primary, shadow Index[K]) error) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.Sync()
+
+	primary := s.Primary()
+	if backfill != nil {
+		if err := backfill(primary, shadow); err != nil {
+			return fmt.Errorf("backfill: %w", err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.indexes.Store(&shadowIndexes[K]{primary: primary, shadow: shadow})
+	s.applied, s.errs, s.lastErr = 0, 0, nil
+	return nil
+}
+
+// Close applies the pending writes to the shadow and stops mirroring.
+// Reads keep working; writes fail.
+func (s *ShadowIndex[K]) Close() {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.mu.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	<-s.done
+}
+
+// Search searches the primary index.
+func (s *ShadowIndex[K]) Search(near Vector, k int) ([]SearchResultNode[K], error) {
+	return s.Primary().Search(near, k)
+}
+
+// Lookup looks key up in the primary index.
+func (s *ShadowIndex[K]) Lookup(key K) (Vector, bool) {
+	return s.Primary().Lookup(key)
+}
+
+// Len returns the number of nodes in the primary index.
+func (s *ShadowIndex[K]) Len() int {
+	return s.Primary().Len()
+}
+
+// Export exports the primary index.
+func (s *ShadowIndex[K]) Export(w io.Writer) error {
+	return s.Primary().Export(w)
+}
@@ -0,0 +1,109 @@
+package hnsw
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShadowIndex(t *testing.T) {
+	primary := newTestGraph[int]()
+	shadow := newTestGraph[int]()
+	shadow.M = 12
+	s := NewShadowIndex[int](primary, shadow)
+	defer s.Close()
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, s.Add(MakeNode(i, randFloats(4))))
+	}
+	require.True(t, s.Delete(5))
+	require.Equal(t, 99, s.Len())
+
+	s.Sync()
+	lag := s.Lag()
+	require.Zero(t, lag.Pending)
+	require.Zero(t, lag.Behind)
+	require.Equal(t, 101, lag.Applied)
+	require.Zero(t, lag.Errors)
+	require.Equal(t, 99, shadow.Len())
+
+	require.NoError(t, s.Swap())
+	require.Same(t, shadow, s.Primary())
+	require.Same(t, primary, s.Shadow())
+	require.Zero(t, s.Lag().Applied)
+
+	// The old primary keeps receiving writes, so the swap can be undone.
+	require.NoError(t, s.Add(MakeNode(100, randFloats(4))))
+	require.NoError(t, s.Swap())
+	require.Same(t, primary, s.Primary())
+	_, ok := primary.Lookup(100)
+	require.True(t, ok)
+
+	// A failed write on the shadow blocks the swap.
+	bad := newTestGraph[int]()
+	require.NoError(t, bad.Add(MakeNode(0, randFloats(3))))
+	s2 := NewShadowIndex[int](primary, bad)
+	require.NoError(t, s2.Add(MakeNode(300, randFloats(4))))
+	s2.Sync()
+	lag = s2.Lag()
+	require.Equal(t, 1, lag.Errors)
+	require.Error(t, lag.LastError)
+	require.Error(t, s2.Swap())
+	require.Same(t, primary, s2.Primary())
+
+	// A new shadow, backfilled from the primary, clears the errors.
+	fresh := newTestGraph[int]()
+	require.NoError(t, s2.ResetShadow(fresh, func(primary, shadow Index[int]) error {
+		var buf bytes.Buffer
+		if err := primary.Export(&buf); err != nil {
+			return err
+		}
+		return shadow.(*Graph[int]).Import(&buf)
+	}))
+	require.Zero(t, s2.Lag().Errors)
+	require.NoError(t, s2.Swap())
+	require.Same(t, fresh, s2.Primary())
+	_, ok = s2.Lookup(300)
+	require.True(t, ok)
+
+	s2.Close()
+	require.ErrorIs(t, s2.Add(MakeNode(301, randFloats(4))), ErrShadowClosed)
+	_, ok = s2.Lookup(300)
+	require.True(t, ok)
+}
+
+// blockingIndex holds every Add until release is closed.
+type blockingIndex struct {
+	*Graph[int]
+	release chan struct{}
+}
+
+func (b blockingIndex) Add(nodes ...Node[int]) error {
+	<-b.release
+	return b.Graph.Add(nodes...)
+}
+
+func TestShadowIndex_MaxPending(t *testing.T) {
+	shadow := blockingIndex{newTestGraph[int](), make(chan struct{})}
+	s := NewShadowIndex[int](newTestGraph[int](), shadow)
+	s.MaxPending = 2
+	defer s.Close()
+
+	require.NoError(t, s.Add(MakeNode(0, randFloats(4))))
+	require.NoError(t, s.Add(MakeNode(1, randFloats(4))))
+	added := make(chan error)
+	go func() { added <- s.Add(MakeNode(2, randFloats(4))) }()
+	select {
+	case <-added:
+		t.Fatal("write didn't wait for the shadow")
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.Equal(t, 2, s.Lag().Pending)
+
+	close(shadow.release)
+	require.NoError(t, <-added)
+	s.Sync()
+	require.Equal(t, 3, shadow.Len())
+}
@@ -0,0 +1,113 @@
+package hnsw
+
+import (
+	"math"
+)
+
+// sketchNormBuckets is the number of buckets of EmbeddingStats.NormHistogram.
+// They are a quarter of an octave wide and centered on norm 1, covering
+// norms from 1/256 to 256.
+const sketchNormBuckets = 64
+
+// NormBucketBound returns the lower bound of bucket i of
+// EmbeddingStats.NormHistogram. Bucket 0 also counts smaller norms, and
+// the last bucket larger ones.
+func NormBucketBound(i int) float32 {
+	return float32(math.Exp2(float64(i-sketchNormBuckets/2) / 4))
+}
+
+// EmbeddingStats summarizes the distribution of the vectors in a graph.
+// Comparing it over time reveals drift in the embeddings fed to the
+// graph, e.g. after an upstream model change, without exporting them.
+type EmbeddingStats struct {
+	// Count is the number of vectors summarized.
+	Count int
+
+	// Mean and Variance are the mean and variance of each dimension.
+	Mean, Variance []float32
+
+	// NormHistogram counts the vectors by their Euclidean norm. Bucket i
+	// holds the norms from NormBucketBound(i) up to NormBucketBound(i+1).
+	NormHistogram []int
+}
+
+// embeddingSketch maintains EmbeddingStats as vectors come and go, in
+// constant time per vector and dimension.
+type embeddingSketch struct {
+	count      int
+	sum, sumSq []float64
+	norms      [sketchNormBuckets]int
+}
+
+func (s *embeddingSketch) add(vec Vector, sign int) {
+	if s.sum == nil {
+		s.sum = make([]float64, len(vec))
+		s.sumSq = make([]float64, len(vec))
+	}
+	var norm float64
+	for d, x := range vec {
+		x := float64(x)
+		s.sum[d] += float64(sign) * x
+		s.sumSq[d] += float64(sign) * x * x
+		norm += x * x
+	}
+	s.count += sign
+	s.norms[normBucket(math.Sqrt(norm))] += sign
+	if s.count == 0 {
+		// Start over, shedding accumulated rounding errors.
+		*s = embeddingSketch{}
+	}
+}
+
+// normBucket returns the bucket of NormHistogram that norm falls into.
+func normBucket(norm float64) int {
+	switch {
+	case !(norm > 0):
+		// Zero or NaN.
+		return 0
+	case math.IsInf(norm, 1):
+		return sketchNormBuckets - 1
+	}
+	b := int(math.Floor(4*math.Log2(norm))) + sketchNormBuckets/2
+	return max(0, min(sketchNormBuckets-1, b))
+}
+
+// insert adds vec to the sketch.
+func (s *embeddingSketch) insert(vec Vector) {
+	s.add(vec, 1)
+}
+
+// remove removes vec, which must have been inserted, from the sketch.
+func (s *embeddingSketch) remove(vec Vector) {
+	s.add(vec, -1)
+}
+
+// rebuild replaces the sketch with one of the vectors in base.
+func rebuildSketch[K comparable](base *layer[K]) embeddingSketch {
+	var s embeddingSketch
+	if base != nil {
+		for _, node := range base.nodes {
+			s.insert(node.Value)
+		}
+	}
+	return s
+}
+
+func (s *embeddingSketch) stats() EmbeddingStats {
+	stats := EmbeddingStats{
+		Count:         s.count,
+		NormHistogram: append([]int(nil), s.norms[:]...),
+	}
+	if s.count == 0 {
+		return stats
+	}
+	n := float64(s.count)
+	stats.Mean = make([]float32, len(s.sum))
+	stats.Variance = make([]float32, len(s.sum))
+	for d := range s.sum {
+		mean := s.sum[d] / n
+		stats.Mean[d] = float32(mean)
+		stats.Variance[d] = float32(max(0, s.sumSq[d]/n-mean*mean))
+	}
+	return stats
+}
@@ -0,0 +1,193 @@
+package hnsw
+
+import (
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// SnapshotGraph separates readers from a writer: writes go to a private
+// graph, and searches run on an immutable snapshot of it, published with
+// Publish. Readers never wait for the writer, so e.g. a background
+// reindex doesn't affect query latency, and a batch of writes becomes
+// visible all at once.
+//
+// Publishing copies the graph, which takes time and memory proportional
+// to its size; publish after batches of writes rather than after each.
+// Vectors and payloads are shared between the copies, not copied, so
+// they must not be modified after they are inserted.
+//
+// SnapshotGraph implements Index: Add and Delete go to the writer,
+// the other methods use the snapshot.
+type SnapshotGraph[K comparable] struct {
+	// writeMu serializes Publish with writes.
+	writeMu sync.Mutex
+	writer  *Graph[K]
+	current atomic.Pointer[Graph[K]]
+}
+
+var _ Index[int] = (*SnapshotGraph[int])(nil)
+
+// NewSnapshotGraph returns a SnapshotGraph writing to g, which it takes
+// over, with g as it is as the first snapshot.
+func NewSnapshotGraph[K comparable](g *Graph[K]) *SnapshotGraph[K] {
+	s := &SnapshotGraph[K]{writer: g}
+	s.Publish()
+	return s
+}
+
+// Publish makes the writes so far visible to readers. Searches running
+// on the previous snapshot finish on it.
+func (s *SnapshotGraph[K]) Publish() {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.writer.mu.RLock()
+	defer s.writer.mu.RUnlock()
+	s.current.Store(s.writer.clone())
+}
+
+// Snapshot returns the current snapshot. It must not be modified.
+func (s *SnapshotGraph[K]) Snapshot() *Graph[K] {
+	return s.current.Load()
+}
+
+// Update calls fn with the writer graph, e.g. to use methods not covered
+// by Index. The changes become visible with the next Publish.
+func (s *SnapshotGraph[K]) Update(fn func(g *Graph[K]) error) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return fn(s.writer)
+}
+
+// Add inserts nodes into the writer graph.
+func (s *SnapshotGraph[K]) Add(nodes ...Node[K]) error {
+	return s.Update(func(g *Graph[K]) error { return g.Add(nodes...) })
+}
+
+// Delete removes the node from the writer graph and reports whether it
+// existed there.
+func (s *SnapshotGraph[K]) Delete(key K) bool {
+	var ok bool
+	s.Update(func(g *Graph[K]) error {
+		ok = g.Delete(key)
+		return nil
+	})
+	return ok
+}
+
+// Search searches the current snapshot.
+func (s *SnapshotGraph[K]) Search(near Vector, k int) ([]SearchResultNode[K], error) {
+	return s.Snapshot().Search(near, k)
+}
+
+// Lookup looks key up in the current snapshot.
+func (s *SnapshotGraph[K]) Lookup(key K) (Vector, bool) {
+	return s.Snapshot().Lookup(key)
+}
+
+// Len returns the number of nodes in the current snapshot.
+func (s *SnapshotGraph[K]) Len() int {
+	return s.Snapshot().Len()
+}
+
+// Export exports the current snapshot.
+func (s *SnapshotGraph[K]) Export(w io.Writer) error {
+	return s.Snapshot().Export(w)
+}
+
+// Clone returns an independent copy of the graph: its parameters, nodes,
+// links and payloads, down to the vectors. Subscriptions, hit counts and
+// a migration in progress are not copied, and the copy gets a new Rng
+// and its own Hardening random source.
+func (g *Graph[K]) Clone() *Graph[K] {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	c := g.clone()
+	if len(c.layers) > 0 {
+		// The layers share each node's vector.
+		vecs := make(map[K]Vector, len(c.layers[0].nodes))
+		for key, node := range c.layers[0].nodes {
+			vecs[key] = slices.Clone(node.Value)
+		}
+		for _, l := range c.layers {
+			for key, node := range l.nodes {
+				node.Value = vecs[key]
+			}
+		}
+	}
+	for key, payload := range c.payloads {
+		c.payloads[key] = slices.Clone(payload)
+	}
+	return c
+}
+
+// clone returns a copy of the graph's parameters and nodes that shares
+// nothing mutable with it. Vectors and payloads are shared: no method
+// writes to them in place, Erase included, so the copy only sees them
+// change if the caller modifies the slices it inserted. Hardening is
+// copied with a fresh random source. Subscriptions, hit counts, a
+// migration in progress and Rng are not copied. The caller must hold the
+// read lock.
+func (g *Graph[K]) clone() *Graph[K] {
+	c := &Graph[K]{
+		Distance:          g.Distance,
+		M:                 g.M,
+		M0:                g.M0,
+		Ml:                g.Ml,
+		EfSearch:          g.EfSearch,
+		EfConstruction:    g.EfConstruction,
+		HitSampling:       g.HitSampling,
+		HashLevels:        g.HashLevels,
+		DisablePooling:    g.DisablePooling,
+		Hardening:         g.Hardening.copy(),
+		DuplicateDistance: g.DuplicateDistance,
+		Duplicates:        g.Duplicates,
+		NeighborSelection: g.NeighborSelection,
+		Fields:            slices.Clone(g.Fields),
+		stale:             maps.Clone(g.stale),
+		tombstones:        maps.Clone(g.tombstones),
+		now:               g.now,
+		payloads:          maps.Clone(g.payloads),
+		sketch: embeddingSketch{
+			count: g.sketch.count,
+			sum:   slices.Clone(g.sketch.sum),
+			sumSq: slices.Clone(g.sketch.sumSq),
+			norms: g.sketch.norms,
+		},
+		version: g.version,
+	}
+	c.layers = make([]*layer[K], len(g.layers))
+	for i, l := range g.layers {
+		// The nodes keep their IDs, so the neighbor lists carry over.
+		cl := &layer[K]{
+			nodes: make(map[K]*layerNode[K], len(l.nodes)),
+			byID:  make([]*layerNode[K], len(l.byID)),
+			free:  slices.Clone(l.free),
+		}
+		for id, n := range l.byID {
+			if n != nil {
+				copied := &layerNode[K]{Node: n.Node, layer: cl, id: n.id, added: n.added}
+				cl.byID[id] = copied
+				cl.nodes[n.Key] = copied
+			}
+		}
+		for id, n := range l.byID {
+			if n == nil {
+				continue
+			}
+			neighbors := make([]uint32, 0, len(n.neighbors))
+			for _, nid := range n.neighbors {
+				// Links to removed nodes are dropped.
+				if l.byID[nid] != nil {
+					neighbors = append(neighbors, nid)
+				}
+			}
+			cl.byID[id].neighbors = neighbors
+		}
+		c.layers[i] = cl
+	}
+	return c
+}
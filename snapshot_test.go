@@ -0,0 +1,140 @@
+package hnsw
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotGraph(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, g.Add(MakeNode(i, Vector{float32(i)})))
+	}
+	require.NoError(t, g.SetPayload(1, []byte("one")))
+	s := NewSnapshotGraph(g)
+	require.Equal(t, 100, s.Len())
+
+	// Writes are invisible until published.
+	require.NoError(t, s.Add(MakeNode(1000, Vector{1000})))
+	require.True(t, s.Delete(50))
+	require.Equal(t, 100, s.Len())
+	_, ok := s.Lookup(1000)
+	require.False(t, ok)
+
+	old := s.Snapshot()
+	s.Publish()
+	require.Equal(t, 100, s.Len())
+	_, ok = s.Lookup(1000)
+	require.True(t, ok)
+	results, err := s.Search(Vector{50}, 1)
+	require.NoError(t, err)
+	require.NotEqual(t, 50, results[0].Key)
+	payload, ok := s.Snapshot().Payload(1)
+	require.True(t, ok)
+	require.Equal(t, []byte("one"), payload)
+
+	// Old snapshots stay intact while the writer changes.
+	require.NoError(t, s.Update(func(g *Graph[int]) error {
+		for i := 0; i < 100; i += 2 {
+			g.Delete(i)
+		}
+		return nil
+	}))
+	require.NoError(t, old.Verify())
+	require.NoError(t, s.Snapshot().Verify())
+	require.Equal(t, 100, old.Len())
+	results, err = old.Search(Vector{50}, 1)
+	require.NoError(t, err)
+	require.Equal(t, 50, results[0].Key)
+}
+
+func TestGraph_Clone(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, g.Add(MakeNode(i, randFloats(4))))
+	}
+	require.NoError(t, g.SetPayload(1, []byte("one")))
+
+	c := g.Clone()
+	require.NoError(t, c.Verify())
+	require.Equal(t, g.Len(), c.Len())
+	require.Equal(t, g.Config(), c.Config())
+	query := randFloats(4)
+	want, err := g.Search(query, 5)
+	require.NoError(t, err)
+	got, err := c.Search(query, 5)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Changes to either don't affect the other.
+	vec, _ := g.Lookup(2)
+	vec[0] = 42
+	payload, _ := g.Payload(1)
+	payload[0] = 'x'
+	g.Delete(3)
+	require.NoError(t, c.Add(MakeNode(1000, randFloats(4))))
+
+	cvec, _ := c.Lookup(2)
+	require.NotEqual(t, float32(42), cvec[0])
+	cpayload, _ := c.Payload(1)
+	require.Equal(t, []byte("one"), cpayload)
+	_, ok := c.Lookup(3)
+	require.True(t, ok)
+	_, ok = g.Lookup(1000)
+	require.False(t, ok)
+	require.NoError(t, g.Verify())
+	require.NoError(t, c.Verify())
+}
+
+func TestGraph_CloneHardened(t *testing.T) {
+	// The clone draws from its own random source, so both graphs can be
+	// written to at once. Run with -race.
+	g := newTestGraph[int]()
+	g.Hardening = &Hardening{Jitter: 0.05, Seed: 1}
+	for i := 0; i < 50; i++ {
+		require.NoError(t, g.Add(MakeNode(i, randFloats(4))))
+	}
+	c := g.Clone()
+	require.NotSame(t, g.Hardening, c.Hardening)
+	require.Equal(t, *g.Hardening.copy(), *c.Hardening)
+
+	var wg sync.WaitGroup
+	for _, graph := range []*Graph[int]{g, c} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 50; i < 150; i++ {
+				require.NoError(t, graph.Add(MakeNode(i, randFloats(4))))
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, g.Verify())
+	require.NoError(t, c.Verify())
+}
+
+func TestSnapshotGraph_Hardened(t *testing.T) {
+	g := newTestGraph[int]()
+	g.Hardening = &Hardening{Jitter: 0.05, Seed: 1}
+	for i := 0; i < 50; i++ {
+		require.NoError(t, g.Add(MakeNode(i, randFloats(4))))
+	}
+	s := NewSnapshotGraph(g)
+	snap := s.Snapshot()
+	require.NotSame(t, g.Hardening, snap.Hardening)
+
+	// Hardened writes draw from the writer's source only.
+	for i := 50; i < 100; i++ {
+		require.NoError(t, s.Add(MakeNode(i, randFloats(4))))
+	}
+	require.NotNil(t, g.Hardening.rng)
+	require.Nil(t, snap.Hardening.rng)
+	require.Equal(t, 50, snap.Len())
+	require.NoError(t, snap.Verify())
+
+	s.Publish()
+	require.Nil(t, s.Snapshot().Hardening.rng)
+	require.Equal(t, 100, s.Len())
+}
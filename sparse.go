@@ -0,0 +1,153 @@
+package hnsw
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/chewxy/math32"
+)
+
+// SparseVector is a vector given by its non-zero values, e.g. the term
+// weights of learned sparse models like SPLADE or of BM25. Values[i] is
+// the value of dimension Indices[i].
+type SparseVector struct {
+	Indices []uint32
+	Values  []float32
+}
+
+// maxSparseIndex bounds the dimensions of sparse vectors, which are
+// stored as float32s, exact up to 2^24. Larger vocabularies can be
+// hashed into the range.
+const maxSparseIndex = 1 << 24
+
+// Prune returns v with only its nonZero values largest in magnitude,
+// which is how learned sparse vectors are usually pruned, and the number
+// of values it dropped.
+func (v SparseVector) Prune(nonZero int) (SparseVector, int) {
+	if nonZero < 0 || len(v.Values) <= nonZero || len(v.Indices) != len(v.Values) {
+		return v, 0
+	}
+	order := make([]int, len(v.Values))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(math32.Abs(v.Values[b]), math32.Abs(v.Values[a]))
+	})
+	var pruned SparseVector
+	for _, i := range order[:nonZero] {
+		pruned.Indices = append(pruned.Indices, v.Indices[i])
+		pruned.Values = append(pruned.Values, v.Values[i])
+	}
+	return pruned, len(v.Values) - nonZero
+}
+
+// Encode packs v into a dense Vector of 2*nonZero values, so that sparse
+// vectors can be stored and searched in a Graph with
+// SparseDotProductDistance. All the vectors of a graph must be encoded
+// with the same nonZero. Encode fails if v has more than nonZero values;
+// use Prune to drop the smallest ones first.
+//
+// The first half of the encoding holds the indices in increasing order,
+// the second half their values; unused slots have index -1 and value 0.
+func (v SparseVector) Encode(nonZero int) (Vector, error) {
+	if len(v.Indices) != len(v.Values) {
+		return nil, fmt.Errorf("sparse vector has %d indices and %d values", len(v.Indices), len(v.Values))
+	}
+	if nonZero <= 0 {
+		return nil, fmt.Errorf("nonZero must be positive")
+	}
+	if len(v.Values) > nonZero {
+		return nil, fmt.Errorf("sparse vector has %d values, more than %d", len(v.Values), nonZero)
+	}
+
+	order := make([]int, len(v.Indices))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return cmp.Compare(v.Indices[a], v.Indices[b])
+	})
+
+	vec := make(Vector, 2*nonZero)
+	for i := range nonZero {
+		vec[i] = -1
+	}
+	for i, j := range order {
+		index := v.Indices[j]
+		if index >= maxSparseIndex {
+			return nil, fmt.Errorf("sparse index %d out of range", index)
+		}
+		if i > 0 && vec[i-1] == float32(index) {
+			return nil, fmt.Errorf("duplicate sparse index %d", index)
+		}
+		vec[i] = float32(index)
+		vec[nonZero+i] = v.Values[j]
+	}
+	return vec, nil
+}
+
+// DecodeSparse returns the sparse vector encoded in vec by
+// SparseVector.Encode.
+func DecodeSparse(vec Vector) (SparseVector, error) {
+	if len(vec)%2 != 0 {
+		return SparseVector{}, fmt.Errorf("sparse encoding has odd length %d", len(vec))
+	}
+	nonZero := len(vec) / 2
+	var v SparseVector
+	for i, index := range vec[:nonZero] {
+		if index < 0 {
+			break
+		}
+		v.Indices = append(v.Indices, uint32(index))
+		v.Values = append(v.Values, vec[nonZero+i])
+	}
+	return v, nil
+}
+
+// MakeSparseNode returns a node holding v encoded with
+// SparseVector.Encode. It fails if v has more than nonZero values.
+func MakeSparseNode[K comparable](key K, v SparseVector, nonZero int) (Node[K], error) {
+	vec, err := v.Encode(nonZero)
+	if err != nil {
+		return Node[K]{}, fmt.Errorf("%v: %w", key, err)
+	}
+	return MakeNode(key, vec), nil
+}
+
+// SparseDotProductDistance computes the negative inner product of two
+// sparse vectors encoded with SparseVector.Encode, like
+// DotProductDistance does for dense vectors, in time proportional to
+// their number of values. The encodings may have different lengths, so
+// queries can keep more values than the stored vectors.
+//
+// It is registered as "sparse-dot", so graphs using it can be exported
+// and imported.
+func SparseDotProductDistance(a, b []float32) (float32, error) {
+	if len(a)%2 != 0 || len(b)%2 != 0 {
+		return 0, fmt.Errorf("sparse encodings have odd lengths %d and %d", len(a), len(b))
+	}
+	na, nb := len(a)/2, len(b)/2
+	var dot float32
+	// Merge the sorted indices.
+	for i, j := 0, 0; i < na && j < nb && a[i] >= 0 && b[j] >= 0; {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			dot += a[na+i] * b[nb+j]
+			i++
+			j++
+		}
+	}
+	return -dot, nil
+}
+
+// isSparseDistance reports whether fn is SparseDotProductDistance.
+func isSparseDistance(fn DistanceFunc) bool {
+	return fn != nil && reflect.ValueOf(fn).Pointer() == reflect.ValueOf(SparseDotProductDistance).Pointer()
+}
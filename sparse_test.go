@@ -0,0 +1,120 @@
+package hnsw
+
+import (
+	"bytes"
+	"cmp"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSparseVector_Encode(t *testing.T) {
+	v := SparseVector{Indices: []uint32{30000, 7, 512}, Values: []float32{0.5, -2, 1}}
+	vec, err := v.Encode(4)
+	require.NoError(t, err)
+	require.Equal(t, Vector{7, 512, 30000, -1, -2, 1, 0.5, 0}, vec)
+	decoded, err := DecodeSparse(vec)
+	require.NoError(t, err)
+	require.Equal(t, SparseVector{Indices: []uint32{7, 512, 30000}, Values: []float32{-2, 1, 0.5}}, decoded)
+
+	// Values don't get dropped silently.
+	_, err = v.Encode(2)
+	require.ErrorContains(t, err, "more than 2")
+
+	// Pruning keeps the largest values in magnitude.
+	pruned, dropped := v.Prune(2)
+	require.Equal(t, 1, dropped)
+	vec, err = pruned.Encode(2)
+	require.NoError(t, err)
+	require.Equal(t, Vector{7, 512, -2, 1}, vec)
+	pruned, dropped = v.Prune(3)
+	require.Zero(t, dropped)
+	require.Equal(t, v, pruned)
+
+	_, err = SparseVector{Indices: []uint32{1, 1}, Values: []float32{1, 2}}.Encode(2)
+	require.ErrorContains(t, err, "duplicate")
+	_, err = SparseVector{Indices: []uint32{1 << 24}, Values: []float32{1}}.Encode(2)
+	require.ErrorContains(t, err, "out of range")
+	_, err = SparseVector{Indices: []uint32{1}}.Encode(2)
+	require.Error(t, err)
+
+	// Encodings of different widths can be compared.
+	q, err := SparseVector{Indices: []uint32{512, 7, 9}, Values: []float32{3, 1, 5}}.Encode(8)
+	require.NoError(t, err)
+	vec, err = v.Encode(4)
+	require.NoError(t, err)
+	dist, err := SparseDotProductDistance(vec, q)
+	require.NoError(t, err)
+	require.Equal(t, float32(-(3 - 2)), dist)
+}
+
+func TestGraph_Sparse(t *testing.T) {
+	const (
+		vocab   = 1000
+		nonZero = 16
+	)
+	rng := rand.New(rand.NewSource(0))
+	randSparse := func() SparseVector {
+		var v SparseVector
+		for _, index := range rng.Perm(vocab)[:nonZero] {
+			v.Indices = append(v.Indices, uint32(index))
+			v.Values = append(v.Values, rng.Float32())
+		}
+		return v
+	}
+
+	g := NewGraph[int]()
+	g.Distance = SparseDotProductDistance
+	g.EfSearch = 64
+	docs := make([]Vector, 500)
+	for i := range docs {
+		node, err := MakeSparseNode(i, randSparse(), nonZero)
+		require.NoError(t, err)
+		require.NoError(t, g.Add(node))
+		docs[i] = node.Value
+	}
+
+	// The results mostly agree with an exhaustive search.
+	var found int
+	for range 20 {
+		q, err := randSparse().Encode(nonZero)
+		require.NoError(t, err)
+		results, err := g.Search(q, 10)
+		require.NoError(t, err)
+
+		exact := make([]int, len(docs))
+		for i := range exact {
+			exact[i] = i
+		}
+		slices.SortFunc(exact, func(a, b int) int {
+			da, _ := SparseDotProductDistance(docs[a], q)
+			db, _ := SparseDotProductDistance(docs[b], q)
+			return cmp.Compare(da, db)
+		})
+		for _, r := range results {
+			if slices.Contains(exact[:10], r.Key) {
+				found++
+			}
+		}
+	}
+	require.Greater(t, found, 150)
+
+	// Queries may keep more values than the stored vectors.
+	q, err := randSparse().Encode(2 * nonZero)
+	require.NoError(t, err)
+	batch, err := g.BatchSearch([]Vector{q}, 10)
+	require.NoError(t, err)
+	results, err := g.Search(q, 10)
+	require.NoError(t, err)
+	require.Equal(t, results, batch[0])
+	_, err = g.BatchSearch([]Vector{q[1:]}, 10)
+	require.ErrorContains(t, err, "odd length")
+
+	var buf bytes.Buffer
+	require.NoError(t, g.Export(&buf))
+	g2 := NewGraph[int]()
+	require.NoError(t, g2.Import(&buf))
+	require.Equal(t, "sparse-dot", g2.Config().Distance)
+}
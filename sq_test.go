@@ -0,0 +1,87 @@
+package hnsw
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/chewxy/math32"
+	"github.com/stretchr/testify/require"
+)
+
+// unitFloats returns a random unit vector of n dimensions.
+func unitFloats(n int) Vector {
+	v := make(Vector, n)
+	var norm float32
+	for i := range v {
+		v[i] = rand.Float32()*2 - 1
+		norm += v[i] * v[i]
+	}
+	norm = math32.Sqrt(norm)
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
+
+func TestScalarQuantizer(t *testing.T) {
+	vecs := make([]Vector, 1000)
+	for i := range vecs {
+		vecs[i] = unitFloats(64)
+	}
+
+	for _, perVector := range []bool{false, true} {
+		sq := &ScalarQuantizer{PerVector: perVector}
+		_, err := sq.Encode(vecs[0])
+		require.Error(t, err)
+		require.NoError(t, sq.Train(vecs))
+
+		code, err := sq.Encode(vecs[0])
+		require.NoError(t, err)
+		if perVector {
+			require.Len(t, code, 4+64)
+		} else {
+			require.Len(t, code, 64)
+		}
+		_, err = sq.Encode(Vector{1})
+		require.Error(t, err)
+
+		// Reconstructions are close, and the quantized distances agree
+		// with the exact ones.
+		decoded := sq.Decode(code)
+		errDist, _ := EuclideanDistance(decoded, vecs[0])
+		require.Less(t, errDist, float32(0.02))
+
+		distances, err := sq.Distances(vecs[0])
+		require.NoError(t, err)
+		for _, v := range vecs[1:20] {
+			code, err := sq.Encode(v)
+			require.NoError(t, err)
+			exact, _ := EuclideanDistance(v, vecs[0])
+			require.InDelta(t, exact, distances(code), 0.02)
+		}
+
+		state, err := sq.MarshalBinary()
+		require.NoError(t, err)
+		var sq2 ScalarQuantizer
+		require.NoError(t, sq2.UnmarshalBinary(state))
+		require.Equal(t, perVector, sq2.PerVector)
+		code2, err := sq2.Encode(vecs[0])
+		require.NoError(t, err)
+		require.Equal(t, code, code2)
+
+		// Exact-scan recall over the codes stays high.
+		index := &QuantizedIndex[int]{Quantizer: sq}
+		for i, v := range vecs {
+			require.NoError(t, index.Add(MakeNode(i, v)))
+		}
+		var found int
+		for i, v := range vecs[:100] {
+			results, err := index.Search(v, 1)
+			require.NoError(t, err)
+			if results[0].Key == i {
+				found++
+			}
+		}
+		require.GreaterOrEqual(t, found, 99)
+	}
+}
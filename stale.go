@@ -0,0 +1,52 @@
+package hnsw
+
+import "slices"
+
+// MarkStale flags nodes whose vectors are out of date, e.g. because they
+// were computed with a previous embedding model. Re-adding a node with
+// Add clears its flag, so pipelines can progressively re-embed the
+// corpus by iterating StaleKeys.
+//
+// Keys that aren't in the graph are ignored. MarkStale returns the number
+// of nodes marked.
+func (g *Graph[K]) MarkStale(keys ...K) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.layers) == 0 {
+		return 0
+	}
+
+	var marked int
+	for _, key := range keys {
+		if _, ok := g.layers[0].nodes[key]; !ok {
+			continue
+		}
+		if g.stale == nil {
+			g.stale = make(map[K]struct{})
+		}
+		g.stale[key] = struct{}{}
+		marked++
+	}
+	return marked
+}
+
+// IsStale reports whether the node with the given key is marked stale.
+func (g *Graph[K]) IsStale(key K) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	_, ok := g.stale[key]
+	return ok
+}
+
+// StaleKeys returns the keys of all nodes marked stale, in ascending
+// order.
+func (g *Graph[K]) StaleKeys() []K {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	keys := make([]K, 0, len(g.stale))
+	for key := range g.stale {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, keyOrder[K]())
+	return keys
+}
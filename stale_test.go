@@ -0,0 +1,36 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_MarkStale(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 32; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+
+	require.Equal(t, 2, g.MarkStale(10, 11, 100))
+	require.Equal(t, []int{10, 11}, g.StaleKeys())
+	require.True(t, g.IsStale(10))
+
+	t.Run("Search", func(t *testing.T) {
+		results, err := g.SearchWithOptions(Vector{10.2}, 1, SearchOptions[int]{})
+		require.NoError(t, err)
+		require.Equal(t, 10, results[0].Key)
+
+		results, err = g.SearchWithOptions(Vector{10.2}, 1, SearchOptions[int]{StalePenalty: 5})
+		require.NoError(t, err)
+		require.Equal(t, 9, results[0].Key)
+		require.InDelta(t, 1.2, results[0].Distance, 1e-5)
+	})
+
+	// Upserting a fresh vector clears the mark.
+	require.NoError(t, g.Add(MakeNode(10, Vector{10})))
+	require.Equal(t, []int{11}, g.StaleKeys())
+
+	g.Delete(11)
+	require.Empty(t, g.StaleKeys())
+}
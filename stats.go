@@ -0,0 +1,132 @@
+package hnsw
+
+import (
+	"unsafe"
+)
+
+// DegreeStats summarizes the number of neighbors of the nodes in a layer.
+type DegreeStats struct {
+	Min, Max int
+	Mean     float64
+}
+
+// GraphStats is a report on the topology of a graph, for diagnosing
+// recall problems such as clusters cut off by heavy deletion.
+type GraphStats struct {
+	Config GraphConfig
+
+	// Nodes is the number of nodes, as returned by Len.
+	Nodes int
+
+	// LayerSizes is the number of nodes in each layer, from the base
+	// layer up.
+	LayerSizes []int
+
+	// Degrees summarizes the neighbors of the nodes of each layer.
+	Degrees []DegreeStats
+
+	// Components is the number of connected components of the base
+	// layer, with edges taken as undirected. A healthy graph has one;
+	// more mean that some nodes can't be found from others.
+	Components int
+
+	// Tombstones is the number of nodes marked with MarkDeleted.
+	Tombstones int
+
+	// MemoryBytes is a rough estimate of the memory used by the nodes,
+	// vectors and edges.
+	MemoryBytes int64
+
+	// Embeddings summarizes the stored vectors, including those of nodes
+	// marked with MarkDeleted. Unlike the other statistics, it is kept up
+	// to date as nodes are added and deleted, so reading it is cheap.
+	Embeddings EmbeddingStats
+}
+
+// Stats computes statistics on the graph. It visits every node, so it
+// takes time proportional to the size of the graph.
+func (g *Graph[K]) Stats() GraphStats {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	stats := GraphStats{
+		Config:     g.config(),
+		Nodes:      g.len(),
+		Tombstones: len(g.tombstones),
+		Embeddings: g.sketch.stats(),
+	}
+	var (
+		key      K
+		node     layerNode[K]
+		keySize  = int64(unsafe.Sizeof(key))
+		nodeSize = int64(unsafe.Sizeof(node))
+	)
+	for i, layer := range g.layers {
+		stats.LayerSizes = append(stats.LayerSizes, len(layer.nodes))
+		degree := DegreeStats{Min: g.maxNeighbors(i)}
+		for _, n := range layer.nodes {
+			d := len(n.neighbors)
+			degree.Min = min(degree.Min, d)
+			degree.Max = max(degree.Max, d)
+			degree.Mean += float64(d)
+
+			// The node, its entries in the layer, its neighbor IDs and,
+			// in the base layer only, its vector.
+			stats.MemoryBytes += nodeSize + 2*(keySize+8) + 8
+			stats.MemoryBytes += 4 * int64(cap(n.neighbors))
+			if i == 0 {
+				stats.MemoryBytes += 4 * int64(len(n.Value))
+			}
+		}
+		if len(layer.nodes) > 0 {
+			degree.Mean /= float64(len(layer.nodes))
+		} else {
+			degree.Min = 0
+		}
+		stats.Degrees = append(stats.Degrees, degree)
+	}
+	if len(g.layers) > 0 {
+		stats.Components = components(g.layers[0])
+	}
+	return stats
+}
+
+// components counts the connected components of layer, treating edges as
+// undirected.
+func components[K comparable](layer *layer[K]) int {
+	// Union-find over the nodes.
+	parent := make(map[K]K, len(layer.nodes))
+	find := func(k K) K {
+		root := k
+		for {
+			p, ok := parent[root]
+			if !ok || p == root {
+				break
+			}
+			root = p
+		}
+		// Compress the path.
+		for k != root {
+			next := parent[k]
+			parent[k] = root
+			k = next
+		}
+		return root
+	}
+	for key, n := range layer.nodes {
+		for _, neighbor := range liveNeighbors(n) {
+			a, b := find(key), find(neighbor.Key)
+			if a != b {
+				parent[a] = b
+			}
+		}
+	}
+
+	var count int
+	for key := range layer.nodes {
+		if find(key) == key {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,81 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Stats(t *testing.T) {
+	g := newTestGraph[int]()
+	require.Zero(t, g.Stats().Components)
+	for i := 0; i < 128; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+	g.MarkDeleted(0)
+
+	stats := g.Stats()
+	require.Equal(t, g.Config(), stats.Config)
+	require.Equal(t, 127, stats.Nodes)
+	require.Equal(t, 1, stats.Tombstones)
+	a := Analyzer[int]{Graph: g}
+	require.Equal(t, a.Topography(), stats.LayerSizes)
+	require.Len(t, stats.Degrees, len(stats.LayerSizes))
+	for i, d := range stats.Degrees {
+		require.LessOrEqual(t, d.Min, d.Max)
+		require.LessOrEqual(t, d.Max, g.M)
+		require.InDelta(t, a.Connectivity()[i], d.Mean, 1e-9)
+	}
+	require.Equal(t, 1, stats.Components)
+	require.Greater(t, stats.MemoryBytes, int64(128*4))
+
+	// Cut node 5 off in the base layer.
+	base := g.layers[0]
+	cut := base.nodes[5]
+	cut.neighbors = nil
+	for _, n := range base.nodes {
+		n.unlink(cut.id)
+	}
+	require.Equal(t, 2, g.Stats().Components)
+	require.Zero(t, g.Stats().Degrees[0].Min)
+}
+
+func TestGraph_StatsEmbeddings(t *testing.T) {
+	g := newTestGraph[int]()
+	require.Zero(t, g.Stats().Embeddings.Count)
+
+	// Dimension 0 is i, dimension 1 is constant.
+	for i := 0; i < 10; i++ {
+		require.NoError(t, g.Add(MakeNode(i, Vector{float32(i), 3})))
+	}
+	e := g.Stats().Embeddings
+	require.Equal(t, 10, e.Count)
+	require.InDeltaSlice(t, []float32{4.5, 3}, e.Mean, 1e-6)
+	require.InDeltaSlice(t, []float32{8.25, 0}, e.Variance, 1e-5)
+	var total int
+	for _, n := range e.NormHistogram {
+		total += n
+	}
+	require.Equal(t, 10, total)
+	// The norms of {0, 3} and {1, 3} are 3 and 3.16.
+	b := normBucket(3)
+	require.LessOrEqual(t, NormBucketBound(b), float32(3))
+	require.Greater(t, NormBucketBound(b+1), float32(3.17))
+	require.Equal(t, 2, e.NormHistogram[b])
+
+	// Replacing, updating and deleting keep the sketch current.
+	require.NoError(t, g.Add(MakeNode(0, Vector{10, 3})))
+	require.NoError(t, g.Update(1, Vector{11, 3}))
+	require.True(t, g.Delete(9))
+	g.MarkDeleted(8)
+	g.Compact()
+	e = g.Stats().Embeddings
+	require.Equal(t, 8, e.Count)
+	require.InDeltaSlice(t, []float32{(10 + 11 + 2 + 3 + 4 + 5 + 6 + 7) / 8.0, 3}, e.Mean, 1e-5)
+
+	for i := 0; i < 10; i++ {
+		g.Delete(i)
+	}
+	require.Zero(t, g.Stats().Embeddings.Count)
+	require.Nil(t, g.Stats().Embeddings.Mean)
+}
@@ -0,0 +1,246 @@
+package hnsw
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrGraphNotFound is returned by Store when no graph has the given
+	// name.
+	ErrGraphNotFound = errors.New("graph not found")
+	// ErrGraphExists is returned by Store.Create when a graph already has
+	// the given name.
+	ErrGraphExists = errors.New("graph already exists")
+)
+
+// storeExt is the extension of the files of a Store.
+const storeExt = ".graph"
+
+// storeName matches valid graph names.
+var storeName = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// Store manages named graphs, e.g. one per tenant or collection, each
+// with its own parameters, saved in a common directory as
+// <name>.graph. Graphs are opened on first use and stay in memory until
+// they are unloaded or dropped.
+//
+// The graphs are SavedGraphs: they can be used concurrently, and are
+// written to disk by their Save method or by Store.Save.
+type Store[K comparable] struct {
+	dir  string
+	opts []OpenOption
+
+	mu     sync.Mutex
+	graphs map[string]*storeEntry[K]
+}
+
+// storeEntry is a graph of a Store. It is put in the map before the
+// graph is opened or created, so that files are read and written
+// without holding the lock of the store; ready is closed once g or err is
+// set.
+type storeEntry[K comparable] struct {
+	ready chan struct{}
+	g     *SavedGraph[K]
+	err   error
+}
+
+// wait waits for the entry to be ready and returns its graph.
+func (e *storeEntry[K]) wait() (*SavedGraph[K], error) {
+	<-e.ready
+	return e.g, e.err
+}
+
+// load runs open for the entry, which must have just been added to the
+// map under name, and removes the entry again if open fails.
+func (s *Store[K]) load(name string, e *storeEntry[K], open func() (*SavedGraph[K], error)) (*SavedGraph[K], error) {
+	e.g, e.err = open()
+	if e.err != nil {
+		s.mu.Lock()
+		if s.graphs[name] == e {
+			delete(s.graphs, name)
+		}
+		s.mu.Unlock()
+	}
+	close(e.ready)
+	return e.g, e.err
+}
+
+// OpenStore returns a Store of the graphs in dir, creating dir if it
+// doesn't exist. The graphs are opened with opts.
+func OpenStore[K comparable](dir string, opts ...OpenOption) (*Store[K], error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Store[K]{
+		dir:    dir,
+		opts:   opts,
+		graphs: make(map[string]*storeEntry[K]),
+	}, nil
+}
+
+// path returns the path of the file of the named graph.
+func (s *Store[K]) path(name string) (string, error) {
+	if !storeName.MatchString(name) {
+		return "", fmt.Errorf("invalid graph name %q", name)
+	}
+	return filepath.Join(s.dir, name+storeExt), nil
+}
+
+// Create creates an empty graph with the parameters of config and saves
+// it. An empty config.Distance means CosineDistance, as with NewGraph.
+// Names consist of letters, digits, '-', '_' and '.', and don't start
+// with '.'.
+func (s *Store[K]) Create(name string, config GraphConfig) (*SavedGraph[K], error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	g, err := newGraphFromConfig[K](config, CosineDistance)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if _, ok := s.graphs[name]; ok {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%s: %w", name, ErrGraphExists)
+	}
+	if _, err := os.Stat(path); err == nil {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%s: %w", name, ErrGraphExists)
+	}
+	e := &storeEntry[K]{ready: make(chan struct{})}
+	s.graphs[name] = e
+	s.mu.Unlock()
+
+	return s.load(name, e, func() (*SavedGraph[K], error) {
+		saved := &SavedGraph[K]{Graph: g, Path: path}
+		if err := saved.Save(); err != nil {
+			return nil, err
+		}
+		return saved, nil
+	})
+}
+
+// Graph returns the named graph, opening it if it isn't in memory yet.
+func (s *Store[K]) Graph(name string) (*SavedGraph[K], error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if e, ok := s.graphs[name]; ok {
+		s.mu.Unlock()
+		return e.wait()
+	}
+	e := &storeEntry[K]{ready: make(chan struct{})}
+	s.graphs[name] = e
+	s.mu.Unlock()
+
+	return s.load(name, e, func() (*SavedGraph[K], error) {
+		// Open creates missing files.
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s: %w", name, ErrGraphNotFound)
+		}
+		g, err := Open[K](path, s.opts...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return g, nil
+	})
+}
+
+// Names returns the names of the graphs in the store, in order.
+func (s *Store[K]) Names() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), storeExt)
+		if ok && e.Type().IsRegular() && storeName.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Save saves every graph in memory. It attempts all of them and returns
+// the errors joined.
+func (s *Store[K]) Save() error {
+	s.mu.Lock()
+	graphs := maps.Clone(s.graphs)
+	s.mu.Unlock()
+
+	var errs []error
+	for _, name := range sortedMapKeys(graphs) {
+		g, err := graphs[name].wait()
+		if err != nil {
+			// The graph failed to open and isn't in memory.
+			continue
+		}
+		if err := g.Save(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Unload saves the named graph and releases it from memory. The next
+// call to Graph opens it again. Unloading a graph that isn't in memory
+// does nothing. Changes made to the graph while it is being saved may be
+// lost.
+func (s *Store[K]) Unload(name string) error {
+	s.mu.Lock()
+	e, ok := s.graphs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	g, err := e.wait()
+	if err != nil {
+		return nil
+	}
+	if err := g.Save(); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	s.mu.Lock()
+	if s.graphs[name] == e {
+		delete(s.graphs, name)
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// Drop deletes the named graph from memory and from disk. A SavedGraph
+// of it obtained earlier writes the file again if it is saved.
+func (s *Store[K]) Drop(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, loaded := s.graphs[name]
+	delete(s.graphs, name)
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if loaded {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", name, ErrGraphNotFound)
+	}
+	return err
+}
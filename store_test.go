@@ -0,0 +1,84 @@
+package hnsw
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenStore[int](dir)
+	require.NoError(t, err)
+
+	a, err := s.Create("tenant-a", GraphConfig{M: 8, Ml: 0.25, EfSearch: 20, Distance: "euclidean"})
+	require.NoError(t, err)
+	b, err := s.Create("tenant-b", GraphConfig{M: 16, M0: 32, Ml: 0.5, EfSearch: 40})
+	require.NoError(t, err)
+	for i := range 100 {
+		require.NoError(t, a.Add(MakeNode(i, randFloats(4))))
+		require.NoError(t, b.Add(MakeNode(i, unitFloats(8))))
+	}
+	require.NoError(t, s.Save())
+
+	_, err = s.Create("tenant-a", GraphConfig{M: 8, Ml: 0.25})
+	require.ErrorIs(t, err, ErrGraphExists)
+	_, err = s.Create("../escape", GraphConfig{M: 8, Ml: 0.25})
+	require.ErrorContains(t, err, "invalid graph name")
+	_, err = s.Create("bad", GraphConfig{M: 8})
+	require.ErrorContains(t, err, "Ml")
+	_, err = s.Graph("tenant-c")
+	require.ErrorIs(t, err, ErrGraphNotFound)
+
+	// The graphs keep their parameters across stores.
+	s, err = OpenStore[int](dir)
+	require.NoError(t, err)
+	names, err := s.Names()
+	require.NoError(t, err)
+	require.Equal(t, []string{"tenant-a", "tenant-b"}, names)
+
+	// Concurrent first uses open the graph once.
+	var wg sync.WaitGroup
+	opened := make([]*SavedGraph[int], 4)
+	for i := range opened {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opened[i], _ = s.Graph("tenant-a")
+		}()
+	}
+	wg.Wait()
+	a, err = s.Graph("tenant-a")
+	require.NoError(t, err)
+	for _, g := range opened {
+		require.Same(t, a, g)
+	}
+	require.Equal(t, 100, a.Len())
+	require.Equal(t, 8, a.M)
+	require.Equal(t, "euclidean", a.Config().Distance)
+	b, err = s.Graph("tenant-b")
+	require.NoError(t, err)
+	require.Equal(t, 100, b.Len())
+	require.Equal(t, 32, b.M0)
+	require.Equal(t, "cosine", b.Config().Distance)
+	again, err := s.Graph("tenant-b")
+	require.NoError(t, err)
+	require.Same(t, b, again)
+
+	// Unloading saves the graph.
+	require.True(t, b.Delete(0))
+	require.NoError(t, s.Unload("tenant-b"))
+	again, err = s.Graph("tenant-b")
+	require.NoError(t, err)
+	require.NotSame(t, b, again)
+	require.Equal(t, 99, again.Len())
+
+	require.NoError(t, s.Drop("tenant-a"))
+	require.ErrorIs(t, s.Drop("tenant-a"), ErrGraphNotFound)
+	_, err = s.Graph("tenant-a")
+	require.ErrorIs(t, err, ErrGraphNotFound)
+	names, err = s.Names()
+	require.NoError(t, err)
+	require.Equal(t, []string{"tenant-b"}, names)
+}
@@ -0,0 +1,133 @@
+package hnsw
+
+import (
+	"cmp"
+	"context"
+	"slices"
+)
+
+// subscription is a continuous query created with Subscribe.
+type subscription[K comparable] struct {
+	k     int
+	score scoreFunc[K]
+	// top is the current answer, closest first.
+	top []SearchResultNode[K]
+	ch  chan []SearchResultNode[K]
+}
+
+// Subscribe starts a continuous query for the k nearest neighbors of
+// query. The returned channel receives the current top k, closest first,
+// and then a new set whenever an Add or Delete changes it, e.g. to
+// notify when a document similar to query arrives.
+//
+// Only the latest set is kept for a slow receiver; intermediate sets
+// are dropped rather than blocking writes to the graph. Calling cancel
+// stops the subscription and closes the channel.
+//
+// Nodes added after the subscription are compared with query exactly;
+// when a member of the set is deleted, the set is recomputed with a
+// regular search.
+func (g *Graph[K]) Subscribe(query Vector, k int) (<-chan []SearchResultNode[K], func(), error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.assertDims(query)
+
+	sub := &subscription[K]{
+		k:     k,
+		score: distanceTo[K](query, g.Distance),
+		ch:    make(chan []SearchResultNode[K], 1),
+	}
+	if err := g.refresh(sub); err != nil {
+		return nil, nil, err
+	}
+	if g.subs == nil {
+		g.subs = make(map[*subscription[K]]struct{})
+	}
+	g.subs[sub] = struct{}{}
+
+	cancel := func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if _, ok := g.subs[sub]; ok {
+			delete(g.subs, sub)
+			close(sub.ch)
+		}
+	}
+	return sub.ch, cancel, nil
+}
+
+// refresh recomputes the answer of sub with a search and publishes it.
+// The caller must hold the lock.
+func (g *Graph[K]) refresh(sub *subscription[K]) error {
+	sub.top = nil
+	if g.len() > 0 {
+		top, err := g.searchScore(context.Background(), sub.score, sub.k, SearchOptions[K]{})
+		if err != nil {
+			return err
+		}
+		slices.SortFunc(top, func(a, b SearchResultNode[K]) int {
+			return cmp.Compare(a.Distance, b.Distance)
+		})
+		sub.top = top
+	}
+	sub.publish()
+	return nil
+}
+
+// publish sends the current answer, replacing one that has not been
+// received yet.
+func (s *subscription[K]) publish() {
+	select {
+	case <-s.ch:
+	default:
+	}
+	s.ch <- slices.Clone(s.top)
+}
+
+// notifyAdd updates the subscriptions and change feeds after node was
+// added. The caller must hold the write lock.
+func (g *Graph[K]) notifyAdd(node Node[K]) {
+	g.publishChange(Change[K]{Key: node.Key, Value: node.Value})
+	if len(g.subs) == 0 {
+		return
+	}
+	layerNode := g.layers[0].nodes[node.Key]
+	for sub := range g.subs {
+		if slices.ContainsFunc(sub.top, func(r SearchResultNode[K]) bool {
+			return r.Key == node.Key
+		}) {
+			// The node was replaced; its distance may have grown.
+			g.refresh(sub)
+			continue
+		}
+
+		dist, err := sub.score(layerNode)
+		if err != nil {
+			continue
+		}
+		if len(sub.top) == sub.k && dist >= sub.top[len(sub.top)-1].Distance {
+			continue
+		}
+		i, _ := slices.BinarySearchFunc(sub.top, dist, func(r SearchResultNode[K], d float32) int {
+			return cmp.Compare(r.Distance, d)
+		})
+		sub.top = slices.Insert(sub.top, i, SearchResultNode[K]{Node: node, Distance: dist, Payload: g.payloads[node.Key]})
+		if len(sub.top) > sub.k {
+			sub.top = sub.top[:sub.k]
+		}
+		sub.publish()
+	}
+}
+
+// notifyDelete updates the subscriptions and change feeds after key was
+// deleted. The caller must hold the write lock.
+func (g *Graph[K]) notifyDelete(key K) {
+	g.publishChange(Change[K]{Key: key, Deleted: true})
+	for sub := range g.subs {
+		if slices.ContainsFunc(sub.top, func(r SearchResultNode[K]) bool {
+			return r.Key == key
+		}) {
+			g.refresh(sub)
+		}
+	}
+}
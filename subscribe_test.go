@@ -0,0 +1,58 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Subscribe(t *testing.T) {
+	g := newTestGraph[int]()
+	g.M = 16
+	for i := 0; i < 8; i++ {
+		g.Add(MakeNode(i, Vector{float32(i * 10)}))
+	}
+
+	updates, cancel, err := g.Subscribe(Vector{42}, 2)
+	require.NoError(t, err)
+	require.Equal(t, []int{4, 5}, keysOf(<-updates))
+
+	// A far node doesn't change the answer.
+	g.Add(MakeNode(100, Vector{100}))
+	require.Empty(t, updates)
+
+	g.Add(MakeNode(41, Vector{41}))
+	require.Equal(t, []int{41, 4}, keysOf(<-updates))
+
+	g.Delete(41)
+	require.Equal(t, []int{4, 5}, keysOf(<-updates))
+
+	// Moving a member away recomputes the answer.
+	g.Add(MakeNode(4, Vector{-10}))
+	require.Equal(t, []int{5, 3}, keysOf(<-updates))
+
+	// Unread updates are replaced by the latest one.
+	g.Add(MakeNode(44, Vector{44}))
+	g.Add(MakeNode(43, Vector{43}))
+	require.Equal(t, []int{43, 44}, keysOf(<-updates))
+
+	// Marking a member deleted recomputes the answer right away.
+	g.MarkDeleted(43)
+	require.Equal(t, []int{44, 5}, keysOf(<-updates))
+	g.Compact()
+	require.Empty(t, updates)
+
+	cancel()
+	_, ok := <-updates
+	require.False(t, ok)
+	cancel()
+	g.Add(MakeNode(42, Vector{42}))
+}
+
+func keysOf[K comparable](results []SearchResultNode[K]) []K {
+	keys := make([]K, len(results))
+	for i, r := range results {
+		keys[i] = r.Key
+	}
+	return keys
+}
@@ -0,0 +1,79 @@
+package hnsw
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Swappable holds an index that can be replaced while it is in use, e.g.
+// by one rebuilt in the background or pulled from a snapshot. Every
+// operation runs on the index that was current when it started, so a swap
+// neither blocks nor disrupts readers; the old index can be dropped once
+// the operations on it have finished.
+//
+// Swappable implements Index by forwarding to the current index. Writes
+// made to the old index during a swap are not carried over. Create it with
+// NewSwappable.
+type Swappable[K comparable] struct {
+	current atomic.Pointer[swappableIndex[K]]
+}
+
+var _ Index[int] = (*Swappable[int])(nil)
+
+// swappableIndex boxes the interface value for atomic.Pointer.
+type swappableIndex[K comparable] struct {
+	Index[K]
+}
+
+// NewSwappable returns a Swappable holding index.
+func NewSwappable[K comparable](index Index[K]) *Swappable[K] {
+	s := &Swappable[K]{}
+	s.Swap(index)
+	return s
+}
+
+// Load returns the current index. Use it to run several operations on the
+// same index.
+func (s *Swappable[K]) Load() Index[K] {
+	return s.current.Load().Index
+}
+
+// Swap replaces the current index with index and returns the old one.
+func (s *Swappable[K]) Swap(index Index[K]) Index[K] {
+	old := s.current.Swap(&swappableIndex[K]{index})
+	if old == nil {
+		return nil
+	}
+	return old.Index
+}
+
+// Add inserts nodes into the current index.
+func (s *Swappable[K]) Add(nodes ...Node[K]) error {
+	return s.Load().Add(nodes...)
+}
+
+// Search searches the current index.
+func (s *Swappable[K]) Search(near Vector, k int) ([]SearchResultNode[K], error) {
+	return s.Load().Search(near, k)
+}
+
+// Delete removes the node from the current index and reports whether it
+// existed.
+func (s *Swappable[K]) Delete(key K) bool {
+	return s.Load().Delete(key)
+}
+
+// Lookup looks key up in the current index.
+func (s *Swappable[K]) Lookup(key K) (Vector, bool) {
+	return s.Load().Lookup(key)
+}
+
+// Len returns the number of nodes in the current index.
+func (s *Swappable[K]) Len() int {
+	return s.Load().Len()
+}
+
+// Export exports the current index.
+func (s *Swappable[K]) Export(w io.Writer) error {
+	return s.Load().Export(w)
+}
@@ -0,0 +1,55 @@
+package hnsw
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwappable(t *testing.T) {
+	a := newTestGraph[int]()
+	for i := 0; i < 50; i++ {
+		require.NoError(t, a.Add(MakeNode(i, randFloats(4))))
+	}
+	s := NewSwappable[int](a)
+	require.Same(t, a, s.Load())
+	require.Equal(t, 50, s.Len())
+
+	b := newTestGraph[int]()
+	for i := 0; i < 80; i++ {
+		require.NoError(t, b.Add(MakeNode(i, randFloats(4))))
+	}
+
+	// Readers keep searching while the index is swapped.
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 200 {
+				results, err := s.Search(randFloats(4), 3)
+				if err != nil || len(results) != 3 {
+					t.Error(results, err)
+					return
+				}
+			}
+		}()
+	}
+	for i := range 20 {
+		if i%2 == 0 {
+			require.Same(t, a, s.Swap(b))
+		} else {
+			require.Same(t, b, s.Swap(a))
+		}
+	}
+	wg.Wait()
+
+	require.Same(t, a, s.Swap(b))
+	require.Equal(t, 80, s.Len())
+	require.NoError(t, s.Add(MakeNode(100, randFloats(4))))
+	_, ok := b.Lookup(100)
+	require.True(t, ok)
+	_, ok = a.Lookup(100)
+	require.False(t, ok)
+}
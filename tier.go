@@ -0,0 +1,111 @@
+package hnsw
+
+import (
+	"container/list"
+	"sync"
+)
+
+// hotTier keeps the full vectors of the most recently used nodes of a
+// QuantizedIndex, evicting the least recently used beyond its size.
+type hotTier[K comparable] struct {
+	mu sync.Mutex
+	// lru holds entries from most to least recently used.
+	lru     *list.List
+	entries map[K]*list.Element
+}
+
+type hotEntry[K comparable] struct {
+	key K
+	vec Vector
+}
+
+// get returns the vector of key and marks it used, if key is hot.
+func (t *hotTier[K]) get(key K) (Vector, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	elem, ok := t.entries[key]
+	if !ok {
+		return nil, false
+	}
+	t.lru.MoveToFront(elem)
+	return elem.Value.(*hotEntry[K]).vec, true
+}
+
+// peek returns the vector of key, if key is hot, without marking it used.
+func (t *hotTier[K]) peek(key K) (Vector, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	elem, ok := t.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return elem.Value.(*hotEntry[K]).vec, true
+}
+
+// put makes key hot with the vector vec, then evicts nodes until at most
+// size are hot.
+func (t *hotTier[K]) put(key K, vec Vector, size int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.lru == nil {
+		t.lru = list.New()
+		t.entries = make(map[K]*list.Element)
+	}
+	if elem, ok := t.entries[key]; ok {
+		elem.Value.(*hotEntry[K]).vec = vec
+		t.lru.MoveToFront(elem)
+	} else {
+		t.entries[key] = t.lru.PushFront(&hotEntry[K]{key: key, vec: vec})
+	}
+	for t.lru.Len() > size {
+		delete(t.entries, t.lru.Remove(t.lru.Back()).(*hotEntry[K]).key)
+	}
+}
+
+func (t *hotTier[K]) remove(key K) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if elem, ok := t.entries[key]; ok {
+		t.lru.Remove(elem)
+		delete(t.entries, key)
+	}
+}
+
+func (t *hotTier[K]) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lru, t.entries = nil, nil
+}
+
+func (t *hotTier[K]) len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.entries)
+}
+
+// original returns the full vector of key: the hot one, or else the one
+// from Originals. It reports whether the vector came from Originals.
+func (q *QuantizedIndex[K]) original(key K) (vec Vector, ok, cold bool) {
+	if vec, ok := q.hot.get(key); ok {
+		return vec, true, false
+	}
+	if q.Originals != nil {
+		vec, ok = q.Originals(key)
+	}
+	return vec, ok, ok
+}
+
+// promote makes the nodes whose vectors were read from Originals hot,
+// unless they were deleted meanwhile.
+func (q *QuantizedIndex[K]) promote(nodes []Node[K]) {
+	if q.HotSize <= 0 {
+		return
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	for _, n := range nodes {
+		if _, ok := q.codes[n.Key]; ok {
+			q.hot.put(n.Key, n.Value, q.HotSize)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuantizedIndex_HotSize(t *testing.T) {
+	vecs := make([]Vector, 10)
+	for i := range vecs {
+		vecs[i] = randFloats(8)
+	}
+	sq := &ScalarQuantizer{}
+	require.NoError(t, sq.Train(vecs))
+	idx := &QuantizedIndex[int]{Quantizer: sq, HotSize: 2}
+	for i, v := range vecs {
+		require.NoError(t, idx.Add(MakeNode(i, v)))
+	}
+	require.Equal(t, 2, idx.Stats().Hot)
+
+	// The most recently added nodes are hot and exact, the others are
+	// decoded.
+	v, ok := idx.Lookup(9)
+	require.True(t, ok)
+	require.Equal(t, vecs[9], v)
+	v, _ = idx.Lookup(0)
+	require.NotEqual(t, vecs[0], v)
+	require.InDeltaSlice(t, vecs[0], v, 0.01)
+
+	res, err := idx.Search(vecs[9], 1)
+	require.NoError(t, err)
+	require.Equal(t, vecs[9], res[0].Value)
+
+	// A cold node re-ranked with its original becomes hot, evicting the
+	// least recently used node.
+	idx.Rerank = 1
+	idx.Originals = func(key int) (Vector, bool) { return vecs[key], true }
+	res, err = idx.Search(vecs[0], 1)
+	require.NoError(t, err)
+	require.Equal(t, 0, res[0].Key)
+	idx.Originals = nil
+	v, _ = idx.Lookup(0)
+	require.Equal(t, vecs[0], v)
+	v, _ = idx.Lookup(8)
+	require.NotEqual(t, vecs[8], v)
+	require.Equal(t, 2, idx.Stats().Hot)
+
+	// Hot vectors are re-ranked exactly without Originals.
+	res, err = idx.Search(vecs[0], 1)
+	require.NoError(t, err)
+	require.Zero(t, res[0].Distance)
+
+	require.True(t, idx.Delete(0))
+	require.Equal(t, 1, idx.Stats().Hot)
+}
@@ -0,0 +1,170 @@
+package hnsw
+
+import "slices"
+
+// MarkDeleted hides nodes from searches and lookups without removing
+// them from the graph. Unlike Delete, it doesn't rewire the neighbors of
+// the nodes, so it is cheap; the marked nodes still route searches. Call
+// Compact to remove them in bulk, e.g. periodically or once
+// Tombstones grows large.
+//
+// Len, Export and Lookup treat marked nodes as deleted, and so do
+// subscriptions and Changes, which see the deletion when the node is
+// marked rather than when it is compacted. Re-adding a node with Add or
+// deleting it with Delete clears its mark. Keys that aren't in the graph
+// are ignored. MarkDeleted returns the number of nodes marked.
+func (g *Graph[K]) MarkDeleted(keys ...K) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.layers) == 0 {
+		return 0
+	}
+
+	var marked int
+	for _, key := range keys {
+		if _, ok := g.layers[0].nodes[key]; ok && g.markTombstone(key) {
+			marked++
+		}
+	}
+	return marked
+}
+
+// markTombstone marks key with MarkDeleted and notifies the subscriptions
+// and change feeds of its deletion, unless it is marked already. It
+// reports whether it marked key. The caller must hold the write lock.
+func (g *Graph[K]) markTombstone(key K) bool {
+	if g.isTombstone(key) {
+		return false
+	}
+	if g.tombstones == nil {
+		g.tombstones = make(map[K]struct{})
+	}
+	g.tombstones[key] = struct{}{}
+	g.notifyDelete(key)
+	return true
+}
+
+// Tombstones returns the number of nodes marked with MarkDeleted that
+// have not been compacted yet.
+func (g *Graph[K]) Tombstones() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.tombstones)
+}
+
+// Compact removes the nodes marked with MarkDeleted from the graph and
+// returns how many it removed.
+//
+// All marked nodes are unlinked first and each affected neighbor is
+// replenished once afterwards, so removing many nodes costs much less
+// than deleting them one by one, where the neighbors replenished for one
+// deletion may be torn down again by the next.
+func (g *Graph[K]) Compact() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.compact()
+}
+
+// DeleteByFilter deletes every node whose key filter returns true, e.g.
+// to purge a tenant or data source, and returns how many it deleted.
+// Nodes marked with MarkDeleted are removed too, without being counted.
+//
+// The nodes are removed in bulk like Compact does, so purging many nodes
+// is much cheaper than deleting them one by one. filter is called with
+// the write lock held and must not use the graph.
+func (g *Graph[K]) DeleteByFilter(filter func(key K) bool) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.layers) == 0 {
+		return 0
+	}
+
+	var n int
+	for key := range g.layers[0].nodes {
+		if !g.isTombstone(key) && filter(key) {
+			g.markTombstone(key)
+			n++
+		}
+	}
+	g.compact()
+	return n
+}
+
+// compact implements Compact. The deletions were notified when the nodes
+// were marked. The caller must hold the write lock.
+func (g *Graph[K]) compact() int {
+	if len(g.tombstones) == 0 {
+		return 0
+	}
+
+	for i, layer := range g.layers {
+		var removed []*layerNode[K]
+		for key := range g.tombstones {
+			node := layer.remove(key)
+			if node == nil {
+				continue
+			}
+			node.removed = true
+			removed = append(removed, node)
+			if i == 0 {
+				g.sketch.remove(node.Value)
+			}
+		}
+
+		if len(removed) == 0 {
+			continue
+		}
+		// Links aren't always mutual, so the links to the removed nodes
+		// are found by sweeping the whole layer rather than by following
+		// their own links.
+		var affected []*layerNode[K]
+		layer.free = layer.free[:0]
+		for id, node := range layer.byID {
+			if node == nil {
+				// No link points here after the sweep.
+				layer.free = append(layer.free, uint32(id))
+				continue
+			}
+			before := len(node.neighbors)
+			node.neighbors = slices.DeleteFunc(node.neighbors, func(id uint32) bool {
+				return layer.byID[id] == nil
+			})
+			if len(node.neighbors) < before {
+				affected = append(affected, node)
+			}
+		}
+		for _, node := range affected {
+			node.replenish(g.maxNeighbors(i), g.Distance)
+		}
+	}
+
+	n := len(g.tombstones)
+	for key := range g.tombstones {
+		delete(g.stale, key)
+		delete(g.payloads, key)
+		if g.next != nil {
+			g.next.Delete(key)
+		}
+	}
+	g.tombstones = nil
+	return n
+}
+
+// isTombstone reports whether key is marked with MarkDeleted. The caller
+// must hold the read lock.
+func (g *Graph[K]) isTombstone(key K) bool {
+	_, ok := g.tombstones[key]
+	return ok
+}
+
+// hideTombstones extends filter to exclude the nodes marked with
+// MarkDeleted. The caller must hold the read lock for as long as the
+// returned filter is used.
+func (g *Graph[K]) hideTombstones(filter func(K) bool) func(K) bool {
+	if len(g.tombstones) == 0 {
+		return filter
+	}
+	return func(key K) bool {
+		return !g.isTombstone(key) && (filter == nil || filter(key))
+	}
+}
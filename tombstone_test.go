@@ -0,0 +1,85 @@
+package hnsw
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_MarkDeleted(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 256; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+
+	var marked []int
+	for i := 0; i < 256; i += 2 {
+		marked = append(marked, i)
+	}
+	require.Equal(t, 128, g.MarkDeleted(append(marked, 1000)...))
+	require.Zero(t, g.MarkDeleted(0))
+	require.Equal(t, 128, g.Tombstones())
+	require.Equal(t, 128, g.Len())
+	_, ok := g.Lookup(0)
+	require.False(t, ok)
+
+	for i := 0; i < 256; i += 16 {
+		results, err := g.Search(Vector{float32(i)}, 4)
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+		for _, r := range results {
+			require.Equal(t, 1, r.Key%2, "deleted node %d returned", r.Key)
+		}
+	}
+
+	t.Run("Export", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, g.Export(&buf))
+		g2 := &Graph[int]{}
+		require.NoError(t, g2.Import(&buf))
+		require.Equal(t, 128, g2.Len())
+		require.NoError(t, g2.Verify())
+		_, ok := g2.Lookup(0)
+		require.False(t, ok)
+	})
+
+	// Re-adding clears the mark.
+	require.NoError(t, g.Add(MakeNode(0, Vector{0})))
+	require.Equal(t, 127, g.Tombstones())
+
+	require.Equal(t, 127, g.Compact())
+	require.Zero(t, g.Tombstones())
+	require.Equal(t, 129, g.Len())
+	require.NoError(t, g.Verify())
+	for i := 2; i < 256; i += 2 {
+		require.Equal(t, -1, g.level(i))
+	}
+
+	results, err := g.Search(Vector{100.4}, 3)
+	require.NoError(t, err)
+	require.Equal(t, []int{101, 99, 103}, keysOf(results))
+}
+
+func TestGraph_DeleteByFilter(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 300; i++ {
+		require.NoError(t, g.Add(MakeNode(i, Vector{float32(i)})))
+	}
+	g.MarkDeleted(1)
+
+	// Purge the keys of "tenant" 0.
+	n := g.DeleteByFilter(func(key int) bool { return key%3 == 0 })
+	require.Equal(t, 100, n)
+	require.Zero(t, g.Tombstones())
+	require.Equal(t, 199, g.Len())
+	require.NoError(t, g.Verify())
+
+	results, err := g.Search(Vector{150}, 4)
+	require.NoError(t, err)
+	for _, r := range results {
+		require.NotZero(t, r.Key%3)
+		require.NotEqual(t, 1, r.Key)
+	}
+	require.Zero(t, g.DeleteByFilter(func(key int) bool { return key%3 == 0 }))
+}
@@ -0,0 +1,204 @@
+package hnsw
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// SkipNeighbors is returned by a WalkFunc to skip the neighbors of the
+// node being visited. They may still be reached through other nodes.
+var SkipNeighbors = errors.New("skip neighbors")
+
+// SkipAll is returned by a WalkFunc to stop the walk. Walk then
+// returns nil.
+var SkipAll = errors.New("skip all")
+
+// WalkOrder is the order in which Walk visits nodes.
+type WalkOrder int
+
+const (
+	// BreadthFirst visits nodes in order of their distance in hops
+	// from the start.
+	BreadthFirst WalkOrder = iota
+	// DepthFirst follows a chain of neighbors as far as it goes before
+	// backtracking.
+	DepthFirst
+)
+
+// WalkFunc is called by Walk for each node reached, along with the number
+// of hops it took to get there. Returning SkipNeighbors or SkipAll
+// changes the course of the walk; any other error stops it and is
+// returned by Walk.
+type WalkFunc[K any] func(key K, depth int) error
+
+// Neighbors returns the keys of the neighbors of key in the given layer,
+// sorted. Layer 0 is the base layer, which holds every node. Nodes marked
+// with MarkDeleted are treated as deleted.
+func (g *Graph[K]) Neighbors(key K, layer int) ([]K, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	node, err := g.layerNode(key, layer)
+	if err != nil {
+		return nil, err
+	}
+	neighbors := g.visibleNeighbors(node)
+	keys := make([]K, len(neighbors))
+	for i, neighbor := range neighbors {
+		keys[i] = neighbor.Key
+	}
+	return keys, nil
+}
+
+// Walk traverses the given layer starting at start, calling fn for each
+// node reached, start included at depth 0. Every node is visited at most
+// once, and neighbors are visited in key order so walks are
+// reproducible. Nodes marked with MarkDeleted are neither visited nor
+// walked through.
+//
+// The graph is read-locked during the walk, so fn must not modify it.
+func (g *Graph[K]) Walk(start K, layer int, order WalkOrder, fn WalkFunc[K]) error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	node, err := g.layerNode(start, layer)
+	if err != nil {
+		return err
+	}
+
+	type step struct {
+		node  *layerNode[K]
+		depth int
+	}
+	var (
+		pending = []step{{node, 0}}
+		visited = map[K]bool{}
+	)
+	for len(pending) > 0 {
+		var s step
+		if order == DepthFirst {
+			s, pending = pending[len(pending)-1], pending[:len(pending)-1]
+		} else {
+			s, pending = pending[0], pending[1:]
+		}
+		if visited[s.node.Key] {
+			continue
+		}
+		visited[s.node.Key] = true
+
+		switch err := fn(s.node.Key, s.depth); err {
+		case nil:
+		case SkipNeighbors:
+			continue
+		case SkipAll:
+			return nil
+		default:
+			return err
+		}
+
+		neighbors := g.visibleNeighbors(s.node)
+		if order == DepthFirst {
+			// Push in reverse so the lowest key is popped first.
+			slices.Reverse(neighbors)
+		}
+		for _, neighbor := range neighbors {
+			if !visited[neighbor.Key] {
+				pending = append(pending, step{neighbor, s.depth + 1})
+			}
+		}
+	}
+	return nil
+}
+
+// layerNode returns the node with the given key in the given layer.
+// Nodes marked with MarkDeleted are not found. The caller must hold the
+// read lock.
+func (g *Graph[K]) layerNode(key K, layer int) (*layerNode[K], error) {
+	if layer < 0 || layer >= len(g.layers) {
+		return nil, fmt.Errorf("layer %d out of range [0, %d)", layer, len(g.layers))
+	}
+	node, ok := g.layers[layer].nodes[key]
+	if !ok || g.isTombstone(key) {
+		return nil, fmt.Errorf("key %v not found in layer %d", key, layer)
+	}
+	return node, nil
+}
+
+// sortedNeighbors returns the live neighbors of n ordered by key.
+func sortedNeighbors[K comparable](n *layerNode[K]) []*layerNode[K] {
+	neighbors := liveNeighbors(n)
+	compareKeys := keyOrder[K]()
+	slices.SortFunc(neighbors, func(a, b *layerNode[K]) int {
+		return compareKeys(a.Key, b.Key)
+	})
+	return neighbors
+}
+
+// visibleNeighbors is sortedNeighbors without the nodes marked with
+// MarkDeleted. The caller must hold the read lock.
+func (g *Graph[K]) visibleNeighbors(n *layerNode[K]) []*layerNode[K] {
+	return slices.DeleteFunc(sortedNeighbors(n), func(neighbor *layerNode[K]) bool {
+		return g.isTombstone(neighbor.Key)
+	})
+}
+
+// neighborKeys returns the sorted keys of the live neighbors of n.
+func (n *layerNode[K]) neighborKeys() []K {
+	neighbors := sortedNeighbors(n)
+	keys := make([]K, len(neighbors))
+	for i, neighbor := range neighbors {
+		keys[i] = neighbor.Key
+	}
+	return keys
+}
+
+// Path returns a shortest path from one key to another over the edges of
+// the base layer, both ends included. It returns nil if to cannot be
+// reached from from. Edges are not always bi-directional, so the path
+// back may differ or not exist. Paths don't pass through nodes marked
+// with MarkDeleted, and marked ends are not found.
+func (g *Graph[K]) Path(from, to K) ([]K, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	start, err := g.layerNode(from, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := g.layerNode(to, 0); err != nil {
+		return nil, err
+	}
+
+	parents := map[K]K{from: from}
+	queue := []*layerNode[K]{start}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if node.Key == to {
+			path := []K{to}
+			for key := to; key != from; {
+				key = parents[key]
+				path = append(path, key)
+			}
+			slices.Reverse(path)
+			return path, nil
+		}
+		for _, neighbor := range g.visibleNeighbors(node) {
+			if _, ok := parents[neighbor.Key]; ok {
+				continue
+			}
+			parents[neighbor.Key] = node.Key
+			queue = append(queue, neighbor)
+		}
+	}
+	return nil, nil
+}
+
+// Reachable reports whether to can be reached from from over the edges
+// of the base layer. An unreachable node cannot be found by searches
+// entering the base layer at from.
+func (g *Graph[K]) Reachable(from, to K) (bool, error) {
+	path, err := g.Path(from, to)
+	return path != nil, err
+}
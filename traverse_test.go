@@ -0,0 +1,141 @@
+package hnsw
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// treeGraph returns a single-layer graph with the edges
+// 0-1, 0-2, 1-3, 1-4, 2-5.
+func treeGraph() *Graph[int] {
+	g := newTestGraph[int]()
+	l := &layer[int]{}
+	for i := 0; i < 6; i++ {
+		l.add(&layerNode[int]{Node: MakeNode(i, Vector{float32(i)})})
+	}
+	link := func(a, b int) {
+		l.nodes[a].neighbors = append(l.nodes[a].neighbors, l.nodes[b].id)
+		l.nodes[b].neighbors = append(l.nodes[b].neighbors, l.nodes[a].id)
+	}
+	link(0, 1)
+	link(0, 2)
+	link(1, 3)
+	link(1, 4)
+	link(2, 5)
+	g.layers = []*layer[int]{l}
+	return g
+}
+
+func TestGraph_Neighbors(t *testing.T) {
+	g := treeGraph()
+
+	keys, err := g.Neighbors(1, 0)
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 3, 4}, keys)
+
+	_, err = g.Neighbors(9, 0)
+	require.Error(t, err)
+	_, err = g.Neighbors(1, 1)
+	require.Error(t, err)
+}
+
+func TestGraph_Walk(t *testing.T) {
+	g := treeGraph()
+
+	walk := func(order WalkOrder, fn WalkFunc[int]) ([]int, error) {
+		var keys []int
+		err := g.Walk(0, 0, order, func(key, depth int) error {
+			keys = append(keys, key)
+			if fn != nil {
+				return fn(key, depth)
+			}
+			return nil
+		})
+		return keys, err
+	}
+
+	keys, err := walk(BreadthFirst, nil)
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5}, keys)
+
+	keys, err = walk(DepthFirst, nil)
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 3, 4, 2, 5}, keys)
+
+	keys, err = walk(BreadthFirst, func(key, depth int) error {
+		if key == 1 {
+			return SkipNeighbors
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 2, 5}, keys)
+
+	keys, err = walk(DepthFirst, func(key, depth int) error {
+		if depth == 2 {
+			return SkipAll
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 3}, keys)
+
+	errBoom := errors.New("boom")
+	_, err = walk(BreadthFirst, func(key, depth int) error {
+		return errBoom
+	})
+	require.ErrorIs(t, err, errBoom)
+}
+
+func TestGraph_Path(t *testing.T) {
+	g := treeGraph()
+
+	path, err := g.Path(3, 5)
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 1, 0, 2, 5}, path)
+
+	path, err = g.Path(4, 4)
+	require.NoError(t, err)
+	require.Equal(t, []int{4}, path)
+
+	// Make 5 reachable only one way.
+	base := g.layers[0]
+	base.nodes[2].unlink(base.nodes[5].id)
+	ok, err := g.Reachable(5, 0)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = g.Reachable(0, 5)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, err = g.Path(0, 9)
+	require.Error(t, err)
+}
+
+func TestGraph_TraverseTombstones(t *testing.T) {
+	g := treeGraph()
+	g.MarkDeleted(1)
+
+	keys, err := g.Neighbors(0, 0)
+	require.NoError(t, err)
+	require.Equal(t, []int{2}, keys)
+	_, err = g.Neighbors(1, 0)
+	require.Error(t, err)
+
+	var walked []int
+	require.NoError(t, g.Walk(0, 0, BreadthFirst, func(key, depth int) error {
+		walked = append(walked, key)
+		return nil
+	}))
+	require.Equal(t, []int{0, 2, 5}, walked)
+	require.Error(t, g.Walk(1, 0, BreadthFirst, func(int, int) error { return nil }))
+
+	// 3 was only reachable through 1.
+	ok, err := g.Reachable(0, 3)
+	require.NoError(t, err)
+	require.False(t, ok)
+	_, err = g.Path(0, 1)
+	require.Error(t, err)
+}
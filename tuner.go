@@ -0,0 +1,276 @@
+package hnsw
+
+import (
+	"math"
+	"math/bits"
+	"math/rand"
+	"slices"
+	"sync"
+)
+
+// QueryClass groups searches that need a similar EfSearch to reach the
+// same recall: searches with a similar k and a similarly selective
+// filter.
+type QueryClass struct {
+	// K is k rounded up to a power of two.
+	K int
+
+	// Selectivity is the estimated fraction of nodes passing the filters
+	// of the search, rounded down to a power of two: 1 for unfiltered
+	// searches, 1/2, 1/4, and so on down to 1/1024.
+	Selectivity float64
+}
+
+// EfTuner learns the EfSearch each class of query needs to reach a
+// target recall, replacing the graph's single EfSearch with per-class
+// settings.
+//
+// A sample of the searches is calibrated: the tuner ranks the query's
+// candidates exhaustively and finds the smallest EfSearch, doubling from
+// k, whose results reach the target recall. Searches of a class then use
+// a high percentile of the EfSearch its calibrated queries needed, once
+// there are enough of them, and the graph's EfSearch until then.
+//
+// The zero value with a Graph is ready to use.
+type EfTuner[K comparable] struct {
+	Graph *Graph[K]
+
+	// TargetRecall is the recall@k to reach. Zero means 0.95.
+	TargetRecall float64
+
+	// SampleRate is the fraction of searches calibrated. Calibration
+	// costs an exhaustive search and several graph searches. Zero means
+	// 0.01.
+	SampleRate float64
+
+	// MaxEfSearch bounds the EfSearch the tuner chooses. Zero means 1024.
+	MaxEfSearch int
+
+	mu      sync.Mutex
+	rng     *rand.Rand
+	classes map[QueryClass]*efClass
+}
+
+// efHistory is the number of calibrated searches remembered per class.
+const efHistory = 128
+
+// minEfSamples is the number of calibrated searches of a class needed
+// before the tuner uses them.
+const minEfSamples = 8
+
+// efPercentile is the percentile of the EfSearch needed by the
+// calibrated searches of a class that the class uses.
+const efPercentile = 0.9
+
+type efClass struct {
+	needed   []int // Ring buffer of the EfSearch needed.
+	next     int
+	searches int
+	visited  int
+	sampled  int
+}
+
+// EfClassReport describes what the tuner learned about a query class.
+type EfClassReport struct {
+	Class QueryClass
+
+	// EfSearch is the EfSearch the class uses.
+	EfSearch int
+
+	// Searches is the number of searches of the class, and Calibrated the
+	// number of them that were calibrated.
+	Searches, Calibrated int
+
+	// MeanVisited is the mean number of nodes the calibrated searches
+	// visited at the chosen EfSearch.
+	MeanVisited float64
+}
+
+// Search finds the k nearest neighbors of near with the EfSearch
+// learned for the query's class.
+func (t *EfTuner[K]) Search(near Vector, k int) ([]SearchResultNode[K], error) {
+	return t.SearchWithOptions(near, k, SearchOptions[K]{})
+}
+
+// SearchWithOptions is like Search but accepts per-query options. A
+// positive opts.EfSearch overrides the tuner.
+func (t *EfTuner[K]) SearchWithOptions(near Vector, k int, opts SearchOptions[K]) ([]SearchResultNode[K], error) {
+	if opts.EfSearch > 0 {
+		return t.Graph.SearchWithOptions(near, k, opts)
+	}
+	class := t.classify(k, opts)
+
+	t.mu.Lock()
+	c := t.class(class)
+	c.searches++
+	calibrate := t.rand().Float64() < t.sampleRate()
+	t.mu.Unlock()
+
+	if calibrate {
+		needed, visited, err := t.calibrate(near, k, opts)
+		if err != nil {
+			return nil, err
+		}
+		t.mu.Lock()
+		if len(c.needed) < efHistory {
+			c.needed = append(c.needed, needed)
+		} else {
+			c.needed[c.next] = needed
+			c.next = (c.next + 1) % efHistory
+		}
+		c.sampled++
+		c.visited += visited
+		t.mu.Unlock()
+	}
+
+	opts.EfSearch = t.EfSearch(class)
+	return t.Graph.SearchWithOptions(near, k, opts)
+}
+
+// EfSearch returns the EfSearch searches of class use.
+func (t *EfTuner[K]) EfSearch(class QueryClass) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.classes[class]
+	if !ok {
+		return t.Graph.EfSearch
+	}
+	return t.efSearch(c)
+}
+
+func (t *EfTuner[K]) efSearch(c *efClass) int {
+	if len(c.needed) < minEfSamples {
+		return t.Graph.EfSearch
+	}
+	needed := slices.Clone(c.needed)
+	slices.Sort(needed)
+	return needed[int(efPercentile*float64(len(needed)-1))]
+}
+
+// Report returns what the tuner learned about each class of query seen
+// so far, ordered by k and then by decreasing selectivity.
+func (t *EfTuner[K]) Report() []EfClassReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out []EfClassReport
+	for class, c := range t.classes {
+		r := EfClassReport{
+			Class:      class,
+			EfSearch:   t.efSearch(c),
+			Searches:   c.searches,
+			Calibrated: c.sampled,
+		}
+		if c.sampled > 0 {
+			r.MeanVisited = float64(c.visited) / float64(c.sampled)
+		}
+		out = append(out, r)
+	}
+	slices.SortFunc(out, func(a, b EfClassReport) int {
+		if a.Class.K != b.Class.K {
+			return a.Class.K - b.Class.K
+		}
+		switch {
+		case a.Class.Selectivity > b.Class.Selectivity:
+			return -1
+		case a.Class.Selectivity < b.Class.Selectivity:
+			return 1
+		}
+		return 0
+	})
+	return out
+}
+
+// classify returns the class of a search.
+func (t *EfTuner[K]) classify(k int, opts SearchOptions[K]) QueryClass {
+	class := QueryClass{K: 1 << bits.Len(uint(max(k, 1)-1)), Selectivity: 1}
+	filter := opts.predicate()
+	if filter == nil {
+		return class
+	}
+	t.Graph.mu.RLock()
+	selectivity := 1.0
+	if len(t.Graph.layers) > 0 {
+		selectivity = t.Graph.selectivity(filter)
+	}
+	t.Graph.mu.RUnlock()
+	if selectivity > 0 {
+		exp := max(-10, math.Floor(math.Log2(selectivity)))
+		class.Selectivity = math.Exp2(exp)
+	} else {
+		class.Selectivity = math.Exp2(-10)
+	}
+	return class
+}
+
+// calibrate returns the smallest EfSearch, doubling from k, whose
+// results reach the target recall, and the number of nodes the search
+// visited with it.
+func (t *EfTuner[K]) calibrate(near Vector, k int, opts SearchOptions[K]) (needed, visited int, err error) {
+	exact := opts
+	exact.Strategy = StrategyBruteForce
+	exact.Stats = nil
+	truth, err := t.Graph.SearchWithOptions(near, k, exact)
+	if err != nil || len(truth) == 0 {
+		return t.Graph.EfSearch, 0, err
+	}
+	// A result counts as correct if it is no farther than the k-th exact
+	// neighbor, so that ties don't lower recall.
+	bound := truth[len(truth)-1].Distance
+
+	maxEf := t.MaxEfSearch
+	if maxEf <= 0 {
+		maxEf = 1024
+	}
+	target := t.TargetRecall
+	if target <= 0 {
+		target = 0.95
+	}
+	for ef := max(k, 1); ; ef *= 2 {
+		ef = min(ef, maxEf)
+		var stats SearchStats
+		probe := opts
+		probe.EfSearch = ef
+		probe.Stats = &stats
+		results, err := t.Graph.SearchWithOptions(near, k, probe)
+		if err != nil {
+			return 0, 0, err
+		}
+		var correct int
+		for _, r := range results {
+			if r.Distance <= bound {
+				correct++
+			}
+		}
+		if float64(correct)/float64(len(truth)) >= target || ef == maxEf {
+			return ef, stats.Visited, nil
+		}
+	}
+}
+
+// class returns the telemetry of class. The caller must hold t.mu.
+func (t *EfTuner[K]) class(class QueryClass) *efClass {
+	c, ok := t.classes[class]
+	if !ok {
+		if t.classes == nil {
+			t.classes = make(map[QueryClass]*efClass)
+		}
+		c = &efClass{}
+		t.classes[class] = c
+	}
+	return c
+}
+
+// rand returns the tuner's random source. The caller must hold t.mu.
+func (t *EfTuner[K]) rand() *rand.Rand {
+	if t.rng == nil {
+		t.rng = defaultRand()
+	}
+	return t.rng
+}
+
+func (t *EfTuner[K]) sampleRate() float64 {
+	if t.SampleRate <= 0 {
+		return 0.01
+	}
+	return t.SampleRate
+}
@@ -0,0 +1,60 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEfTuner(t *testing.T) {
+	g := newTestGraph[int]()
+	g.EfSearch = 4
+	for i := 0; i < 2000; i++ {
+		require.NoError(t, g.Add(MakeNode(i, randFloats(16))))
+	}
+	tuner := &EfTuner[int]{Graph: g, SampleRate: 1, TargetRecall: 0.9}
+
+	for range 20 {
+		_, err := tuner.Search(randFloats(16), 10)
+		require.NoError(t, err)
+	}
+	var recall float64
+	for range 40 {
+		q := randFloats(16)
+		results, err := tuner.Search(q, 10)
+		require.NoError(t, err)
+		truth, err := g.SearchWithOptions(q, 10, SearchOptions[int]{Strategy: StrategyBruteForce})
+		require.NoError(t, err)
+		for _, r := range results {
+			if r.Distance <= truth[len(truth)-1].Distance {
+				recall += 0.1
+			}
+		}
+	}
+	require.Greater(t, recall/40, 0.85)
+
+	unfiltered := QueryClass{K: 16, Selectivity: 1}
+	require.Greater(t, tuner.EfSearch(unfiltered), g.EfSearch)
+	require.Equal(t, g.EfSearch, tuner.EfSearch(QueryClass{K: 1, Selectivity: 1}))
+
+	// Filtered searches form their own class.
+	for range 10 {
+		_, err := tuner.SearchWithOptions(randFloats(16), 3, SearchOptions[int]{
+			Filter: func(key int) bool { return key%4 == 0 },
+		})
+		require.NoError(t, err)
+	}
+	// An explicit EfSearch bypasses the tuner.
+	_, err := tuner.SearchWithOptions(randFloats(16), 3, SearchOptions[int]{EfSearch: 50})
+	require.NoError(t, err)
+
+	report := tuner.Report()
+	require.Len(t, report, 2)
+	require.Equal(t, 4, report[0].Class.K)
+	require.LessOrEqual(t, report[0].Class.Selectivity, 0.25)
+	require.GreaterOrEqual(t, report[0].Class.Selectivity, 0.125)
+	require.Equal(t, 10, report[0].Searches)
+	require.Equal(t, unfiltered, report[1].Class)
+	require.Equal(t, 60, report[1].Calibrated)
+	require.Positive(t, report[1].MeanVisited)
+}
@@ -0,0 +1,158 @@
+package hnsw
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"golang.org/x/exp/maps"
+)
+
+// UpdateVectors replaces the vectors of many nodes at once, e.g. when a
+// nightly job refreshes embeddings. Keys not in the graph are added.
+//
+// All vectors are replaced first and the neighborhoods of the updated
+// nodes are repaired afterwards, so each repair already sees the final
+// vectors. This is much cheaper than deleting and re-adding every node,
+// which repairs the graph around every delete.
+func (g *Graph[K]) UpdateVectors(vecs map[K]Vector) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.Distance == nil {
+		return fmt.Errorf("(*Graph).Distance must be set")
+	}
+
+	// Sort the keys so that the repair order, and with it the graph,
+	// is reproducible.
+	keys := maps.Keys(vecs)
+	slices.SortFunc(keys, keyOrder[K]())
+
+	var updated, added []K
+	for _, key := range keys {
+		vec := vecs[key]
+		if dims := g.Dims(); dims != 0 && len(vec) != dims {
+			return fmt.Errorf("embedding dimension mismatch for %v: %d != %d", key, len(vec), dims)
+		}
+		if g.level(key) < 0 {
+			added = append(added, key)
+			continue
+		}
+		g.setVector(key, vec)
+		updated = append(updated, key)
+	}
+
+	for _, key := range updated {
+		if err := g.relink(key); err != nil {
+			return fmt.Errorf("relink %v: %w", key, err)
+		}
+	}
+	for _, key := range added {
+		level, err := g.randomLevel(key)
+		if err != nil {
+			return err
+		}
+		if err := g.insert(MakeNode(key, vecs[key]), level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update replaces the vector of the node with the given key, e.g. when
+// its embedding is refreshed. The node keeps its level and only its own
+// neighbors are searched for again, whereas re-adding it with Add
+// detaches it from every layer, repairs the neighborhoods it leaves and
+// inserts it anew. Use UpdateVectors to update many nodes at once.
+//
+// It fails if the key is not in the graph.
+func (g *Graph[K]) Update(key K, vec Vector) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.Distance == nil {
+		return fmt.Errorf("(*Graph).Distance must be set")
+	}
+	if g.level(key) < 0 || g.isTombstone(key) {
+		return fmt.Errorf("key %v not found", key)
+	}
+	if dims := g.Dims(); len(vec) != dims {
+		return fmt.Errorf("embedding dimension mismatch: %d != %d", dims, len(vec))
+	}
+
+	g.setVector(key, vec)
+	if err := g.relink(key); err != nil {
+		return fmt.Errorf("relink %v: %w", key, err)
+	}
+	g.notifyAdd(MakeNode(key, vec))
+	return nil
+}
+
+// setVector replaces the vector of the node with the given key in every
+// layer and clears its marks. The caller must hold the write lock.
+func (g *Graph[K]) setVector(key K, vec Vector) {
+	g.sketch.remove(g.layers[0].nodes[key].Value)
+	g.sketch.insert(vec)
+	for _, layer := range g.layers {
+		if node, ok := layer.nodes[key]; ok {
+			node.Value = vec
+		}
+	}
+	delete(g.stale, key)
+	delete(g.tombstones, key)
+}
+
+// relink replaces the neighbors of the node with the given key in every
+// layer with the nodes closest to its current vector. Edges pointing at
+// the node are kept; they are pruned as usual when their owners'
+// neighbor sets overflow. The caller must hold the write lock.
+func (g *Graph[K]) relink(key K) error {
+	vec := g.layers[0].nodes[key].Value
+	score := distanceTo[K](vec, g.Distance)
+
+	var elevator *K
+	for i := len(g.layers) - 1; i >= 0; i-- {
+		layer := g.layers[i]
+		searchPoint := layer.entry()
+		if elevator != nil {
+			searchPoint = layer.nodes[*elevator]
+		}
+		if searchPoint == nil {
+			continue
+		}
+
+		neighborhood, err := searchPoint.search(layerSearch[K]{
+			// One extra to make up for the node itself.
+			k:        g.candidates(i) + 1,
+			efSearch: g.EfConstruction,
+			score:    score,
+		})
+		if err != nil {
+			return err
+		}
+		best := slices.MinFunc(neighborhood, func(a, b searchCandidate[K]) int {
+			return cmp.Compare(a.dist, b.dist)
+		})
+		elevator = ptr(best.node.Key)
+
+		node, ok := layer.nodes[key]
+		if !ok {
+			continue
+		}
+		node.neighbors = node.neighbors[:0]
+		m := g.maxNeighbors(i)
+		selected, err := g.selectNeighbors(key, vec, neighborhood, m)
+		if err != nil {
+			return err
+		}
+		for _, c := range selected {
+			if err := g.link(node, c.node, m); err != nil {
+				return err
+			}
+			if err := g.link(c.node, node, m); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
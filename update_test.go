@@ -0,0 +1,68 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_UpdateVectors(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 128; i++ {
+		g.Add(MakeNode(i, randFloats(4)))
+	}
+
+	vecs := map[int]Vector{}
+	for i := 0; i < 64; i++ {
+		vecs[i] = randFloats(4)
+	}
+	vecs[1000] = randFloats(4)
+	require.NoError(t, g.UpdateVectors(vecs))
+	require.NoError(t, g.Verify())
+	require.Equal(t, 129, g.Len())
+
+	var found int
+	for key, vec := range vecs {
+		got, ok := g.Lookup(key)
+		require.True(t, ok)
+		require.Equal(t, vec, got)
+
+		results, err := g.Search(vec, 1)
+		require.NoError(t, err)
+		if len(results) == 1 && results[0].Key == key {
+			found++
+		}
+	}
+	require.Greater(t, found, len(vecs)*7/10)
+
+	require.Error(t, g.UpdateVectors(map[int]Vector{0: {1, 2}}))
+}
+
+func TestGraph_Update(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 128; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+	levels := make(map[int]int)
+	for i := 0; i < 128; i++ {
+		levels[i] = g.level(i)
+	}
+
+	// Move node 0 next to 100.
+	require.NoError(t, g.Update(0, Vector{100.2}))
+	require.NoError(t, g.Verify())
+	require.Equal(t, 128, g.Len())
+	for i := 0; i < 128; i++ {
+		require.Equal(t, levels[i], g.level(i))
+	}
+
+	results, err := g.Search(Vector{100.2}, 2)
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 100}, keysOf(results))
+	results, err = g.Search(Vector{0}, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, results[0].Key)
+
+	require.Error(t, g.Update(1000, Vector{1}))
+	require.Error(t, g.Update(1, Vector{1, 2}))
+}
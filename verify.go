@@ -0,0 +1,120 @@
+package hnsw
+
+import (
+	"fmt"
+	"math"
+)
+
+// Verify checks the structural invariants of the graph:
+//
+//   - every node is registered under its ID in its layer,
+//   - every neighbor of a node exists in the same layer (edges to
+//     removed nodes are tolerated, they are ignored and pruned lazily),
+//   - no node is its own neighbor,
+//   - every node in a layer also exists in the layer below it,
+//   - all vectors have the same dimensionality.
+//
+// It returns the first violation found.
+func (g *Graph[K]) Verify() error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.verify()
+}
+
+func (g *Graph[K]) verify() error {
+	dims := -1
+	for i, layer := range g.layers {
+		for key, node := range layer.nodes {
+			if node.Key != key {
+				return fmt.Errorf("layer %d: node %v stored under key %v", i, node.Key, key)
+			}
+			if dims == -1 {
+				dims = len(node.Value)
+			} else if len(node.Value) != dims {
+				return fmt.Errorf("layer %d: node %v has %d dimensions, want %d", i, key, len(node.Value), dims)
+			}
+			if node.layer != layer || int(node.id) >= len(layer.byID) || layer.byID[node.id] != node {
+				return fmt.Errorf("layer %d: node %v is not registered under its ID %d", i, key, node.id)
+			}
+			for _, id := range node.neighbors {
+				if int(id) >= len(layer.byID) {
+					return fmt.Errorf("layer %d: node %v has dangling neighbor ID %d", i, key, id)
+				}
+				neighbor := layer.byID[id]
+				if neighbor == nil {
+					// Removed.
+					continue
+				}
+				if neighbor == node {
+					return fmt.Errorf("layer %d: node %v is its own neighbor", i, key)
+				}
+				if layer.nodes[neighbor.Key] != neighbor {
+					return fmt.Errorf("layer %d: node %v has dangling neighbor %v", i, key, neighbor.Key)
+				}
+			}
+			if i > 0 {
+				if _, ok := g.layers[i-1].nodes[key]; !ok {
+					return fmt.Errorf("layer %d: node %v is missing from layer %d", i, key, i-1)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// selfTestSamples is the number of stored vectors searched for during a
+// self-test.
+const selfTestSamples = 16
+
+// selfTest verifies the graph and then searches for a sample of stored
+// vectors. A healthy graph finds (a node at least as close as) the stored
+// vector for most of them.
+func (g *Graph[K]) selfTest() error {
+	err := g.Verify()
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	if g.Len() == 0 {
+		return nil
+	}
+
+	g.mu.RLock()
+	var samples []Node[K]
+	for _, node := range g.layers[0].nodes {
+		if len(samples) == selfTestSamples {
+			break
+		}
+		samples = append(samples, node.Node)
+	}
+	g.mu.RUnlock()
+
+	var tested, found int
+	for _, sample := range samples {
+		self, err := g.Distance(sample.Value, sample.Value)
+		if err != nil {
+			return fmt.Errorf("distance of %v to itself: %w", sample.Key, err)
+		}
+		if math.IsNaN(float64(self)) {
+			// E.g. a zero vector under cosine distance. There's no
+			// meaningful answer to compare against.
+			continue
+		}
+		tested++
+
+		results, err := g.Search(sample.Value, 1)
+		if err != nil {
+			return fmt.Errorf("search for %v: %w", sample.Key, err)
+		}
+		if len(results) == 0 {
+			return fmt.Errorf("search for %v returned no results", sample.Key)
+		}
+		if results[0].Key == sample.Key || results[0].Distance <= self+1e-6 {
+			found++
+		}
+	}
+
+	if found*2 < tested {
+		return fmt.Errorf("only %d of %d stored vectors found themselves", found, tested)
+	}
+	return nil
+}
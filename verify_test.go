@@ -0,0 +1,68 @@
+package hnsw
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Verify(t *testing.T) {
+	g := newTestGraph[int]()
+	for i := 0; i < 128; i++ {
+		g.Add(MakeNode(i, Vector{float32(i)}))
+	}
+	require.NoError(t, g.Verify())
+
+	// Point a node at a neighbor that doesn't exist.
+	base := g.layers[0]
+	node := base.nodes[0]
+	node.neighbors = append(node.neighbors, uint32(len(base.byID)))
+	require.ErrorContains(t, g.Verify(), "dangling neighbor ID 128")
+
+	// Or at one that is registered under an ID but not under its key.
+	ghost := &layerNode[int]{Node: MakeNode(-1, Vector{-1}), layer: base, id: uint32(len(base.byID))}
+	base.byID = append(base.byID, ghost)
+	require.ErrorContains(t, g.Verify(), "dangling neighbor -1")
+}
+
+func TestOpen_SelfTest(t *testing.T) {
+	path := t.TempDir() + "/graph"
+
+	g, err := Open[int](path, WithSelfTest())
+	require.NoError(t, err)
+	for i := 0; i < 128; i++ {
+		g.Add(MakeNode(i, randFloats(4)))
+	}
+	require.NoError(t, g.Save())
+
+	g, err = Open[int](path, WithSelfTest())
+	require.NoError(t, err)
+	require.Equal(t, 128, g.Len())
+
+	// Corrupt the graph and save it again.
+	node := g.layers[0].nodes[0]
+	node.neighbors = append(node.neighbors, node.id)
+	require.NoError(t, g.Save())
+
+	_, err = Open[int](path, WithSelfTest())
+	require.ErrorContains(t, err, "self-test")
+
+	// Without the self-test the graph loads anyway.
+	_, err = Open[int](path)
+	require.NoError(t, err)
+
+	// Damage on disk is caught by the checksum, with or without it.
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	vec, _ := g.Lookup(5)
+	i := bytes.Index(data, binary.LittleEndian.AppendUint32(nil, math.Float32bits(vec[0])))
+	require.Positive(t, i)
+	data[i] ^= 1
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	_, err = Open[int](path)
+	require.ErrorContains(t, err, "checksum mismatch")
+}
@@ -0,0 +1,387 @@
+package hnsw
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+)
+
+const (
+	walAdd = iota + 1
+	walDelete
+	walPayload
+)
+
+// WALGraph is a saved graph that also appends every Add, Delete and
+// SetPayload to a write-ahead log next to the snapshot, at its path plus
+// ".wal". Opening it loads the snapshot and replays the log, so
+// no change is lost between snapshots without re-serializing the whole
+// graph after every write.
+//
+// Checkpoint writes a new snapshot and empties the log; call it
+// periodically to bound the log's size and the time spent replaying it.
+//
+// The log records what each change did rather than what was asked: a
+// node dropped by MergeDuplicates isn't logged, and a change whose record
+// can't be written is undone. The parameters of the graph, such as
+// DuplicateDistance, are saved with the snapshot by Checkpoint, not
+// logged.
+//
+// The graph itself isn't exposed, so that no change escapes the log:
+// besides the logged Add, Delete and SetPayload, and Erase, WALGraph only
+// has the read methods of Graph. Use Configure to set parameters.
+type WALGraph[K comparable] struct {
+	// walMu serializes log appends with the graph updates they record.
+	walMu sync.Mutex
+	saved *SavedGraph[K]
+	log   *os.File
+	// size is the length of the log up to its last complete record.
+	size int64
+}
+
+// OpenWAL opens the graph at path and replays its write-ahead log.
+// A record torn by a crash at the end of the log is discarded.
+func OpenWAL[K comparable](path string, opts ...OpenOption) (*WALGraph[K], error) {
+	saved, err := Open[K](path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	log, err := os.OpenFile(path+".wal", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	good, err := replayWAL(saved.Graph, log)
+	if err != nil {
+		log.Close()
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	// Drop a torn record and position at the end for appending.
+	if err := log.Truncate(good); err != nil {
+		log.Close()
+		return nil, err
+	}
+	if _, err := log.Seek(good, io.SeekStart); err != nil {
+		log.Close()
+		return nil, err
+	}
+
+	return &WALGraph[K]{
+		saved: saved,
+		log:   log,
+		size:  good,
+	}, nil
+}
+
+// replayWAL applies the records of log to g and returns the offset
+// after the last complete record.
+func replayWAL[K comparable](g *Graph[K], log io.Reader) (int64, error) {
+	// The log only holds nodes that were added, so they are replayed
+	// without looking for duplicates.
+	defer func(d float32) { g.DuplicateDistance = d }(g.DuplicateDistance)
+	g.DuplicateDistance = 0
+
+	r := &countingReader{r: bufio.NewReader(log)}
+	var good int64
+	for {
+		var op int
+		if _, err := binaryRead(r, &op); err != nil {
+			if errors.Is(err, io.EOF) {
+				return good, nil
+			}
+			return 0, err
+		}
+
+		var (
+			key K
+			err error
+		)
+		switch op {
+		case walAdd:
+			var vec Vector
+			_, err = multiBinaryRead(r, &key, &vec)
+			if err == nil {
+				err = g.Add(MakeNode(key, vec))
+				if err != nil {
+					return 0, err
+				}
+			}
+		case walDelete:
+			_, err = binaryRead(r, &key)
+			if err == nil {
+				g.Delete(key)
+			}
+		case walPayload:
+			var (
+				set     int
+				payload string
+			)
+			_, err = multiBinaryRead(r, &key, &set, &payload)
+			if err == nil {
+				var p []byte
+				if set == 1 {
+					p = []byte(payload)
+				}
+				if err := g.SetPayload(key, p); err != nil {
+					return 0, err
+				}
+			}
+		default:
+			return 0, fmt.Errorf("unknown record type %d at offset %d", op, good)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// Torn write at the end of the log.
+			return good, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		good = r.n
+	}
+}
+
+// append writes records to the log. A failed write is cut off the log,
+// so that later records don't follow a torn one. The caller must hold
+// walMu.
+func (g *WALGraph[K]) append(records []byte) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if _, err := g.log.Write(records); err != nil {
+		if err := g.log.Truncate(g.size); err == nil {
+			g.log.Seek(g.size, io.SeekStart)
+		}
+		return err
+	}
+	g.size += int64(len(records))
+	return nil
+}
+
+// walUndo restores a node replaced or inserted by WALGraph.Add.
+type walUndo[K comparable] struct {
+	key     K
+	old     Vector
+	existed bool
+}
+
+// undo reverts the insertions of Add, last first.
+func (g *WALGraph[K]) undo(undos []walUndo[K]) {
+	for _, u := range slices.Backward(undos) {
+		if !u.existed {
+			g.saved.Graph.Delete(u.key)
+			continue
+		}
+		g.saved.Graph.mu.Lock()
+		if level, err := g.saved.Graph.randomLevel(u.key); err == nil {
+			node := MakeNode(u.key, u.old)
+			if g.saved.Graph.insert(node, level) == nil {
+				g.saved.Graph.notifyAdd(node)
+			}
+		}
+		g.saved.Graph.mu.Unlock()
+	}
+}
+
+// Add inserts nodes into the graph and appends them to the log. Like
+// Graph.Add, it stops at the first node that fails; the nodes before it
+// remain and are logged. If the log can't be written, none of the nodes
+// remain.
+func (g *WALGraph[K]) Add(nodes ...Node[K]) error {
+	g.walMu.Lock()
+	defer g.walMu.Unlock()
+
+	var (
+		records bytes.Buffer
+		undos   []walUndo[K]
+		addErr  error
+	)
+	for _, node := range nodes {
+		u := walUndo[K]{key: node.Key}
+		u.old, u.existed = g.saved.Graph.Lookup(node.Key)
+		key, err := g.saved.Graph.AddUnique(node)
+		if err != nil {
+			addErr = err
+			break
+		}
+		if key != node.Key {
+			// Merged into an existing node: nothing changed.
+			continue
+		}
+		undos = append(undos, u)
+		if _, err := multiBinaryWrite(&records, walAdd, node.Key, node.Value); err != nil {
+			g.undo(undos)
+			return fmt.Errorf("log add %v: %w", node.Key, err)
+		}
+	}
+	if err := g.append(records.Bytes()); err != nil {
+		g.undo(undos)
+		return fmt.Errorf("log add: %w", err)
+	}
+	return addErr
+}
+
+// Delete logs the deletion of a node and removes it from the graph.
+func (g *WALGraph[K]) Delete(key K) (bool, error) {
+	g.walMu.Lock()
+	defer g.walMu.Unlock()
+
+	if _, ok := g.saved.Graph.Lookup(key); !ok {
+		return false, nil
+	}
+	var record bytes.Buffer
+	if _, err := multiBinaryWrite(&record, walDelete, key); err != nil {
+		return false, fmt.Errorf("log delete %v: %w", key, err)
+	}
+	if err := g.append(record.Bytes()); err != nil {
+		return false, fmt.Errorf("log delete %v: %w", key, err)
+	}
+	return g.saved.Graph.Delete(key), nil
+}
+
+// SetPayload attaches a payload to a node and appends the change to the
+// log. See Graph.SetPayload. If the log can't be written, the previous
+// payload is restored.
+func (g *WALGraph[K]) SetPayload(key K, payload []byte) error {
+	g.walMu.Lock()
+	defer g.walMu.Unlock()
+
+	old, _ := g.saved.Graph.Payload(key)
+	if err := g.saved.Graph.SetPayload(key, payload); err != nil {
+		return err
+	}
+	set := 0
+	if payload != nil {
+		set = 1
+	}
+	var record bytes.Buffer
+	_, err := multiBinaryWrite(&record, walPayload, key, set, string(payload))
+	if err == nil {
+		err = g.append(record.Bytes())
+	}
+	if err != nil {
+		g.saved.Graph.SetPayload(key, old)
+		return fmt.Errorf("log payload %v: %w", key, err)
+	}
+	return nil
+}
+
+// Configure calls fn with the graph to set its parameters, such as
+// Distance or DuplicateDistance, which the next Checkpoint saves. fn must
+// not change the nodes or payloads: those changes wouldn't be logged.
+func (g *WALGraph[K]) Configure(fn func(g *Graph[K])) {
+	g.walMu.Lock()
+	defer g.walMu.Unlock()
+	g.saved.Graph.mu.Lock()
+	defer g.saved.Graph.mu.Unlock()
+	fn(g.saved.Graph)
+}
+
+// Config returns the configuration of the graph.
+func (g *WALGraph[K]) Config() GraphConfig {
+	return g.saved.Config()
+}
+
+// Search finds the k nearest neighbors of near.
+func (g *WALGraph[K]) Search(near Vector, k int) ([]SearchResultNode[K], error) {
+	return g.saved.Search(near, k)
+}
+
+// SearchWithOptions is like Search but with options; see
+// Graph.SearchWithOptions.
+func (g *WALGraph[K]) SearchWithOptions(near Vector, k int, opts SearchOptions[K]) ([]SearchResultNode[K], error) {
+	return g.saved.SearchWithOptions(near, k, opts)
+}
+
+// Lookup returns the vector stored under key.
+func (g *WALGraph[K]) Lookup(key K) (Vector, bool) {
+	return g.saved.Lookup(key)
+}
+
+// Payload returns the payload of the node with the given key.
+func (g *WALGraph[K]) Payload(key K) ([]byte, bool) {
+	return g.saved.Payload(key)
+}
+
+// Len returns the number of nodes in the graph.
+func (g *WALGraph[K]) Len() int {
+	return g.saved.Len()
+}
+
+// Export writes the graph to w, like Graph.Export.
+func (g *WALGraph[K]) Export(w io.Writer) error {
+	return g.saved.Export(w)
+}
+
+// Verify checks the invariants of the graph; see Graph.Verify.
+func (g *WALGraph[K]) Verify() error {
+	return g.saved.Verify()
+}
+
+// Sync commits the log to stable storage. Records are handed to the
+// operating system as they are written, so they survive a crash of the
+// process; Sync makes them survive a crash of the machine.
+func (g *WALGraph[K]) Sync() error {
+	g.walMu.Lock()
+	defer g.walMu.Unlock()
+	return g.log.Sync()
+}
+
+// Checkpoint atomically writes a snapshot of the graph and empties the
+// log. Writes wait for it to finish.
+func (g *WALGraph[K]) Checkpoint() error {
+	g.walMu.Lock()
+	defer g.walMu.Unlock()
+	return g.checkpoint()
+}
+
+// checkpoint implements Checkpoint. The caller must hold walMu.
+func (g *WALGraph[K]) checkpoint() error {
+	if err := g.saved.Save(); err != nil {
+		return err
+	}
+	if err := g.log.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := g.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	g.size = 0
+	return nil
+}
+
+// Close syncs and closes the log. It does not write a snapshot.
+func (g *WALGraph[K]) Close() error {
+	g.walMu.Lock()
+	defer g.walMu.Unlock()
+
+	if err := g.log.Sync(); err != nil {
+		g.log.Close()
+		return err
+	}
+	return g.log.Close()
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
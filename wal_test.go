@@ -0,0 +1,138 @@
+package hnsw
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWALGraph(t *testing.T) {
+	path := t.TempDir() + "/graph"
+
+	g, err := OpenWAL[int](path)
+	require.NoError(t, err)
+	for i := 0; i < 64; i++ {
+		require.NoError(t, g.Add(MakeNode(i, randFloats(4))))
+	}
+	require.NoError(t, g.SetPayload(5, []byte("five")))
+	deleted, err := g.Delete(3)
+	require.NoError(t, err)
+	require.True(t, deleted)
+	require.NoError(t, g.Close())
+
+	// Without a checkpoint, everything comes from the log.
+	g, err = OpenWAL[int](path)
+	require.NoError(t, err)
+	require.Equal(t, 63, g.Len())
+	_, ok := g.Lookup(3)
+	require.False(t, ok)
+	payload, ok := g.Payload(5)
+	require.True(t, ok)
+	require.Equal(t, []byte("five"), payload)
+
+	require.NoError(t, g.Checkpoint())
+	info, err := os.Stat(path + ".wal")
+	require.NoError(t, err)
+	require.Zero(t, info.Size())
+
+	require.NoError(t, g.Add(MakeNode(100, randFloats(4))))
+	require.NoError(t, g.Close())
+
+	// Simulate a crash in the middle of writing a record.
+	f, err := os.OpenFile(path+".wal", os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = multiBinaryWrite(f, walAdd, 101, 4)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	g, err = OpenWAL[int](path)
+	require.NoError(t, err)
+	require.Equal(t, 64, g.Len())
+	_, ok = g.Lookup(100)
+	require.True(t, ok)
+	_, ok = g.Lookup(101)
+	require.False(t, ok)
+
+	// The torn record is gone, so new records can be replayed.
+	require.NoError(t, g.Add(MakeNode(102, randFloats(4))))
+	require.NoError(t, g.Close())
+	g, err = OpenWAL[int](path)
+	require.NoError(t, err)
+	require.Equal(t, 65, g.Len())
+	require.NoError(t, g.Close())
+}
+
+func TestWALGraph_AddOutcomes(t *testing.T) {
+	path := t.TempDir() + "/graph"
+	g, err := OpenWAL[int](path)
+	require.NoError(t, err)
+	configure := func(g *Graph[int]) {
+		g.Distance = EuclideanDistance
+		g.DuplicateDistance = 0.5
+		g.Hardening = &Hardening{Jitter: 0.05, Seed: 7}
+	}
+	g.Configure(configure)
+	require.NoError(t, g.Add(MakeNode(1, Vector{1, 0}), MakeNode(2, Vector{5, 0})))
+
+	// A rejected duplicate stops the batch; the nodes before it stay.
+	var dup *DuplicateError[int]
+	err = g.Add(MakeNode(3, Vector{9, 0}), MakeNode(4, Vector{1.1, 0}), MakeNode(5, Vector{20, 0}))
+	require.ErrorAs(t, err, &dup)
+	// A merged duplicate isn't logged.
+	g.Configure(func(g *Graph[int]) { g.Duplicates = MergeDuplicates })
+	require.NoError(t, g.Add(MakeNode(6, Vector{5.1, 0})))
+	require.Equal(t, 3, g.Len())
+	require.NoError(t, g.Close())
+
+	g, err = OpenWAL[int](path)
+	require.NoError(t, err)
+	require.Equal(t, 3, g.Len())
+	for _, key := range []int{4, 5, 6} {
+		_, ok := g.Lookup(key)
+		require.False(t, ok, key)
+	}
+	// The parameters are saved with the snapshot, not logged.
+	require.Zero(t, g.Config().DuplicateDistance)
+	require.Nil(t, g.Config().Hardening)
+	g.Configure(configure)
+	g.Configure(func(g *Graph[int]) { g.Duplicates = MergeDuplicates })
+	require.NoError(t, g.Checkpoint())
+	require.NoError(t, g.Close())
+	g, err = OpenWAL[int](path)
+	require.NoError(t, err)
+	config := g.Config()
+	require.Equal(t, "euclidean", config.Distance)
+	require.Equal(t, float32(0.5), config.DuplicateDistance)
+	require.Equal(t, MergeDuplicates, config.Duplicates)
+	require.Equal(t, &Hardening{Jitter: 0.05, Seed: 7}, config.Hardening)
+
+	// Changes that can't be logged are undone.
+	require.NoError(t, g.log.Close())
+	require.Error(t, g.Add(MakeNode(1, Vector{-1, 0}), MakeNode(7, Vector{30, 0})))
+	vec, ok := g.Lookup(1)
+	require.True(t, ok)
+	require.Equal(t, Vector{1, 0}, vec)
+	_, ok = g.Lookup(7)
+	require.False(t, ok)
+	deleted, err := g.Delete(2)
+	require.Error(t, err)
+	require.False(t, deleted)
+	_, ok = g.Lookup(2)
+	require.True(t, ok)
+	require.Error(t, g.SetPayload(2, []byte("two")))
+	_, ok = g.Payload(2)
+	require.False(t, ok)
+	require.Equal(t, 3, g.Len())
+	require.NoError(t, g.Verify())
+}
+
+func TestWALGraph_NoUnloggedWrites(t *testing.T) {
+	// Changes that bypass the log aren't reachable through WALGraph.
+	typ := reflect.TypeFor[*WALGraph[int]]()
+	for _, name := range []string{"MarkDeleted", "DeleteByFilter", "Update", "AddBatch", "Compact", "Import", "Rebuild"} {
+		_, ok := typ.MethodByName(name)
+		require.False(t, ok, name)
+	}
+}